@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	blockClassPhysical  = `physical`
+	blockClassLogical   = `logical`
+	blockClassAllocated = `allocated`
+)
+
+var (
+	blockLabels    = []string{`pool`, `size`, `class`}
+	blocksDescName = prometheus.BuildFQName(namespace, subsystemPool, `blocks`)
+	blocksDesc     = prometheus.NewDesc(
+		blocksDescName,
+		`Number of blocks in the pool of the given size in bytes, by size class [physical: on-disk compressed size, logical: uncompressed size, allocated: size including padding and parity].`,
+		blockLabels,
+		nil,
+	)
+	blockBytesDescName = prometheus.BuildFQName(namespace, subsystemPool, `block_bytes`)
+	blockBytesDesc     = prometheus.NewDesc(
+		blockBytesDescName,
+		`Total size in bytes of blocks in the pool of the given size, by size class [physical: on-disk compressed size, logical: uncompressed size, allocated: size including padding and parity].`,
+		blockLabels,
+		nil,
+	)
+)
+
+func init() {
+	registerCollector(`pool-blocks`, defaultDisabled, ``, nil, newPoolBlocksCollector)
+}
+
+// poolBlocksCollector reports the block size histogram from zdb, which requires traversal of all pool metadata.
+type poolBlocksCollector struct {
+	log    log.Logger
+	client zfs.Client
+}
+
+func (c *poolBlocksCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- blocksDesc
+	ch <- blockBytesDesc
+}
+
+func (c *poolBlocksCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool); err != nil && !isPoolMissing(c.log, `pool-blocks`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *poolBlocksCollector) updatePoolMetrics(ch chan<- metric, pool string) error {
+	buckets, err := c.client.Pool(pool).BlockSizeHistogram()
+	if err != nil {
+		return err
+	}
+
+	for _, bucket := range buckets {
+		size := strconv.FormatUint(bucket.Size, 10)
+		for _, class := range []struct {
+			name  string
+			count uint64
+			bytes uint64
+		}{
+			{name: blockClassPhysical, count: bucket.PhysicalCount, bytes: bucket.PhysicalBytes},
+			{name: blockClassLogical, count: bucket.LogicalCount, bytes: bucket.LogicalBytes},
+			{name: blockClassAllocated, count: bucket.AllocatedCount, bytes: bucket.AllocatedBytes},
+		} {
+			labelValues := []string{pool, size, class.name}
+			ch <- metric{
+				name:       expandMetricName(blocksDescName, labelValues...),
+				prometheus: prometheus.MustNewConstMetric(blocksDesc, prometheus.GaugeValue, float64(class.count), labelValues...),
+			}
+			ch <- metric{
+				name:       expandMetricName(blockBytesDescName, labelValues...),
+				prometheus: prometheus.MustNewConstMetric(blockBytesDesc, prometheus.GaugeValue, float64(class.bytes), labelValues...),
+			}
+		}
+	}
+
+	return nil
+}
+
+func newPoolBlocksCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	return &poolBlocksCollector{log: l, client: c}, nil
+}
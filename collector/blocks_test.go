@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestPoolBlocksMetrics(t *testing.T) {
+	const result = `# HELP zfs_pool_blocks Number of blocks in the pool of the given size in bytes, by size class [physical: on-disk compressed size, logical: uncompressed size, allocated: size including padding and parity].
+# TYPE zfs_pool_blocks gauge
+zfs_pool_blocks{class="allocated",pool="testpool",size="512"} 0
+zfs_pool_blocks{class="allocated",pool="testpool",size="4096"} 4708
+zfs_pool_blocks{class="logical",pool="testpool",size="512"} 3584
+zfs_pool_blocks{class="logical",pool="testpool",size="4096"} 1000
+zfs_pool_blocks{class="physical",pool="testpool",size="512"} 3584
+zfs_pool_blocks{class="physical",pool="testpool",size="4096"} 1024
+# HELP zfs_pool_block_bytes Total size in bytes of blocks in the pool of the given size, by size class [physical: on-disk compressed size, logical: uncompressed size, allocated: size including padding and parity].
+# TYPE zfs_pool_block_bytes gauge
+zfs_pool_block_bytes{class="allocated",pool="testpool",size="512"} 0
+zfs_pool_block_bytes{class="allocated",pool="testpool",size="4096"} 1.9283968e+07
+zfs_pool_block_bytes{class="logical",pool="testpool",size="512"} 1.835008e+06
+zfs_pool_block_bytes{class="logical",pool="testpool",size="4096"} 4.096e+06
+zfs_pool_block_bytes{class="physical",pool="testpool",size="512"} 1.835008e+06
+zfs_pool_block_bytes{class="physical",pool="testpool",size="4096"} 4.194304e+06
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().BlockSizeHistogram().Return([]zfs.BlockSizeBucket{
+		{Size: 512, PhysicalCount: 3584, PhysicalBytes: 1835008, LogicalCount: 3584, LogicalBytes: 1835008},
+		{Size: 4096, PhysicalCount: 1024, PhysicalBytes: 4194304, LogicalCount: 1000, LogicalBytes: 4096000, AllocatedCount: 4708, AllocatedBytes: 19283968},
+	}, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool-blocks`: {
+			Name:    "pool-blocks",
+			Enabled: boolPointer(true),
+			factory: newPoolBlocksCollector,
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_blocks`, `zfs_pool_block_bytes`}); err != nil {
+		t.Fatal(err)
+	}
+}
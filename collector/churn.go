@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	snapshotChurnLabels      = []string{`name`, `pool`}
+	snapshotsCreatedDescName = prometheus.BuildFQName(namespace, subsystemDataset, `snapshots_created_total`)
+	snapshotsCreatedDesc     = prometheus.NewDesc(
+		snapshotsCreatedDescName,
+		`Number of snapshots of the dataset created since the exporter started.`,
+		snapshotChurnLabels,
+		nil,
+	)
+	snapshotsDestroyedDescName = prometheus.BuildFQName(namespace, subsystemDataset, `snapshots_destroyed_total`)
+	snapshotsDestroyedDesc     = prometheus.NewDesc(
+		snapshotsDestroyedDescName,
+		`Number of snapshots of the dataset destroyed since the exporter started.`,
+		snapshotChurnLabels,
+		nil,
+	)
+)
+
+func init() {
+	registerCollector(`dataset-snapshot-churn`, defaultDisabled, ``, nil, newSnapshotChurnFactory())
+}
+
+// snapshotChurn holds the snapshots seen by previous scrapes, and the resulting counts of created and destroyed
+// snapshots. It outlives the collectors created for each scrape.
+type snapshotChurn struct {
+	sync.Mutex
+	// snapshots maps pool names to the GUIDs of the snapshots in the pool, by snapshot name.
+	snapshots map[string]map[string]string
+	// counts maps pool names to the created/destroyed counts of the datasets in the pool, by dataset name.
+	counts map[string]map[string]*snapshotChurnCount
+}
+
+type snapshotChurnCount struct {
+	created   uint64
+	destroyed uint64
+}
+
+// update compares the current snapshots of a pool with those from the previous scrape, and returns the resulting
+// counts. The first scrape of a pool establishes the baseline.
+func (s *snapshotChurn) update(pool string, current map[string]string) map[string]snapshotChurnCount {
+	s.Lock()
+	defer s.Unlock()
+
+	counts, ok := s.counts[pool]
+	if !ok {
+		counts = make(map[string]*snapshotChurnCount)
+		s.counts[pool] = counts
+	}
+	count := func(snapshot string) *snapshotChurnCount {
+		dataset := strings.SplitN(snapshot, `@`, 2)[0]
+		c, ok := counts[dataset]
+		if !ok {
+			c = &snapshotChurnCount{}
+			counts[dataset] = c
+		}
+		return c
+	}
+
+	previous, seen := s.snapshots[pool]
+	for snapshot, guid := range current {
+		c := count(snapshot)
+		// Snapshots destroyed and re-created with the same name between scrapes are identified by their GUID.
+		if prevGUID, ok := previous[snapshot]; seen && (!ok || prevGUID != guid) {
+			c.created++
+			if ok {
+				c.destroyed++
+			}
+		}
+	}
+	for snapshot := range previous {
+		if _, ok := current[snapshot]; !ok {
+			count(snapshot).destroyed++
+		}
+	}
+	s.snapshots[pool] = current
+
+	result := make(map[string]snapshotChurnCount, len(counts))
+	for dataset, c := range counts {
+		result[dataset] = *c
+	}
+	return result
+}
+
+// snapshotChurnCollector reports the number of snapshots created and destroyed per dataset, by comparing the snapshots
+// present between scrapes.
+type snapshotChurnCollector struct {
+	log    log.Logger
+	client zfs.Client
+	churn  *snapshotChurn
+}
+
+func (c *snapshotChurnCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- snapshotsCreatedDesc
+	ch <- snapshotsDestroyedDesc
+}
+
+func (c *snapshotChurnCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool, excludes); err != nil && !isPoolMissing(c.log, `dataset-snapshot-churn`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *snapshotChurnCollector) updatePoolMetrics(ch chan<- metric, pool string, excludes regexpCollection) error {
+	results, err := c.client.Datasets(pool, zfs.DatasetSnapshot).Properties(`guid`)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]string, len(results))
+	for _, snapshot := range results {
+		if excludes.MatchString(snapshot.DatasetName()) {
+			continue
+		}
+		current[snapshot.DatasetName()] = snapshot.Properties()[`guid`]
+	}
+
+	for dataset, count := range c.churn.update(pool, current) {
+		labelValues := []string{dataset, pool}
+		ch <- metric{
+			name:       expandMetricName(snapshotsCreatedDescName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(snapshotsCreatedDesc, prometheus.CounterValue, float64(count.created), labelValues...),
+		}
+		ch <- metric{
+			name:       expandMetricName(snapshotsDestroyedDescName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(snapshotsDestroyedDesc, prometheus.CounterValue, float64(count.destroyed), labelValues...),
+		}
+	}
+
+	return nil
+}
+
+// newSnapshotChurnFactory returns a factory for collectors sharing snapshot state across scrapes.
+func newSnapshotChurnFactory() factoryFunc {
+	churn := &snapshotChurn{
+		snapshots: make(map[string]map[string]string),
+		counts:    make(map[string]map[string]*snapshotChurnCount),
+	}
+	return func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+		return &snapshotChurnCollector{log: l, client: c, churn: churn}, nil
+	}
+}
@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestSnapshotChurnMetrics(t *testing.T) {
+	scrapes := []struct {
+		snapshots     map[string]string
+		metricResults string
+	}{
+		{
+			snapshots: map[string]string{
+				`testpool/data@daily-1`: `1001`,
+				`testpool/data@daily-2`: `1002`,
+			},
+			metricResults: `# HELP zfs_dataset_snapshots_created_total Number of snapshots of the dataset created since the exporter started.
+# TYPE zfs_dataset_snapshots_created_total counter
+zfs_dataset_snapshots_created_total{name="testpool/data",pool="testpool"} 0
+# HELP zfs_dataset_snapshots_destroyed_total Number of snapshots of the dataset destroyed since the exporter started.
+# TYPE zfs_dataset_snapshots_destroyed_total counter
+zfs_dataset_snapshots_destroyed_total{name="testpool/data",pool="testpool"} 0
+`,
+		},
+		{
+			snapshots: map[string]string{
+				`testpool/data@daily-2`: `1002`,
+				`testpool/data@daily-3`: `1003`,
+			},
+			metricResults: `# HELP zfs_dataset_snapshots_created_total Number of snapshots of the dataset created since the exporter started.
+# TYPE zfs_dataset_snapshots_created_total counter
+zfs_dataset_snapshots_created_total{name="testpool/data",pool="testpool"} 1
+# HELP zfs_dataset_snapshots_destroyed_total Number of snapshots of the dataset destroyed since the exporter started.
+# TYPE zfs_dataset_snapshots_destroyed_total counter
+zfs_dataset_snapshots_destroyed_total{name="testpool/data",pool="testpool"} 1
+`,
+		},
+	}
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`dataset-snapshot-churn`: {
+			Name:    "dataset-snapshot-churn",
+			Enabled: boolPointer(true),
+			factory: newSnapshotChurnFactory(),
+		},
+	}
+
+	for _, scrape := range scrapes {
+		zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+		results := make([]zfs.DatasetProperties, 0, len(scrape.snapshots))
+		for name, guid := range scrape.snapshots {
+			zfsDatasetProperties := mock_zfs.NewMockDatasetProperties(ctrl)
+			zfsDatasetProperties.EXPECT().DatasetName().Return(name).Times(2)
+			zfsDatasetProperties.EXPECT().Properties().Return(map[string]string{`guid`: guid}).Times(1)
+			results = append(results, zfsDatasetProperties)
+		}
+		zfsDatasets := mock_zfs.NewMockDatasets(ctrl)
+		zfsDatasets.EXPECT().Properties(`guid`).Return(results, nil).Times(1)
+		zfsClient.EXPECT().Datasets(`testpool`, zfs.DatasetSnapshot).Return(zfsDatasets).Times(1)
+
+		if err = callCollector(ctx, collector, []byte(scrape.metricResults), []string{`zfs_dataset_snapshots_created_total`, `zfs_dataset_snapshots_destroyed_total`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -3,10 +3,14 @@ package collector
 import (
 	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/pdf/zfs_exporter/v2/zfs"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -42,20 +46,168 @@ var (
 		[]string{`collector`},
 		nil,
 	)
+	upDescName = prometheus.BuildFQName(namespace, ``, `up`)
+	upDesc     = prometheus.NewDesc(
+		upDescName,
+		`Whether ZFS could be queried, ie - the pools could be listed [0: query failed, 1: query succeeded].`,
+		nil,
+		nil,
+	)
+	moduleLoadedDescName = prometheus.BuildFQName(namespace, `module`, `loaded`)
+	moduleLoadedDesc     = prometheus.NewDesc(
+		moduleLoadedDescName,
+		`Whether the ZFS kernel module is loaded, only reported when a module path is configured [0: not loaded, 1: loaded].`,
+		nil,
+		nil,
+	)
+	scrapeErrorDescName = prometheus.BuildFQName(namespace, `scrape`, `error`)
+	scrapeErrorDesc     = prometheus.NewDesc(
+		scrapeErrorDescName,
+		`Whether any collector failed in the last scrape [0: no errors, 1: errors].`,
+		nil,
+		nil,
+	)
+	scrapeDurationHistogramName = prometheus.BuildFQName(namespace, `scrape`, `collector_duration_histogram_seconds`)
+	collectedMetricsDescName    = prometheus.BuildFQName(namespace, `collected`, `metrics_total`)
+	collectedMetricsDesc        = prometheus.NewDesc(
+		collectedMetricsDescName,
+		`zfs_exporter: Number of metric samples produced by a collector in the last scrape.`,
+		[]string{`collector`},
+		nil,
+	)
+
+	// DefaultDurationBuckets are the buckets of the collector duration histogram, spanning the sub-millisecond latency
+	// of cached or skipped collectors to the tens of seconds that zfs/zpool commands may take on large pools
+	DefaultDurationBuckets = []float64{.0005, .001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
 
 	errUnsupportedProperty = errors.New(`unsupported property`)
+
+	// ErrValueUnavailable is returned by a transform when the property has no value (ie - `-`), in which case no sample
+	// is reported
+	ErrValueUnavailable = errors.New(`value unavailable`)
+
+	// ErrValueNotFinite is returned when a property value transforms to NaN or Inf, which would fail the scrape, in
+	// which case no sample is reported
+	ErrValueNotFinite = errors.New(`value not finite`)
 )
 
+// warnedProperties is a set of collector and property pairs.
+type warnedProperties struct {
+	sync.Mutex
+	warned map[[2]string]struct{}
+}
+
+// warnedLogger is the logger passed to collectors by a ZFS instance, which records the unsupported property warnings
+// that have been logged, as collectors are created for each scrape.
+type warnedLogger struct {
+	log.Logger
+	unsupported *warnedProperties
+}
+
+func newWarnedLogger(l log.Logger) *warnedLogger {
+	return &warnedLogger{
+		Logger:      l,
+		unsupported: &warnedProperties{warned: make(map[[2]string]struct{})},
+	}
+}
+
+// add records the pair, reporting whether it was newly added.
+func (w *warnedProperties) add(collector, property string) bool {
+	w.Lock()
+	defer w.Unlock()
+
+	key := [2]string{collector, property}
+	if _, ok := w.warned[key]; ok {
+		return false
+	}
+	w.warned[key] = struct{}{}
+	return true
+}
+
+// warnUnsupported logs that the property is unsupported by the collector, at most once per collector and property for
+// the loggers of a ZFS instance, so that logs are not flooded by a warning on every scrape.
+func warnUnsupported(l log.Logger, collector, property string, err error) {
+	if w, ok := l.(*warnedLogger); ok && !w.unsupported.add(collector, property) {
+		return
+	}
+	_ = level.Warn(l).Log(`msg`, propertyUnsupportedMsg, `help`, helpIssue, `collector`, collector, `property`, property, `err`, err)
+}
+
+// warnNotFinite logs that the value of a property was skipped, as it is not finite.
+func warnNotFinite(l log.Logger, collector, property string, labelValues []string, err error) {
+	_ = level.Warn(l).Log(`msg`, `Skipping property with non-finite value`, `collector`, collector, `property`, property, `labels`, strings.Join(labelValues, `,`), `err`, err)
+}
+
+// checkFinite returns ErrValueNotFinite if v is NaN or Inf, which Prometheus would reject, describing the value it was
+// transformed from.
+func checkFinite(v float64, value string) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("%w: %q", ErrValueNotFinite, value)
+	}
+	return nil
+}
+
 type factoryFunc func(l log.Logger, c zfs.Client, properties []string) (Collector, error)
 
 type transformFunc func(string) (float64, error)
 
+// deriveFunc computes a value from the fetched values of a derived property's inputs, returning false if no value
+// should be emitted.
+type deriveFunc func(values map[string]string) (float64, bool, error)
+
 // State holds metadata for managing collector status
 type State struct {
-	Name       string
-	Enabled    *bool
-	Properties *string
-	factory    factoryFunc
+	Name                string
+	Enabled             *bool
+	Properties          *string
+	ExcludeProperties   *string
+	defaultEnabled      bool
+	defaultProperties   string
+	factory             factoryFunc
+	store               *propertyStore
+	enabledSetByUser    *bool
+	propertiesSetByUser *bool
+}
+
+func (s State) isEnabledSetByUser() bool {
+	return s.enabledSetByUser != nil && *s.enabledSetByUser
+}
+
+func (s State) isPropertiesSetByUser() bool {
+	return s.propertiesSetByUser != nil && *s.propertiesSetByUser
+}
+
+// properties returns the configured properties less any excluded properties, or nil for collectors that do not
+// support property selection.
+func (s State) properties() []string {
+	if s.Properties == nil || *s.Properties == `` {
+		return nil
+	}
+	props := strings.Split(*s.Properties, `,`)
+	excluded := s.excludedProperties()
+	if len(excluded) == 0 {
+		return props
+	}
+
+	skip := make(map[string]struct{}, len(excluded))
+	for _, prop := range excluded {
+		skip[prop] = struct{}{}
+	}
+	result := make([]string, 0, len(props))
+	for _, prop := range props {
+		if _, ok := skip[prop]; !ok {
+			result = append(result, prop)
+		}
+	}
+	return result
+}
+
+// excludedProperties returns the properties excluded from the output of the collector.
+func (s State) excludedProperties() []string {
+	if s.ExcludeProperties == nil || *s.ExcludeProperties == `` {
+		return nil
+	}
+	return strings.Split(*s.ExcludeProperties, `,`)
 }
 
 // Collector defines the minimum functionality for registering a collector
@@ -70,13 +222,102 @@ type metric struct {
 }
 
 type property struct {
-	name      string
-	desc      *prometheus.Desc
-	transform transformFunc
+	name       string
+	help       string
+	desc       *prometheus.Desc
+	transform  transformFunc
+	minVersion *zfs.Version
+	maxVersion *zfs.Version
+	inputs     []string
+	derive     deriveFunc
+	// label is the name of the label on the info metric of the store that reports the string value of the property,
+	// for properties that are not numeric.
+	label string
+	// state describes the optional info-style metric reporting the string value of an enum property as a `state` label.
+	state *prometheus.Desc
+	// suffixLabel is the name of the label reporting the suffix of properties that are parameterised by a suffix
+	// following `@` (ie - `written@<snapshot>`), which are stored by their name up to and including the `@`.
+	suffixLabel string
+	// valueLabel is the name of the label reporting the string value of properties that are reported as a dedicated
+	// info-style metric, rather than as a label of the info metric of the store.
+	valueLabel string
+	// rootDataset marks pool properties that are properties of the root dataset of the pool, fetched via `zfs get`.
+	rootDataset bool
+}
+
+// withSuffix returns a copy of the property that is parameterised by a suffix following `@` in the property name,
+// reported as the named label.
+func (p property) withSuffix(label string) property {
+	p.suffixLabel = label
+	return p
+}
+
+// ofRootDataset returns a copy of the pool property that is a property of the root dataset of the pool.
+func (p property) ofRootDataset() property {
+	p.rootDataset = true
+	return p
+}
+
+// labelValues returns the label values of the property requested as name, appending the suffix of the name for
+// parameterised properties, and the value for properties reported as a label.
+func (p property) labelValues(name, value string, labelValues []string) []string {
+	if p.suffixLabel == `` && p.valueLabel == `` {
+		return labelValues
+	}
+	result := append([]string{}, labelValues...)
+	if p.suffixLabel != `` {
+		_, suffix, _ := strings.Cut(name, `@`)
+		result = append(result, suffix)
+	}
+	if p.valueLabel != `` {
+		result = append(result, value)
+	}
+	return result
+}
+
+// withState returns a copy of the property that may additionally be reported as an info-style `<name>_state` metric,
+// labeled with the string value of the property, via pushState.
+func (p property) withState(helpText string, labels ...string) property {
+	p.state = prometheus.NewDesc(
+		p.name+`_state`,
+		helpText,
+		append(append([]string{}, labels...), `state`),
+		nil,
+	)
+	return p
+}
+
+// requires returns a copy of the property that is only supported by the provided ZFS version or newer.
+func (p property) requires(major, minor, patch int) property {
+	p.minVersion = &zfs.Version{Major: major, Minor: minor, Patch: patch}
+	return p
+}
+
+// removedIn returns a copy of the property that is only supported by ZFS versions older than the provided version.
+func (p property) removedIn(major, minor, patch int) property {
+	p.maxVersion = &zfs.Version{Major: major, Minor: minor, Patch: patch}
+	return p
+}
+
+// supportedBy determines whether the property is supported by the provided ZFS version.
+func (p property) supportedBy(v zfs.Version) bool {
+	if p.minVersion != nil && !v.AtLeast(p.minVersion.Major, p.minVersion.Minor, p.minVersion.Patch) {
+		return false
+	}
+	if p.maxVersion != nil && v.AtLeast(p.maxVersion.Major, p.maxVersion.Minor, p.maxVersion.Patch) {
+		return false
+	}
+	return true
 }
 
 func (p property) push(ch chan<- metric, value string, labelValues ...string) error {
 	v, err := p.transform(value)
+	if err == ErrValueUnavailable {
+		return nil
+	}
+	if err == nil {
+		err = checkFinite(v, value)
+	}
 	if err != nil {
 		return err
 	}
@@ -93,20 +334,272 @@ func (p property) push(ch chan<- metric, value string, labelValues ...string) er
 	return nil
 }
 
+// pushState pushes the info-style state metric of the property, labeled with its string value. The value is validated
+// by the transform of the property, so that the state label only takes known values.
+func (p property) pushState(ch chan<- metric, value string, labelValues ...string) error {
+	if p.state == nil {
+		return nil
+	}
+	_, err := p.transform(value)
+	if err == ErrValueUnavailable {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	stateValues := append(append([]string{}, labelValues...), value)
+	ch <- metric{
+		name:       expandMetricName(p.name+`_state`, stateValues...),
+		prometheus: prometheus.MustNewConstMetric(p.state, prometheus.GaugeValue, 1, stateValues...),
+	}
+
+	return nil
+}
+
+// pushDerived computes and pushes the value of a derived property from the fetched values.
+func (p property) pushDerived(ch chan<- metric, values map[string]string, labelValues ...string) error {
+	for _, input := range p.inputs {
+		if _, ok := values[input]; !ok {
+			return nil
+		}
+	}
+	// As for push, inputs that are unavailable (ie - `-` for properties the pool or dataset does not support) skip the
+	// derived property rather than failing the collector.
+	v, ok, err := p.derive(values)
+	if errors.Is(err, ErrValueUnavailable) {
+		return nil
+	}
+	if err != nil || !ok {
+		return err
+	}
+	if err = checkFinite(v, p.name); err != nil {
+		return err
+	}
+	ch <- metric{
+		name: expandMetricName(p.name, labelValues...),
+		prometheus: prometheus.MustNewConstMetric(
+			p.desc,
+			prometheus.GaugeValue,
+			v,
+			labelValues...,
+		),
+	}
+
+	return nil
+}
+
 type propertyStore struct {
 	defaultSubsystem string
 	defaultLabels    []string
 	store            map[string]property
+	// infoDescs caches the descriptors of the info metric by the names of the info properties they report, as the
+	// same properties are requested on every scrape.
+	infoDescs sync.Map
+}
+
+// names returns the sorted names of the properties in the store that may be requested as named. Properties
+// parameterised by a suffix are excluded, as they must be requested with a suffix, see parameterisedNames.
+func (p *propertyStore) names() []string {
+	result := make([]string, 0, len(p.store))
+	for name, prop := range p.store {
+		if prop.suffixLabel != `` {
+			continue
+		}
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// parameterisedNames returns the sorted names of the properties in the store that are parameterised by a suffix, in the
+// form they are requested (ie - `written@<snapshot>`).
+func (p *propertyStore) parameterisedNames() []string {
+	var result []string
+	for name, prop := range p.store {
+		if prop.suffixLabel != `` {
+			result = append(result, name+`<`+prop.suffixLabel+`>`)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// supported filters props to those supported by the installed ZFS version. The version is only queried when a requested
+// property declares a minimum or maximum version, and props are returned unfiltered if the version cannot be determined.
+// Versions prior to 0.8, which cannot report their version, are treated as predating every version requirement.
+func (p *propertyStore) supported(l log.Logger, collector string, client zfs.Client, props []string) []string {
+	var (
+		v       zfs.Version
+		err     error
+		checked bool
+	)
+	result := make([]string, 0, len(props))
+	for _, name := range props {
+		prop, ok := p.lookup(name)
+		if !ok || (prop.minVersion == nil && prop.maxVersion == nil) {
+			result = append(result, name)
+			continue
+		}
+		if !checked {
+			v, err = client.Version()
+			checked = true
+			if errors.Is(err, zfs.ErrVersionUnknown) {
+				v, err = zfs.Version{}, nil
+			}
+			if err != nil {
+				_ = level.Debug(l).Log(`msg`, `Could not determine ZFS version, not filtering properties`, `collector`, collector, `err`, err)
+			}
+		}
+		if err != nil || prop.supportedBy(v) {
+			result = append(result, name)
+			continue
+		}
+		_ = level.Debug(l).Log(`msg`, `Property unsupported by ZFS version, skipping`, `collector`, collector, `property`, name, `version`, v, `minVersion`, prop.minVersion, `maxVersion`, prop.maxVersion)
+	}
+
+	return result
+}
+
+// fetchable returns the properties that must be fetched to satisfy the requested props, replacing derived properties
+// with their inputs.
+func (p *propertyStore) fetchable(props []string) []string {
+	result := make([]string, 0, len(props))
+	seen := make(map[string]struct{}, len(props))
+	add := func(name string) {
+		if _, ok := seen[name]; ok {
+			return
+		}
+		seen[name] = struct{}{}
+		result = append(result, name)
+	}
+	for _, name := range props {
+		if prop, ok := p.lookup(name); ok && prop.derive != nil {
+			for _, input := range prop.inputs {
+				add(input)
+			}
+			continue
+		}
+		add(name)
+	}
+
+	return result
+}
+
+// push metrics for the requested props from the fetched values. Values fetched only as inputs to derived properties
+// are not reported.
+func (p *propertyStore) push(l log.Logger, collector string, ch chan<- metric, props []string, values map[string]string, labelValues ...string) error {
+	for _, k := range props {
+		prop, err := p.find(k)
+		if err != nil {
+			warnUnsupported(l, collector, k, err)
+		}
+		if prop.label != `` {
+			continue
+		}
+		if prop.derive != nil {
+			if err = prop.pushDerived(ch, values, labelValues...); errors.Is(err, ErrValueNotFinite) {
+				warnNotFinite(l, collector, k, labelValues, err)
+			} else if err != nil {
+				return err
+			}
+			continue
+		}
+		v, ok := values[k]
+		if !ok {
+			continue
+		}
+		if err = prop.push(ch, v, prop.labelValues(k, v, labelValues)...); errors.Is(err, ErrValueNotFinite) {
+			warnNotFinite(l, collector, k, labelValues, err)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	p.pushInfo(ch, props, values, labelValues...)
+
+	return nil
+}
+
+// info returns the descriptor of the info metric reporting the values of the info properties among props as labels,
+// along with the names of those properties, or nil if there are none. Descriptors are built once for each distinct set
+// of info properties.
+func (p *propertyStore) info(props []string) (*prometheus.Desc, []string) {
+	var names []string
+	for _, k := range props {
+		if prop, ok := p.store[k]; ok && prop.label != `` {
+			names = append(names, k)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	key := strings.Join(names, `,`)
+	if desc, ok := p.infoDescs.Load(key); ok {
+		return desc.(*prometheus.Desc), names
+	}
+	labels := append([]string{}, p.defaultLabels...)
+	for _, k := range names {
+		labels = append(labels, p.store[k].label)
+	}
+	desc, _ := p.infoDescs.LoadOrStore(key, prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, p.defaultSubsystem, `info`),
+		fmt.Sprintf(`Information about the %s from string properties, reported as labels with a constant value of 1.`, p.defaultSubsystem),
+		labels,
+		nil,
+	))
+
+	return desc.(*prometheus.Desc), names
+}
+
+// pushInfo pushes the info metric for the info properties among props, if any of their values were fetched.
+func (p *propertyStore) pushInfo(ch chan<- metric, props []string, values map[string]string, labelValues ...string) {
+	desc, names := p.info(props)
+	if desc == nil {
+		return
+	}
+	fetched := false
+	infoValues := append([]string{}, labelValues...)
+	for _, k := range names {
+		v, ok := values[k]
+		fetched = fetched || ok
+		if v == `-` {
+			v = ``
+		}
+		infoValues = append(infoValues, v)
+	}
+	if !fetched {
+		return
+	}
+
+	ch <- metric{
+		name:       expandMetricName(prometheus.BuildFQName(namespace, p.defaultSubsystem, `info`), labelValues...),
+		prometheus: prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, infoValues...),
+	}
+}
+
+// lookup returns the property requested as name, matching parameterised properties (ie - `written@<snapshot>`) by
+// their name up to and including the `@`.
+func (p *propertyStore) lookup(name string) (property, bool) {
+	if prop, ok := p.store[name]; ok && prop.suffixLabel == `` {
+		return prop, true
+	}
+	base, suffix, ok := strings.Cut(name, `@`)
+	if !ok || suffix == `` {
+		return property{}, false
+	}
+	prop, ok := p.store[base+`@`]
+	return prop, ok && prop.suffixLabel != ``
 }
 
 func (p *propertyStore) find(name string) (property, error) {
-	prop, ok := p.store[name]
+	prop, ok := p.lookup(name)
 	if !ok {
 		prop = newProperty(
 			p.defaultSubsystem,
 			name,
 			propertyUnsupportedDesc,
-			transformNumeric,
+			TransformNumeric,
 			p.defaultLabels...,
 		)
 		return prop, errUnsupportedProperty
@@ -114,7 +607,7 @@ func (p *propertyStore) find(name string) (property, error) {
 	return prop, nil
 }
 
-func registerCollector(collector string, isDefaultEnabled bool, defaultProps string, factory factoryFunc) {
+func registerCollector(collector string, isDefaultEnabled bool, defaultProps string, store *propertyStore, factory factoryFunc) {
 	helpDefaultState := helpDefaultStateDisabled
 	if isDefaultEnabled {
 		helpDefaultState = helpDefaultStateEnabled
@@ -124,27 +617,103 @@ func registerCollector(collector string, isDefaultEnabled bool, defaultProps str
 	enabledFlagHelp := fmt.Sprintf("Enable the %s collector (default: %s)", collector, helpDefaultState)
 	enabledDefaultValue := fmt.Sprintf("%t", isDefaultEnabled)
 
-	propsFlagName := fmt.Sprintf("properties.%s", collector)
-	propsFlagHelp := fmt.Sprintf("Properties to include for the %s collector, comma-separated.", collector)
+	var enabledSetByUser, propsSetByUser bool
+	enabledFlag := kingpin.Flag(enabledFlagName, enabledFlagHelp).Default(enabledDefaultValue).IsSetByUser(&enabledSetByUser).Bool()
 
-	enabledFlag := kingpin.Flag(enabledFlagName, enabledFlagHelp).Default(enabledDefaultValue).Bool()
-	propsFlag := kingpin.Flag(propsFlagName, propsFlagHelp).Default(defaultProps).String()
+	// Collectors without a property store do not support property selection.
+	var propsFlag, excludePropsFlag *string
+	if store != nil {
+		propsFlagName := fmt.Sprintf("properties.%s", collector)
+		propsFlagHelp := fmt.Sprintf("Properties to include for the %s collector, comma-separated.", collector)
+		propsFlag = kingpin.Flag(propsFlagName, propsFlagHelp).Default(defaultProps).IsSetByUser(&propsSetByUser).String()
+
+		excludePropsFlagName := fmt.Sprintf("collector.%s.exclude-properties", collector)
+		excludePropsFlagHelp := fmt.Sprintf("Properties to exclude from the %s collector, comma-separated. Takes precedence over the included properties.", collector)
+		excludePropsFlag = kingpin.Flag(excludePropsFlagName, excludePropsFlagHelp).String()
+	}
 
 	collectorStates[collector] = State{
-		Enabled:    enabledFlag,
-		Properties: propsFlag,
-		factory:    factory,
+		Name:                collector,
+		Enabled:             enabledFlag,
+		Properties:          propsFlag,
+		ExcludeProperties:   excludePropsFlag,
+		defaultEnabled:      isDefaultEnabled,
+		defaultProperties:   defaultProps,
+		factory:             factory,
+		store:               store,
+		enabledSetByUser:    &enabledSetByUser,
+		propertiesSetByUser: &propsSetByUser,
+	}
+}
+
+// ValidateProperties checks that the properties selected for each enabled collector are known to that collector.
+func ValidateProperties() error {
+	return validateProperties(collectorStates)
+}
+
+func validateProperties(states map[string]State) error {
+	collectors := make([]string, 0, len(states))
+	for collector := range states {
+		collectors = append(collectors, collector)
+	}
+	sort.Strings(collectors)
+
+	for _, collector := range collectors {
+		state := states[collector]
+		if !*state.Enabled || state.store == nil {
+			continue
+		}
+		for _, prop := range append(state.properties(), state.excludedProperties()...) {
+			if _, ok := state.store.lookup(prop); !ok {
+				return fmt.Errorf("unknown property %q for the %s collector, valid properties are: %s", prop, collector, strings.Join(append(state.store.names(), state.store.parameterisedNames()...), `, `))
+			}
+		}
+	}
+
+	return nil
+}
+
+// isPoolMissing reports whether err was caused by the pool being exported or destroyed after the pools were listed,
+// logging the pool at debug level if so. Pool membership is inherently racy, so missing pools are skipped rather than
+// failing the collection.
+func isPoolMissing(l log.Logger, collector, pool string, err error) bool {
+	if !errors.Is(err, zfs.ErrPoolNotFound) {
+		return false
 	}
+	_ = level.Debug(l).Log(`msg`, `Pool no longer exists, skipping`, `collector`, collector, `pool`, pool, `err`, err)
+	return true
 }
 
 func expandMetricName(prefix string, context ...string) string {
 	return strings.Join(append(context, prefix), `-`)
 }
 
+// newInfoProperty returns a property whose string value is reported as the named label on the info metric of its
+// store, rather than as a metric of its own. Unset values (ie - `-`) are reported as an empty label.
+func newInfoProperty(label string) property {
+	return property{label: label}
+}
+
+// newValueLabelProperty returns a property reporting its string value as the named label of a dedicated info-style
+// metric, with a constant value of 1. Nothing is reported when the value is unset (ie - `-`).
+func newValueLabelProperty(subsystem, metricName, helpText, label string, labels ...string) property {
+	prop := newProperty(subsystem, metricName, helpText, transformInfo, append(append([]string{}, labels...), label)...)
+	prop.valueLabel = label
+	return prop
+}
+
+func newDerivedProperty(subsystem, metricName, helpText string, inputs []string, derive deriveFunc, labels ...string) property {
+	prop := newProperty(subsystem, metricName, helpText, nil, labels...)
+	prop.inputs = inputs
+	prop.derive = derive
+	return prop
+}
+
 func newProperty(subsystem, metricName, helpText string, transform transformFunc, labels ...string) property {
 	name := prometheus.BuildFQName(namespace, subsystem, metricName)
 	return property{
 		name:      name,
+		help:      helpText,
 		desc:      prometheus.NewDesc(name, helpText, labels, nil),
 		transform: transform,
 	}
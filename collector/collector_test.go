@@ -3,6 +3,8 @@ package collector
 import (
 	"bytes"
 	"context"
+	"reflect"
+	"testing"
 	"time"
 
 	"github.com/go-kit/log"
@@ -46,3 +48,111 @@ func stringPointer(s string) *string {
 func boolPointer(b bool) *bool {
 	return &b
 }
+
+func TestValidateProperties(t *testing.T) {
+	testCases := []struct {
+		name       string
+		enabled    bool
+		properties string
+		excludes   string
+		wantErr    bool
+	}{
+		{
+			name:       `known properties`,
+			enabled:    true,
+			properties: `allocated,capacity,expandsize`,
+		},
+		{
+			name:       `unknown property`,
+			enabled:    true,
+			properties: `allocated,bogus`,
+			wantErr:    true,
+		},
+		{
+			name:       `excluded properties`,
+			enabled:    true,
+			properties: `allocated,dedupratio`,
+			excludes:   `dedupratio`,
+		},
+		{
+			name:       `unknown excluded property`,
+			enabled:    true,
+			properties: `allocated`,
+			excludes:   `bogus`,
+			wantErr:    true,
+		},
+		{
+			name:       `unknown property for disabled collector`,
+			properties: `bogus`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			states := map[string]State{
+				`pool`: {
+					Name:              `pool`,
+					Enabled:           boolPointer(tc.enabled),
+					Properties:        stringPointer(tc.properties),
+					ExcludeProperties: stringPointer(tc.excludes),
+					store:             &poolProperties,
+				},
+				`pool-blocks`: {
+					Name:    `pool-blocks`,
+					Enabled: boolPointer(true),
+				},
+			}
+			err := validateProperties(states)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPropertyStoreLookup(t *testing.T) {
+	testCases := []struct {
+		name string
+		want bool
+	}{
+		{name: `written`, want: true},
+		{name: `written@daily`, want: true},
+		{name: `written@`, want: false},
+		{name: `used@daily`, want: false},
+	}
+
+	for _, tc := range testCases {
+		if _, ok := datasetProperties.lookup(tc.name); ok != tc.want {
+			t.Errorf("lookup(%q) got %t, want %t", tc.name, ok, tc.want)
+		}
+	}
+}
+
+func TestPropertyStoreNames(t *testing.T) {
+	for _, name := range datasetProperties.names() {
+		if _, ok := datasetProperties.lookup(name); !ok {
+			t.Errorf("names() returned %q, which is not a valid property", name)
+		}
+	}
+	if got, want := datasetProperties.parameterisedNames(), []string{`written@<snapshot>`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parameterisedNames() got %v, want %v", got, want)
+	}
+}
+
+func TestPropertyStoreInfoCached(t *testing.T) {
+	props := []string{`used`, `mountpoint`, `keylocation`}
+	desc, names := datasetProperties.info(props)
+	if desc == nil {
+		t.Fatal(`got nil info descriptor`)
+	}
+	if want := []string{`mountpoint`, `keylocation`}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("got info properties %v, want %v", names, want)
+	}
+	if again, _ := datasetProperties.info(props); again != desc {
+		t.Error(`info descriptor rebuilt for the same properties`)
+	}
+	if other, _ := datasetProperties.info([]string{`mountpoint`}); other == desc {
+		t.Error(`info descriptor shared by different properties`)
+	}
+}
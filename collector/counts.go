@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolDatasetCountDescName = prometheus.BuildFQName(namespace, subsystemPool, `dataset_count`)
+	poolDatasetCountDesc     = prometheus.NewDesc(
+		poolDatasetCountDescName,
+		`Number of filesystems and volumes in the pool, including the root dataset.`,
+		poolLabels,
+		nil,
+	)
+	poolSnapshotCountDescName = prometheus.BuildFQName(namespace, subsystemPool, `snapshot_count`)
+	poolSnapshotCountDesc     = prometheus.NewDesc(
+		poolSnapshotCountDescName,
+		`Number of snapshots in the pool.`,
+		poolLabels,
+		nil,
+	)
+)
+
+func init() {
+	registerCollector(`pool-dataset-counts`, defaultDisabled, ``, nil, newPoolDatasetCountsCollector)
+}
+
+// poolDatasetCountsCollector reports the number of datasets and snapshots of each pool from a single `zfs list`
+// command, as a lightweight alternative to the per-dataset collectors.
+type poolDatasetCountsCollector struct {
+	log    log.Logger
+	client zfs.Client
+}
+
+// datasetCounts holds the number of datasets and snapshots of a pool.
+type datasetCounts struct {
+	datasets  float64
+	snapshots float64
+}
+
+func (c *poolDatasetCountsCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- poolDatasetCountDesc
+	ch <- poolSnapshotCountDesc
+}
+
+func (c *poolDatasetCountsCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	names, err := c.client.DatasetNames(zfs.DatasetFilesystem, zfs.DatasetVolume, zfs.DatasetSnapshot)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]*datasetCounts, len(pools))
+	for _, pool := range pools {
+		counts[pool] = nil
+	}
+	for _, name := range names {
+		if excludes.MatchString(name) {
+			continue
+		}
+		pool := name
+		if i := strings.IndexAny(name, `/@`); i >= 0 {
+			pool = name[:i]
+		}
+		count, ok := counts[pool]
+		if !ok {
+			continue
+		}
+		if count == nil {
+			count = &datasetCounts{}
+			counts[pool] = count
+		}
+		if strings.Contains(name, `@`) {
+			count.snapshots++
+		} else {
+			count.datasets++
+		}
+	}
+
+	// Pools not listed (ie - exported since the pools were listed) are not reported.
+	for _, pool := range pools {
+		count := counts[pool]
+		if count == nil {
+			continue
+		}
+		ch <- metric{
+			name:       expandMetricName(poolDatasetCountDescName, pool),
+			prometheus: prometheus.MustNewConstMetric(poolDatasetCountDesc, prometheus.GaugeValue, count.datasets, pool),
+		}
+		ch <- metric{
+			name:       expandMetricName(poolSnapshotCountDescName, pool),
+			prometheus: prometheus.MustNewConstMetric(poolSnapshotCountDesc, prometheus.GaugeValue, count.snapshots, pool),
+		}
+	}
+
+	return nil
+}
+
+func newPoolDatasetCountsCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	return &poolDatasetCountsCollector{log: l, client: c}, nil
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestPoolDatasetCountsMetrics(t *testing.T) {
+	const result = `# HELP zfs_pool_dataset_count Number of filesystems and volumes in the pool, including the root dataset.
+# TYPE zfs_pool_dataset_count gauge
+zfs_pool_dataset_count{pool="tank"} 3
+zfs_pool_dataset_count{pool="tank2"} 1
+# HELP zfs_pool_snapshot_count Number of snapshots in the pool.
+# TYPE zfs_pool_snapshot_count gauge
+zfs_pool_snapshot_count{pool="tank"} 3
+zfs_pool_snapshot_count{pool="tank2"} 0
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`tank`, `tank2`, `backup`}, nil).Times(1)
+	zfsClient.EXPECT().DatasetNames(zfs.DatasetFilesystem, zfs.DatasetVolume, zfs.DatasetSnapshot).Return([]string{
+		`backup`,
+		`backup/tank`,
+		`tank`,
+		`tank@daily`,
+		`tank/home`,
+		`tank/home@daily`,
+		`tank/home@hourly`,
+		`tank/vol0`,
+		`tank/scratch`,
+		`tank/scratch@daily`,
+		`tank2`,
+	}, nil).Times(1)
+
+	config := defaultConfig(zfsClient)
+	config.Pools = []string{`tank`, `tank2`}
+	config.Excludes = []string{`^tank/scratch`}
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool-dataset-counts`: {
+			Name:    "pool-dataset-counts",
+			Enabled: boolPointer(true),
+			factory: newPoolDatasetCountsCollector,
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_dataset_count`, `zfs_pool_snapshot_count`}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,10 +2,11 @@ package collector
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
+	"github.com/alecthomas/kingpin/v2"
 	"github.com/go-kit/log"
-	"github.com/go-kit/log/level"
 	"github.com/pdf/zfs_exporter/v2/zfs"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -17,6 +18,24 @@ const (
 )
 
 var (
+	canmountValues = []string{`off`, `on`, `noauto`}
+	// keystatusValues are ordered so that a key that is unavailable, leaving the dataset inaccessible, is reported as 0.
+	keystatusValues = []string{`unavailable`, `available`, `none`}
+	// snapdirValues includes `disabled`, supported since OpenZFS 2.2.
+	snapdirValues = []string{`hidden`, `visible`, `disabled`}
+
+	// snapshotAggregate reports snapshot counts per dataset from the dataset-snapshot collector, rather than properties
+	// per snapshot.
+	snapshotAggregate *bool
+
+	snapshotCountLabels   = []string{`name`, `pool`}
+	snapshotCountDescName = prometheus.BuildFQName(namespace, subsystemDataset, `snapshot_count`)
+	snapshotCountDesc     = prometheus.NewDesc(
+		snapshotCountDescName,
+		`Number of snapshots of the dataset.`,
+		snapshotCountLabels,
+		nil,
+	)
 	datasetLabels     = []string{`name`, `pool`, `type`}
 	datasetProperties = propertyStore{
 		defaultSubsystem: subsystemDataset,
@@ -26,143 +45,273 @@ var (
 				subsystemDataset,
 				`available_bytes`,
 				`The amount of space in bytes available to the dataset and all its children.`,
-				transformNumeric,
+				TransformNumeric,
+				datasetLabels...,
+			),
+			`canmount`: newProperty(
+				subsystemDataset,
+				`canmount`,
+				fmt.Sprintf(`Whether the filesystem may be mounted, and whether it is mounted automatically %s.`, enumHelp(canmountValues...)),
+				transformEnum(canmountValues...),
 				datasetLabels...,
 			),
 			`compressratio`: newProperty(
 				subsystemDataset,
 				`compression_ratio`,
 				`The ratio of compressed size vs uncompressed size for this dataset.`,
-				transformMultiplier,
+				TransformMultiplier,
+				datasetLabels...,
+			),
+			`compression`: newValueLabelProperty(
+				subsystemDataset,
+				`compression`,
+				`The compression algorithm of the dataset, reported as the compression label with a constant value of 1, including "off" for uncompressed datasets.`,
+				`compression`,
+				datasetLabels...,
+			),
+			`delegated`: newDerivedProperty(
+				subsystemDataset,
+				`delegated`,
+				`Whether the dataset is delegated to a jail or zone [0: not delegated, 1: delegated].`,
+				[]string{zonedProperty},
+				deriveBool(zonedProperty),
 				datasetLabels...,
 			),
+			`encryption`: newValueLabelProperty(
+				subsystemDataset,
+				`encryption`,
+				`The encryption cipher of the dataset, reported as the encryption label with a constant value of 1, including "off" for unencrypted datasets.`,
+				`encryption`,
+				datasetLabels...,
+			).requires(0, 8, 0),
+			`filesystem_count`: newProperty(
+				subsystemDataset,
+				`filesystem_count`,
+				`The total number of filesystems and volumes that exist under this location in the dataset tree. This value is only available when a filesystem_limit has been set somewhere in the tree under which the dataset resides.`,
+				TransformNumeric,
+				datasetLabels...,
+			),
+			`filesystem_limit`: newProperty(
+				subsystemDataset,
+				`filesystem_limit`,
+				`The limit on the number of filesystems and volumes that can exist under this point in the dataset tree. Not reported if no limit is set.`,
+				transformLimit,
+				datasetLabels...,
+			),
+			`keyformat`:   newInfoProperty(`keyformat`),
+			`keylocation`: newInfoProperty(`keylocation`),
+			`keystatus`: newProperty(
+				subsystemDataset,
+				`key_available`,
+				fmt.Sprintf(`Whether the encryption key of the dataset is loaded, making its data accessible %s.`, enumHelp(keystatusValues...)),
+				transformEnum(keystatusValues...),
+				datasetLabels...,
+			).requires(0, 8, 0),
 			`logicalused`: newProperty(
 				subsystemDataset,
 				`logical_used_bytes`,
 				`The amount of space in bytes that is "logically" consumed by this dataset and all its descendents. See the "used_bytes" property.`,
-				transformNumeric,
+				TransformNumeric,
 				datasetLabels...,
 			),
 			`logicalreferenced`: newProperty(
 				subsystemDataset,
 				`logical_referenced_bytes`,
 				`The amount of space that is "logically" accessible by this dataset. See the "referenced_bytes" property.`,
-				transformNumeric,
+				TransformNumeric,
+				datasetLabels...,
+			),
+			`mounted`: newProperty(
+				subsystemDataset,
+				`mounted`,
+				`Whether the filesystem is currently mounted [0: not mounted, 1: mounted].`,
+				TransformBool,
+				datasetLabels...,
+			),
+			`mountpoint`: newInfoProperty(`mountpoint`),
+			`origin`: newValueLabelProperty(
+				subsystemDataset,
+				`origin_info`,
+				`The snapshot from which the clone was created, reported as the origin label with a constant value of 1. Not reported for datasets that are not clones.`,
+				`origin`,
 				datasetLabels...,
 			),
 			`quota`: newProperty(
 				subsystemDataset,
 				`quota_bytes`,
 				`The maximum amount of space in bytes this dataset and its descendents can consume.`,
-				transformNumeric,
+				TransformNumeric,
+				datasetLabels...,
+			),
+			`quota_used`: newDerivedProperty(
+				subsystemDataset,
+				`quota_used_ratio`,
+				`The ratio of space consumed by this dataset and all its descendents to its quota. Not reported for datasets without a quota.`,
+				[]string{`quota`, `used`},
+				deriveQuotaUsedRatio,
+				datasetLabels...,
+			),
+			`receive_resume_token`: newDerivedProperty(
+				subsystemDataset,
+				`receive_bytes`,
+				`The amount of space in bytes consumed by a partially received dataset, while a resumable receive is in progress or interrupted.`,
+				[]string{`receive_resume_token`, `used`},
+				deriveReceiveBytes,
+				datasetLabels...,
+			).requires(0, 7, 0),
+			`recordsize`: newProperty(
+				subsystemDataset,
+				`record_size_bytes`,
+				`The suggested block size in bytes for files in the filesystem.`,
+				TransformNumeric,
 				datasetLabels...,
 			),
 			`refcompressratio`: newProperty(
 				subsystemDataset,
 				`referenced_compression_ratio`,
 				`The ratio of compressed size vs uncompressed size for the referenced space of this dataset. See also the "compression_ratio" property.`,
-				transformMultiplier,
+				TransformMultiplier,
 				datasetLabels...,
 			),
 			`referenced`: newProperty(
 				subsystemDataset,
 				`referenced_bytes`,
 				`The amount of data in bytes that is accessible by this dataset, which may or may not be shared with other datasets in the pool.`,
-				transformNumeric,
+				TransformNumeric,
 				datasetLabels...,
 			),
 			`refquota`: newProperty(
 				subsystemDataset,
 				`referenced_quota_bytes`,
 				`The maximum amount of space in bytes this dataset can consume.`,
-				transformNumeric,
+				TransformNumeric,
 				datasetLabels...,
 			),
 			`refreservation`: newProperty(
 				subsystemDataset,
 				`referenced_reservation_bytes`,
 				`The minimum amount of space in bytes guaranteed to this dataset.`,
-				transformNumeric,
+				TransformNumeric,
+				datasetLabels...,
+			),
+			`refreservation_ratio`: newDerivedProperty(
+				subsystemDataset,
+				`referenced_reservation_ratio`,
+				`The ratio of the refreservation to the size of the volume, 0 for thin-provisioned (sparse) volumes, and slightly over 1 for fully reserved volumes, whose refreservation includes metadata. Not reported for filesystems.`,
+				[]string{`refreservation`, `volsize`},
+				deriveRefreservationRatio,
 				datasetLabels...,
 			),
 			`reservation`: newProperty(
 				subsystemDataset,
 				`reservation_bytes`,
 				`The minimum amount of space in bytes guaranteed to a dataset and its descendants.`,
-				transformNumeric,
+				TransformNumeric,
 				datasetLabels...,
 			),
+			`snapdir`: newProperty(
+				subsystemDataset,
+				`snapdir`,
+				fmt.Sprintf(`Whether the .zfs directory in the root of the filesystem is visible, providing access to its snapshots %s.`, enumHelp(snapdirValues...)),
+				transformEnum(snapdirValues...),
+				datasetLabels...,
+			),
+			// The snapshot count and limit keep the _total suffix of earlier releases despite being gauges, as
+			// zfs_dataset_snapshot_count is reported by the aggregating dataset-snapshot collector with other labels.
 			`snapshot_count`: newProperty(
 				subsystemDataset,
 				`snapshot_count_total`,
 				`The total number of snapshots that exist under this location in the dataset tree. This value is only available when a snapshot_limit has been set somewhere in the tree under which the dataset resides.`,
-				transformNumeric,
+				TransformNumeric,
 				datasetLabels...,
 			),
 			`snapshot_limit`: newProperty(
 				subsystemDataset,
 				`snapshot_limit_total`,
-				`The total limit on the number of snapshots that can be created on a dataset and its descendents.`,
-				transformNumeric,
+				`The total limit on the number of snapshots that can be created on a dataset and its descendents. Not reported if no limit is set.`,
+				transformLimit,
 				datasetLabels...,
 			),
+			`special_small_blocks`: newProperty(
+				subsystemDataset,
+				`special_small_blocks_bytes`,
+				`The threshold block size in bytes for including small file blocks in the special allocation class, 0 when disabled.`,
+				TransformNumeric,
+				datasetLabels...,
+			).requires(0, 8, 0),
 			`used`: newProperty(
 				subsystemDataset,
 				`used_bytes`,
 				`The amount of space in bytes consumed by this dataset and all its descendents.`,
-				transformNumeric,
+				TransformNumeric,
 				datasetLabels...,
 			),
 			`usedbychildren`: newProperty(
 				subsystemDataset,
 				`used_by_children_bytes`,
 				`The amount of space in bytes used by children of this dataset, which would be freed if all the dataset's children were destroyed.`,
-				transformNumeric,
+				TransformNumeric,
 				datasetLabels...,
 			),
 			`usedbydataset`: newProperty(
 				subsystemDataset,
 				`used_by_dataset_bytes`,
 				`The amount of space in bytes used by this dataset itself, which would be freed if the dataset were destroyed.`,
-				transformNumeric,
+				TransformNumeric,
 				datasetLabels...,
 			),
 			`usedbyrefreservation`: newProperty(
 				subsystemDataset,
 				`used_by_referenced_reservation_bytes`,
 				`The amount of space in bytes used by a refreservation set on this dataset, which would be freed if the refreservation was removed.`,
-				transformNumeric,
+				TransformNumeric,
 				datasetLabels...,
 			),
 			`usedbysnapshots`: newProperty(
 				subsystemDataset,
 				`used_by_snapshot_bytes`,
 				`The amount of space in bytes consumed by snapshots of this dataset.`,
-				transformNumeric,
+				TransformNumeric,
+				datasetLabels...,
+			),
+			`volblocksize`: newProperty(
+				subsystemDataset,
+				`volume_block_size_bytes`,
+				`The block size in bytes of the volume.`,
+				TransformNumeric,
 				datasetLabels...,
 			),
 			`volsize`: newProperty(
 				subsystemDataset,
 				`volume_size_bytes`,
 				`The logical size in bytes of this volume.`,
-				transformNumeric,
+				TransformNumeric,
 				datasetLabels...,
 			),
 			`written`: newProperty(
 				subsystemDataset,
 				`written_bytes`,
 				`The amount of referenced space in bytes written to this dataset since the previous snapshot.`,
-				transformNumeric,
+				TransformNumeric,
 				datasetLabels...,
 			),
+			`written@`: newProperty(
+				subsystemDataset,
+				`written_since_snapshot_bytes`,
+				"The amount of referenced space in bytes written to this dataset since the snapshot, selected by name as `written@<snapshot>`. Datasets without the snapshot are not reported.",
+				transformNumericAvailable,
+				append(append([]string{}, datasetLabels...), `snapshot`)...,
+			).withSuffix(`snapshot`),
 		},
 	}
 )
 
 func init() {
-	registerCollector(`dataset-filesystem`, defaultEnabled, defaultFilesystemProps, newFilesystemCollector)
-	registerCollector(`dataset-snapshot`, defaultDisabled, defaultSnapshotProps, newSnapshotCollector)
-	registerCollector(`dataset-volume`, defaultEnabled, defaultVolumeProps, newVolumeCollector)
+	registerCollector(`dataset-filesystem`, defaultEnabled, defaultFilesystemProps, &datasetProperties, newFilesystemCollector)
+	registerCollector(`dataset-snapshot`, defaultDisabled, defaultSnapshotProps, &datasetProperties, newSnapshotCollector)
+	registerCollector(`dataset-volume`, defaultEnabled, defaultVolumeProps, &datasetProperties, newVolumeCollector)
+
+	snapshotAggregate = kingpin.Flag(`collector.dataset-snapshot.aggregate`, `Report the number of snapshots of each dataset from the dataset-snapshot collector, instead of properties per snapshot.`).Default(`false`).Bool()
 }
 
 type datasetCollector struct {
@@ -170,26 +319,47 @@ type datasetCollector struct {
 	log    log.Logger
 	client zfs.Client
 	props  []string
+	// aggregate reports the number of snapshots per dataset, rather than snapshot properties.
+	aggregate bool
+	// raw properties whose unparsed values are reported, for debugging.
+	raw rawPropertySet
 }
 
 func (c *datasetCollector) describe(ch chan<- *prometheus.Desc) {
+	if c.aggregate {
+		ch <- snapshotCountDesc
+		return
+	}
 	for _, k := range c.props {
 		prop, err := datasetProperties.find(k)
 		if err != nil {
-			_ = level.Warn(c.log).Log(`msg`, propertyUnsupportedMsg, `help`, helpIssue, `collector`, c.kind, `property`, k, `err`, err)
+			warnUnsupported(c.log, string(c.kind), k, err)
+		}
+		if prop.label != `` {
 			continue
 		}
 		ch <- prop.desc
 	}
+	if desc, _ := datasetProperties.info(c.props); desc != nil {
+		ch <- desc
+	}
+	c.raw.describe(ch)
 }
 
 func (c *datasetCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	props := datasetProperties.supported(c.log, string(c.kind), c.client, c.props)
+	if len(props) == 0 && !c.aggregate {
+		return nil
+	}
+
+	dialect := newDatasetDialect(c.log, string(c.kind), c.client, datasetProperties.fetchable(props))
+
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(pools))
 	for _, pool := range pools {
 		wg.Add(1)
 		go func(pool string) {
-			if err := c.updatePoolMetrics(ch, pool, excludes); err != nil {
+			if err := c.updatePoolMetrics(ch, pool, props, dialect, excludes); err != nil && !isPoolMissing(c.log, string(c.kind), pool, err) {
 				errChan <- err
 			}
 			wg.Done()
@@ -205,18 +375,22 @@ func (c *datasetCollector) update(ch chan<- metric, pools []string, excludes reg
 	}
 }
 
-func (c *datasetCollector) updatePoolMetrics(ch chan<- metric, pool string, excludes regexpCollection) error {
+func (c *datasetCollector) updatePoolMetrics(ch chan<- metric, pool string, props []string, dialect datasetDialect, excludes regexpCollection) error {
+	if c.aggregate {
+		return c.updateSnapshotCounts(ch, pool, excludes)
+	}
+
 	datasets := c.client.Datasets(pool, c.kind)
-	props, err := datasets.Properties(c.props...)
+	results, err := datasets.Properties(dialect.translate(datasetProperties.fetchable(props))...)
 	if err != nil {
 		return err
 	}
 
-	for _, dataset := range props {
+	for _, dataset := range results {
 		if excludes.MatchString(dataset.DatasetName()) {
 			continue
 		}
-		if err = c.updateDatasetMetrics(ch, pool, dataset); err != nil {
+		if err = c.updateDatasetMetrics(ch, pool, props, dialect, dataset); err != nil {
 			return err
 		}
 	}
@@ -224,22 +398,83 @@ func (c *datasetCollector) updatePoolMetrics(ch chan<- metric, pool string, excl
 	return nil
 }
 
-func (c *datasetCollector) updateDatasetMetrics(ch chan<- metric, pool string, dataset zfs.DatasetProperties) error {
-	labelValues := []string{dataset.DatasetName(), pool, string(c.kind)}
+func (c *datasetCollector) updateDatasetMetrics(ch chan<- metric, pool string, props []string, dialect datasetDialect, dataset zfs.DatasetProperties) error {
+	name := dataset.DatasetName()
+	labelValues := []string{name, pool, string(c.kind)}
+	values := dialect.values(dataset.Properties())
+	c.raw.push(ch, `dataset-`+string(c.kind), pool, name, values)
 
-	for k, v := range dataset.Properties() {
-		prop, err := datasetProperties.find(k)
-		if err != nil {
-			_ = level.Warn(c.log).Log(`msg`, propertyUnsupportedMsg, `help`, helpIssue, `collector`, c.kind, `property`, k, `err`, err)
+	return datasetProperties.push(c.log, string(c.kind), ch, props, values, labelValues...)
+}
+
+// updateSnapshotCounts reports the number of snapshots of each dataset in the pool, by grouping snapshot names on the
+// dataset name preceding the `@`.
+func (c *datasetCollector) updateSnapshotCounts(ch chan<- metric, pool string, excludes regexpCollection) error {
+	results, err := c.client.Datasets(pool, zfs.DatasetSnapshot).Properties(`name`)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]uint64)
+	for _, snapshot := range results {
+		if excludes.MatchString(snapshot.DatasetName()) {
+			continue
 		}
-		if err = prop.push(ch, v, labelValues...); err != nil {
-			return err
+		dataset, _, ok := strings.Cut(snapshot.DatasetName(), `@`)
+		if !ok {
+			continue
+		}
+		counts[dataset]++
+	}
+
+	for dataset, count := range counts {
+		labelValues := []string{dataset, pool}
+		ch <- metric{
+			name:       expandMetricName(snapshotCountDescName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(snapshotCountDesc, prometheus.GaugeValue, float64(count), labelValues...),
 		}
 	}
 
 	return nil
 }
 
+// deriveReceiveBytes reports the used space of a dataset as received bytes, only when a receive resume token is present.
+func deriveReceiveBytes(values map[string]string) (float64, bool, error) {
+	if token := values[`receive_resume_token`]; token == `` || token == `-` {
+		return 0, false, nil
+	}
+	v, err := TransformNumeric(values[`used`])
+	return v, err == nil, err
+}
+
+// deriveQuotaUsedRatio reports used space as a ratio of the quota, skipping datasets without a quota (reported as `0`,
+// `none` or `-`).
+func deriveQuotaUsedRatio(values map[string]string) (float64, bool, error) {
+	quota, err := TransformNumeric(values[`quota`])
+	if err != nil || quota == 0 {
+		return 0, false, err
+	}
+	used, err := TransformNumeric(values[`used`])
+	if err != nil {
+		return 0, false, err
+	}
+	return used / quota, true, nil
+}
+
+// deriveRefreservationRatio reports the refreservation as a ratio of the volume size, skipping datasets without a
+// volume size (ie - filesystems).
+func deriveRefreservationRatio(values map[string]string) (float64, bool, error) {
+	volsize, err := TransformNumeric(values[`volsize`])
+	if err != nil || volsize == 0 {
+		return 0, false, err
+	}
+	refreservation, err := TransformNumeric(values[`refreservation`])
+	if err != nil {
+		return 0, false, err
+	}
+	return refreservation / volsize, true, nil
+}
+
 func newDatasetCollector(kind zfs.DatasetKind, l log.Logger, c zfs.Client, props []string) (Collector, error) {
 	switch kind {
 	case zfs.DatasetFilesystem, zfs.DatasetSnapshot, zfs.DatasetVolume:
@@ -247,7 +482,7 @@ func newDatasetCollector(kind zfs.DatasetKind, l log.Logger, c zfs.Client, props
 		return nil, fmt.Errorf("unknown dataset type: %s", kind)
 	}
 
-	return &datasetCollector{kind: kind, log: l, client: c, props: props}, nil
+	return &datasetCollector{kind: kind, log: l, client: c, props: props, raw: newRawPropertySet(*rawProperties)}, nil
 }
 
 func newFilesystemCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
@@ -255,7 +490,7 @@ func newFilesystemCollector(l log.Logger, c zfs.Client, props []string) (Collect
 }
 
 func newSnapshotCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
-	return newDatasetCollector(zfs.DatasetSnapshot, l, c, props)
+	return &datasetCollector{kind: zfs.DatasetSnapshot, log: l, client: c, props: props, aggregate: *snapshotAggregate, raw: newRawPropertySet(*rawProperties)}, nil
 }
 
 func newVolumeCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
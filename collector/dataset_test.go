@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/go-kit/log"
 	"github.com/golang/mock/gomock"
 	"github.com/pdf/zfs_exporter/v2/zfs"
 	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
@@ -21,7 +22,9 @@ func TestDatsetMetrics(t *testing.T) {
 		kinds          []zfs.DatasetKind
 		pools          []string
 		explicitPools  []string
+		version        *zfs.Version
 		propsRequested []string
+		propsFetched   []string
 		metricNames    []string
 		propsResults   map[string][]datasetResults
 		metricResults  string
@@ -184,6 +187,651 @@ zfs_dataset_available_bytes{name="testpool1/test",pool="testpool1",type="filesys
 zfs_dataset_available_bytes{name="testpool/test",pool="testpool",type="filesystem"} 1024
 zfs_dataset_available_bytes{name="testpool/test",pool="testpool",type="snapshot"} 1024
 zfs_dataset_available_bytes{name="testpool/test",pool="testpool",type="volume"} 1024
+`,
+		},
+		{
+			name:           `receive in progress`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`receive_resume_token`},
+			propsFetched:   []string{`receive_resume_token`, `used`},
+			metricNames:    []string{`zfs_dataset_receive_bytes`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/receiving`,
+						results: map[string]string{
+							`receive_resume_token`: `1-e604ea4bf-e0-789c63a2aaca`,
+							`used`:                 `4096`,
+						},
+					},
+					{
+						name: `testpool/idle`,
+						results: map[string]string{
+							`receive_resume_token`: `-`,
+							`used`:                 `1024`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_receive_bytes The amount of space in bytes consumed by a partially received dataset, while a resumable receive is in progress or interrupted.
+# TYPE zfs_dataset_receive_bytes gauge
+zfs_dataset_receive_bytes{name="testpool/receiving",pool="testpool",type="filesystem"} 4096
+`,
+		},
+		{
+			name:           `receive unsupported by version`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			version:        &zfs.Version{Major: 0, Minor: 6, Patch: 5},
+			propsRequested: []string{`receive_resume_token`, `used`},
+			propsFetched:   []string{`used`},
+			metricNames:    []string{`zfs_dataset_receive_bytes`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/receiving`,
+						results: map[string]string{
+							`used`: `4096`,
+						},
+					},
+				},
+			},
+			metricResults: ``,
+		},
+		{
+			name:           `delegated`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`delegated`},
+			propsFetched:   []string{zonedProperty},
+			metricNames:    []string{`zfs_dataset_delegated`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/jail`,
+						results: map[string]string{
+							zonedProperty: `on`,
+						},
+					},
+					{
+						name: `testpool/host`,
+						results: map[string]string{
+							zonedProperty: `off`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_delegated Whether the dataset is delegated to a jail or zone [0: not delegated, 1: delegated].
+# TYPE zfs_dataset_delegated gauge
+zfs_dataset_delegated{name="testpool/host",pool="testpool",type="filesystem"} 0
+zfs_dataset_delegated{name="testpool/jail",pool="testpool",type="filesystem"} 1
+`,
+		},
+		{
+			name:           `delegated on FreeBSD`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			version:        &zfs.Version{Major: 2, Minor: 1, Patch: 9, Userland: `zfs-2.1.9-FreeBSD_g92e0d9d18`, Kernel: `zfs-kmod-2.1.9-FreeBSD_g92e0d9d18`},
+			propsRequested: []string{`delegated`},
+			propsFetched:   []string{jailedProperty},
+			metricNames:    []string{`zfs_dataset_delegated`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/jail`,
+						results: map[string]string{
+							jailedProperty: `on`,
+						},
+					},
+					{
+						name: `testpool/host`,
+						results: map[string]string{
+							jailedProperty: `off`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_delegated Whether the dataset is delegated to a jail or zone [0: not delegated, 1: delegated].
+# TYPE zfs_dataset_delegated gauge
+zfs_dataset_delegated{name="testpool/host",pool="testpool",type="filesystem"} 0
+zfs_dataset_delegated{name="testpool/jail",pool="testpool",type="filesystem"} 1
+`,
+		},
+		{
+			name:           `compression effectiveness`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`compressratio`, `logicalreferenced`, `logicalused`, `refcompressratio`, `referenced`, `used`},
+			metricNames:    []string{`zfs_dataset_compression_ratio`, `zfs_dataset_logical_referenced_bytes`, `zfs_dataset_logical_used_bytes`, `zfs_dataset_referenced_compression_ratio`, `zfs_dataset_referenced_bytes`, `zfs_dataset_used_bytes`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/test`,
+						results: map[string]string{
+							`compressratio`:     `1.50x`,
+							`logicalreferenced`: `2048`,
+							`logicalused`:       `3072`,
+							`refcompressratio`:  `2.00x`,
+							`referenced`:        `1024`,
+							`used`:              `2048`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_compression_ratio The ratio of compressed size vs uncompressed size for this dataset.
+# TYPE zfs_dataset_compression_ratio gauge
+zfs_dataset_compression_ratio{name="testpool/test",pool="testpool",type="filesystem"} 0.6666666666666666
+# HELP zfs_dataset_logical_referenced_bytes The amount of space that is "logically" accessible by this dataset. See the "referenced_bytes" property.
+# TYPE zfs_dataset_logical_referenced_bytes gauge
+zfs_dataset_logical_referenced_bytes{name="testpool/test",pool="testpool",type="filesystem"} 2048
+# HELP zfs_dataset_logical_used_bytes The amount of space in bytes that is "logically" consumed by this dataset and all its descendents. See the "used_bytes" property.
+# TYPE zfs_dataset_logical_used_bytes gauge
+zfs_dataset_logical_used_bytes{name="testpool/test",pool="testpool",type="filesystem"} 3072
+# HELP zfs_dataset_referenced_bytes The amount of data in bytes that is accessible by this dataset, which may or may not be shared with other datasets in the pool.
+# TYPE zfs_dataset_referenced_bytes gauge
+zfs_dataset_referenced_bytes{name="testpool/test",pool="testpool",type="filesystem"} 1024
+# HELP zfs_dataset_referenced_compression_ratio The ratio of compressed size vs uncompressed size for the referenced space of this dataset. See also the "compression_ratio" property.
+# TYPE zfs_dataset_referenced_compression_ratio gauge
+zfs_dataset_referenced_compression_ratio{name="testpool/test",pool="testpool",type="filesystem"} 0.5
+# HELP zfs_dataset_used_bytes The amount of space in bytes consumed by this dataset and all its descendents.
+# TYPE zfs_dataset_used_bytes gauge
+zfs_dataset_used_bytes{name="testpool/test",pool="testpool",type="filesystem"} 2048
+`,
+		},
+		{
+			name:           `info properties`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`mountpoint`, `used`},
+			metricNames:    []string{`zfs_dataset_info`, `zfs_dataset_used_bytes`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/home`,
+						results: map[string]string{
+							`mountpoint`: `/home`,
+							`used`:       `1024`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_info Information about the dataset from string properties, reported as labels with a constant value of 1.
+# TYPE zfs_dataset_info gauge
+zfs_dataset_info{mountpoint="/home",name="testpool/home",pool="testpool",type="filesystem"} 1
+# HELP zfs_dataset_used_bytes The amount of space in bytes consumed by this dataset and all its descendents.
+# TYPE zfs_dataset_used_bytes gauge
+zfs_dataset_used_bytes{name="testpool/home",pool="testpool",type="filesystem"} 1024
+`,
+		},
+		{
+			name:           `mount state`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`canmount`, `mounted`},
+			metricNames:    []string{`zfs_dataset_canmount`, `zfs_dataset_mounted`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/home`,
+						results: map[string]string{
+							`canmount`: `on`,
+							`mounted`:  `yes`,
+						},
+					},
+					{
+						name: `testpool/backup`,
+						results: map[string]string{
+							`canmount`: `noauto`,
+							`mounted`:  `no`,
+						},
+					},
+					{
+						name: `testpool/ROOT`,
+						results: map[string]string{
+							`canmount`: `off`,
+							`mounted`:  `no`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_canmount Whether the filesystem may be mounted, and whether it is mounted automatically [0: off, 1: on, 2: noauto].
+# TYPE zfs_dataset_canmount gauge
+zfs_dataset_canmount{name="testpool/ROOT",pool="testpool",type="filesystem"} 0
+zfs_dataset_canmount{name="testpool/backup",pool="testpool",type="filesystem"} 2
+zfs_dataset_canmount{name="testpool/home",pool="testpool",type="filesystem"} 1
+# HELP zfs_dataset_mounted Whether the filesystem is currently mounted [0: not mounted, 1: mounted].
+# TYPE zfs_dataset_mounted gauge
+zfs_dataset_mounted{name="testpool/ROOT",pool="testpool",type="filesystem"} 0
+zfs_dataset_mounted{name="testpool/backup",pool="testpool",type="filesystem"} 0
+zfs_dataset_mounted{name="testpool/home",pool="testpool",type="filesystem"} 1
+`,
+		},
+		{
+			name:           `snapshot limits`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`snapdir`, `snapshot_count`, `snapshot_limit`},
+			metricNames:    []string{`zfs_dataset_snapdir`, `zfs_dataset_snapshot_count_total`, `zfs_dataset_snapshot_limit_total`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/home`,
+						results: map[string]string{
+							`snapdir`:        `visible`,
+							`snapshot_count`: `12`,
+							`snapshot_limit`: `100`,
+						},
+					},
+					{
+						name: `testpool/home/alice`,
+						results: map[string]string{
+							`snapdir`:        `hidden`,
+							`snapshot_count`: `4`,
+							`snapshot_limit`: `18446744073709551615`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_snapdir Whether the .zfs directory in the root of the filesystem is visible, providing access to its snapshots [0: hidden, 1: visible, 2: disabled].
+# TYPE zfs_dataset_snapdir gauge
+zfs_dataset_snapdir{name="testpool/home",pool="testpool",type="filesystem"} 1
+zfs_dataset_snapdir{name="testpool/home/alice",pool="testpool",type="filesystem"} 0
+# HELP zfs_dataset_snapshot_count_total The total number of snapshots that exist under this location in the dataset tree. This value is only available when a snapshot_limit has been set somewhere in the tree under which the dataset resides.
+# TYPE zfs_dataset_snapshot_count_total gauge
+zfs_dataset_snapshot_count_total{name="testpool/home",pool="testpool",type="filesystem"} 12
+zfs_dataset_snapshot_count_total{name="testpool/home/alice",pool="testpool",type="filesystem"} 4
+# HELP zfs_dataset_snapshot_limit_total The total limit on the number of snapshots that can be created on a dataset and its descendents. Not reported if no limit is set.
+# TYPE zfs_dataset_snapshot_limit_total gauge
+zfs_dataset_snapshot_limit_total{name="testpool/home",pool="testpool",type="filesystem"} 100
+`,
+		},
+		{
+			name:           `filesystem limits`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`filesystem_count`, `filesystem_limit`},
+			metricNames:    []string{`zfs_dataset_filesystem_count`, `zfs_dataset_filesystem_limit`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/home`,
+						results: map[string]string{
+							`filesystem_count`: `3`,
+							`filesystem_limit`: `10`,
+						},
+					},
+					{
+						name: `testpool/home/alice`,
+						results: map[string]string{
+							`filesystem_count`: `1`,
+							`filesystem_limit`: `18446744073709551615`,
+						},
+					},
+					{
+						name: `testpool/scratch`,
+						results: map[string]string{
+							`filesystem_count`: `0`,
+							`filesystem_limit`: `none`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_filesystem_count The total number of filesystems and volumes that exist under this location in the dataset tree. This value is only available when a filesystem_limit has been set somewhere in the tree under which the dataset resides.
+# TYPE zfs_dataset_filesystem_count gauge
+zfs_dataset_filesystem_count{name="testpool/home",pool="testpool",type="filesystem"} 3
+zfs_dataset_filesystem_count{name="testpool/home/alice",pool="testpool",type="filesystem"} 1
+zfs_dataset_filesystem_count{name="testpool/scratch",pool="testpool",type="filesystem"} 0
+# HELP zfs_dataset_filesystem_limit The limit on the number of filesystems and volumes that can exist under this point in the dataset tree. Not reported if no limit is set.
+# TYPE zfs_dataset_filesystem_limit gauge
+zfs_dataset_filesystem_limit{name="testpool/home",pool="testpool",type="filesystem"} 10
+`,
+		},
+		{
+			name:           `space breakdown`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`used`, `usedbychildren`, `usedbydataset`, `usedbyrefreservation`, `usedbysnapshots`},
+			metricNames:    []string{`zfs_dataset_used_bytes`, `zfs_dataset_used_by_children_bytes`, `zfs_dataset_used_by_dataset_bytes`, `zfs_dataset_used_by_referenced_reservation_bytes`, `zfs_dataset_used_by_snapshot_bytes`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/home`,
+						results: map[string]string{
+							`used`:                 `15360`,
+							`usedbychildren`:       `8192`,
+							`usedbydataset`:        `4096`,
+							`usedbyrefreservation`: `1024`,
+							`usedbysnapshots`:      `2048`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_used_by_children_bytes The amount of space in bytes used by children of this dataset, which would be freed if all the dataset's children were destroyed.
+# TYPE zfs_dataset_used_by_children_bytes gauge
+zfs_dataset_used_by_children_bytes{name="testpool/home",pool="testpool",type="filesystem"} 8192
+# HELP zfs_dataset_used_by_dataset_bytes The amount of space in bytes used by this dataset itself, which would be freed if the dataset were destroyed.
+# TYPE zfs_dataset_used_by_dataset_bytes gauge
+zfs_dataset_used_by_dataset_bytes{name="testpool/home",pool="testpool",type="filesystem"} 4096
+# HELP zfs_dataset_used_by_referenced_reservation_bytes The amount of space in bytes used by a refreservation set on this dataset, which would be freed if the refreservation was removed.
+# TYPE zfs_dataset_used_by_referenced_reservation_bytes gauge
+zfs_dataset_used_by_referenced_reservation_bytes{name="testpool/home",pool="testpool",type="filesystem"} 1024
+# HELP zfs_dataset_used_by_snapshot_bytes The amount of space in bytes consumed by snapshots of this dataset.
+# TYPE zfs_dataset_used_by_snapshot_bytes gauge
+zfs_dataset_used_by_snapshot_bytes{name="testpool/home",pool="testpool",type="filesystem"} 2048
+# HELP zfs_dataset_used_bytes The amount of space in bytes consumed by this dataset and all its descendents.
+# TYPE zfs_dataset_used_bytes gauge
+zfs_dataset_used_bytes{name="testpool/home",pool="testpool",type="filesystem"} 15360
+`,
+		},
+		{
+			name:           `written since snapshot`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`written`, `written@daily`},
+			metricNames:    []string{`zfs_dataset_written_bytes`, `zfs_dataset_written_since_snapshot_bytes`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/backup`,
+						results: map[string]string{
+							`written`:       `1048576`,
+							`written@daily`: `5242880`,
+						},
+					},
+					{
+						name: `testpool/scratch`,
+						results: map[string]string{
+							`written`:       `0`,
+							`written@daily`: `-`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_written_bytes The amount of referenced space in bytes written to this dataset since the previous snapshot.
+# TYPE zfs_dataset_written_bytes gauge
+zfs_dataset_written_bytes{name="testpool/backup",pool="testpool",type="filesystem"} 1.048576e+06
+zfs_dataset_written_bytes{name="testpool/scratch",pool="testpool",type="filesystem"} 0
+# HELP zfs_dataset_written_since_snapshot_bytes The amount of referenced space in bytes written to this dataset since the snapshot, selected by name as ` + "`written@<snapshot>`" + `. Datasets without the snapshot are not reported.
+# TYPE zfs_dataset_written_since_snapshot_bytes gauge
+zfs_dataset_written_since_snapshot_bytes{name="testpool/backup",pool="testpool",snapshot="daily",type="filesystem"} 5.24288e+06
+`,
+		},
+		{
+			name:           `clone origin`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`origin`, `used`},
+			metricNames:    []string{`zfs_dataset_origin_info`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/clone`,
+						results: map[string]string{
+							`origin`: `testpool/data@base`,
+							`used`:   `1024`,
+						},
+					},
+					{
+						name: `testpool/data`,
+						results: map[string]string{
+							`origin`: `-`,
+							`used`:   `4096`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_origin_info The snapshot from which the clone was created, reported as the origin label with a constant value of 1. Not reported for datasets that are not clones.
+# TYPE zfs_dataset_origin_info gauge
+zfs_dataset_origin_info{name="testpool/clone",origin="testpool/data@base",pool="testpool",type="filesystem"} 1
+`,
+		},
+		{
+			name:           `compression and encryption`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`compression`, `encryption`},
+			metricNames:    []string{`zfs_dataset_compression`, `zfs_dataset_encryption`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/secure`,
+						results: map[string]string{
+							`compression`: `zstd-3`,
+							`encryption`:  `aes-256-gcm`,
+						},
+					},
+					{
+						name: `testpool/plain`,
+						results: map[string]string{
+							`compression`: `off`,
+							`encryption`:  `off`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_compression The compression algorithm of the dataset, reported as the compression label with a constant value of 1, including "off" for uncompressed datasets.
+# TYPE zfs_dataset_compression gauge
+zfs_dataset_compression{compression="off",name="testpool/plain",pool="testpool",type="filesystem"} 1
+zfs_dataset_compression{compression="zstd-3",name="testpool/secure",pool="testpool",type="filesystem"} 1
+# HELP zfs_dataset_encryption The encryption cipher of the dataset, reported as the encryption label with a constant value of 1, including "off" for unencrypted datasets.
+# TYPE zfs_dataset_encryption gauge
+zfs_dataset_encryption{encryption="aes-256-gcm",name="testpool/secure",pool="testpool",type="filesystem"} 1
+zfs_dataset_encryption{encryption="off",name="testpool/plain",pool="testpool",type="filesystem"} 1
+`,
+		},
+		{
+			name:           `key status`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`keyformat`, `keylocation`, `keystatus`},
+			metricNames:    []string{`zfs_dataset_info`, `zfs_dataset_key_available`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/secure`,
+						results: map[string]string{
+							`keyformat`:   `passphrase`,
+							`keylocation`: `prompt`,
+							`keystatus`:   `unavailable`,
+						},
+					},
+					{
+						name: `testpool/unlocked`,
+						results: map[string]string{
+							`keyformat`:   `raw`,
+							`keylocation`: `file:///etc/zfs/keys/unlocked`,
+							`keystatus`:   `available`,
+						},
+					},
+					{
+						name: `testpool/plain`,
+						results: map[string]string{
+							`keyformat`:   `none`,
+							`keylocation`: `none`,
+							`keystatus`:   `-`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_info Information about the dataset from string properties, reported as labels with a constant value of 1.
+# TYPE zfs_dataset_info gauge
+zfs_dataset_info{keyformat="none",keylocation="none",name="testpool/plain",pool="testpool",type="filesystem"} 1
+zfs_dataset_info{keyformat="passphrase",keylocation="prompt",name="testpool/secure",pool="testpool",type="filesystem"} 1
+zfs_dataset_info{keyformat="raw",keylocation="file:///etc/zfs/keys/unlocked",name="testpool/unlocked",pool="testpool",type="filesystem"} 1
+# HELP zfs_dataset_key_available Whether the encryption key of the dataset is loaded, making its data accessible [0: unavailable, 1: available, 2: none].
+# TYPE zfs_dataset_key_available gauge
+zfs_dataset_key_available{name="testpool/secure",pool="testpool",type="filesystem"} 0
+zfs_dataset_key_available{name="testpool/unlocked",pool="testpool",type="filesystem"} 1
+`,
+		},
+		{
+			name:           `block sizes`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`recordsize`, `special_small_blocks`},
+			metricNames:    []string{`zfs_dataset_record_size_bytes`, `zfs_dataset_special_small_blocks_bytes`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/media`,
+						results: map[string]string{
+							`recordsize`:           `1048576`,
+							`special_small_blocks`: `65536`,
+						},
+					},
+					{
+						name: `testpool/home`,
+						results: map[string]string{
+							`recordsize`:           `131072`,
+							`special_small_blocks`: `0`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_record_size_bytes The suggested block size in bytes for files in the filesystem.
+# TYPE zfs_dataset_record_size_bytes gauge
+zfs_dataset_record_size_bytes{name="testpool/home",pool="testpool",type="filesystem"} 131072
+zfs_dataset_record_size_bytes{name="testpool/media",pool="testpool",type="filesystem"} 1.048576e+06
+# HELP zfs_dataset_special_small_blocks_bytes The threshold block size in bytes for including small file blocks in the special allocation class, 0 when disabled.
+# TYPE zfs_dataset_special_small_blocks_bytes gauge
+zfs_dataset_special_small_blocks_bytes{name="testpool/home",pool="testpool",type="filesystem"} 0
+zfs_dataset_special_small_blocks_bytes{name="testpool/media",pool="testpool",type="filesystem"} 65536
+`,
+		},
+		{
+			name:           `special small blocks unsupported by version`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			version:        &zfs.Version{Major: 0, Minor: 7, Patch: 13},
+			propsRequested: []string{`recordsize`, `special_small_blocks`},
+			propsFetched:   []string{`recordsize`},
+			metricNames:    []string{`zfs_dataset_record_size_bytes`, `zfs_dataset_special_small_blocks_bytes`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/media`,
+						results: map[string]string{
+							`recordsize`: `1048576`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_record_size_bytes The suggested block size in bytes for files in the filesystem.
+# TYPE zfs_dataset_record_size_bytes gauge
+zfs_dataset_record_size_bytes{name="testpool/media",pool="testpool",type="filesystem"} 1.048576e+06
+`,
+		},
+		{
+			name:           `volume block size`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetVolume},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`volblocksize`},
+			metricNames:    []string{`zfs_dataset_volume_block_size_bytes`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/vm`,
+						results: map[string]string{
+							`volblocksize`: `16384`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_volume_block_size_bytes The block size in bytes of the volume.
+# TYPE zfs_dataset_volume_block_size_bytes gauge
+zfs_dataset_volume_block_size_bytes{name="testpool/vm",pool="testpool",type="volume"} 16384
+`,
+		},
+		{
+			name:           `volume refreservation`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetVolume},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`refreservation`, `refreservation_ratio`, `usedbyrefreservation`, `volsize`},
+			propsFetched:   []string{`refreservation`, `volsize`, `usedbyrefreservation`},
+			metricNames:    []string{`zfs_dataset_referenced_reservation_bytes`, `zfs_dataset_referenced_reservation_ratio`, `zfs_dataset_used_by_referenced_reservation_bytes`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/thick`,
+						results: map[string]string{
+							`refreservation`:       `1090519040`,
+							`usedbyrefreservation`: `1073741824`,
+							`volsize`:              `1073741824`,
+						},
+					},
+					{
+						name: `testpool/sparse`,
+						results: map[string]string{
+							`refreservation`:       `none`,
+							`usedbyrefreservation`: `0`,
+							`volsize`:              `1073741824`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_referenced_reservation_bytes The minimum amount of space in bytes guaranteed to this dataset.
+# TYPE zfs_dataset_referenced_reservation_bytes gauge
+zfs_dataset_referenced_reservation_bytes{name="testpool/sparse",pool="testpool",type="volume"} 0
+zfs_dataset_referenced_reservation_bytes{name="testpool/thick",pool="testpool",type="volume"} 1.09051904e+09
+# HELP zfs_dataset_referenced_reservation_ratio The ratio of the refreservation to the size of the volume, 0 for thin-provisioned (sparse) volumes, and slightly over 1 for fully reserved volumes, whose refreservation includes metadata. Not reported for filesystems.
+# TYPE zfs_dataset_referenced_reservation_ratio gauge
+zfs_dataset_referenced_reservation_ratio{name="testpool/sparse",pool="testpool",type="volume"} 0
+zfs_dataset_referenced_reservation_ratio{name="testpool/thick",pool="testpool",type="volume"} 1.015625
+# HELP zfs_dataset_used_by_referenced_reservation_bytes The amount of space in bytes used by a refreservation set on this dataset, which would be freed if the refreservation was removed.
+# TYPE zfs_dataset_used_by_referenced_reservation_bytes gauge
+zfs_dataset_used_by_referenced_reservation_bytes{name="testpool/sparse",pool="testpool",type="volume"} 0
+zfs_dataset_used_by_referenced_reservation_bytes{name="testpool/thick",pool="testpool",type="volume"} 1.073741824e+09
+`,
+		},
+		{
+			name:           `quota usage`,
+			kinds:          []zfs.DatasetKind{zfs.DatasetFilesystem},
+			pools:          []string{`testpool`},
+			propsRequested: []string{`quota`, `quota_used`, `reservation`},
+			propsFetched:   []string{`quota`, `used`, `reservation`},
+			metricNames:    []string{`zfs_dataset_quota_bytes`, `zfs_dataset_quota_used_ratio`, `zfs_dataset_reservation_bytes`},
+			propsResults: map[string][]datasetResults{
+				`testpool`: {
+					{
+						name: `testpool/team-a`,
+						results: map[string]string{
+							`quota`:       `4096`,
+							`reservation`: `1024`,
+							`used`:        `3072`,
+						},
+					},
+					{
+						name: `testpool/team-b`,
+						results: map[string]string{
+							`quota`:       `0`,
+							`reservation`: `0`,
+							`used`:        `2048`,
+						},
+					},
+					{
+						name: `testpool/team-c`,
+						results: map[string]string{
+							`quota`:       `none`,
+							`reservation`: `none`,
+							`used`:        `1024`,
+						},
+					},
+				},
+			},
+			metricResults: `# HELP zfs_dataset_quota_bytes The maximum amount of space in bytes this dataset and its descendents can consume.
+# TYPE zfs_dataset_quota_bytes gauge
+zfs_dataset_quota_bytes{name="testpool/team-a",pool="testpool",type="filesystem"} 4096
+zfs_dataset_quota_bytes{name="testpool/team-b",pool="testpool",type="filesystem"} 0
+zfs_dataset_quota_bytes{name="testpool/team-c",pool="testpool",type="filesystem"} 0
+# HELP zfs_dataset_quota_used_ratio The ratio of space consumed by this dataset and all its descendents to its quota. Not reported for datasets without a quota.
+# TYPE zfs_dataset_quota_used_ratio gauge
+zfs_dataset_quota_used_ratio{name="testpool/team-a",pool="testpool",type="filesystem"} 0.75
+# HELP zfs_dataset_reservation_bytes The minimum amount of space in bytes guaranteed to a dataset and its descendants.
+# TYPE zfs_dataset_reservation_bytes gauge
+zfs_dataset_reservation_bytes{name="testpool/team-a",pool="testpool",type="filesystem"} 1024
+zfs_dataset_reservation_bytes{name="testpool/team-b",pool="testpool",type="filesystem"} 0
+zfs_dataset_reservation_bytes{name="testpool/team-c",pool="testpool",type="filesystem"} 0
 `,
 		},
 		{
@@ -221,6 +869,15 @@ zfs_dataset_unsupported{name="testpool/test",pool="testpool",type="filesystem"}
 			}
 
 			zfsClient.EXPECT().PoolNames().Return(tc.pools, nil).Times(1)
+			version := zfs.Version{Major: 2, Minor: 2, Patch: 2}
+			if tc.version != nil {
+				version = *tc.version
+			}
+			zfsClient.EXPECT().Version().Return(version, nil).AnyTimes()
+			propsFetched := tc.propsRequested
+			if tc.propsFetched != nil {
+				propsFetched = tc.propsFetched
+			}
 			collector, err := NewZFS(config)
 			if err != nil {
 				t.Fatal(err)
@@ -272,7 +929,7 @@ zfs_dataset_unsupported{name="testpool/test",pool="testpool",type="filesystem"}
 						zfsDatasetResults[i] = zfsDatasetProperties
 					}
 					zfsDatasets := mock_zfs.NewMockDatasets(ctrl)
-					zfsDatasets.EXPECT().Properties(tc.propsRequested).Return(zfsDatasetResults, nil).Times(1)
+					zfsDatasets.EXPECT().Properties(propsFetched).Return(zfsDatasetResults, nil).Times(1)
 					zfsClient.EXPECT().Datasets(pool, kind).Return(zfsDatasets).Times(1)
 				}
 			}
@@ -283,3 +940,51 @@ zfs_dataset_unsupported{name="testpool/test",pool="testpool",type="filesystem"}
 		})
 	}
 }
+
+func TestSnapshotAggregateMetrics(t *testing.T) {
+	const result = `# HELP zfs_dataset_snapshot_count Number of snapshots of the dataset.
+# TYPE zfs_dataset_snapshot_count gauge
+zfs_dataset_snapshot_count{name="testpool/data",pool="testpool"} 3
+zfs_dataset_snapshot_count{name="testpool/home",pool="testpool"} 2
+`
+	snapshots := []string{
+		`testpool/data@daily-1`,
+		`testpool/data@daily-2`,
+		`testpool/data@hourly-1`,
+		`testpool/home@daily-1`,
+		`testpool/home@daily-2`,
+	}
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsClient.EXPECT().Version().Return(zfs.Version{Major: 2, Minor: 2, Patch: 2}, nil).AnyTimes()
+	results := make([]zfs.DatasetProperties, 0, len(snapshots))
+	for _, name := range snapshots {
+		zfsDatasetProperties := mock_zfs.NewMockDatasetProperties(ctrl)
+		zfsDatasetProperties.EXPECT().DatasetName().Return(name).Times(2)
+		results = append(results, zfsDatasetProperties)
+	}
+	zfsDatasets := mock_zfs.NewMockDatasets(ctrl)
+	zfsDatasets.EXPECT().Properties(`name`).Return(results, nil).Times(1)
+	zfsClient.EXPECT().Datasets(`testpool`, zfs.DatasetSnapshot).Return(zfsDatasets).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`dataset-snapshot`: {
+			Name:       "dataset-snapshot",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(defaultSnapshotProps),
+			factory: func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+				return &datasetCollector{kind: zfs.DatasetSnapshot, log: l, client: c, props: props, aggregate: true}, nil
+			},
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_dataset_snapshot_count`, `zfs_dataset_used_bytes`}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolDDTEntriesDescName = prometheus.BuildFQName(namespace, subsystemPool, `ddt_entries`)
+	poolDDTEntriesDesc     = prometheus.NewDesc(
+		poolDDTEntriesDescName,
+		`Number of entries in the pool deduplication table.`,
+		poolLabels,
+		nil,
+	)
+	poolDDTSizeDescName = prometheus.BuildFQName(namespace, subsystemPool, `ddt_size_bytes`)
+	poolDDTSizeDesc     = prometheus.NewDesc(
+		poolDDTSizeDescName,
+		`Approximate size in bytes of the pool deduplication table in memory.`,
+		poolLabels,
+		nil,
+	)
+	poolDDTDiskSizeDescName = prometheus.BuildFQName(namespace, subsystemPool, `ddt_disk_size_bytes`)
+	poolDDTDiskSizeDesc     = prometheus.NewDesc(
+		poolDDTDiskSizeDescName,
+		`Approximate size in bytes of the pool deduplication table on disk.`,
+		poolLabels,
+		nil,
+	)
+	poolDDTHitRatioDescName = prometheus.BuildFQName(namespace, subsystemPool, `ddt_hit_ratio`)
+	poolDDTHitRatioDesc     = prometheus.NewDesc(
+		poolDDTHitRatioDescName,
+		`Ratio of blocks referenced by the pool that matched an existing deduplication table entry, rather than being stored.`,
+		poolLabels,
+		nil,
+	)
+	poolDDTMissRatioDescName = prometheus.BuildFQName(namespace, subsystemPool, `ddt_miss_ratio`)
+	poolDDTMissRatioDesc     = prometheus.NewDesc(
+		poolDDTMissRatioDescName,
+		`Ratio of blocks referenced by the pool that did not match an existing deduplication table entry, and were stored.`,
+		poolLabels,
+		nil,
+	)
+)
+
+func init() {
+	registerCollector(`pool-dedup`, defaultDisabled, ``, nil, newPoolDedupCollector)
+}
+
+// poolDedupCollector reports deduplication table statistics from `zpool status -D`.
+type poolDedupCollector struct {
+	log    log.Logger
+	client zfs.Client
+}
+
+func (c *poolDedupCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- poolDDTEntriesDesc
+	ch <- poolDDTSizeDesc
+	ch <- poolDDTDiskSizeDesc
+	ch <- poolDDTHitRatioDesc
+	ch <- poolDDTMissRatioDesc
+}
+
+func (c *poolDedupCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool); err != nil && !isPoolMissing(c.log, `pool-dedup`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *poolDedupCollector) updatePoolMetrics(ch chan<- metric, pool string) error {
+	status, err := c.client.Pool(pool).Status(zfs.StatusDedup)
+	if err != nil {
+		return err
+	}
+	// Pools without deduplicated data have no DDT, and report nothing.
+	dedup := status.Dedup
+	if dedup == nil {
+		return nil
+	}
+
+	ch <- metric{
+		name:       expandMetricName(poolDDTEntriesDescName, pool),
+		prometheus: prometheus.MustNewConstMetric(poolDDTEntriesDesc, prometheus.GaugeValue, float64(dedup.Entries), pool),
+	}
+	// Sizes are reported per entry.
+	ch <- metric{
+		name:       expandMetricName(poolDDTSizeDescName, pool),
+		prometheus: prometheus.MustNewConstMetric(poolDDTSizeDesc, prometheus.GaugeValue, float64(dedup.Entries*dedup.EntryCoreBytes), pool),
+	}
+	ch <- metric{
+		name:       expandMetricName(poolDDTDiskSizeDescName, pool),
+		prometheus: prometheus.MustNewConstMetric(poolDDTDiskSizeDesc, prometheus.GaugeValue, float64(dedup.Entries*dedup.EntryDiskBytes), pool),
+	}
+	if dedup.ReferencedBlocks > 0 {
+		missRatio := float64(dedup.AllocatedBlocks) / float64(dedup.ReferencedBlocks)
+		ch <- metric{
+			name:       expandMetricName(poolDDTHitRatioDescName, pool),
+			prometheus: prometheus.MustNewConstMetric(poolDDTHitRatioDesc, prometheus.GaugeValue, 1-missRatio, pool),
+		}
+		ch <- metric{
+			name:       expandMetricName(poolDDTMissRatioDescName, pool),
+			prometheus: prometheus.MustNewConstMetric(poolDDTMissRatioDesc, prometheus.GaugeValue, missRatio, pool),
+		}
+	}
+
+	return nil
+}
+
+func newPoolDedupCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	return &poolDedupCollector{log: l, client: c}, nil
+}
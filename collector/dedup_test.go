@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestPoolDedupMetrics(t *testing.T) {
+	const result = `# HELP zfs_pool_ddt_disk_size_bytes Approximate size in bytes of the pool deduplication table on disk.
+# TYPE zfs_pool_ddt_disk_size_bytes gauge
+zfs_pool_ddt_disk_size_bytes{pool="dedup"} 651264
+# HELP zfs_pool_ddt_entries Number of entries in the pool deduplication table.
+# TYPE zfs_pool_ddt_entries gauge
+zfs_pool_ddt_entries{pool="dedup"} 1536
+# HELP zfs_pool_ddt_hit_ratio Ratio of blocks referenced by the pool that matched an existing deduplication table entry, rather than being stored.
+# TYPE zfs_pool_ddt_hit_ratio gauge
+zfs_pool_ddt_hit_ratio{pool="dedup"} 0.25
+# HELP zfs_pool_ddt_miss_ratio Ratio of blocks referenced by the pool that did not match an existing deduplication table entry, and were stored.
+# TYPE zfs_pool_ddt_miss_ratio gauge
+zfs_pool_ddt_miss_ratio{pool="dedup"} 0.75
+# HELP zfs_pool_ddt_size_bytes Approximate size in bytes of the pool deduplication table in memory.
+# TYPE zfs_pool_ddt_size_bytes gauge
+zfs_pool_ddt_size_bytes{pool="dedup"} 208896
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`dedup`, `nodedup`}, nil).Times(1)
+	dedupPool := mock_zfs.NewMockPool(ctrl)
+	dedupPool.EXPECT().Status(zfs.StatusDedup).Return(&zfs.Status{Dedup: &zfs.DedupStatus{
+		Entries:          1536,
+		EntryDiskBytes:   424,
+		EntryCoreBytes:   136,
+		AllocatedBlocks:  1536,
+		ReferencedBlocks: 2048,
+	}}, nil).Times(1)
+	zfsClient.EXPECT().Pool(`dedup`).Return(dedupPool).Times(1)
+	noDedupPool := mock_zfs.NewMockPool(ctrl)
+	noDedupPool.EXPECT().Status(zfs.StatusDedup).Return(&zfs.Status{}, nil).Times(1)
+	zfsClient.EXPECT().Pool(`nodedup`).Return(noDedupPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool-dedup`: {
+			Name:    "pool-dedup",
+			Enabled: boolPointer(true),
+			factory: newPoolDedupCollector,
+		},
+	}
+
+	metricNames := []string{
+		`zfs_pool_ddt_disk_size_bytes`,
+		`zfs_pool_ddt_entries`,
+		`zfs_pool_ddt_hit_ratio`,
+		`zfs_pool_ddt_miss_ratio`,
+		`zfs_pool_ddt_size_bytes`,
+	}
+	if err = callCollector(ctx, collector, []byte(result), metricNames); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const spareAvailable = `AVAIL`
+
+var (
+	poolDeviceLabels           = []string{`pool`, `device`}
+	poolSpareAvailableDescName = prometheus.BuildFQName(namespace, subsystemPool, `spare_available`)
+	poolSpareAvailableDesc     = prometheus.NewDesc(
+		poolSpareAvailableDescName,
+		`Whether the hot spare is available for use [0: in use or unavailable, 1: available].`,
+		poolDeviceLabels,
+		nil,
+	)
+	poolDistributedSpareAvailableDescName = prometheus.BuildFQName(namespace, subsystemPool, `draid_spare_available`)
+	poolDistributedSpareAvailableDesc     = prometheus.NewDesc(
+		poolDistributedSpareAvailableDescName,
+		`Whether the dRAID distributed spare is available for use [0: in use or unavailable, 1: available].`,
+		poolDeviceLabels,
+		nil,
+	)
+	poolCacheDeviceStateDescName = prometheus.BuildFQName(namespace, subsystemPool, `cache_device_state`)
+	poolCacheDeviceStateDesc     = prometheus.NewDesc(
+		poolCacheDeviceStateDescName,
+		fmt.Sprintf(`Health status code for the cache (L2ARC) device %s.`, healthCodeHelp),
+		poolDeviceLabels,
+		nil,
+	)
+	poolLogDeviceStateDescName = prometheus.BuildFQName(namespace, subsystemPool, `log_device_state`)
+	poolLogDeviceStateDesc     = prometheus.NewDesc(
+		poolLogDeviceStateDescName,
+		fmt.Sprintf(`Health status code for the separate intent log (SLOG) device %s.`, healthCodeHelp),
+		poolDeviceLabels,
+		nil,
+	)
+	poolVdevCountDescName = prometheus.BuildFQName(namespace, subsystemPool, `vdev_count`)
+	poolVdevCountDesc     = prometheus.NewDesc(
+		poolVdevCountDescName,
+		`Number of top-level data vdevs of the pool of each layout type (ie - mirror, raidz2, draid1 or disk).`,
+		[]string{`pool`, `type`},
+		nil,
+	)
+)
+
+func init() {
+	registerCollector(`pool-devices`, defaultDisabled, ``, nil, newPoolDevicesCollector)
+}
+
+// poolDevicesCollector reports the state of the auxiliary devices of each pool from `zpool status`, whose health is
+// not reflected by the health of the pool, and the layout of its data vdevs.
+type poolDevicesCollector struct {
+	log    log.Logger
+	client zfs.Client
+}
+
+func (c *poolDevicesCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- poolSpareAvailableDesc
+	ch <- poolDistributedSpareAvailableDesc
+	ch <- poolCacheDeviceStateDesc
+	ch <- poolLogDeviceStateDesc
+	ch <- poolVdevCountDesc
+}
+
+func (c *poolDevicesCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool); err != nil && !isPoolMissing(c.log, `pool-devices`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *poolDevicesCollector) updatePoolMetrics(ch chan<- metric, pool string) error {
+	status, err := c.client.Pool(pool).Status()
+	if err != nil {
+		return err
+	}
+
+	layouts := make(map[string]int)
+	for _, vdev := range status.Vdevs {
+		switch vdev.Class {
+		case zfs.VdevClassData:
+			layouts[vdev.Layout()]++
+		case zfs.VdevClassSpare:
+			var value float64
+			if vdev.State == spareAvailable {
+				value = 1
+			}
+			descName, desc := poolSpareAvailableDescName, poolSpareAvailableDesc
+			if vdev.DistributedSpare() {
+				descName, desc = poolDistributedSpareAvailableDescName, poolDistributedSpareAvailableDesc
+			}
+			labelValues := []string{pool, vdev.Name}
+			ch <- metric{
+				name:       expandMetricName(descName, labelValues...),
+				prometheus: prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labelValues...),
+			}
+		case zfs.VdevClassCache:
+			if err = pushDeviceStates(ch, poolCacheDeviceStateDescName, poolCacheDeviceStateDesc, pool, vdev); err != nil {
+				return err
+			}
+		case zfs.VdevClassLog:
+			if err = pushDeviceStates(ch, poolLogDeviceStateDescName, poolLogDeviceStateDesc, pool, vdev); err != nil {
+				return err
+			}
+		}
+	}
+
+	for layout, count := range layouts {
+		labelValues := []string{pool, layout}
+		ch <- metric{
+			name:       expandMetricName(poolVdevCountDescName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(poolVdevCountDesc, prometheus.GaugeValue, float64(count), labelValues...),
+		}
+	}
+
+	return nil
+}
+
+// pushDeviceStates reports the health of a vdev and each of its children, so that the failure of a single device of a
+// mirror is visible.
+func pushDeviceStates(ch chan<- metric, descName string, desc *prometheus.Desc, pool string, vdev zfs.Vdev) error {
+	value, err := transformHealthCode(vdev.State)
+	if err != nil {
+		return err
+	}
+	labelValues := []string{pool, vdev.Name}
+	ch <- metric{
+		name:       expandMetricName(descName, labelValues...),
+		prometheus: prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labelValues...),
+	}
+	for _, child := range vdev.Children {
+		if err = pushDeviceStates(ch, descName, desc, pool, child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newPoolDevicesCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	return &poolDevicesCollector{log: l, client: c}, nil
+}
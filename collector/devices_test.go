@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestPoolDevicesMetrics(t *testing.T) {
+	const result = `# HELP zfs_pool_cache_device_state Health status code for the cache (L2ARC) device [0: ONLINE, 1: DEGRADED, 2: FAULTED, 3: OFFLINE, 4: UNAVAIL, 5: REMOVED, 6: SUSPENDED].
+# TYPE zfs_pool_cache_device_state gauge
+zfs_pool_cache_device_state{device="nvme2n1",pool="testpool"} 0
+# HELP zfs_pool_draid_spare_available Whether the dRAID distributed spare is available for use [0: in use or unavailable, 1: available].
+# TYPE zfs_pool_draid_spare_available gauge
+zfs_pool_draid_spare_available{device="draid2-0-0",pool="testpool"} 0
+zfs_pool_draid_spare_available{device="draid2-0-1",pool="testpool"} 1
+# HELP zfs_pool_log_device_state Health status code for the separate intent log (SLOG) device [0: ONLINE, 1: DEGRADED, 2: FAULTED, 3: OFFLINE, 4: UNAVAIL, 5: REMOVED, 6: SUSPENDED].
+# TYPE zfs_pool_log_device_state gauge
+zfs_pool_log_device_state{device="mirror-3",pool="testpool"} 1
+zfs_pool_log_device_state{device="nvme0n1",pool="testpool"} 0
+zfs_pool_log_device_state{device="nvme1n1",pool="testpool"} 2
+# HELP zfs_pool_spare_available Whether the hot spare is available for use [0: in use or unavailable, 1: available].
+# TYPE zfs_pool_spare_available gauge
+zfs_pool_spare_available{device="sdc",pool="testpool"} 1
+zfs_pool_spare_available{device="sdd",pool="testpool"} 0
+# HELP zfs_pool_vdev_count Number of top-level data vdevs of the pool of each layout type (ie - mirror, raidz2, draid1 or disk).
+# TYPE zfs_pool_vdev_count gauge
+zfs_pool_vdev_count{pool="testpool",type="mirror"} 1
+zfs_pool_vdev_count{pool="testpool",type="raidz2"} 2
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Status().Return(&zfs.Status{Vdevs: []zfs.Vdev{
+		{Name: `mirror-0`, Class: zfs.VdevClassData, State: `ONLINE`, Children: []zfs.Vdev{
+			{Name: `sda`, Class: zfs.VdevClassData, State: `ONLINE`},
+			{Name: `sdb`, Class: zfs.VdevClassData, State: `ONLINE`},
+		}},
+		{Name: `raidz2-1`, Class: zfs.VdevClassData, State: `ONLINE`, Children: []zfs.Vdev{
+			{Name: `sde`, Class: zfs.VdevClassData, State: `ONLINE`},
+			{Name: `sdf`, Class: zfs.VdevClassData, State: `ONLINE`},
+			{Name: `sdg`, Class: zfs.VdevClassData, State: `ONLINE`},
+			{Name: `sdh`, Class: zfs.VdevClassData, State: `ONLINE`},
+		}},
+		{Name: `raidz2-2`, Class: zfs.VdevClassData, State: `ONLINE`, Children: []zfs.Vdev{
+			{Name: `sdi`, Class: zfs.VdevClassData, State: `ONLINE`},
+			{Name: `sdj`, Class: zfs.VdevClassData, State: `ONLINE`},
+			{Name: `sdk`, Class: zfs.VdevClassData, State: `ONLINE`},
+			{Name: `sdl`, Class: zfs.VdevClassData, State: `ONLINE`},
+		}},
+		{Name: `mirror-3`, Class: zfs.VdevClassLog, State: `DEGRADED`, Children: []zfs.Vdev{
+			{Name: `nvme0n1`, Class: zfs.VdevClassLog, State: `ONLINE`},
+			{Name: `nvme1n1`, Class: zfs.VdevClassLog, State: `FAULTED`},
+		}},
+		{Name: `nvme2n1`, Class: zfs.VdevClassCache, State: `ONLINE`},
+		{Name: `sdc`, Class: zfs.VdevClassSpare, State: `AVAIL`},
+		{Name: `sdd`, Class: zfs.VdevClassSpare, State: `INUSE`},
+		{Name: `draid2-0-0`, Class: zfs.VdevClassSpare, State: `INUSE`},
+		{Name: `draid2-0-1`, Class: zfs.VdevClassSpare, State: `AVAIL`},
+	}}, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool-devices`: {
+			Name:    "pool-devices",
+			Enabled: boolPointer(true),
+			factory: newPoolDevicesCollector,
+		},
+	}
+
+	metricNames := []string{
+		`zfs_pool_cache_device_state`,
+		`zfs_pool_draid_spare_available`,
+		`zfs_pool_log_device_state`,
+		`zfs_pool_spare_available`,
+		`zfs_pool_vdev_count`,
+	}
+	if err = callCollector(ctx, collector, []byte(result), metricNames); err != nil {
+		t.Fatal(err)
+	}
+}
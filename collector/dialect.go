@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+)
+
+const (
+	// zonedProperty is the dataset property indicating delegation to a zone (illumos) or user namespace (Linux).
+	zonedProperty = `zoned`
+	// jailedProperty is the name of zonedProperty on FreeBSD, indicating delegation to a jail.
+	jailedProperty = `jailed`
+)
+
+// datasetDialect maps the names of dataset properties in the property store to the names accepted by the target, which
+// may run a different platform to the exporter when commands are run remotely. Properties not mapped are unchanged.
+type datasetDialect map[string]string
+
+// newDatasetDialect returns the dialect of the target, determined from its ZFS version when any of the fetched props
+// differ between platforms. Targets whose version cannot be determined are assumed not to be FreeBSD.
+func newDatasetDialect(l log.Logger, collector string, client zfs.Client, fetch []string) datasetDialect {
+	if !hasProperty(fetch, zonedProperty) {
+		return nil
+	}
+	v, err := client.Version()
+	if err != nil {
+		_ = level.Debug(l).Log(`msg`, `Could not determine ZFS version, assuming platform is not FreeBSD`, `collector`, collector, `err`, err)
+		return nil
+	}
+	if !v.FreeBSD() {
+		return nil
+	}
+
+	return datasetDialect{zonedProperty: jailedProperty}
+}
+
+// translate returns props named as accepted by the target.
+func (d datasetDialect) translate(props []string) []string {
+	if len(d) == 0 {
+		return props
+	}
+	result := make([]string, len(props))
+	for i, name := range props {
+		if target, ok := d[name]; ok {
+			name = target
+		}
+		result[i] = name
+	}
+	return result
+}
+
+// values returns the values fetched from the target keyed by the names in the property store.
+func (d datasetDialect) values(values map[string]string) map[string]string {
+	if len(d) == 0 {
+		return values
+	}
+	result := make(map[string]string, len(values))
+	for k, v := range values {
+		result[k] = v
+	}
+	for name, target := range d {
+		if v, ok := values[target]; ok {
+			delete(result, target)
+			result[name] = v
+		}
+	}
+	return result
+}
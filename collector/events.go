@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolEventsLabels   = []string{`pool`, `class`}
+	poolEventsDescName = prometheus.BuildFQName(namespace, subsystemPool, `events_total`)
+	poolEventsDesc     = prometheus.NewDesc(
+		poolEventsDescName,
+		`Number of events logged for the pool by class since the exporter started, including those present in the event log at startup.`,
+		poolEventsLabels,
+		nil,
+	)
+)
+
+func init() {
+	registerCollector(`pool-events`, defaultDisabled, ``, nil, newPoolEventsFactory())
+}
+
+// poolEvents holds a cursor into the event log of each pool, and the resulting counts of events by class. It outlives
+// the collectors created for each scrape.
+type poolEvents struct {
+	sync.Mutex
+	// cursors maps pool names to the position in the event log following the last counted event.
+	cursors map[string]poolEventsCursor
+	// counts maps pool names to the number of events counted for the pool, by class.
+	counts map[string]map[string]uint64
+}
+
+// poolEventsCursor identifies the last counted event by its time. Events may share a timestamp, so the number of
+// counted events sharing the time is also held.
+type poolEventsCursor struct {
+	time  time.Time
+	count int
+}
+
+// update counts the events following the cursor for a pool, advances the cursor, and returns the resulting counts.
+// Events are ordered oldest first, and events discarded from the log are not re-counted.
+func (e *poolEvents) update(pool string, events []zfs.Event) map[string]uint64 {
+	e.Lock()
+	defer e.Unlock()
+
+	counts, ok := e.counts[pool]
+	if !ok {
+		counts = make(map[string]uint64)
+		e.counts[pool] = counts
+	}
+
+	cursor := e.cursors[pool]
+	next := cursor
+	ties := 0
+	for _, event := range events {
+		if event.Time.Before(cursor.time) {
+			continue
+		}
+		if event.Time.Equal(cursor.time) {
+			ties++
+			if ties <= cursor.count {
+				continue
+			}
+		}
+		counts[event.Class]++
+
+		if event.Time.Equal(next.time) {
+			next.count++
+		} else {
+			next = poolEventsCursor{time: event.Time, count: 1}
+		}
+	}
+	e.cursors[pool] = next
+
+	result := make(map[string]uint64, len(counts))
+	for class, count := range counts {
+		result[class] = count
+	}
+	return result
+}
+
+// poolEventsCollector reports the number of events logged by class for each pool, via `zpool events`.
+type poolEventsCollector struct {
+	log    log.Logger
+	client zfs.Client
+	events *poolEvents
+}
+
+func (c *poolEventsCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- poolEventsDesc
+}
+
+func (c *poolEventsCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool); err != nil && !isPoolMissing(c.log, `pool-events`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *poolEventsCollector) updatePoolMetrics(ch chan<- metric, pool string) error {
+	events, err := c.client.Pool(pool).Events()
+	if err != nil {
+		return err
+	}
+
+	for class, count := range c.events.update(pool, events) {
+		labelValues := []string{pool, class}
+		ch <- metric{
+			name:       expandMetricName(poolEventsDescName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(poolEventsDesc, prometheus.CounterValue, float64(count), labelValues...),
+		}
+	}
+
+	return nil
+}
+
+// newPoolEventsFactory returns a factory for collectors sharing event log cursors across scrapes.
+func newPoolEventsFactory() factoryFunc {
+	events := &poolEvents{
+		cursors: make(map[string]poolEventsCursor),
+		counts:  make(map[string]map[string]uint64),
+	}
+	return func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+		return &poolEventsCollector{log: l, client: c, events: events}, nil
+	}
+}
@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestPoolEventsMetrics(t *testing.T) {
+	at := func(sec, nsec int) time.Time {
+		return time.Date(2021, time.October, 10, 10, 0, sec, nsec, time.UTC)
+	}
+	scrapes := []struct {
+		events        []zfs.Event
+		metricResults string
+	}{
+		{
+			events: []zfs.Event{
+				{Time: at(0, 0), Class: `history_event`},
+				{Time: at(1, 5), Class: `checksum`},
+				{Time: at(1, 5), Class: `checksum`},
+			},
+			metricResults: `# HELP zfs_pool_events_total Number of events logged for the pool by class since the exporter started, including those present in the event log at startup.
+# TYPE zfs_pool_events_total counter
+zfs_pool_events_total{class="checksum",pool="testpool"} 2
+zfs_pool_events_total{class="history_event",pool="testpool"} 1
+`,
+		},
+		{
+			// The oldest event has been discarded from the log, and a further event shares the time of the last
+			// counted events.
+			events: []zfs.Event{
+				{Time: at(1, 5), Class: `checksum`},
+				{Time: at(1, 5), Class: `checksum`},
+				{Time: at(1, 5), Class: `checksum`},
+				{Time: at(2, 0), Class: `io`},
+				{Time: at(3, 0), Class: `probe_failure`},
+			},
+			metricResults: `# HELP zfs_pool_events_total Number of events logged for the pool by class since the exporter started, including those present in the event log at startup.
+# TYPE zfs_pool_events_total counter
+zfs_pool_events_total{class="checksum",pool="testpool"} 3
+zfs_pool_events_total{class="history_event",pool="testpool"} 1
+zfs_pool_events_total{class="io",pool="testpool"} 1
+zfs_pool_events_total{class="probe_failure",pool="testpool"} 1
+`,
+		},
+		{
+			// No new events.
+			events: []zfs.Event{
+				{Time: at(2, 0), Class: `io`},
+				{Time: at(3, 0), Class: `probe_failure`},
+			},
+			metricResults: `# HELP zfs_pool_events_total Number of events logged for the pool by class since the exporter started, including those present in the event log at startup.
+# TYPE zfs_pool_events_total counter
+zfs_pool_events_total{class="checksum",pool="testpool"} 3
+zfs_pool_events_total{class="history_event",pool="testpool"} 1
+zfs_pool_events_total{class="io",pool="testpool"} 1
+zfs_pool_events_total{class="probe_failure",pool="testpool"} 1
+`,
+		},
+	}
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool-events`: {
+			Name:    "pool-events",
+			Enabled: boolPointer(true),
+			factory: newPoolEventsFactory(),
+		},
+	}
+
+	for _, scrape := range scrapes {
+		zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+		zfsPool := mock_zfs.NewMockPool(ctrl)
+		zfsPool.EXPECT().Events().Return(scrape.events, nil).Times(1)
+		zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+		if err = callCollector(ctx, collector, []byte(scrape.metricResults), []string{`zfs_pool_events_total`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
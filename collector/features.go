@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	featureStates = []string{zfs.FeatureDisabled, zfs.FeatureEnabled, zfs.FeatureActive}
+
+	poolFeatureLabels        = []string{`pool`, `feature`}
+	poolFeatureStateDescName = prometheus.BuildFQName(namespace, subsystemPool, `feature_state`)
+	poolFeatureStateDesc     = prometheus.NewDesc(
+		poolFeatureStateDescName,
+		fmt.Sprintf(`State of the pool feature flag %s.`, enumHelp(featureStates...)),
+		poolFeatureLabels,
+		nil,
+	)
+	transformFeatureState = transformEnum(featureStates...)
+)
+
+func init() {
+	registerCollector(`pool-features`, defaultDisabled, ``, nil, newPoolFeaturesCollector)
+}
+
+// poolFeaturesCollector reports the state of the feature flags of each pool, for upgrade planning. Pools with a legacy
+// on-disk version have no feature flags, and report nothing.
+type poolFeaturesCollector struct {
+	log    log.Logger
+	client zfs.Client
+}
+
+func (c *poolFeaturesCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- poolFeatureStateDesc
+}
+
+func (c *poolFeaturesCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool); err != nil && !isPoolMissing(c.log, `pool-features`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *poolFeaturesCollector) updatePoolMetrics(ch chan<- metric, pool string) error {
+	features, err := c.client.Pool(pool).Features()
+	if err != nil {
+		return err
+	}
+
+	for feature, state := range features {
+		value, err := transformFeatureState(state)
+		if err == ErrValueUnavailable {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		labelValues := []string{pool, feature}
+		ch <- metric{
+			name:       expandMetricName(poolFeatureStateDescName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(poolFeatureStateDesc, prometheus.GaugeValue, value, labelValues...),
+		}
+	}
+
+	return nil
+}
+
+func newPoolFeaturesCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	return &poolFeaturesCollector{log: l, client: c}, nil
+}
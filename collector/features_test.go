@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestPoolFeaturesMetrics(t *testing.T) {
+	testCases := []struct {
+		name          string
+		features      map[string]string
+		metricResults string
+	}{
+		{
+			name: `feature flags`,
+			features: map[string]string{
+				`async_destroy`: zfs.FeatureEnabled,
+				`lz4_compress`:  zfs.FeatureActive,
+				`draid`:         zfs.FeatureDisabled,
+				`unknown`:       `-`,
+			},
+			metricResults: `# HELP zfs_pool_feature_state State of the pool feature flag [0: disabled, 1: enabled, 2: active].
+# TYPE zfs_pool_feature_state gauge
+zfs_pool_feature_state{feature="async_destroy",pool="testpool"} 1
+zfs_pool_feature_state{feature="draid",pool="testpool"} 0
+zfs_pool_feature_state{feature="lz4_compress",pool="testpool"} 2
+`,
+		},
+		{
+			name:          `legacy version`,
+			features:      map[string]string{},
+			metricResults: ``,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl, ctx := gomock.WithContext(context.Background(), t)
+			zfsClient := mock_zfs.NewMockClient(ctrl)
+			zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+			zfsPool := mock_zfs.NewMockPool(ctrl)
+			zfsPool.EXPECT().Features().Return(tc.features, nil).Times(1)
+			zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+			collector, err := NewZFS(defaultConfig(zfsClient))
+			if err != nil {
+				t.Fatal(err)
+			}
+			collector.Collectors = map[string]State{
+				`pool-features`: {
+					Name:    "pool-features",
+					Enabled: boolPointer(true),
+					factory: newPoolFeaturesCollector,
+				},
+			}
+
+			if err = callCollector(ctx, collector, []byte(tc.metricResults), []string{`zfs_pool_feature_state`}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// collectorInfo describes a registered collector for the collectors endpoint.
+type collectorInfo struct {
+	Name              string         `json:"name"`
+	DefaultEnabled    bool           `json:"default_enabled"`
+	Enabled           bool           `json:"enabled"`
+	DefaultProperties []string       `json:"default_properties,omitempty"`
+	Properties        []string       `json:"properties,omitempty"`
+	Available         []propertyInfo `json:"available_properties,omitempty"`
+}
+
+// propertyInfo describes a property that may be selected for a collector.
+type propertyInfo struct {
+	Name   string   `json:"name"`
+	Metric string   `json:"metric"`
+	Help   string   `json:"help,omitempty"`
+	Label  string   `json:"label,omitempty"`
+	Inputs []string `json:"inputs,omitempty"`
+}
+
+// NewCollectorsHandler returns an http.Handler that lists the registered collectors as JSON, along with their state and
+// the properties that may be selected for each, so that valid flag values are discoverable.
+func NewCollectorsHandler() http.Handler {
+	return collectorsHandler{states: collectorStates}
+}
+
+type collectorsHandler struct {
+	states map[string]State
+}
+
+// ServeHTTP implements the http.Handler interface
+func (h collectorsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(`Content-Type`, `application/json`)
+	enc := json.NewEncoder(w)
+	enc.SetIndent(``, `  `)
+	if err := enc.Encode(describeCollectors(h.states)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// NewMetricsHandler returns an http.Handler that serves the metrics gathered from gatherer, in the OpenMetrics format
+// when negotiated by the Accept header of the request, which is required to expose exemplars, or the Prometheus text
+// format otherwise.
+func NewMetricsHandler(gatherer prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// NewHealthyHandler returns an http.Handler that reports the exporter is running, for liveness probes.
+func NewHealthyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `Healthy.`)
+	})
+}
+
+// NewReadyHandler returns an http.Handler that reports whether ZFS is usable, for readiness probes. Pools are listed
+// via client on each request, responding with 503 Service Unavailable if the command fails, ie - when the kernel
+// module is not loaded.
+func NewReadyHandler(client zfs.Client) http.Handler {
+	return readyHandler{client: client}
+}
+
+type readyHandler struct {
+	client zfs.Client
+}
+
+// ServeHTTP implements the http.Handler interface
+func (h readyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.client.PoolNames(); err != nil {
+		http.Error(w, fmt.Sprintf("Not ready: %s", err), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, `Ready.`)
+}
+
+func describeCollectors(states map[string]State) []collectorInfo {
+	result := make([]collectorInfo, 0, len(states))
+	for name, state := range states {
+		info := collectorInfo{
+			Name:           name,
+			DefaultEnabled: state.defaultEnabled,
+			Enabled:        state.Enabled != nil && *state.Enabled,
+			Properties:     state.properties(),
+		}
+		if state.defaultProperties != `` {
+			info.DefaultProperties = strings.Split(state.defaultProperties, `,`)
+		}
+		if state.store != nil {
+			info.Available = describeProperties(state.store)
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// describeProperties describes the properties of the store, naming parameterised properties in the form they are
+// requested (ie - `written@<snapshot>`).
+func describeProperties(store *propertyStore) []propertyInfo {
+	names := append(store.names(), store.parameterisedNames()...)
+	result := make([]propertyInfo, len(names))
+	for i, name := range names {
+		key, _, _ := strings.Cut(name, `<`)
+		prop := store.store[key]
+		info := propertyInfo{
+			Name:   name,
+			Metric: prop.name,
+			Help:   prop.help,
+			Inputs: prop.inputs,
+		}
+		if prop.label != `` {
+			info.Metric = prometheus.BuildFQName(namespace, store.defaultSubsystem, `info`)
+			info.Label = prop.label
+		}
+		result[i] = info
+	}
+
+	return result
+}
@@ -0,0 +1,213 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectorsHandler(t *testing.T) {
+	server := httptest.NewServer(NewCollectorsHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + `/collectors`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get(`Content-Type`); got != `application/json` {
+		t.Fatalf("got content type %q, want %q", got, `application/json`)
+	}
+
+	var collectors []collectorInfo
+	if err = json.NewDecoder(resp.Body).Decode(&collectors); err != nil {
+		t.Fatal(err)
+	}
+
+	var pool *collectorInfo
+	for i := range collectors {
+		if collectors[i].Name == `pool` {
+			pool = &collectors[i]
+		}
+	}
+	if pool == nil {
+		t.Fatalf("pool collector not found in %v", collectors)
+	}
+	if !pool.DefaultEnabled {
+		t.Error(`got pool collector disabled by default, want enabled`)
+	}
+
+	var health *propertyInfo
+	for i := range pool.Available {
+		if pool.Available[i].Name == `health` {
+			health = &pool.Available[i]
+		}
+	}
+	if health == nil {
+		t.Fatalf("health property not found in %v", pool.Available)
+	}
+	if health.Metric != `zfs_pool_health` || health.Help == `` {
+		t.Fatalf("got health property %+v, want metric zfs_pool_health with help", *health)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	testCases := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantExemplar    bool
+	}{
+		{
+			name:            `openmetrics`,
+			accept:          `application/openmetrics-text; version=0.0.1`,
+			wantContentType: `application/openmetrics-text`,
+			wantExemplar:    true,
+		},
+		{
+			name:            `text`,
+			accept:          `text/plain`,
+			wantContentType: `text/plain`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			zfsClient := mock_zfs.NewMockClient(ctrl)
+			zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+			zfsPool := mock_zfs.NewMockPool(ctrl)
+			zfsPool.EXPECT().Status().Return(&zfs.Status{Scan: &zfs.ScanStatus{
+				Function: zfs.ScanScrub,
+				Errors:   2,
+				EndTime:  time.Date(2021, time.October, 10, 10, 10, 0, 0, time.UTC),
+			}}, nil).Times(1)
+			zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+			collector, err := NewZFS(defaultConfig(zfsClient))
+			if err != nil {
+				t.Fatal(err)
+			}
+			collector.Collectors = map[string]State{
+				`pool-scan`: {
+					Name:    "pool-scan",
+					Enabled: boolPointer(true),
+					factory: newPoolScanCollectorFactory(),
+				},
+			}
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(collector)
+
+			server := httptest.NewServer(NewMetricsHandler(registry))
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(`Accept`, tc.accept)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if got := resp.Header.Get(`Content-Type`); !strings.HasPrefix(got, tc.wantContentType) {
+				t.Fatalf("got content type %q, want %q", got, tc.wantContentType)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := `zfs_pool_scrubs_completed_total{pool="testpool"} 0.0 # {errors="2"} 1.0 1.6338606e+09`
+			if got := strings.Contains(string(body), want); got != tc.wantExemplar {
+				t.Fatalf("got exemplar %t, want %t in:\n%s", got, tc.wantExemplar, body)
+			}
+		})
+	}
+}
+
+// readyRunner returns output for `zpool list`, or err if set.
+type readyRunner struct {
+	err error
+}
+
+// Run implements the zfs.CommandRunner interface
+func (r readyRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return io.NopCloser(strings.NewReader("testpool\n")), nil
+}
+
+func TestHealthyHandler(t *testing.T) {
+	server := httptest.NewServer(NewHealthyHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + `/-/healthy`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       `available`,
+			wantStatus: http.StatusOK,
+			wantBody:   "Ready.\n",
+		},
+		{
+			name:       `unavailable`,
+			err:        errors.New(`The ZFS modules are not loaded.`),
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "Not ready: The ZFS modules are not loaded.\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(NewReadyHandler(zfs.New(zfs.Config{Runner: readyRunner{err: tc.err}})))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + `/-/ready`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.wantStatus {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+			if string(body) != tc.wantBody {
+				t.Fatalf("got body %q, want %q", body, tc.wantBody)
+			}
+		})
+	}
+}
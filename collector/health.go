@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolsAllHealthyDescName = prometheus.BuildFQName(namespace, `pools`, `all_healthy`)
+	poolsAllHealthyDesc     = prometheus.NewDesc(
+		poolsAllHealthyDescName,
+		`Whether none of the pools are reported by "zpool status -x" [0: one or more pools unhealthy, 1: all pools healthy].`,
+		nil,
+		nil,
+	)
+	poolUnhealthyDescName = prometheus.BuildFQName(namespace, subsystemPool, `unhealthy`)
+	poolUnhealthyDesc     = prometheus.NewDesc(
+		poolUnhealthyDescName,
+		`Whether the pool is reported by "zpool status -x" as having errors or being unavailable [0: healthy, 1: unhealthy].`,
+		poolLabels,
+		nil,
+	)
+)
+
+func init() {
+	registerCollector(`pool-health-summary`, defaultDisabled, ``, nil, newPoolHealthSummaryCollector)
+}
+
+// poolHealthSummaryCollector reports the health of all pools from a single `zpool status -x` command, as a lightweight
+// alternative to parsing the full status of each pool.
+type poolHealthSummaryCollector struct {
+	log    log.Logger
+	client zfs.Client
+}
+
+func (c *poolHealthSummaryCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- poolsAllHealthyDesc
+	ch <- poolUnhealthyDesc
+}
+
+func (c *poolHealthSummaryCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	unhealthy, err := c.client.UnhealthyPools()
+	if err != nil {
+		return err
+	}
+	unhealthyPools := make(map[string]struct{}, len(unhealthy))
+	for _, pool := range unhealthy {
+		unhealthyPools[pool] = struct{}{}
+	}
+
+	// Only the selected pools are reported, so that pools excluded from collection do not raise alerts.
+	allHealthy := 1.0
+	for _, pool := range pools {
+		value := 0.0
+		if _, ok := unhealthyPools[pool]; ok {
+			value = 1
+			allHealthy = 0
+		}
+		ch <- metric{
+			name:       expandMetricName(poolUnhealthyDescName, pool),
+			prometheus: prometheus.MustNewConstMetric(poolUnhealthyDesc, prometheus.GaugeValue, value, pool),
+		}
+	}
+	ch <- metric{
+		name:       poolsAllHealthyDescName,
+		prometheus: prometheus.MustNewConstMetric(poolsAllHealthyDesc, prometheus.GaugeValue, allHealthy),
+	}
+
+	return nil
+}
+
+func newPoolHealthSummaryCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	return &poolHealthSummaryCollector{log: l, client: c}, nil
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestPoolHealthSummaryMetrics(t *testing.T) {
+	testCases := []struct {
+		name          string
+		pools         []string
+		unhealthy     []string
+		metricResults string
+	}{
+		{
+			name:      `healthy`,
+			pools:     []string{`tank`, `backup`},
+			unhealthy: []string{},
+			metricResults: `# HELP zfs_pool_unhealthy Whether the pool is reported by "zpool status -x" as having errors or being unavailable [0: healthy, 1: unhealthy].
+# TYPE zfs_pool_unhealthy gauge
+zfs_pool_unhealthy{pool="backup"} 0
+zfs_pool_unhealthy{pool="tank"} 0
+# HELP zfs_pools_all_healthy Whether none of the pools are reported by "zpool status -x" [0: one or more pools unhealthy, 1: all pools healthy].
+# TYPE zfs_pools_all_healthy gauge
+zfs_pools_all_healthy 1
+`,
+		},
+		{
+			name:      `degraded`,
+			pools:     []string{`tank`, `backup`},
+			unhealthy: []string{`tank`},
+			metricResults: `# HELP zfs_pool_unhealthy Whether the pool is reported by "zpool status -x" as having errors or being unavailable [0: healthy, 1: unhealthy].
+# TYPE zfs_pool_unhealthy gauge
+zfs_pool_unhealthy{pool="backup"} 0
+zfs_pool_unhealthy{pool="tank"} 1
+# HELP zfs_pools_all_healthy Whether none of the pools are reported by "zpool status -x" [0: one or more pools unhealthy, 1: all pools healthy].
+# TYPE zfs_pools_all_healthy gauge
+zfs_pools_all_healthy 0
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl, ctx := gomock.WithContext(context.Background(), t)
+			zfsClient := mock_zfs.NewMockClient(ctrl)
+			zfsClient.EXPECT().PoolNames().Return(tc.pools, nil).Times(1)
+			zfsClient.EXPECT().UnhealthyPools().Return(tc.unhealthy, nil).Times(1)
+
+			collector, err := NewZFS(defaultConfig(zfsClient))
+			if err != nil {
+				t.Fatal(err)
+			}
+			collector.Collectors = map[string]State{
+				`pool-health-summary`: {
+					Name:    `pool-health-summary`,
+					Enabled: boolPointer(true),
+					factory: newPoolHealthSummaryCollector,
+				},
+			}
+
+			if err = callCollector(ctx, collector, []byte(tc.metricResults), []string{`zfs_pool_unhealthy`, `zfs_pools_all_healthy`}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
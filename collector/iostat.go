@@ -0,0 +1,208 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultPoolIOStatProps = `read_bandwidth,read_operations,write_bandwidth,write_operations`
+)
+
+var (
+	// poolIOStatTimestamps reports pool I/O statistics with the time they were sampled, rather than the scrape time.
+	poolIOStatTimestamps *bool
+
+	poolQueueLabels          = []string{`pool`, `queue`}
+	poolQueuePendingDescName = prometheus.BuildFQName(namespace, subsystemPool, `queue_pending`)
+	poolQueuePendingDesc     = prometheus.NewDesc(
+		poolQueuePendingDescName,
+		`Number of I/Os waiting in the pool I/O queue.`,
+		poolQueueLabels,
+		nil,
+	)
+	poolQueueActiveDescName = prometheus.BuildFQName(namespace, subsystemPool, `queue_active`)
+	poolQueueActiveDesc     = prometheus.NewDesc(
+		poolQueueActiveDescName,
+		`Number of I/Os issued to the pool devices from the pool I/O queue.`,
+		poolQueueLabels,
+		nil,
+	)
+	poolIOStatProperties = propertyStore{
+		defaultSubsystem: subsystemPool,
+		defaultLabels:    poolLabels,
+		store: map[string]property{
+			`read_operations`: newProperty(
+				subsystemPool,
+				`read_operations_per_second`,
+				`Rate of read operations issued to the pool.`,
+				TransformNumeric,
+				poolLabels...,
+			),
+			`write_operations`: newProperty(
+				subsystemPool,
+				`write_operations_per_second`,
+				`Rate of write operations issued to the pool.`,
+				TransformNumeric,
+				poolLabels...,
+			),
+			`read_bandwidth`: newProperty(
+				subsystemPool,
+				`read_bytes_per_second`,
+				`Rate of bytes read from the pool.`,
+				TransformNumeric,
+				poolLabels...,
+			),
+			`write_bandwidth`: newProperty(
+				subsystemPool,
+				`write_bytes_per_second`,
+				`Rate of bytes written to the pool.`,
+				TransformNumeric,
+				poolLabels...,
+			),
+		},
+	}
+)
+
+func init() {
+	registerCollector(`pool-iostat`, defaultDisabled, defaultPoolIOStatProps, &poolIOStatProperties, newPoolIOStatCollector)
+	registerCollector(`pool-queues`, defaultDisabled, ``, nil, newPoolQueuesCollector)
+
+	poolIOStatTimestamps = kingpin.Flag(`collector.pool-iostat.timestamps`, `Report pool I/O statistics with the time at which their sampling interval ended, rather than the scrape time, so that rates are not skewed by jitter in the scrape interval.`).Default(`false`).Bool()
+}
+
+// poolIOStatCollector reports pool I/O statistics from `zpool iostat`, sampled over the interval configured on the
+// client.
+type poolIOStatCollector struct {
+	log    log.Logger
+	client zfs.Client
+	props  []string
+	// timestamps reports metrics with the time they were sampled.
+	timestamps bool
+}
+
+func (c *poolIOStatCollector) describe(ch chan<- *prometheus.Desc) {
+	for _, k := range c.props {
+		prop, err := poolIOStatProperties.find(k)
+		if err != nil {
+			warnUnsupported(c.log, `pool-iostat`, k, err)
+			continue
+		}
+		ch <- prop.desc
+	}
+}
+
+func (c *poolIOStatCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool); err != nil && !isPoolMissing(c.log, `pool-iostat`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *poolIOStatCollector) updatePoolMetrics(ch chan<- metric, pool string) error {
+	results, err := c.client.Pool(pool).IOStat()
+	if err != nil {
+		return err
+	}
+	if !c.timestamps {
+		return poolIOStatProperties.push(c.log, `pool-iostat`, ch, c.props, results.Properties(), pool)
+	}
+
+	// The statistics describe the interval ending when the command completed.
+	sampled := time.Now()
+	samples := make(chan metric, len(c.props))
+	err = poolIOStatProperties.push(c.log, `pool-iostat`, samples, c.props, results.Properties(), pool)
+	close(samples)
+	for sample := range samples {
+		sample.prometheus = prometheus.NewMetricWithTimestamp(sampled, sample.prometheus)
+		ch <- sample
+	}
+
+	return err
+}
+
+func newPoolIOStatCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	if len(props) == 0 {
+		props = poolIOStatProperties.names()
+	}
+	return &poolIOStatCollector{log: l, client: c, props: props, timestamps: *poolIOStatTimestamps}, nil
+}
+
+// poolQueuesCollector reports pool I/O queue depths from `zpool iostat -q`, sampled over the interval configured on the
+// client.
+type poolQueuesCollector struct {
+	log    log.Logger
+	client zfs.Client
+}
+
+func (c *poolQueuesCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- poolQueuePendingDesc
+	ch <- poolQueueActiveDesc
+}
+
+func (c *poolQueuesCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool); err != nil && !isPoolMissing(c.log, `pool-queues`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *poolQueuesCollector) updatePoolMetrics(ch chan<- metric, pool string) error {
+	queues, err := c.client.Pool(pool).IOQueues()
+	if err != nil {
+		return err
+	}
+
+	for _, queue := range queues {
+		labelValues := []string{pool, queue.Name}
+		ch <- metric{
+			name:       expandMetricName(poolQueuePendingDescName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(poolQueuePendingDesc, prometheus.GaugeValue, float64(queue.Pending), labelValues...),
+		}
+		ch <- metric{
+			name:       expandMetricName(poolQueueActiveDescName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(poolQueueActiveDesc, prometheus.GaugeValue, float64(queue.Active), labelValues...),
+		}
+	}
+
+	return nil
+}
+
+func newPoolQueuesCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	return &poolQueuesCollector{log: l, client: c}, nil
+}
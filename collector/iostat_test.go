@@ -0,0 +1,206 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPoolIOStatMetrics(t *testing.T) {
+	const result = `# HELP zfs_pool_read_bytes_per_second Rate of bytes read from the pool.
+# TYPE zfs_pool_read_bytes_per_second gauge
+zfs_pool_read_bytes_per_second{pool="testpool"} 16384
+# HELP zfs_pool_read_operations_per_second Rate of read operations issued to the pool.
+# TYPE zfs_pool_read_operations_per_second gauge
+zfs_pool_read_operations_per_second{pool="testpool"} 4
+# HELP zfs_pool_write_bytes_per_second Rate of bytes written to the pool.
+# TYPE zfs_pool_write_bytes_per_second gauge
+zfs_pool_write_bytes_per_second{pool="testpool"} 0
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{
+		`allocated`:       `1024`,
+		`free`:            `3072`,
+		`read_operations`: `4`,
+		`read_bandwidth`:  `16384`,
+		`write_bandwidth`: `0`,
+	}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().IOStat().Return(zfsPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool-iostat`: {
+			Name:    "pool-iostat",
+			Enabled: boolPointer(true),
+			factory: newPoolIOStatCollector,
+		},
+	}
+
+	metricNames := []string{
+		`zfs_pool_read_bytes_per_second`,
+		`zfs_pool_read_operations_per_second`,
+		`zfs_pool_write_bytes_per_second`,
+		`zfs_pool_write_operations_per_second`,
+	}
+	if err = callCollector(ctx, collector, []byte(result), metricNames); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolIOStatProperties(t *testing.T) {
+	const result = `# HELP zfs_pool_read_bytes_per_second Rate of bytes read from the pool.
+# TYPE zfs_pool_read_bytes_per_second gauge
+zfs_pool_read_bytes_per_second{pool="testpool"} 16384
+# HELP zfs_pool_write_bytes_per_second Rate of bytes written to the pool.
+# TYPE zfs_pool_write_bytes_per_second gauge
+zfs_pool_write_bytes_per_second{pool="testpool"} 0
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{
+		`read_operations`:  `4`,
+		`write_operations`: `0`,
+		`read_bandwidth`:   `16384`,
+		`write_bandwidth`:  `0`,
+	}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().IOStat().Return(zfsPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool-iostat`: {
+			Name:       "pool-iostat",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`read_bandwidth,write_bandwidth`),
+			factory:    newPoolIOStatCollector,
+		},
+	}
+
+	metricNames := []string{
+		`zfs_pool_read_bytes_per_second`,
+		`zfs_pool_read_operations_per_second`,
+		`zfs_pool_write_bytes_per_second`,
+		`zfs_pool_write_operations_per_second`,
+	}
+	if err = callCollector(ctx, collector, []byte(result), metricNames); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolIOStatTimestamps(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{
+		`read_operations`: `4`,
+		`read_bandwidth`:  `16384`,
+	}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().IOStat().Return(zfsPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool-iostat`: {
+			Name:    "pool-iostat",
+			Enabled: boolPointer(true),
+			factory: func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+				return &poolIOStatCollector{log: l, client: c, props: poolIOStatProperties.names(), timestamps: true}, nil
+			},
+		},
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	before := time.Now().UnixMilli()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now().UnixMilli()
+
+	timestamped := 0
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), `zfs_pool_`) {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if m.TimestampMs == nil {
+				t.Errorf("%s: got no timestamp, want sample time", family.GetName())
+				continue
+			}
+			if ts := m.GetTimestampMs(); ts < before || ts > after {
+				t.Errorf("%s: got timestamp %d, want between %d and %d", family.GetName(), ts, before, after)
+			}
+			timestamped++
+		}
+	}
+	if timestamped != 2 {
+		t.Fatalf("got %d timestamped metrics, want 2", timestamped)
+	}
+}
+
+func TestPoolQueuesMetrics(t *testing.T) {
+	const result = `# HELP zfs_pool_queue_active Number of I/Os issued to the pool devices from the pool I/O queue.
+# TYPE zfs_pool_queue_active gauge
+zfs_pool_queue_active{pool="testpool",queue="async_write"} 10
+zfs_pool_queue_active{pool="testpool",queue="sync_read"} 1
+# HELP zfs_pool_queue_pending Number of I/Os waiting in the pool I/O queue.
+# TYPE zfs_pool_queue_pending gauge
+zfs_pool_queue_pending{pool="testpool",queue="async_write"} 120
+zfs_pool_queue_pending{pool="testpool",queue="sync_read"} 0
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().IOQueues().Return([]zfs.IOQueue{
+		{Name: `sync_read`, Pending: 0, Active: 1},
+		{Name: `async_write`, Pending: 120, Active: 10},
+	}, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool-queues`: {
+			Name:    "pool-queues",
+			Enabled: boolPointer(true),
+			factory: newPoolQueuesCollector,
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_queue_pending`, `zfs_pool_queue_active`}); err != nil {
+		t.Fatal(err)
+	}
+}
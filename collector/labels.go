@@ -0,0 +1,450 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// ParseLabels parses comma-separated name=value pairs, as accepted by the constant labels flag, into a map. Names must
+// be valid Prometheus label names, may only be specified once, and may not be among the labels set by the exporter, as
+// duplicate labels would fail every scrape.
+func ParseLabels(input string) (map[string]string, error) {
+	labels, err := parseLabelPairs(input)
+	if err != nil {
+		return nil, err
+	}
+	reserved := exporterLabelNames()
+	for _, name := range sortedKeys(labels) {
+		if _, ok := reserved[name]; ok {
+			return nil, fmt.Errorf("label name %q is reserved by the exporter", name)
+		}
+	}
+
+	return labels, nil
+}
+
+// ParseLabelRenames parses comma-separated old=new pairs of label names, as accepted by the label rename flag, into a
+// map keyed by the original label name. New names must be valid Prometheus label names, unique, and may not be among
+// the labels set by the exporter that are not themselves renamed, nor among the constant labels.
+func ParseLabelRenames(input string, labels map[string]string) (map[string]string, error) {
+	renames, err := parseLabelPairs(input)
+	if err != nil {
+		return nil, err
+	}
+	reserved := exporterLabelNames()
+	for name := range renames {
+		delete(reserved, name)
+	}
+	seen := make(map[string]struct{}, len(renames))
+	for _, name := range sortedKeys(renames) {
+		rename := renames[name]
+		if !model.LabelName(rename).IsValid() || strings.HasPrefix(rename, `__`) {
+			return nil, fmt.Errorf("invalid label name %q", rename)
+		}
+		if _, ok := reserved[rename]; ok {
+			return nil, fmt.Errorf("cannot rename label %q to %q, which is reserved by the exporter", name, rename)
+		}
+		if _, ok := labels[rename]; ok {
+			return nil, fmt.Errorf("cannot rename label %q to %q, which is a constant label", name, rename)
+		}
+		if _, ok := seen[rename]; ok {
+			return nil, fmt.Errorf("duplicate label name %q", rename)
+		}
+		seen[rename] = struct{}{}
+	}
+
+	return renames, nil
+}
+
+// parseLabelPairs parses comma-separated name=value pairs into a map. Names must be valid Prometheus label names, and may
+// only be specified once.
+func parseLabelPairs(input string) (map[string]string, error) {
+	result := make(map[string]string)
+	if input == `` {
+		return result, nil
+	}
+	for _, pair := range strings.Split(input, `,`) {
+		name, value, ok := strings.Cut(pair, `=`)
+		if !ok || value == `` {
+			return nil, fmt.Errorf("invalid label %q, expected name=value", pair)
+		}
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, `__`) {
+			return nil, fmt.Errorf("invalid label name %q", name)
+		}
+		if _, ok := result[name]; ok {
+			return nil, fmt.Errorf("duplicate label name %q", name)
+		}
+		result[name] = value
+	}
+
+	return result, nil
+}
+
+// sortedKeys returns the keys of m in order, so that the first of several invalid labels is reported consistently.
+func sortedKeys(m map[string]string) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// ParseNameLabels compiles the pattern accepted by the name labels flag, returning nil if empty. The pattern must have at
+// least one named capture group, and the group names must be valid Prometheus label names, unique, and not among the
+// labels set by the exporter, as duplicate labels would fail every scrape.
+func ParseNameLabels(pattern string) (*regexp.Regexp, error) {
+	if pattern == `` {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name label pattern %q: %w", pattern, err)
+	}
+	reserved := exporterLabelNames()
+	seen := make(map[string]struct{})
+	for _, name := range re.SubexpNames() {
+		if name == `` {
+			continue
+		}
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, `__`) {
+			return nil, fmt.Errorf("invalid label name %q", name)
+		}
+		if _, ok := reserved[name]; ok {
+			return nil, fmt.Errorf("label name %q is reserved by the exporter", name)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("duplicate label name %q", name)
+		}
+		seen[name] = struct{}{}
+	}
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("name label pattern %q has no named capture groups", pattern)
+	}
+
+	return re, nil
+}
+
+// exporterLabelNames returns the names of the labels set by the exporter, from the labels of the built-in metrics and
+// the label properties of each collector.
+func exporterLabelNames() map[string]struct{} {
+	result := make(map[string]struct{})
+	for _, labels := range [][]string{
+		poolLabels,
+		datasetLabels,
+		snapshotCountLabels,
+		snapshotChurnLabels,
+		poolDeviceLabels,
+		poolEventsLabels,
+		poolFeatureLabels,
+		poolQueueLabels,
+		vdevLabels,
+		blockLabels,
+		propertyRawLabels,
+		sendLabels,
+		// Labels of the remaining metrics, of the user and group space metrics, of state metrics, and of histograms.
+		{`collector`, `command`, `version`, `userland`, `kernel`, `user`, `group`, `state`, `le`, `quantile`},
+	} {
+		for _, label := range labels {
+			result[label] = struct{}{}
+		}
+	}
+	for _, state := range collectorStates {
+		if state.store == nil {
+			continue
+		}
+		for _, prop := range state.store.store {
+			for _, label := range []string{prop.label, prop.valueLabel} {
+				if label != `` {
+					result[label] = struct{}{}
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// labelGatherer renames labels and adds constant labels to every metric gathered from the wrapped gatherer.
+type labelGatherer struct {
+	gatherer prometheus.Gatherer
+	renames  map[string]string
+	labels   []*dto.LabelPair
+}
+
+// Gather implements the prometheus.Gatherer interface.
+func (g labelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	if err != nil {
+		return families, err
+	}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			if err = g.relabel(family.GetName(), m); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return families, nil
+}
+
+// relabel replaces the labels of m with renamed copies plus the constant labels. Label pairs may be shared with the
+// metric that produced them (ie - const metrics cached across scrapes), so they are never modified in place.
+func (g labelGatherer) relabel(name string, m *dto.Metric) error {
+	result := make([]*dto.LabelPair, 0, len(m.GetLabel())+len(g.labels))
+	seen := make(map[string]struct{}, cap(result))
+	add := func(labelName, value string) error {
+		if _, ok := seen[labelName]; ok {
+			return fmt.Errorf("duplicate label %q on metric %s after relabeling", labelName, name)
+		}
+		seen[labelName] = struct{}{}
+		result = append(result, newLabelPair(labelName, value))
+		return nil
+	}
+	for _, pair := range m.GetLabel() {
+		labelName := pair.GetName()
+		if rename, ok := g.renames[labelName]; ok {
+			labelName = rename
+		}
+		if err := add(labelName, pair.GetValue()); err != nil {
+			return err
+		}
+	}
+	for _, pair := range g.labels {
+		if err := add(pair.GetName(), pair.GetValue()); err != nil {
+			return err
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GetName() < result[j].GetName()
+	})
+	m.Label = result
+
+	return nil
+}
+
+// nameLabelGatherer adds the named capture groups of a pattern matching the dataset or pool name of each metric gathered
+// from the wrapped gatherer as labels.
+type nameLabelGatherer struct {
+	gatherer prometheus.Gatherer
+	pattern  *regexp.Regexp
+}
+
+// Gather implements the prometheus.Gatherer interface.
+func (g nameLabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	if err != nil {
+		return families, err
+	}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			if err = g.extract(family.GetName(), m); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return families, nil
+}
+
+// extract adds the labels captured from the `name` label of m, or from the `pool` label of metrics without a name (ie -
+// pool metrics). Metrics whose name does not match, and groups that capture nothing, add no labels. As with relabel,
+// label pairs are never modified in place.
+func (g nameLabelGatherer) extract(metricName string, m *dto.Metric) error {
+	var (
+		name, pool       string
+		hasName, hasPool bool
+	)
+	for _, pair := range m.GetLabel() {
+		switch pair.GetName() {
+		case `name`:
+			name, hasName = pair.GetValue(), true
+		case `pool`:
+			pool, hasPool = pair.GetValue(), true
+		}
+	}
+	if !hasName {
+		if !hasPool {
+			return nil
+		}
+		name = pool
+	}
+	match := g.pattern.FindStringSubmatch(name)
+	if match == nil {
+		return nil
+	}
+
+	result := append(make([]*dto.LabelPair, 0, len(m.GetLabel())+len(match)), m.GetLabel()...)
+	for i, labelName := range g.pattern.SubexpNames() {
+		if labelName == `` || match[i] == `` {
+			continue
+		}
+		for _, pair := range result {
+			if pair.GetName() == labelName {
+				return fmt.Errorf("duplicate label %q on metric %s after extracting labels from name %q", labelName, metricName, name)
+			}
+		}
+		result = append(result, newLabelPair(labelName, match[i]))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GetName() < result[j].GetName()
+	})
+	m.Label = result
+
+	return nil
+}
+
+// datasetLabelGatherer replaces the `name` label of every metric gathered from the wrapped gatherer with a `dataset`
+// label, stripped of the pool prefix already reported by the `pool` label.
+type datasetLabelGatherer struct {
+	gatherer prometheus.Gatherer
+}
+
+// Gather implements the prometheus.Gatherer interface.
+func (g datasetLabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	if err != nil {
+		return families, err
+	}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			if err = g.strip(family.GetName(), m); err != nil {
+				return nil, err
+			}
+		}
+		// Restore the order of metrics by label values, which stripping the pool from the name changes.
+		metrics := family.GetMetric()
+		sort.SliceStable(metrics, func(i, j int) bool {
+			return lessLabels(metrics[i].GetLabel(), metrics[j].GetLabel())
+		})
+	}
+
+	return families, nil
+}
+
+// lessLabels orders sorted label pairs by the values of each label in turn, as metrics are ordered by registries.
+func lessLabels(a, b []*dto.LabelPair) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].GetName() != b[i].GetName() {
+			return a[i].GetName() < b[i].GetName()
+		}
+		if a[i].GetValue() != b[i].GetValue() {
+			return a[i].GetValue() < b[i].GetValue()
+		}
+	}
+	return len(a) < len(b)
+}
+
+// strip replaces the `name` label of m with a `dataset` label, ie - `tank/a/b@snap` in pool `tank` is reported as
+// `a/b@snap`, and the root dataset of the pool as empty. Metrics without both labels, or whose name is not within the
+// pool, are unchanged. As with relabel, label pairs are never modified in place.
+func (g datasetLabelGatherer) strip(metricName string, m *dto.Metric) error {
+	var (
+		name, pool                   string
+		hasName, hasPool, hasDataset bool
+	)
+	for _, pair := range m.GetLabel() {
+		switch pair.GetName() {
+		case `name`:
+			name, hasName = pair.GetValue(), true
+		case `pool`:
+			pool, hasPool = pair.GetValue(), true
+		case `dataset`:
+			hasDataset = true
+		}
+	}
+	if !hasName || !hasPool {
+		return nil
+	}
+	dataset, ok := stripPool(pool, name)
+	if !ok {
+		return nil
+	}
+	if hasDataset {
+		return fmt.Errorf("duplicate label %q on metric %s after stripping the pool from name %q", `dataset`, metricName, name)
+	}
+
+	result := make([]*dto.LabelPair, 0, len(m.GetLabel()))
+	for _, pair := range m.GetLabel() {
+		if pair.GetName() != `name` {
+			result = append(result, pair)
+		}
+	}
+	result = append(result, newLabelPair(`dataset`, dataset))
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GetName() < result[j].GetName()
+	})
+	m.Label = result
+
+	return nil
+}
+
+// stripPool returns the name of a dataset or snapshot relative to its pool, and whether the name is within the pool.
+// Snapshots of the root dataset retain their `@`, so that they are distinguished from datasets. Empty names (ie - of
+// pool properties) are within every pool.
+func stripPool(pool, name string) (string, bool) {
+	if name == `` {
+		return ``, true
+	}
+	rest, ok := strings.CutPrefix(name, pool)
+	if !ok {
+		return ``, false
+	}
+	switch {
+	case rest == ``, rest[0] == '@':
+		return rest, true
+	case rest[0] == '/':
+		return rest[1:], true
+	default:
+		// A pool sharing a prefix, ie - `tank2` in `tank`.
+		return ``, false
+	}
+}
+
+func newLabelPair(name, value string) *dto.LabelPair {
+	return &dto.LabelPair{Name: &name, Value: &value}
+}
+
+// NewLabelGatherer wraps gatherer, renaming the labels of every metric according to renames, keyed by the original
+// label name, and adding the constant labels. Metrics whose labels conflict after relabeling fail the gather.
+func NewLabelGatherer(gatherer prometheus.Gatherer, renames, labels map[string]string) prometheus.Gatherer {
+	if len(renames) == 0 && len(labels) == 0 {
+		return gatherer
+	}
+	names := sortedKeys(labels)
+	pairs := make([]*dto.LabelPair, len(names))
+	for i, name := range names {
+		pairs[i] = newLabelPair(name, labels[name])
+	}
+
+	return labelGatherer{gatherer: gatherer, renames: renames, labels: pairs}
+}
+
+// NewNameLabelGatherer wraps gatherer, adding the named capture groups of pattern as labels to every metric whose dataset
+// name (or pool name, for metrics without a dataset) matches. Metrics whose labels conflict with a captured label fail
+// the gather.
+func NewNameLabelGatherer(gatherer prometheus.Gatherer, pattern *regexp.Regexp) prometheus.Gatherer {
+	if pattern == nil {
+		return gatherer
+	}
+
+	return nameLabelGatherer{gatherer: gatherer, pattern: pattern}
+}
+
+// NewDatasetLabelGatherer wraps gatherer, replacing the `name` label of every dataset and snapshot metric with a
+// `dataset` label relative to the pool when enabled. Metrics that already have a `dataset` label fail the gather.
+func NewDatasetLabelGatherer(gatherer prometheus.Gatherer, enabled bool) prometheus.Gatherer {
+	if !enabled {
+		return gatherer
+	}
+
+	return datasetLabelGatherer{gatherer: gatherer}
+}
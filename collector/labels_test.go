@@ -0,0 +1,313 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseLabels(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: `empty`, input: ``, want: map[string]string{}},
+		{name: `single`, input: `cluster=prod`, want: map[string]string{`cluster`: `prod`}},
+		{name: `multiple`, input: `cluster=prod,dc=nyc`, want: map[string]string{`cluster`: `prod`, `dc`: `nyc`}},
+		{name: `value with equals`, input: `env=a=b`, want: map[string]string{`env`: `a=b`}},
+		{name: `missing value`, input: `cluster`, wantErr: true},
+		{name: `empty value`, input: `cluster=`, wantErr: true},
+		{name: `invalid name`, input: `0cluster=prod`, wantErr: true},
+		{name: `reserved name`, input: `__name__=prod`, wantErr: true},
+		{name: `duplicate name`, input: `cluster=prod,cluster=dev`, wantErr: true},
+		{name: `exporter label`, input: `pool=prod`, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseLabels(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %q", tc.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got labels %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+}
+
+func TestParseLabelRenames(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		labels  map[string]string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: `empty`, input: ``, want: map[string]string{}},
+		{name: `single`, input: `pool=zpool`, want: map[string]string{`pool`: `zpool`}},
+		{name: `swap`, input: `name=pool,pool=name`, want: map[string]string{`name`: `pool`, `pool`: `name`}},
+		{name: `invalid name`, input: `pool=0pool`, wantErr: true},
+		{name: `exporter label`, input: `pool=state`, wantErr: true},
+		{name: `constant label`, input: `pool=cluster`, labels: map[string]string{`cluster`: `prod`}, wantErr: true},
+		{name: `duplicate name`, input: `pool=zpool,name=zpool`, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseLabelRenames(tc.input, tc.labels)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %q", tc.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got renames %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLabelGatherer(t *testing.T) {
+	const result = `# HELP zfs_pool_allocated_bytes Amount of storage in bytes used within the pool.
+# TYPE zfs_pool_allocated_bytes gauge
+zfs_pool_allocated_bytes{cluster="prod",dc="nyc",zpool="testpool"} 1024
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`allocated`: `1024`}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Properties(`allocated`).Return(zfsPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated`),
+			factory:    newPoolCollector,
+		},
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+	gatherer := NewLabelGatherer(registry, map[string]string{`pool`: `zpool`}, map[string]string{`cluster`: `prod`, `dc`: `nyc`})
+
+	done := make(chan error)
+	go func() {
+		done <- testutil.GatherAndCompare(gatherer, strings.NewReader(result), `zfs_pool_allocated_bytes`)
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+}
+
+func TestLabelGathererRepeated(t *testing.T) {
+	const result = `# HELP test_bytes Test metric.
+# TYPE test_bytes gauge
+test_bytes{cluster="prod",name="testpool",pool="rpool"} 1
+`
+
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: `test_bytes`, Help: `Test metric.`}, []string{`name`, `pool`})
+	gauge.WithLabelValues(`rpool`, `testpool`).Set(1)
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(gauge)
+	// Swapping labels would be applied twice if the gathered label pairs were modified in place.
+	gatherer := NewLabelGatherer(registry, map[string]string{`name`: `pool`, `pool`: `name`}, map[string]string{`cluster`: `prod`})
+
+	for i := 0; i < 2; i++ {
+		if err := testutil.GatherAndCompare(gatherer, strings.NewReader(result), `test_bytes`); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLabelGathererConflict(t *testing.T) {
+	// Labels set by the exporter are rejected when parsing the flags, though those of custom collectors may conflict.
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: `test_bytes`, Help: `Test metric.`}, []string{`env`, `pool`})
+	gauge.WithLabelValues(`dev`, `testpool`).Set(1)
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(gauge)
+
+	if _, err := NewLabelGatherer(registry, nil, map[string]string{`env`: `prod`}).Gather(); err == nil {
+		t.Fatal(`expected error for constant label conflicting with metric label`)
+	}
+	if _, err := NewLabelGatherer(registry, map[string]string{`pool`: `env`}, nil).Gather(); err == nil {
+		t.Fatal(`expected error for renamed label conflicting with metric label`)
+	}
+}
+
+func TestParseNameLabels(t *testing.T) {
+	if pattern, err := ParseNameLabels(``); err != nil || pattern != nil {
+		t.Fatalf("got pattern %v and error %v for empty input, want neither", pattern, err)
+	}
+	if _, err := ParseNameLabels(`^[^/]+/(?P<env>[^/]+)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []string{`^[^/]+/([^/]+)`, `^(?P<env>[^/]+`, `^(?P<__env>[^/]+)`} {
+		if _, err := ParseNameLabels(input); err == nil {
+			t.Errorf("expected error for input %q", input)
+		}
+	}
+}
+
+func TestParseNameLabelsConflict(t *testing.T) {
+	for _, input := range []string{
+		`^[^/]+/(?P<pool>[^/]+)`,
+		`^[^/]+/(?P<name>[^/]+)`,
+		`^[^/]+/(?P<type>[^/]+)`,
+		`^[^/]+/(?P<vdev>[^/]+)`,
+		`^[^/]+/(?P<state>[^/]+)`,
+		`^[^/]+/(?P<mountpoint>[^/]+)`,
+		`^[^/]+/(?P<env>[^/]+)/(?P<env>[^/]+)`,
+	} {
+		if _, err := ParseNameLabels(input); err == nil {
+			t.Errorf("expected error for input %q", input)
+		}
+	}
+}
+
+func TestNameLabelGatherer(t *testing.T) {
+	const result = `# HELP test_dataset_bytes Test dataset metric.
+# TYPE test_dataset_bytes gauge
+test_dataset_bytes{name="tank",pool="tank",type="filesystem"} 2
+test_dataset_bytes{app="app1",env="prod",name="tank/prod/app1",pool="tank",type="filesystem"} 1
+# HELP test_pool_bytes Test pool metric.
+# TYPE test_pool_bytes gauge
+test_pool_bytes{pool="tank"} 3
+`
+
+	datasets := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: `test_dataset_bytes`, Help: `Test dataset metric.`}, []string{`name`, `pool`, `type`})
+	datasets.WithLabelValues(`tank/prod/app1`, `tank`, `filesystem`).Set(1)
+	datasets.WithLabelValues(`tank`, `tank`, `filesystem`).Set(2)
+	pools := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: `test_pool_bytes`, Help: `Test pool metric.`}, []string{`pool`})
+	pools.WithLabelValues(`tank`).Set(3)
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(datasets, pools)
+
+	pattern, err := ParseNameLabels(`^[^/]+/(?P<env>[^/]+)/(?P<app>[^/]+)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gatherer := NewNameLabelGatherer(registry, pattern)
+	// Repeated, as labels would be extracted twice if the gathered label pairs were modified in place.
+	for i := 0; i < 2; i++ {
+		if err = testutil.GatherAndCompare(gatherer, strings.NewReader(result), `test_dataset_bytes`, `test_pool_bytes`); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNameLabelGathererPool(t *testing.T) {
+	const result = `# HELP test_pool_bytes Test pool metric.
+# TYPE test_pool_bytes gauge
+test_pool_bytes{env="prod",pool="prod-tank"} 1
+`
+
+	pools := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: `test_pool_bytes`, Help: `Test pool metric.`}, []string{`pool`})
+	pools.WithLabelValues(`prod-tank`).Set(1)
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(pools)
+
+	pattern, err := ParseNameLabels(`^(?P<env>[a-z]+)-`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = testutil.GatherAndCompare(NewNameLabelGatherer(registry, pattern), strings.NewReader(result), `test_pool_bytes`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNameLabelGathererConflict(t *testing.T) {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: `test_bytes`, Help: `Test metric.`}, []string{`name`, `type`})
+	gauge.WithLabelValues(`tank/prod`, `filesystem`).Set(1)
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(gauge)
+
+	// Reserved labels are rejected by ParseNameLabels, but labels of other metrics (ie - from plugins) may still conflict.
+	pattern := regexp.MustCompile(`^[^/]+/(?P<type>[^/]+)`)
+	if _, err := NewNameLabelGatherer(registry, pattern).Gather(); err == nil {
+		t.Fatal(`expected error for extracted label conflicting with metric label`)
+	}
+}
+
+func TestDatasetLabelGatherer(t *testing.T) {
+	const result = `# HELP test_dataset_bytes Test dataset metric.
+# TYPE test_dataset_bytes gauge
+test_dataset_bytes{dataset="",pool="tank",type="filesystem"} 1
+test_dataset_bytes{dataset="a/b",pool="tank",type="filesystem"} 2
+test_dataset_bytes{dataset="a/b@snap",pool="tank",type="snapshot"} 3
+test_dataset_bytes{dataset="data",pool="tank2",type="filesystem"} 4
+test_dataset_bytes{dataset="@snap",pool="tank",type="snapshot"} 5
+# HELP test_pool_bytes Test pool metric.
+# TYPE test_pool_bytes gauge
+test_pool_bytes{pool="tank"} 6
+# HELP test_send_bytes Test send metric.
+# TYPE test_send_bytes gauge
+test_send_bytes{dataset="tank/a/b@snap"} 7
+`
+
+	datasets := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: `test_dataset_bytes`, Help: `Test dataset metric.`}, []string{`name`, `pool`, `type`})
+	datasets.WithLabelValues(`tank`, `tank`, `filesystem`).Set(1)
+	datasets.WithLabelValues(`tank/a/b`, `tank`, `filesystem`).Set(2)
+	datasets.WithLabelValues(`tank/a/b@snap`, `tank`, `snapshot`).Set(3)
+	datasets.WithLabelValues(`tank2/data`, `tank2`, `filesystem`).Set(4)
+	datasets.WithLabelValues(`tank@snap`, `tank`, `snapshot`).Set(5)
+	pools := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: `test_pool_bytes`, Help: `Test pool metric.`}, []string{`pool`})
+	pools.WithLabelValues(`tank`).Set(6)
+	sends := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: `test_send_bytes`, Help: `Test send metric.`}, []string{`dataset`})
+	sends.WithLabelValues(`tank/a/b@snap`).Set(7)
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(datasets, pools, sends)
+
+	gatherer := NewDatasetLabelGatherer(registry, true)
+	// Repeated, as the pool would be stripped twice if the gathered label pairs were modified in place.
+	for i := 0; i < 2; i++ {
+		if err := testutil.GatherAndCompare(gatherer, strings.NewReader(result), `test_dataset_bytes`, `test_pool_bytes`, `test_send_bytes`); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDatasetLabelGathererConflict(t *testing.T) {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: `test_bytes`, Help: `Test metric.`}, []string{`dataset`, `name`, `pool`})
+	gauge.WithLabelValues(`data`, `tank/data`, `tank`).Set(1)
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(gauge)
+
+	if _, err := NewDatasetLabelGatherer(registry, true).Gather(); err == nil {
+		t.Fatal(`expected error for dataset label conflicting with metric label`)
+	}
+}
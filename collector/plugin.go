@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Plugin is implemented by collectors defined outside of this package. A new Plugin is instantiated for each
+// collection, so any state that must persist between collections should be held by the PluginFactory.
+type Plugin interface {
+	// Describe sends the descriptors of all metrics that may be collected to ch
+	Describe(ch chan<- *prometheus.Desc)
+	// Update collects metrics for the provided pools, sending them to ch
+	Update(ch chan<- prometheus.Metric, pools []string) error
+}
+
+// PluginFactory instantiates a Plugin
+type PluginFactory func(logger log.Logger, client zfs.Client) (Plugin, error)
+
+// RegisterPlugin registers a collector defined outside of this package, adding the `--collector.<name>` flag to
+// enable it. Plugins must be registered before flags are parsed, ie - from an init function.
+func RegisterPlugin(name string, isDefaultEnabled bool, factory PluginFactory) {
+	registerCollector(name, isDefaultEnabled, ``, nil, newPluginFactory(factory))
+}
+
+func newPluginFactory(factory PluginFactory) factoryFunc {
+	return func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+		plugin, err := factory(l, c)
+		if err != nil {
+			return nil, err
+		}
+		return &pluginCollector{plugin: plugin}, nil
+	}
+}
+
+// pluginCollector adapts a Plugin to the Collector interface.
+type pluginCollector struct {
+	plugin Plugin
+}
+
+func (c *pluginCollector) describe(ch chan<- *prometheus.Desc) {
+	c.plugin.Describe(ch)
+}
+
+func (c *pluginCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	metrics := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range metrics {
+			ch <- metric{name: pluginMetricName(m), prometheus: m}
+		}
+		close(done)
+	}()
+
+	err := c.plugin.Update(metrics, pools)
+	close(metrics)
+	<-done
+
+	return err
+}
+
+// pluginMetricName identifies a metric for caching, by its descriptor and label values.
+func pluginMetricName(m prometheus.Metric) string {
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		return m.Desc().String()
+	}
+	labelValues := make([]string, 0, len(pb.GetLabel()))
+	for _, label := range pb.GetLabel() {
+		labelValues = append(labelValues, label.GetName()+`=`+label.GetValue())
+	}
+	return expandMetricName(m.Desc().String(), strings.Join(labelValues, `,`))
+}
@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var testPluginDesc = prometheus.NewDesc(`zfs_test_plugin_pools`, `Pools seen by the test plugin.`, []string{`pool`}, nil)
+
+type testPlugin struct{}
+
+func (p testPlugin) Describe(ch chan<- *prometheus.Desc) {
+	ch <- testPluginDesc
+}
+
+func (p testPlugin) Update(ch chan<- prometheus.Metric, pools []string) error {
+	for _, pool := range pools {
+		ch <- prometheus.MustNewConstMetric(testPluginDesc, prometheus.GaugeValue, 1, pool)
+	}
+	return nil
+}
+
+func TestRegisterPlugin(t *testing.T) {
+	const result = `# HELP zfs_test_plugin_pools Pools seen by the test plugin.
+# TYPE zfs_test_plugin_pools gauge
+zfs_test_plugin_pools{pool="testpool1"} 1
+zfs_test_plugin_pools{pool="testpool2"} 1
+`
+
+	RegisterPlugin(`test-plugin`, true, func(logger log.Logger, client zfs.Client) (Plugin, error) {
+		return testPlugin{}, nil
+	})
+	state, ok := collectorStates[`test-plugin`]
+	if !ok {
+		t.Fatal(`plugin was not registered`)
+	}
+	// Flag defaults are only applied when flags are parsed.
+	state.Enabled = boolPointer(true)
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool1`, `testpool2`}, nil).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{`test-plugin`: state}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_test_plugin_pools`}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,9 +1,14 @@
 package collector
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"sync"
+	"time"
 
+	"github.com/alecthomas/kingpin/v2"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/pdf/zfs_exporter/v2/zfs"
@@ -11,11 +16,55 @@ import (
 )
 
 const (
-	defaultPoolProps = `allocated,dedupratio,fragmentation,free,freeing,health,leaked,readonly,size`
+	poolMinSlopBytes = 128 << 20
+	poolMaxSlopBytes = 128 << 30
+	// defaultPoolSlopShift is the default value of the spa_slop_shift module parameter, reserving 1/32 of the pool.
+	defaultPoolSlopShift = 5
+	// maxPoolSlopShift is the largest value of the spa_slop_shift module parameter accepted by ZFS.
+	maxPoolSlopShift = 31
+	// defaultPoolFragmentationThreshold is the fragmentation ratio above which a pool is reported as critically
+	// fragmented.
+	defaultPoolFragmentationThreshold = 0.5
+
+	// poolFeatureFlagsVersion is the on-disk version of pools using feature flags, which report their version as `-`.
+	poolFeatureFlagsVersion = 5000
+
+	defaultPoolProps = `allocated,capacity,dedupratio,expandsize,fragmentation,free,freeing,health,leaked,readonly,size`
 )
 
 var (
-	poolLabels     = []string{`pool`}
+	// poolSlopShift is the spa_slop_shift module parameter configured on the host, which determines the slop space.
+	poolSlopShift *uint
+	// poolFragmentationThreshold is the fragmentation ratio above which a pool is reported as critically fragmented.
+	poolFragmentationThreshold *float64
+	// poolHealthState additionally reports pool health from the pool collector as an info-style metric labeled with the
+	// status, for filtering dashboards by status rather than by code.
+	poolHealthState *bool
+	// poolList fetches the properties available as `zpool list` columns for all pools with a single command.
+	poolList *bool
+
+	poolLabels             = []string{`pool`}
+	poolUpDescName         = prometheus.BuildFQName(namespace, subsystemPool, `up`)
+	poolLastScrapeDescName = prometheus.BuildFQName(namespace, subsystemPool, `last_scrape_timestamp_seconds`)
+	poolLastScrapeDesc     = prometheus.NewDesc(
+		poolLastScrapeDescName,
+		`Time at which the pool was last queried successfully, in seconds since the epoch.`,
+		poolLabels,
+		nil,
+	)
+	poolHealthTransitionsDescName = prometheus.BuildFQName(namespace, subsystemPool, `health_transitions_total`)
+	poolHealthTransitionsDesc     = prometheus.NewDesc(
+		poolHealthTransitionsDescName,
+		`Number of changes of pool health observed between scrapes since the exporter started.`,
+		poolLabels,
+		nil,
+	)
+	poolUpDesc = prometheus.NewDesc(
+		poolUpDescName,
+		`Whether the pool could be queried successfully [0: query failed, 1: query succeeded].`,
+		poolLabels,
+		nil,
+	)
 	poolProperties = propertyStore{
 		defaultSubsystem: subsystemPool,
 		defaultLabels:    poolLabels,
@@ -24,85 +73,166 @@ var (
 				subsystemPool,
 				`allocated_bytes`,
 				`Amount of storage in bytes used within the pool.`,
-				transformNumeric,
+				TransformNumeric,
 				poolLabels...,
 			),
+			`altroot`: newInfoProperty(`altroot`),
+			`available`: newProperty(
+				subsystemPool,
+				`available_bytes`,
+				`Amount of storage in bytes available to datasets in the pool, the "available" property of the root dataset. Unlike free, this accounts for parity, the space reserved by ZFS and reservations.`,
+				TransformNumeric,
+				poolLabels...,
+			).ofRootDataset(),
+			`bclonesaved`: newProperty(
+				subsystemPool,
+				`block_clone_saved_bytes`,
+				`Amount of storage in bytes saved by block cloning in the pool.`,
+				TransformNumeric,
+				poolLabels...,
+			).requires(2, 2, 0),
+			`bcloneused`: newProperty(
+				subsystemPool,
+				`block_clone_used_bytes`,
+				`Amount of storage in bytes used by cloned blocks in the pool.`,
+				TransformNumeric,
+				poolLabels...,
+			).requires(2, 2, 0),
+			`bootfs`:    newInfoProperty(`bootfs`),
+			`cachefile`: newInfoProperty(`cachefile`),
+			`checkpoint`: newProperty(
+				subsystemPool,
+				`checkpoint_bytes`,
+				`Amount of space in bytes consumed by the pool checkpoint, 0 if no checkpoint exists.`,
+				TransformNumeric,
+				poolLabels...,
+			).requires(0, 8, 0),
+			`checkpoint_exists`: newDerivedProperty(
+				subsystemPool,
+				`checkpoint_exists`,
+				`Whether a checkpoint exists for the pool [0: no checkpoint, 1: checkpoint exists].`,
+				[]string{`checkpoint`},
+				deriveExists(`checkpoint`),
+				poolLabels...,
+			).requires(0, 8, 0),
+			`comment`: newInfoProperty(`comment`),
+			`dedupditto`: newProperty(
+				subsystemPool,
+				`deduplication_ditto_threshold`,
+				`The reference count threshold above which an additional ditto copy of deduplicated blocks is stored, 0 if disabled.`,
+				TransformNumeric,
+				poolLabels...,
+			).removedIn(0, 8, 0),
 			`dedupratio`: newProperty(
 				subsystemPool,
 				`deduplication_ratio`,
 				`The ratio of deduplicated size vs undeduplicated size for data in this pool.`,
-				transformMultiplier,
+				TransformMultiplier,
 				poolLabels...,
 			),
 			`capacity`: newProperty(
 				subsystemPool,
 				`capacity_ratio`,
 				`Ratio of pool space used.`,
-				transformPercentage,
+				TransformPercentage,
 				poolLabels...,
 			),
 			`expandsize`: newProperty(
 				subsystemPool,
 				`expand_size_bytes`,
 				`Amount of uninitialized space within the pool or device that can be used to increase the total capacity of the pool.`,
-				transformNumeric,
+				TransformNumeric,
+				poolLabels...,
+			),
+			`expandable`: newDerivedProperty(
+				subsystemPool,
+				`expandable`,
+				`Whether the pool has uninitialized space that can be used to increase its capacity, ie - after replacing devices with larger devices [0: not expandable, 1: expandable].`,
+				[]string{`expandsize`},
+				deriveExpandable,
 				poolLabels...,
 			),
 			`fragmentation`: newProperty(
 				subsystemPool,
 				`fragmentation_ratio`,
 				`The fragmentation ratio of the pool.`,
-				transformPercentage,
+				TransformPercentage,
+				poolLabels...,
+			),
+			`fragmentation_critical`: newDerivedProperty(
+				subsystemPool,
+				`fragmentation_critical`,
+				`Whether the fragmentation ratio of the pool exceeds the configured threshold [0: below threshold, 1: above threshold].`,
+				[]string{`fragmentation`},
+				deriveFragmentationCritical,
 				poolLabels...,
 			),
 			`free`: newProperty(
 				subsystemPool,
 				`free_bytes`,
 				`The amount of free space in bytes available in the pool.`,
-				transformNumeric,
+				TransformNumeric,
 				poolLabels...,
 			),
 			`freeing`: newProperty(
 				subsystemPool,
 				`freeing_bytes`,
 				`The amount of space in bytes remaining to be freed following the destruction of a file system or snapshot.`,
-				transformNumeric,
+				TransformNumeric,
 				poolLabels...,
 			),
 			`health`: newProperty(
 				subsystemPool,
 				`health`,
-				fmt.Sprintf("Health status code for the pool [%d: %s, %d: %s, %d: %s, %d: %s, %d: %s, %d: %s, %d: %s].",
-					poolOnline, zfs.PoolOnline,
-					poolDegraded, zfs.PoolDegraded,
-					poolFaulted, zfs.PoolFaulted,
-					poolOffline, zfs.PoolOffline,
-					poolUnavail, zfs.PoolUnavail,
-					poolRemoved, zfs.PoolRemoved,
-					poolSuspended, zfs.PoolSuspended,
-				),
+				fmt.Sprintf(`Health status code for the pool %s.`, healthCodeHelp),
 				transformHealthCode,
 				poolLabels...,
+			).withState(
+				`Health status of the pool reported as the state label, with a constant value of 1.`,
+				poolLabels...,
 			),
 			`leaked`: newProperty(
 				subsystemPool,
 				`leaked_bytes`,
 				`Number of leaked bytes in the pool.`,
-				transformNumeric,
+				TransformNumeric,
 				poolLabels...,
 			),
 			`readonly`: newProperty(
 				subsystemPool,
 				`readonly`,
 				`Read-only status of the pool [0: read-write, 1: read-only].`,
-				transformBool,
+				TransformBool,
 				poolLabels...,
 			),
 			`size`: newProperty(
 				subsystemPool,
 				`size_bytes`,
 				`Total size in bytes of the storage pool.`,
-				transformNumeric,
+				TransformNumeric,
+				poolLabels...,
+			),
+			`version`: newProperty(
+				subsystemPool,
+				`version`,
+				fmt.Sprintf(`The on-disk version of the pool, %d for pools using feature flags. Pools with a lower legacy version may be upgraded with "zpool upgrade".`, poolFeatureFlagsVersion),
+				transformPoolVersion,
+				poolLabels...,
+			),
+			`slop_reserved`: newDerivedProperty(
+				subsystemPool,
+				`slop_reserved_bytes`,
+				`The approximate amount of space in bytes reserved by ZFS as slop space, which only operations that free space may consume, derived from the size of the pool and spa_slop_shift.`,
+				[]string{`size`},
+				deriveSlopReserved,
+				poolLabels...,
+			),
+			`writable`: newDerivedProperty(
+				subsystemPool,
+				`writable_bytes`,
+				`The approximate amount of raw space in bytes that may be allocated in the pool before writes fail, the size less the allocated and slop space, and no less than 0. On raidz and dRAID vdevs this includes the space consumed by parity, so overstates the data that may be written, see available.`,
+				[]string{`allocated`, `size`},
+				deriveWritable,
 				poolLabels...,
 			),
 		},
@@ -110,36 +240,129 @@ var (
 )
 
 func init() {
-	registerCollector(`pool`, defaultEnabled, defaultPoolProps, newPoolCollector)
+	registerCollector(`pool`, defaultEnabled, defaultPoolProps, &poolProperties, newPoolCollectorFactory())
+
+	poolList = kingpin.Flag(`collector.pool.list`, `Fetch the pool properties available from "zpool list" for all pools with a single command, rather than a command per pool.`).Default(`true`).Bool()
+	poolSlopShift = kingpin.Flag(`collector.pool.slop-shift`, `The spa_slop_shift module parameter configured on the host, for deriving the slop space of pools.`).Default(strconv.Itoa(defaultPoolSlopShift)).Uint()
+	poolFragmentationThreshold = kingpin.Flag(`collector.pool.fragmentation-threshold`, `The fragmentation ratio above which zfs_pool_fragmentation_critical reports a pool as critically fragmented.`).Default(strconv.FormatFloat(defaultPoolFragmentationThreshold, 'g', -1, 64)).Float64()
+	poolHealthState = kingpin.Flag(`collector.pool.health-state`, `Additionally report the health of each pool from the pool collector as zfs_pool_health_state, labeled with the status, alongside the zfs_pool_health status code.`).Default(`false`).Bool()
+}
+
+// poolHealthTransitions holds the last observed health of each pool, and the resulting counts of health changes. It
+// outlives the collectors created for each scrape.
+type poolHealthTransitions struct {
+	sync.Mutex
+	// health maps pool names to the health observed at the last scrape.
+	health map[string]string
+	// counts maps pool names to the number of health changes observed for the pool.
+	counts map[string]uint64
+}
+
+// update records the health of the pool, returning the number of health changes observed for the pool. The first
+// observation of a pool is not counted as a change.
+func (t *poolHealthTransitions) update(pool, health string) uint64 {
+	t.Lock()
+	defer t.Unlock()
+
+	if last, ok := t.health[pool]; ok && last != health {
+		t.counts[pool]++
+	}
+	t.health[pool] = health
+
+	return t.counts[pool]
+}
+
+// poolScrapeTimes holds the time at which each pool was last queried successfully. It outlives the collectors created
+// for each scrape.
+type poolScrapeTimes struct {
+	sync.Mutex
+	now   func() time.Time
+	times map[string]time.Time
+}
+
+// update records the current time for the pool if queried successfully, returning the time at which the pool was last
+// queried successfully, if ever.
+func (t *poolScrapeTimes) update(pool string, success bool) (time.Time, bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	if success {
+		t.times[pool] = t.now()
+	}
+	last, ok := t.times[pool]
+
+	return last, ok
 }
 
 type poolCollector struct {
 	log    log.Logger
 	client zfs.Client
 	props  []string
+	// healthState additionally reports the health of each pool as an info-style metric, labeled with the status.
+	healthState bool
+	// list fetches the properties available from `zpool list` for all pools with a single command.
+	list bool
+	// raw properties whose unparsed values are reported, for debugging.
+	raw rawPropertySet
+	// transitions counts health changes across scrapes, nil if not tracked.
+	transitions *poolHealthTransitions
+	// lastScrape records the time of the last successful query of each pool across scrapes, nil if not tracked.
+	lastScrape *poolScrapeTimes
 }
 
 func (c *poolCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- poolUpDesc
+	if c.lastScrape != nil {
+		ch <- poolLastScrapeDesc
+	}
 	for _, k := range c.props {
 		prop, err := poolProperties.find(k)
 		if err != nil {
-			_ = level.Warn(c.log).Log(`msg`, propertyUnsupportedMsg, `help`, helpIssue, `collector`, `pool`, `property`, k, `err`, err)
+			warnUnsupported(c.log, `pool`, k, err)
+		}
+		if prop.label != `` {
 			continue
 		}
 		ch <- prop.desc
+		if c.healthState && prop.state != nil {
+			ch <- prop.state
+		}
+		if c.transitions != nil && k == `health` {
+			ch <- poolHealthTransitionsDesc
+		}
 	}
+	if desc, _ := poolProperties.info(c.props); desc != nil {
+		ch <- desc
+	}
+	c.raw.describe(ch)
 }
 
 func (c *poolCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	props := poolProperties.supported(c.log, `pool`, c.client, c.props)
+	if len(props) == 0 {
+		return nil
+	}
+
+	var listed map[string]zfs.PoolProperties
+	if c.list {
+		listed = c.listProperties(props)
+	}
+
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(pools))
 	for _, pool := range pools {
 		wg.Add(1)
 		go func(pool string) {
-			if err := c.updatePoolMetrics(ch, pool); err != nil {
+			defer wg.Done()
+			err := c.updatePoolMetrics(ch, pool, props, listed[pool])
+			if err != nil && isPoolMissing(c.log, `pool`, pool, err) {
+				return
+			}
+			c.pushPoolUp(ch, pool, err == nil)
+			c.pushLastScrape(ch, pool, err == nil)
+			if err != nil {
 				errChan <- err
 			}
-			wg.Done()
 		}(pool)
 	}
 	wg.Wait()
@@ -152,27 +375,285 @@ func (c *poolCollector) update(ch chan<- metric, pools []string, excludes regexp
 	}
 }
 
-func (c *poolCollector) updatePoolMetrics(ch chan<- metric, pool string) error {
-	p := c.client.Pool(pool)
-	props, err := p.Properties(c.props...)
+// listProperties fetches the properties available from `zpool list` for all pools with a single command, so that only
+// the remaining properties are fetched per pool. Returns nil if none of the properties are available, or the command
+// fails, in which case all properties are fetched per pool.
+func (c *poolCollector) listProperties(props []string) map[string]zfs.PoolProperties {
+	var listable []string
+	for _, k := range poolProperties.fetchable(props) {
+		if hasProperty(zfs.PoolListProperties, k) {
+			listable = append(listable, k)
+		}
+	}
+	if len(listable) == 0 {
+		return nil
+	}
+
+	results, err := c.client.PoolList(listable...)
 	if err != nil {
-		return err
+		_ = level.Debug(c.log).Log(`msg`, `Error listing pool properties, fetching per pool`, `collector`, `pool`, `err`, err)
+		return nil
 	}
+	return results
+}
 
-	labelValues := []string{pool}
-	for k, v := range props.Properties() {
-		prop, err := poolProperties.find(k)
+// updatePoolMetrics reports the properties of the pool, fetching those not already listed.
+func (c *poolCollector) updatePoolMetrics(ch chan<- metric, pool string, props []string, listed zfs.PoolProperties) error {
+	fetch := poolProperties.fetchable(props)
+	values := make(map[string]string, len(fetch))
+	if listed != nil {
+		remaining := make([]string, 0, len(fetch))
+		for k, v := range listed.Properties() {
+			values[k] = v
+		}
+		for _, k := range fetch {
+			if _, ok := values[k]; !ok {
+				remaining = append(remaining, k)
+			}
+		}
+		fetch = remaining
+	}
+	fetch, rootFetch := splitRootDatasetProperties(fetch)
+	var p zfs.Pool
+	if len(fetch) > 0 || len(rootFetch) > 0 {
+		p = c.client.Pool(pool)
+	}
+	if len(fetch) > 0 {
+		results, err := p.Properties(fetch...)
 		if err != nil {
-			_ = level.Warn(c.log).Log(`msg`, propertyUnsupportedMsg, `help`, helpIssue, `collector`, `pool`, `property`, k, `err`, err)
+			if !errors.Is(err, zfs.ErrPoolNotFound) {
+				c.pushHealthFallback(ch, p, pool, props)
+			}
+			return err
 		}
-		if err = prop.push(ch, v, labelValues...); err != nil {
+		for k, v := range results.Properties() {
+			values[k] = v
+		}
+	}
+	if len(rootFetch) > 0 {
+		results, err := p.RootProperties(rootFetch...)
+		if err != nil {
 			return err
 		}
+		for k, v := range results.Properties() {
+			values[k] = v
+		}
+	}
+
+	// Raw values are reported before they are parsed, so that values that fail to parse are visible.
+	c.raw.push(ch, `pool`, pool, ``, values)
+	if err := poolProperties.push(c.log, `pool`, ch, props, values, pool); err != nil {
+		return err
+	}
+	if health, ok := values[`health`]; ok && hasProperty(props, `health`) {
+		return c.pushHealthDetail(ch, pool, health)
+	}
+
+	return nil
+}
+
+// pushHealthDetail reports the health of the pool as a state metric and the count of health changes, when enabled.
+func (c *poolCollector) pushHealthDetail(ch chan<- metric, pool string, health string) error {
+	if c.transitions != nil {
+		ch <- metric{
+			name:       expandMetricName(poolHealthTransitionsDescName, pool),
+			prometheus: prometheus.MustNewConstMetric(poolHealthTransitionsDesc, prometheus.CounterValue, float64(c.transitions.update(pool, health)), pool),
+		}
+	}
+	if !c.healthState {
+		return nil
+	}
+	prop, _ := poolProperties.find(`health`)
+	return prop.pushState(ch, health, pool)
+}
+
+// splitRootDatasetProperties separates the properties of the root dataset of the pool, which are fetched via `zfs get`,
+// from the pool properties.
+func splitRootDatasetProperties(props []string) (pool []string, root []string) {
+	pool = make([]string, 0, len(props))
+	for _, k := range props {
+		if prop, ok := poolProperties.lookup(k); ok && prop.rootDataset {
+			root = append(root, k)
+			continue
+		}
+		pool = append(pool, k)
+	}
+	return pool, root
+}
+
+// pushHealthFallback reports the pool health via a dedicated query when the properties query fails, so that health is
+// reported whenever possible.
+func (c *poolCollector) pushHealthFallback(ch chan<- metric, p zfs.Pool, pool string, props []string) {
+	if !hasProperty(props, `health`) {
+		return
+	}
+
+	health, err := p.Health()
+	if err != nil {
+		_ = level.Warn(c.log).Log(`msg`, `Error querying pool health`, `collector`, `pool`, `pool`, pool, `err`, err)
+		return
+	}
+	prop, _ := poolProperties.find(`health`)
+	if err = prop.push(ch, string(health), pool); err != nil {
+		_ = level.Warn(c.log).Log(`msg`, `Error reporting pool health`, `collector`, `pool`, `pool`, pool, `err`, err)
+		return
+	}
+	if err = c.pushHealthDetail(ch, pool, string(health)); err != nil {
+		_ = level.Warn(c.log).Log(`msg`, `Error reporting pool health`, `collector`, `pool`, `pool`, pool, `err`, err)
+	}
+}
+
+// hasProperty reports whether name is among props.
+func hasProperty(props []string, name string) bool {
+	for _, k := range props {
+		if k == name {
+			return true
+		}
+	}
+	return false
+}
+
+// pushPoolUp reports whether the pool could be queried, so that pools are visible even when queries fail.
+func (c *poolCollector) pushPoolUp(ch chan<- metric, pool string, up bool) {
+	var value float64
+	if up {
+		value = 1
+	}
+	ch <- metric{
+		name:       expandMetricName(poolUpDescName, pool),
+		prometheus: prometheus.MustNewConstMetric(poolUpDesc, prometheus.GaugeValue, value, pool),
+	}
+}
+
+// pushLastScrape reports the time at which the pool was last queried successfully, so that pools that stop reporting
+// may be detected. Pools that have never been queried successfully are not reported.
+func (c *poolCollector) pushLastScrape(ch chan<- metric, pool string, success bool) {
+	if c.lastScrape == nil {
+		return
+	}
+	last, ok := c.lastScrape.update(pool, success)
+	if !ok {
+		return
+	}
+	ch <- metric{
+		name:       expandMetricName(poolLastScrapeDescName, pool),
+		prometheus: prometheus.MustNewConstMetric(poolLastScrapeDesc, prometheus.GaugeValue, float64(last.UnixNano())/1e9, pool),
+	}
+}
+
+// transformPoolVersion converts the version of a pool, reporting pools using feature flags, whose version is `-`, as
+// the feature flags version.
+func transformPoolVersion(value string) (float64, error) {
+	if value == `-` {
+		return poolFeatureFlagsVersion, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func deriveSlopReserved(values map[string]string) (float64, bool, error) {
+	size, err := TransformNumeric(values[`size`])
+	if err != nil {
+		return 0, false, err
+	}
+
+	return poolSlopBytes(size), true, nil
+}
+
+// deriveWritable reports the raw space that may be allocated before writes fail, the size less the allocated and slop
+// space, clamped to 0 as the slop space may itself be partly allocated.
+func deriveWritable(values map[string]string) (float64, bool, error) {
+	allocated, err := TransformNumeric(values[`allocated`])
+	if err != nil {
+		return 0, false, err
+	}
+	size, err := TransformNumeric(values[`size`])
+	if err != nil {
+		return 0, false, err
+	}
+
+	return math.Max(size-allocated-poolSlopBytes(size), 0), true, nil
+}
+
+// deriveExpandable reports whether the pool may be expanded, pools reporting no expansion space as `-` are not.
+func deriveExpandable(values map[string]string) (float64, bool, error) {
+	expandsize, err := TransformNumeric(values[`expandsize`])
+	if err != nil {
+		return 0, false, err
+	}
+	if expandsize > 0 {
+		return 1, true, nil
+	}
+
+	return 0, true, nil
+}
+
+// deriveFragmentationCritical reports whether the fragmentation ratio exceeds the configured threshold, skipping pools
+// that do not report fragmentation.
+func deriveFragmentationCritical(values map[string]string) (float64, bool, error) {
+	fragmentation, err := TransformPercentage(values[`fragmentation`])
+	if err != nil {
+		return 0, false, err
+	}
+	if fragmentation > *poolFragmentationThreshold {
+		return 1, true, nil
+	}
+
+	return 0, true, nil
+}
+
+// poolSlopBytes returns the slop space reserved in a pool of size bytes, as calculated by spa_get_slop_space: the size
+// shifted by spa_slop_shift, but no less than 128MiB (or half the pool, if smaller), and no more than 128GiB.
+func poolSlopBytes(size float64) float64 {
+	slop := math.Ldexp(size, -int(*poolSlopShift))
+	if minSlop := math.Min(size/2, poolMinSlopBytes); slop < minSlop {
+		slop = minSlop
+	}
+	if slop > poolMaxSlopBytes {
+		slop = poolMaxSlopBytes
+	}
+
+	return slop
+}
+
+// ValidatePoolFlags checks that the slop shift and fragmentation threshold configured for the pool collector are within
+// range.
+func ValidatePoolFlags() error {
+	return validatePoolFlags(*poolSlopShift, *poolFragmentationThreshold)
+}
+
+func validatePoolFlags(slopShift uint, fragmentationThreshold float64) error {
+	if slopShift < 1 || slopShift > maxPoolSlopShift {
+		return fmt.Errorf("invalid slop shift %d: must be between 1 and %d", slopShift, maxPoolSlopShift)
+	}
+	if !(fragmentationThreshold > 0 && fragmentationThreshold <= 1) {
+		return fmt.Errorf("invalid fragmentation threshold %v: must be greater than 0, and no more than 1", fragmentationThreshold)
 	}
 
 	return nil
 }
 
 func newPoolCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
-	return &poolCollector{log: l, client: c, props: props}, nil
+	return &poolCollector{log: l, client: c, props: props, healthState: *poolHealthState, list: *poolList, raw: newRawPropertySet(*rawProperties)}, nil
+}
+
+// newPoolCollectorFactory returns a factory for collectors sharing pool health and query times across scrapes, to count
+// health changes and report the last successful query of each pool.
+func newPoolCollectorFactory() factoryFunc {
+	transitions := &poolHealthTransitions{
+		health: make(map[string]string),
+		counts: make(map[string]uint64),
+	}
+	lastScrape := &poolScrapeTimes{
+		now:   time.Now,
+		times: make(map[string]time.Time),
+	}
+	return func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+		collector, err := newPoolCollector(l, c, props)
+		if err != nil {
+			return nil, err
+		}
+		pc := collector.(*poolCollector)
+		pc.transitions, pc.lastScrape = transitions, lastScrape
+		return pc, nil
+	}
 }
@@ -1,20 +1,67 @@
 package collector
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-kit/log"
 	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
 	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// defaultPoolFlags sets the pool collector flags to their defaults for the duration of the test, as flags are not
+// parsed by tests.
+func defaultPoolFlags(t *testing.T) {
+	slopShift, fragmentationThreshold := *poolSlopShift, *poolFragmentationThreshold
+	*poolSlopShift, *poolFragmentationThreshold = defaultPoolSlopShift, defaultPoolFragmentationThreshold
+	t.Cleanup(func() {
+		*poolSlopShift, *poolFragmentationThreshold = slopShift, fragmentationThreshold
+	})
+}
+
+func TestValidatePoolFlags(t *testing.T) {
+	testCases := []struct {
+		slopShift              uint
+		fragmentationThreshold float64
+		wantErr                bool
+	}{
+		{slopShift: defaultPoolSlopShift, fragmentationThreshold: defaultPoolFragmentationThreshold},
+		{slopShift: 1, fragmentationThreshold: 1},
+		{slopShift: maxPoolSlopShift, fragmentationThreshold: 0.01},
+		{slopShift: 0, fragmentationThreshold: defaultPoolFragmentationThreshold, wantErr: true},
+		{slopShift: maxPoolSlopShift + 1, fragmentationThreshold: defaultPoolFragmentationThreshold, wantErr: true},
+		{slopShift: defaultPoolSlopShift, fragmentationThreshold: 0, wantErr: true},
+		{slopShift: defaultPoolSlopShift, fragmentationThreshold: -0.5, wantErr: true},
+		{slopShift: defaultPoolSlopShift, fragmentationThreshold: 1.5, wantErr: true},
+		{slopShift: defaultPoolSlopShift, fragmentationThreshold: math.NaN(), wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		if err := validatePoolFlags(tc.slopShift, tc.fragmentationThreshold); (err != nil) != tc.wantErr {
+			t.Errorf("slop shift %d, fragmentation threshold %v: got error %v, want error %t", tc.slopShift, tc.fragmentationThreshold, err, tc.wantErr)
+		}
+	}
+}
+
 func TestPoolMetrics(t *testing.T) {
+	defaultPoolFlags(t)
 	testCases := []struct {
 		name           string
 		pools          []string
 		explicitPools  []string
 		propsRequested []string
+		propsFetched   []string
+		version        *zfs.Version
 		metricNames    []string
 		propsResults   map[string]map[string]string
 		metricResults  string
@@ -164,6 +211,46 @@ zfs_pool_health{pool="suspendedpool"} 6
 			metricResults: `# HELP zfs_pool_unsupported !!! This property is unsupported, results are likely to be undesirable, please file an issue at https://github.com/pdf/zfs_exporter/issues to have this property supported !!!
 # TYPE zfs_pool_unsupported gauge
 zfs_pool_unsupported{pool="testpool"} 1024
+`,
+		},
+		{
+			name:           `checkpoint`,
+			pools:          []string{`checkpointpool`, `nocheckpointpool`},
+			propsRequested: []string{`checkpoint`, `checkpoint_exists`},
+			propsFetched:   []string{`checkpoint`},
+			metricNames:    []string{`zfs_pool_checkpoint_bytes`, `zfs_pool_checkpoint_exists`},
+			propsResults: map[string]map[string]string{
+				`checkpointpool`: {
+					`checkpoint`: `1048576`,
+				},
+				`nocheckpointpool`: {
+					`checkpoint`: `-`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_checkpoint_bytes Amount of space in bytes consumed by the pool checkpoint, 0 if no checkpoint exists.
+# TYPE zfs_pool_checkpoint_bytes gauge
+zfs_pool_checkpoint_bytes{pool="checkpointpool"} 1.048576e+06
+zfs_pool_checkpoint_bytes{pool="nocheckpointpool"} 0
+# HELP zfs_pool_checkpoint_exists Whether a checkpoint exists for the pool [0: no checkpoint, 1: checkpoint exists].
+# TYPE zfs_pool_checkpoint_exists gauge
+zfs_pool_checkpoint_exists{pool="checkpointpool"} 1
+zfs_pool_checkpoint_exists{pool="nocheckpointpool"} 0
+`,
+		},
+		{
+			name:           `checkpoint exists only`,
+			pools:          []string{`checkpointpool`},
+			propsRequested: []string{`checkpoint_exists`},
+			propsFetched:   []string{`checkpoint`},
+			metricNames:    []string{`zfs_pool_checkpoint_bytes`, `zfs_pool_checkpoint_exists`},
+			propsResults: map[string]map[string]string{
+				`checkpointpool`: {
+					`checkpoint`: `1048576`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_checkpoint_exists Whether a checkpoint exists for the pool [0: no checkpoint, 1: checkpoint exists].
+# TYPE zfs_pool_checkpoint_exists gauge
+zfs_pool_checkpoint_exists{pool="checkpointpool"} 1
 `,
 		},
 		{
@@ -183,6 +270,290 @@ zfs_pool_fragmentation_ratio{pool="testpool"} 0.05
 # HELP zfs_pool_deduplication_ratio The ratio of deduplicated size vs undeduplicated size for data in this pool.
 # TYPE zfs_pool_deduplication_ratio gauge
 zfs_pool_deduplication_ratio{pool="testpool"} 0.4
+`,
+		},
+		{
+			name:           `default properties`,
+			pools:          []string{`testpool`},
+			propsRequested: strings.Split(defaultPoolProps, `,`),
+			metricNames:    []string{`zfs_pool_capacity_ratio`},
+			propsResults: map[string]map[string]string{
+				`testpool`: {
+					`allocated`:     `1024`,
+					`capacity`:      `50`,
+					`dedupratio`:    `1.00`,
+					`expandsize`:    `-`,
+					`fragmentation`: `5`,
+					`free`:          `1024`,
+					`freeing`:       `0`,
+					`health`:        `ONLINE`,
+					`leaked`:        `0`,
+					`readonly`:      `off`,
+					`size`:          `2048`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_capacity_ratio Ratio of pool space used.
+# TYPE zfs_pool_capacity_ratio gauge
+zfs_pool_capacity_ratio{pool="testpool"} 0.5
+`,
+		},
+		{
+			name:           `block cloning`,
+			pools:          []string{`clonepool`, `noclonepool`},
+			propsRequested: []string{`bcloneused`, `bclonesaved`},
+			metricNames:    []string{`zfs_pool_block_clone_used_bytes`, `zfs_pool_block_clone_saved_bytes`},
+			propsResults: map[string]map[string]string{
+				`clonepool`: {
+					`bcloneused`:  `1048576`,
+					`bclonesaved`: `3145728`,
+				},
+				// Pools without the block_cloning feature enabled.
+				`noclonepool`: {
+					`bcloneused`:  `-`,
+					`bclonesaved`: `-`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_block_clone_saved_bytes Amount of storage in bytes saved by block cloning in the pool.
+# TYPE zfs_pool_block_clone_saved_bytes gauge
+zfs_pool_block_clone_saved_bytes{pool="clonepool"} 3.145728e+06
+zfs_pool_block_clone_saved_bytes{pool="noclonepool"} 0
+# HELP zfs_pool_block_clone_used_bytes Amount of storage in bytes used by cloned blocks in the pool.
+# TYPE zfs_pool_block_clone_used_bytes gauge
+zfs_pool_block_clone_used_bytes{pool="clonepool"} 1.048576e+06
+zfs_pool_block_clone_used_bytes{pool="noclonepool"} 0
+`,
+		},
+		{
+			name:           `block cloning unsupported by version`,
+			pools:          []string{`testpool`},
+			propsRequested: []string{`bcloneused`, `bclonesaved`, `free`},
+			propsFetched:   []string{`free`},
+			version:        &zfs.Version{Major: 2, Minor: 1, Patch: 14},
+			metricNames:    []string{`zfs_pool_block_clone_used_bytes`, `zfs_pool_block_clone_saved_bytes`, `zfs_pool_free_bytes`},
+			propsResults: map[string]map[string]string{
+				`testpool`: {
+					`free`: `1024`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_free_bytes The amount of free space in bytes available in the pool.
+# TYPE zfs_pool_free_bytes gauge
+zfs_pool_free_bytes{pool="testpool"} 1024
+`,
+		},
+		{
+			name:           `dedupditto`,
+			pools:          []string{`testpool`},
+			propsRequested: []string{`dedupditto`},
+			version:        &zfs.Version{Major: 0, Minor: 7, Patch: 13},
+			metricNames:    []string{`zfs_pool_deduplication_ditto_threshold`},
+			propsResults: map[string]map[string]string{
+				`testpool`: {
+					`dedupditto`: `100`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_deduplication_ditto_threshold The reference count threshold above which an additional ditto copy of deduplicated blocks is stored, 0 if disabled.
+# TYPE zfs_pool_deduplication_ditto_threshold gauge
+zfs_pool_deduplication_ditto_threshold{pool="testpool"} 100
+`,
+		},
+		{
+			name:           `dedupditto unsupported by version`,
+			pools:          []string{`testpool`},
+			propsRequested: []string{`dedupditto`, `free`},
+			propsFetched:   []string{`free`},
+			metricNames:    []string{`zfs_pool_deduplication_ditto_threshold`, `zfs_pool_free_bytes`},
+			propsResults: map[string]map[string]string{
+				`testpool`: {
+					`free`: `1024`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_free_bytes The amount of free space in bytes available in the pool.
+# TYPE zfs_pool_free_bytes gauge
+zfs_pool_free_bytes{pool="testpool"} 1024
+`,
+		},
+		{
+			name:           `slop space`,
+			pools:          []string{`largepool`, `hugepool`, `smallpool`},
+			propsRequested: []string{`slop_reserved`, `writable`},
+			propsFetched:   []string{`size`, `allocated`},
+			metricNames:    []string{`zfs_pool_slop_reserved_bytes`, `zfs_pool_writable_bytes`},
+			propsResults: map[string]map[string]string{
+				`largepool`: {
+					`allocated`: `60129542144`,
+					`size`:      `68719476736`,
+				},
+				`hugepool`: {
+					`allocated`: `0`,
+					`size`:      `8796093022208`,
+				},
+				`smallpool`: {
+					`allocated`: `94371840`,
+					`size`:      `104857600`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_slop_reserved_bytes The approximate amount of space in bytes reserved by ZFS as slop space, which only operations that free space may consume, derived from the size of the pool and spa_slop_shift.
+# TYPE zfs_pool_slop_reserved_bytes gauge
+zfs_pool_slop_reserved_bytes{pool="hugepool"} 1.37438953472e+11
+zfs_pool_slop_reserved_bytes{pool="largepool"} 2.147483648e+09
+zfs_pool_slop_reserved_bytes{pool="smallpool"} 5.24288e+07
+# HELP zfs_pool_writable_bytes The approximate amount of raw space in bytes that may be allocated in the pool before writes fail, the size less the allocated and slop space, and no less than 0. On raidz and dRAID vdevs this includes the space consumed by parity, so overstates the data that may be written, see available.
+# TYPE zfs_pool_writable_bytes gauge
+zfs_pool_writable_bytes{pool="hugepool"} 8.658654068736e+12
+zfs_pool_writable_bytes{pool="largepool"} 6.442450944e+09
+zfs_pool_writable_bytes{pool="smallpool"} 0
+`,
+		},
+		{
+			name:           `fragmentation threshold`,
+			pools:          []string{`fragmented`, `healthy`, `legacy`},
+			propsRequested: []string{`fragmentation_critical`},
+			propsFetched:   []string{`fragmentation`},
+			metricNames:    []string{`zfs_pool_fragmentation_critical`},
+			propsResults: map[string]map[string]string{
+				`fragmented`: {
+					`fragmentation`: `73%`,
+				},
+				`healthy`: {
+					`fragmentation`: `12%`,
+				},
+				`legacy`: {
+					`fragmentation`: `-`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_fragmentation_critical Whether the fragmentation ratio of the pool exceeds the configured threshold [0: below threshold, 1: above threshold].
+# TYPE zfs_pool_fragmentation_critical gauge
+zfs_pool_fragmentation_critical{pool="fragmented"} 1
+zfs_pool_fragmentation_critical{pool="healthy"} 0
+`,
+		},
+		{
+			name:           `expandable`,
+			pools:          []string{`grown`, `testpool`},
+			propsRequested: []string{`expandable`, `expandsize`},
+			propsFetched:   []string{`expandsize`},
+			metricNames:    []string{`zfs_pool_expandable`, `zfs_pool_expand_size_bytes`},
+			propsResults: map[string]map[string]string{
+				`grown`: {
+					`expandsize`: `1099511627776`,
+				},
+				`testpool`: {
+					`expandsize`: `-`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_expand_size_bytes Amount of uninitialized space within the pool or device that can be used to increase the total capacity of the pool.
+# TYPE zfs_pool_expand_size_bytes gauge
+zfs_pool_expand_size_bytes{pool="grown"} 1.099511627776e+12
+zfs_pool_expand_size_bytes{pool="testpool"} 0
+# HELP zfs_pool_expandable Whether the pool has uninitialized space that can be used to increase its capacity, ie - after replacing devices with larger devices [0: not expandable, 1: expandable].
+# TYPE zfs_pool_expandable gauge
+zfs_pool_expandable{pool="grown"} 1
+zfs_pool_expandable{pool="testpool"} 0
+`,
+		},
+		{
+			name:           `info properties`,
+			pools:          []string{`testpool`, `rpool`},
+			propsRequested: []string{`comment`, `free`, `bootfs`},
+			metricNames:    []string{`zfs_pool_free_bytes`, `zfs_pool_info`},
+			propsResults: map[string]map[string]string{
+				`testpool`: {
+					`comment`: `-`,
+					`free`:    `1024`,
+					`bootfs`:  `-`,
+				},
+				`rpool`: {
+					`comment`: `primary storage`,
+					`free`:    `2048`,
+					`bootfs`:  `rpool/ROOT/default`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_free_bytes The amount of free space in bytes available in the pool.
+# TYPE zfs_pool_free_bytes gauge
+zfs_pool_free_bytes{pool="rpool"} 2048
+zfs_pool_free_bytes{pool="testpool"} 1024
+# HELP zfs_pool_info Information about the pool from string properties, reported as labels with a constant value of 1.
+# TYPE zfs_pool_info gauge
+zfs_pool_info{bootfs="",comment="",pool="testpool"} 1
+zfs_pool_info{bootfs="rpool/ROOT/default",comment="primary storage",pool="rpool"} 1
+`,
+		},
+		{
+			name:           `version`,
+			pools:          []string{`testpool`, `legacypool`},
+			propsRequested: []string{`version`},
+			metricNames:    []string{`zfs_pool_version`},
+			propsResults: map[string]map[string]string{
+				`testpool`: {
+					`version`: `-`,
+				},
+				`legacypool`: {
+					`version`: `28`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_version The on-disk version of the pool, 5000 for pools using feature flags. Pools with a lower legacy version may be upgraded with "zpool upgrade".
+# TYPE zfs_pool_version gauge
+zfs_pool_version{pool="legacypool"} 28
+zfs_pool_version{pool="testpool"} 5000
+`,
+		},
+		{
+			name:           `inventory info properties`,
+			pools:          []string{`testpool`, `rpool`},
+			propsRequested: []string{`altroot`, `cachefile`, `comment`},
+			metricNames:    []string{`zfs_pool_info`},
+			propsResults: map[string]map[string]string{
+				`testpool`: {
+					`altroot`:   `/mnt`,
+					`cachefile`: `none`,
+					`comment`:   `owner=storage-team`,
+				},
+				`rpool`: {
+					`altroot`:   `-`,
+					`cachefile`: ``,
+					`comment`:   `-`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_info Information about the pool from string properties, reported as labels with a constant value of 1.
+# TYPE zfs_pool_info gauge
+zfs_pool_info{altroot="",cachefile="",comment="",pool="rpool"} 1
+zfs_pool_info{altroot="/mnt",cachefile="none",comment="owner=storage-team",pool="testpool"} 1
+`,
+		},
+		{
+			name:           `fragmentation unavailable`,
+			pools:          []string{`testpool`},
+			propsRequested: []string{`fragmentation`, `free`},
+			metricNames:    []string{`zfs_pool_fragmentation_ratio`, `zfs_pool_free_bytes`},
+			propsResults: map[string]map[string]string{
+				`testpool`: {
+					`fragmentation`: `-`,
+					`free`:          `1024`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_free_bytes The amount of free space in bytes available in the pool.
+# TYPE zfs_pool_free_bytes gauge
+zfs_pool_free_bytes{pool="testpool"} 1024
+`,
+		},
+		{
+			name:           `fragmentation unavailable for derived property`,
+			pools:          []string{`testpool`},
+			propsRequested: []string{`fragmentation_critical`, `free`},
+			propsFetched:   []string{`fragmentation`, `free`},
+			metricNames:    []string{`zfs_pool_fragmentation_critical`, `zfs_pool_free_bytes`, `zfs_pool_up`},
+			propsResults: map[string]map[string]string{
+				`testpool`: {
+					`fragmentation`: `-`,
+					`free`:          `1024`,
+				},
+			},
+			metricResults: `# HELP zfs_pool_free_bytes The amount of free space in bytes available in the pool.
+# TYPE zfs_pool_free_bytes gauge
+zfs_pool_free_bytes{pool="testpool"} 1024
+# HELP zfs_pool_up Whether the pool could be queried successfully [0: query failed, 1: query succeeded].
+# TYPE zfs_pool_up gauge
+zfs_pool_up{pool="testpool"} 1
 `,
 		},
 	}
@@ -199,6 +570,15 @@ zfs_pool_deduplication_ratio{pool="testpool"} 0.4
 			}
 
 			zfsClient.EXPECT().PoolNames().Return(tc.pools, nil).Times(1)
+			version := zfs.Version{Major: 2, Minor: 2, Patch: 2}
+			if tc.version != nil {
+				version = *tc.version
+			}
+			zfsClient.EXPECT().Version().Return(version, nil).AnyTimes()
+			propsFetched := tc.propsRequested
+			if tc.propsFetched != nil {
+				propsFetched = tc.propsFetched
+			}
 			for _, pool := range tc.pools {
 				if tc.explicitPools != nil {
 					wanted := false
@@ -215,7 +595,7 @@ zfs_pool_deduplication_ratio{pool="testpool"} 0.4
 				zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
 				zfsPoolProperties.EXPECT().Properties().Return(tc.propsResults[pool]).Times(1)
 				zfsPool := mock_zfs.NewMockPool(ctrl)
-				zfsPool.EXPECT().Properties(tc.propsRequested).Return(zfsPoolProperties, nil).Times(1)
+				zfsPool.EXPECT().Properties(propsFetched).Return(zfsPoolProperties, nil).Times(1)
 				zfsClient.EXPECT().Pool(pool).Return(zfsPool).Times(1)
 			}
 
@@ -238,3 +618,669 @@ zfs_pool_deduplication_ratio{pool="testpool"} 0.4
 		})
 	}
 }
+
+func TestPoolMetricsQueryError(t *testing.T) {
+	const result = `# HELP zfs_pool_allocated_bytes Amount of storage in bytes used within the pool.
+# TYPE zfs_pool_allocated_bytes gauge
+zfs_pool_allocated_bytes{pool="goodpool"} 1024
+# HELP zfs_pool_up Whether the pool could be queried successfully [0: query failed, 1: query succeeded].
+# TYPE zfs_pool_up gauge
+zfs_pool_up{pool="badpool"} 0
+zfs_pool_up{pool="goodpool"} 1
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`goodpool`, `badpool`}, nil).Times(1)
+
+	goodProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	goodProperties.EXPECT().Properties().Return(map[string]string{`allocated`: `1024`}).Times(1)
+	goodPool := mock_zfs.NewMockPool(ctrl)
+	goodPool.EXPECT().Properties(`allocated`).Return(goodProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`goodpool`).Return(goodPool).Times(1)
+
+	badPool := mock_zfs.NewMockPool(ctrl)
+	badPool.EXPECT().Properties(`allocated`).Return(nil, errors.New(`exit status 1`)).Times(1)
+	zfsClient.EXPECT().Pool(`badpool`).Return(badPool).Times(1)
+
+	config := defaultConfig(zfsClient)
+	config.DisableMetrics = false
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated`),
+			factory:    newPoolCollector,
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_allocated_bytes`, `zfs_pool_up`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolMetricsHealthFallback(t *testing.T) {
+	const result = `# HELP zfs_pool_health Health status code for the pool [0: ONLINE, 1: DEGRADED, 2: FAULTED, 3: OFFLINE, 4: UNAVAIL, 5: REMOVED, 6: SUSPENDED].
+# TYPE zfs_pool_health gauge
+zfs_pool_health{pool="testpool"} 1
+# HELP zfs_pool_up Whether the pool could be queried successfully [0: query failed, 1: query succeeded].
+# TYPE zfs_pool_up gauge
+zfs_pool_up{pool="testpool"} 0
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Properties(`allocated`, `health`).Return(nil, errors.New(`exit status 1`)).Times(1)
+	zfsPool.EXPECT().Health().Return(zfs.PoolDegraded, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated,health`),
+			factory:    newPoolCollector,
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_allocated_bytes`, `zfs_pool_health`, `zfs_pool_up`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolMetricsHealthState(t *testing.T) {
+	const result = `# HELP zfs_pool_health Health status code for the pool [0: ONLINE, 1: DEGRADED, 2: FAULTED, 3: OFFLINE, 4: UNAVAIL, 5: REMOVED, 6: SUSPENDED].
+# TYPE zfs_pool_health gauge
+zfs_pool_health{pool="rpool"} 0
+zfs_pool_health{pool="testpool"} 1
+# HELP zfs_pool_health_state Health status of the pool reported as the state label, with a constant value of 1.
+# TYPE zfs_pool_health_state gauge
+zfs_pool_health_state{pool="rpool",state="ONLINE"} 1
+zfs_pool_health_state{pool="testpool",state="DEGRADED"} 1
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`rpool`, `testpool`}, nil).Times(1)
+	for pool, health := range map[string]string{`rpool`: `ONLINE`, `testpool`: `DEGRADED`} {
+		zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+		zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`health`: health}).Times(1)
+		zfsPool := mock_zfs.NewMockPool(ctrl)
+		zfsPool.EXPECT().Properties(`health`).Return(zfsPoolProperties, nil).Times(1)
+		zfsClient.EXPECT().Pool(pool).Return(zfsPool).Times(1)
+	}
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`health`),
+			factory: func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+				return &poolCollector{log: l, client: c, props: props, healthState: true}, nil
+			},
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_health`, `zfs_pool_health_state`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolMetricsHealthTransitions(t *testing.T) {
+	scrapes := []struct {
+		health        string
+		metricResults string
+	}{
+		{
+			health: `ONLINE`,
+			metricResults: `# HELP zfs_pool_health_transitions_total Number of changes of pool health observed between scrapes since the exporter started.
+# TYPE zfs_pool_health_transitions_total counter
+zfs_pool_health_transitions_total{pool="testpool"} 0
+`,
+		},
+		{
+			health: `DEGRADED`,
+			metricResults: `# HELP zfs_pool_health_transitions_total Number of changes of pool health observed between scrapes since the exporter started.
+# TYPE zfs_pool_health_transitions_total counter
+zfs_pool_health_transitions_total{pool="testpool"} 1
+`,
+		},
+		{
+			// No change.
+			health: `DEGRADED`,
+			metricResults: `# HELP zfs_pool_health_transitions_total Number of changes of pool health observed between scrapes since the exporter started.
+# TYPE zfs_pool_health_transitions_total counter
+zfs_pool_health_transitions_total{pool="testpool"} 1
+`,
+		},
+		{
+			health: `ONLINE`,
+			metricResults: `# HELP zfs_pool_health_transitions_total Number of changes of pool health observed between scrapes since the exporter started.
+# TYPE zfs_pool_health_transitions_total counter
+zfs_pool_health_transitions_total{pool="testpool"} 2
+`,
+		},
+	}
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`health`),
+			factory:    newPoolCollectorFactory(),
+		},
+	}
+
+	for _, scrape := range scrapes {
+		zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+		zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+		zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`health`: scrape.health}).Times(1)
+		zfsPool := mock_zfs.NewMockPool(ctrl)
+		zfsPool.EXPECT().Properties(`health`).Return(zfsPoolProperties, nil).Times(1)
+		zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+		if err = callCollector(ctx, collector, []byte(scrape.metricResults), []string{`zfs_pool_health_transitions_total`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPoolMetricsList(t *testing.T) {
+	const result = `# HELP zfs_pool_allocated_bytes Amount of storage in bytes used within the pool.
+# TYPE zfs_pool_allocated_bytes gauge
+zfs_pool_allocated_bytes{pool="newpool"} 2048
+zfs_pool_allocated_bytes{pool="testpool"} 1024
+# HELP zfs_pool_health Health status code for the pool [0: ONLINE, 1: DEGRADED, 2: FAULTED, 3: OFFLINE, 4: UNAVAIL, 5: REMOVED, 6: SUSPENDED].
+# TYPE zfs_pool_health gauge
+zfs_pool_health{pool="newpool"} 0
+zfs_pool_health{pool="testpool"} 1
+# HELP zfs_pool_version The on-disk version of the pool, 5000 for pools using feature flags. Pools with a lower legacy version may be upgraded with "zpool upgrade".
+# TYPE zfs_pool_version gauge
+zfs_pool_version{pool="newpool"} 28
+zfs_pool_version{pool="testpool"} 5000
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`newpool`, `testpool`}, nil).Times(1)
+	// Properties available from zpool list are fetched for all pools at once, and the remainder per pool.
+	listedProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	listedProperties.EXPECT().Properties().Return(map[string]string{`allocated`: `1024`, `health`: `DEGRADED`}).Times(1)
+	zfsClient.EXPECT().PoolList(`allocated`, `health`).Return(map[string]zfs.PoolProperties{`testpool`: listedProperties}, nil).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`version`: `-`}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Properties(`version`).Return(zfsPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+	// A pool missing from the list, ie - imported since, has all properties fetched.
+	newPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	newPoolProperties.EXPECT().Properties().Return(map[string]string{`allocated`: `2048`, `health`: `ONLINE`, `version`: `28`}).Times(1)
+	newPool := mock_zfs.NewMockPool(ctrl)
+	newPool.EXPECT().Properties(`allocated`, `health`, `version`).Return(newPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`newpool`).Return(newPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated,health,version`),
+			factory: func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+				return &poolCollector{log: l, client: c, props: props, list: true}, nil
+			},
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_allocated_bytes`, `zfs_pool_health`, `zfs_pool_version`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolMetricsListError(t *testing.T) {
+	const result = `# HELP zfs_pool_allocated_bytes Amount of storage in bytes used within the pool.
+# TYPE zfs_pool_allocated_bytes gauge
+zfs_pool_allocated_bytes{pool="testpool"} 1024
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	// Properties are fetched per pool when zpool list fails.
+	zfsClient.EXPECT().PoolList(`allocated`).Return(nil, errors.New(`bad property list`)).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`allocated`: `1024`}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Properties(`allocated`).Return(zfsPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated`),
+			factory: func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+				return &poolCollector{log: l, client: c, props: props, list: true}, nil
+			},
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_allocated_bytes`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolMetricsAvailable(t *testing.T) {
+	const result = `# HELP zfs_pool_available_bytes Amount of storage in bytes available to datasets in the pool, the "available" property of the root dataset. Unlike free, this accounts for parity, the space reserved by ZFS and reservations.
+# TYPE zfs_pool_available_bytes gauge
+zfs_pool_available_bytes{pool="testpool"} 6.291456e+06
+# HELP zfs_pool_free_bytes The amount of free space in bytes available in the pool.
+# TYPE zfs_pool_free_bytes gauge
+zfs_pool_free_bytes{pool="testpool"} 1.048576e+07
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`free`: `10485760`}).Times(1)
+	zfsRootProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsRootProperties.EXPECT().Properties().Return(map[string]string{`available`: `6291456`}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Properties(`free`).Return(zfsPoolProperties, nil).Times(1)
+	zfsPool.EXPECT().RootProperties(`available`).Return(zfsRootProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`available,free`),
+			factory:    newPoolCollector,
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_available_bytes`, `zfs_pool_free_bytes`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolMetricsUnsupportedWarning(t *testing.T) {
+	ctrl, _ := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(2)
+	for i := 0; i < 2; i++ {
+		zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+		zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`free`: `1024`, `warnedprop`: `1`}).Times(1)
+		zfsPool := mock_zfs.NewMockPool(ctrl)
+		zfsPool.EXPECT().Properties(`free`, `warnedprop`).Return(zfsPoolProperties, nil).Times(1)
+		zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+	}
+
+	var buf bytes.Buffer
+	config := defaultConfig(zfsClient)
+	config.Logger = log.NewLogfmtLogger(log.NewSyncWriter(&buf))
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`free,warnedprop`),
+			factory:    newPoolCollector,
+		},
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	for i := 0; i < 2; i++ {
+		if _, err = registry.Gather(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := strings.Count(buf.String(), propertyUnsupportedMsg); got != 1 {
+		t.Fatalf("got %d unsupported property warnings, want 1:\n%s", got, buf.String())
+	}
+}
+
+func TestPoolMetricsLastScrape(t *testing.T) {
+	scrapes := []struct {
+		now           time.Time
+		err           error
+		metricResults string
+	}{
+		{
+			now: time.Unix(1700000000, 0),
+			metricResults: `# HELP zfs_pool_last_scrape_timestamp_seconds Time at which the pool was last queried successfully, in seconds since the epoch.
+# TYPE zfs_pool_last_scrape_timestamp_seconds gauge
+zfs_pool_last_scrape_timestamp_seconds{pool="testpool"} 1.7e+09
+`,
+		},
+		{
+			// A failed query reports the time of the last successful query.
+			now: time.Unix(1700000015, 0),
+			err: errors.New(`pool is busy`),
+			metricResults: `# HELP zfs_pool_last_scrape_timestamp_seconds Time at which the pool was last queried successfully, in seconds since the epoch.
+# TYPE zfs_pool_last_scrape_timestamp_seconds gauge
+zfs_pool_last_scrape_timestamp_seconds{pool="testpool"} 1.7e+09
+`,
+		},
+		{
+			now: time.Unix(1700000030, 0),
+			metricResults: `# HELP zfs_pool_last_scrape_timestamp_seconds Time at which the pool was last queried successfully, in seconds since the epoch.
+# TYPE zfs_pool_last_scrape_timestamp_seconds gauge
+zfs_pool_last_scrape_timestamp_seconds{pool="testpool"} 1.70000003e+09
+`,
+		},
+	}
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var now time.Time
+	lastScrape := &poolScrapeTimes{
+		now:   func() time.Time { return now },
+		times: make(map[string]time.Time),
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated`),
+			factory: func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+				return &poolCollector{log: l, client: c, props: props, lastScrape: lastScrape}, nil
+			},
+		},
+	}
+
+	for _, scrape := range scrapes {
+		now = scrape.now
+		zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+		zfsPool := mock_zfs.NewMockPool(ctrl)
+		if scrape.err != nil {
+			zfsPool.EXPECT().Properties(`allocated`).Return(nil, scrape.err).Times(1)
+		} else {
+			zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+			zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`allocated`: `1024`}).Times(1)
+			zfsPool.EXPECT().Properties(`allocated`).Return(zfsPoolProperties, nil).Times(1)
+		}
+		zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+		if err = callCollector(ctx, collector, []byte(scrape.metricResults), []string{`zfs_pool_last_scrape_timestamp_seconds`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPoolMetricsRawProperties(t *testing.T) {
+	const result = `# HELP zfs_pool_fragmentation_ratio The fragmentation ratio of the pool.
+# TYPE zfs_pool_fragmentation_ratio gauge
+zfs_pool_fragmentation_ratio{pool="testpool"} 0.05
+# HELP zfs_pool_health Health status code for the pool [0: ONLINE, 1: DEGRADED, 2: FAULTED, 3: OFFLINE, 4: UNAVAIL, 5: REMOVED, 6: SUSPENDED].
+# TYPE zfs_pool_health gauge
+zfs_pool_health{pool="testpool"} 0
+# HELP zfs_property_raw The unparsed value of the property as returned by zfs/zpool, reported as the value label with a constant value of 1. The name label is the dataset name, empty for pool properties.
+# TYPE zfs_property_raw gauge
+zfs_property_raw{collector="pool",name="",pool="testpool",property="health",value="ONLINE"} 1
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`fragmentation`: `5%`, `health`: `ONLINE`}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Properties(`fragmentation`, `health`).Return(zfsPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`fragmentation,health`),
+			factory: func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+				return &poolCollector{log: l, client: c, props: props, raw: newRawPropertySet(`health`)}, nil
+			},
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_fragmentation_ratio`, `zfs_pool_health`, `zfs_property_raw`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolExcludeProperties(t *testing.T) {
+	const result = `# HELP zfs_pool_allocated_bytes Amount of storage in bytes used within the pool.
+# TYPE zfs_pool_allocated_bytes gauge
+zfs_pool_allocated_bytes{pool="testpool"} 1024
+# HELP zfs_pool_up Whether the pool could be queried successfully [0: query failed, 1: query succeeded].
+# TYPE zfs_pool_up gauge
+zfs_pool_up{pool="testpool"} 1
+`
+	excludedName := `zfs_pool_deduplication_ratio`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`allocated`: `1024`}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Properties(`allocated`).Return(zfsPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:              "pool",
+			Enabled:           boolPointer(true),
+			Properties:        stringPointer(`allocated,dedupratio`),
+			ExcludeProperties: stringPointer(`dedupratio`),
+			factory:           newPoolCollector,
+			store:             &poolProperties,
+		},
+	}
+
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		collector.Describe(descs)
+		close(descs)
+	}()
+	for desc := range descs {
+		if strings.Contains(desc.String(), `"`+excludedName+`"`) {
+			t.Errorf("got description for excluded property: %s", desc)
+		}
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_allocated_bytes`, excludedName, `zfs_pool_up`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// poolRunner returns canned output for commands keyed by the full command line, failing commands with a canned error.
+type poolRunner struct {
+	output map[string]string
+	errors map[string]error
+}
+
+// Run implements the zfs.CommandRunner interface
+func (r poolRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	key := strings.Join(append([]string{name}, args...), ` `)
+	return poolRunnerOutput{Reader: strings.NewReader(r.output[key]), err: r.errors[key]}, nil
+}
+
+type poolRunnerOutput struct {
+	io.Reader
+	err error
+}
+
+func (o poolRunnerOutput) Close() error {
+	return o.err
+}
+
+func TestPoolMetricsPoolMissing(t *testing.T) {
+	const result = `# HELP zfs_pool_allocated_bytes Amount of storage in bytes used within the pool.
+# TYPE zfs_pool_allocated_bytes gauge
+zfs_pool_allocated_bytes{pool="goodpool"} 1024
+# HELP zfs_pool_up Whether the pool could be queried successfully [0: query failed, 1: query succeeded].
+# TYPE zfs_pool_up gauge
+zfs_pool_up{pool="goodpool"} 1
+# HELP zfs_scrape_collector_success zfs_exporter: Whether a collector succeeded.
+# TYPE zfs_scrape_collector_success gauge
+zfs_scrape_collector_success{collector="pool"} 1
+`
+
+	// The pool is exported between listing pools and querying its properties.
+	runner := poolRunner{
+		output: map[string]string{
+			`zpool list -Ho name`: "goodpool\ngonepool\n",
+			`zpool get -Hpo name,property,value allocated goodpool`: "goodpool\tallocated\t1024\n",
+		},
+		errors: map[string]error{
+			`zpool get -Hpo name,property,value allocated gonepool`: errors.New(`exit status 1: cannot open 'gonepool': no such pool`),
+		},
+	}
+
+	config := defaultConfig(zfs.New(zfs.Config{Runner: runner}))
+	config.DisableMetrics = false
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated`),
+			factory:    newPoolCollector,
+		},
+	}
+
+	if err = callCollector(context.Background(), collector, []byte(result), []string{`zfs_pool_allocated_bytes`, `zfs_pool_up`, `zfs_scrape_collector_success`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolMetricsFixtureDir(t *testing.T) {
+	const result = `# HELP zfs_pool_allocated_bytes Amount of storage in bytes used within the pool.
+# TYPE zfs_pool_allocated_bytes gauge
+zfs_pool_allocated_bytes{pool="tank"} 1024
+# HELP zfs_pool_size_bytes Total size in bytes of the storage pool.
+# TYPE zfs_pool_size_bytes gauge
+zfs_pool_size_bytes{pool="tank"} 4096
+# HELP zfs_pool_up Whether the pool could be queried successfully [0: query failed, 1: query succeeded].
+# TYPE zfs_pool_up gauge
+zfs_pool_up{pool="tank"} 1
+`
+
+	// Output captured from a host, ie - `zpool get -Hpo name,property,value allocated,size tank`.
+	dir := t.TempDir()
+	fixtures := map[string]string{
+		zfs.FixtureName(`zpool`, `list`, `-Ho`, `name`):                                          "tank\n",
+		zfs.FixtureName(`zpool`, `get`, `-Hpo`, `name,property,value`, `allocated,size`, `tank`): "tank\tallocated\t1024\ntank\tsize\t4096\n",
+	}
+	for name, output := range fixtures {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(output), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	collector, err := NewZFS(defaultConfig(zfs.New(zfs.Config{Runner: zfs.NewFixtureRunner(dir)})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated,size`),
+			factory:    newPoolCollector,
+		},
+	}
+
+	if err = callCollector(context.Background(), collector, []byte(result), []string{`zfs_pool_allocated_bytes`, `zfs_pool_size_bytes`, `zfs_pool_up`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolMetricsAvailableRaidz(t *testing.T) {
+	const result = `# HELP zfs_pool_available_bytes Amount of storage in bytes available to datasets in the pool, the "available" property of the root dataset. Unlike free, this accounts for parity, the space reserved by ZFS and reservations.
+# TYPE zfs_pool_available_bytes gauge
+zfs_pool_available_bytes{pool="raidpool"} 1.75921860444e+12
+# HELP zfs_pool_free_bytes The amount of free space in bytes available in the pool.
+# TYPE zfs_pool_free_bytes gauge
+zfs_pool_free_bytes{pool="raidpool"} 2.74877906944e+12
+`
+
+	// A raidz1 pool of three disks, where a third of the raw free space is consumed by parity once written.
+	dir := t.TempDir()
+	fixtures := map[string]string{
+		zfs.FixtureName(`zpool`, `list`, `-Ho`, `name`):                                       "raidpool\n",
+		zfs.FixtureName(`zpool`, `get`, `-Hpo`, `name,property,value`, `free`, `raidpool`):    "raidpool\tfree\t2748779069440\n",
+		zfs.FixtureName(`zfs`, `get`, `-Hpo`, `name,property,value`, `available`, `raidpool`): "raidpool\tavailable\t1759218604440\n",
+	}
+	for name, output := range fixtures {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(output), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	collector, err := NewZFS(defaultConfig(zfs.New(zfs.Config{Runner: zfs.NewFixtureRunner(dir)})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`available,free`),
+			factory:    newPoolCollector,
+		},
+	}
+
+	if err = callCollector(context.Background(), collector, []byte(result), []string{`zfs_pool_available_bytes`, `zfs_pool_free_bytes`}); err != nil {
+		t.Fatal(err)
+	}
+}
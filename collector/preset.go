@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// PresetDefault leaves collectors and properties at their registered defaults
+	PresetDefault = `default`
+	// PresetBasic enables a curated minimal set of collectors and properties
+	PresetBasic = `basic`
+	// PresetFull enables all collectors with all supported properties
+	PresetFull = `full`
+)
+
+var (
+	// PresetNames lists the available presets
+	PresetNames = []string{PresetDefault, PresetBasic, PresetFull}
+
+	// basicPreset maps collector names to the properties they collect, collectors not listed are disabled.
+	basicPreset = map[string]string{
+		`pool`:        `capacity,free,health`,
+		`pool-iostat`: defaultPoolIOStatProps,
+	}
+)
+
+// ApplyPreset updates the registered collectors to match the named preset. Collector flags explicitly set by the
+// user take precedence over the preset.
+func ApplyPreset(name string) error {
+	return applyPreset(collectorStates, name)
+}
+
+func applyPreset(states map[string]State, name string) error {
+	switch name {
+	case ``, PresetDefault:
+		return nil
+	case PresetBasic:
+		for collector, state := range states {
+			props, ok := basicPreset[collector]
+			if !state.isEnabledSetByUser() {
+				*state.Enabled = ok
+			}
+			if ok && state.Properties != nil && !state.isPropertiesSetByUser() {
+				*state.Properties = props
+			}
+		}
+	case PresetFull:
+		for _, state := range states {
+			if !state.isEnabledSetByUser() {
+				*state.Enabled = true
+			}
+			if state.store != nil && !state.isPropertiesSetByUser() {
+				*state.Properties = strings.Join(state.store.names(), `,`)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown preset: %s", name)
+	}
+
+	return nil
+}
+
+// DisableDefaultCollectors disables every registered collector that was not explicitly enabled by the user, so that
+// only the collectors requested via their flags are run.
+func DisableDefaultCollectors() {
+	disableDefaultCollectors(collectorStates)
+}
+
+func disableDefaultCollectors(states map[string]State) {
+	for _, state := range states {
+		if !state.isEnabledSetByUser() {
+			*state.Enabled = false
+		}
+	}
+}
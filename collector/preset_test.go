@@ -0,0 +1,261 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestApplyPreset(t *testing.T) {
+	testCases := []struct {
+		name           string
+		preset         string
+		userEnabled    map[string]bool
+		wantEnabled    map[string]bool
+		wantProperties map[string]string
+		wantErr        bool
+	}{
+		{
+			name:   `default`,
+			preset: PresetDefault,
+			wantEnabled: map[string]bool{
+				`pool`:               true,
+				`dataset-filesystem`: true,
+				`dataset-snapshot`:   false,
+			},
+			wantProperties: map[string]string{
+				`pool`:               defaultPoolProps,
+				`dataset-filesystem`: defaultFilesystemProps,
+				`dataset-snapshot`:   defaultSnapshotProps,
+			},
+		},
+		{
+			name:   `basic`,
+			preset: PresetBasic,
+			wantEnabled: map[string]bool{
+				`pool`:               true,
+				`dataset-filesystem`: false,
+				`dataset-snapshot`:   false,
+			},
+			wantProperties: map[string]string{
+				`pool`:               `capacity,free,health`,
+				`dataset-filesystem`: defaultFilesystemProps,
+				`dataset-snapshot`:   defaultSnapshotProps,
+			},
+		},
+		{
+			name:   `basic with user override`,
+			preset: PresetBasic,
+			userEnabled: map[string]bool{
+				`dataset-filesystem`: true,
+			},
+			wantEnabled: map[string]bool{
+				`pool`:               true,
+				`dataset-filesystem`: true,
+				`dataset-snapshot`:   false,
+			},
+			wantProperties: map[string]string{
+				`pool`:               `capacity,free,health`,
+				`dataset-filesystem`: defaultFilesystemProps,
+				`dataset-snapshot`:   defaultSnapshotProps,
+			},
+		},
+		{
+			name:   `full`,
+			preset: PresetFull,
+			wantEnabled: map[string]bool{
+				`pool`:               true,
+				`dataset-filesystem`: true,
+				`dataset-snapshot`:   true,
+			},
+			wantProperties: map[string]string{
+				`pool`:               strings.Join(poolProperties.names(), `,`),
+				`dataset-filesystem`: strings.Join(datasetProperties.names(), `,`),
+				`dataset-snapshot`:   strings.Join(datasetProperties.names(), `,`),
+			},
+		},
+		{
+			name:    `unknown`,
+			preset:  `unknown`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			states := map[string]State{
+				`pool`: {
+					Name:       `pool`,
+					Enabled:    boolPointer(true),
+					Properties: stringPointer(defaultPoolProps),
+					store:      &poolProperties,
+				},
+				`dataset-filesystem`: {
+					Name:       `dataset-filesystem`,
+					Enabled:    boolPointer(true),
+					Properties: stringPointer(defaultFilesystemProps),
+					store:      &datasetProperties,
+				},
+				`dataset-snapshot`: {
+					Name:       `dataset-snapshot`,
+					Enabled:    boolPointer(false),
+					Properties: stringPointer(defaultSnapshotProps),
+					store:      &datasetProperties,
+				},
+			}
+			for name, enabled := range tc.userEnabled {
+				state := states[name]
+				*state.Enabled = enabled
+				state.enabledSetByUser = boolPointer(true)
+				states[name] = state
+			}
+
+			err := applyPreset(states, tc.preset)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal(`expected error, got nil`)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range tc.wantEnabled {
+				if got := *states[name].Enabled; got != want {
+					t.Errorf("collector %s enabled = %t, want %t", name, got, want)
+				}
+			}
+			for name, want := range tc.wantProperties {
+				if got := *states[name].Properties; got != want {
+					t.Errorf("collector %s properties = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// registeredStates returns copies of the registered collector states at their defaults, so that presets may be applied
+// without modifying the flags of the registered collectors.
+func registeredStates() map[string]State {
+	states := make(map[string]State, len(collectorStates))
+	for name, state := range collectorStates {
+		state.Enabled = boolPointer(state.defaultEnabled)
+		if state.Properties != nil {
+			state.Properties = stringPointer(state.defaultProperties)
+		}
+		state.ExcludeProperties = nil
+		state.enabledSetByUser = nil
+		state.propertiesSetByUser = nil
+		states[name] = state
+	}
+	return states
+}
+
+func TestApplyPresetValidProperties(t *testing.T) {
+	for _, preset := range PresetNames {
+		states := registeredStates()
+		if err := applyPreset(states, preset); err != nil {
+			t.Fatal(err)
+		}
+		if err := validateProperties(states); err != nil {
+			t.Errorf("preset %s selected invalid properties: %s", preset, err)
+		}
+	}
+}
+
+func TestApplyPresetBasicCollectors(t *testing.T) {
+	want := map[string]string{
+		`pool`:        `capacity,free,health`,
+		`pool-iostat`: defaultPoolIOStatProps,
+	}
+
+	// Every registered collector is considered, so that collectors added later are checked against the preset.
+	states := registeredStates()
+	if err := applyPreset(states, PresetBasic); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for name, state := range states {
+		if !*state.Enabled {
+			continue
+		}
+		got[name] = ``
+		if state.Properties != nil {
+			got[name] = *state.Properties
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("basic preset collectors = %v, want %v", got, want)
+	}
+}
+
+func TestDisableDefaultCollectors(t *testing.T) {
+	const result = `# HELP zfs_pool_health Health status code for the pool [0: ONLINE, 1: DEGRADED, 2: FAULTED, 3: OFFLINE, 4: UNAVAIL, 5: REMOVED, 6: SUSPENDED].
+# TYPE zfs_pool_health gauge
+zfs_pool_health{pool="testpool"} 0
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	// Unexpected calls by any collector other than pool fail the test.
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`health`: `ONLINE`}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Properties(`health`).Return(zfsPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	states := map[string]State{
+		`pool`: {
+			Name:             `pool`,
+			Enabled:          boolPointer(true),
+			Properties:       stringPointer(`health`),
+			factory:          newPoolCollector,
+			store:            &poolProperties,
+			enabledSetByUser: boolPointer(true),
+		},
+		`dataset-filesystem`: {
+			Name:       `dataset-filesystem`,
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(defaultFilesystemProps),
+			factory:    newFilesystemCollector,
+			store:      &datasetProperties,
+		},
+		`dataset-snapshot`: {
+			Name:       `dataset-snapshot`,
+			Enabled:    boolPointer(false),
+			Properties: stringPointer(defaultSnapshotProps),
+			factory:    newSnapshotCollector,
+			store:      &datasetProperties,
+		},
+		`version`: {
+			Name:    `version`,
+			Enabled: boolPointer(true),
+			factory: newVersionCollector,
+		},
+	}
+	disableDefaultCollectors(states)
+
+	for name, state := range states {
+		if want := name == `pool`; *state.Enabled != want {
+			t.Errorf("collector %s enabled = %t, want %t", name, *state.Enabled, want)
+		}
+	}
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = states
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_pool_health`}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// rawProperties are the properties whose unparsed values are reported, for debugging.
+	rawProperties *string
+
+	propertyRawLabels   = []string{`collector`, `property`, `pool`, `name`, `value`}
+	propertyRawDescName = prometheus.BuildFQName(namespace, ``, `property_raw`)
+	propertyRawDesc     = prometheus.NewDesc(
+		propertyRawDescName,
+		`The unparsed value of the property as returned by zfs/zpool, reported as the value label with a constant value of 1. The name label is the dataset name, empty for pool properties.`,
+		propertyRawLabels,
+		nil,
+	)
+)
+
+func init() {
+	rawProperties = kingpin.Flag(`collector.raw-properties`, `Properties whose unparsed values are reported as zfs_property_raw by the pool and dataset collectors, comma-separated. For debugging only, as every distinct value creates a new series.`).String()
+}
+
+// rawPropertySet holds the properties whose unparsed values are reported.
+type rawPropertySet map[string]struct{}
+
+func newRawPropertySet(props string) rawPropertySet {
+	if props == `` {
+		return nil
+	}
+	result := make(rawPropertySet)
+	for _, prop := range strings.Split(props, `,`) {
+		result[prop] = struct{}{}
+	}
+	return result
+}
+
+func (r rawPropertySet) describe(ch chan<- *prometheus.Desc) {
+	if len(r) > 0 {
+		ch <- propertyRawDesc
+	}
+}
+
+// push reports the unparsed value of each fetched property in the set, for the pool, or the named dataset of the pool.
+func (r rawPropertySet) push(ch chan<- metric, collector, pool, name string, values map[string]string) {
+	for prop := range r {
+		value, ok := values[prop]
+		if !ok {
+			continue
+		}
+		labelValues := []string{collector, prop, pool, name, value}
+		ch <- metric{
+			name:       expandMetricName(propertyRawDescName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(propertyRawDesc, prometheus.GaugeValue, 1, labelValues...),
+		}
+	}
+}
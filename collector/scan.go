@@ -0,0 +1,241 @@
+package collector
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolScanProcessedRateDescName = prometheus.BuildFQName(namespace, subsystemPool, `scan_processed_bytes_per_second`)
+	poolScanProcessedRateDesc     = prometheus.NewDesc(
+		poolScanProcessedRateDescName,
+		`Rate at which the running scrub or resilver is scanning pool metadata, 0 if no scan is running.`,
+		poolLabels,
+		nil,
+	)
+	poolScanIssuedRateDescName = prometheus.BuildFQName(namespace, subsystemPool, `scan_issued_bytes_per_second`)
+	poolScanIssuedRateDesc     = prometheus.NewDesc(
+		poolScanIssuedRateDescName,
+		`Rate at which the running scrub or resilver is issuing I/O to the pool devices, 0 if no scan is running.`,
+		poolLabels,
+		nil,
+	)
+	poolResilverIssuedDescName = prometheus.BuildFQName(namespace, subsystemPool, `resilver_bytes_issued`)
+	poolResilverIssuedDesc     = prometheus.NewDesc(
+		poolResilverIssuedDescName,
+		`Amount of data in bytes for which the running resilver has issued I/O to the pool devices. Only reported while a resilver is running.`,
+		poolLabels,
+		nil,
+	)
+	poolResilverTotalDescName = prometheus.BuildFQName(namespace, subsystemPool, `resilver_bytes_total`)
+	poolResilverTotalDesc     = prometheus.NewDesc(
+		poolResilverTotalDescName,
+		`Total amount of data in bytes to be scanned by the running resilver. Only reported while a resilver is running.`,
+		poolLabels,
+		nil,
+	)
+	poolResilverRateDescName = prometheus.BuildFQName(namespace, subsystemPool, `resilver_rate_bytes_per_second`)
+	poolResilverRateDesc     = prometheus.NewDesc(
+		poolResilverRateDescName,
+		`Rate at which the running resilver is issuing I/O to the pool devices. Only reported while a resilver is running.`,
+		poolLabels,
+		nil,
+	)
+	poolResilverETADescName = prometheus.BuildFQName(namespace, subsystemPool, `resilver_eta_seconds`)
+	poolResilverETADesc     = prometheus.NewDesc(
+		poolResilverETADescName,
+		`Estimated time remaining in seconds until the running resilver completes. Only reported while a resilver is running, once an estimate is available.`,
+		poolLabels,
+		nil,
+	)
+	poolDRAIDRebuildProgressDescName = prometheus.BuildFQName(namespace, subsystemPool, `draid_rebuild_progress_ratio`)
+	poolDRAIDRebuildProgressDesc     = prometheus.NewDesc(
+		poolDRAIDRebuildProgressDescName,
+		`Progress of the running sequential rebuild of the dRAID vdev onto its distributed spare, as a ratio of the data scanned to the total. Only reported while a rebuild is running.`,
+		[]string{`pool`, `vdev`},
+		nil,
+	)
+	poolScrubsCompletedDescName = prometheus.BuildFQName(namespace, subsystemPool, `scrubs_completed_total`)
+	poolScrubsCompletedDesc     = prometheus.NewDesc(
+		poolScrubsCompletedDescName,
+		`Number of scrub completions observed between scrapes since the exporter started. Not reported until a completed scrub has been observed.`,
+		poolLabels,
+		nil,
+	)
+)
+
+func init() {
+	registerCollector(`pool-scan`, defaultDisabled, ``, nil, newPoolScanCollectorFactory())
+}
+
+// poolScrubCompletions holds the last completed scrub observed for each pool, and the resulting counts of scrub
+// completions. It outlives the collectors created for each scrape.
+type poolScrubCompletions struct {
+	sync.Mutex
+	// last maps pool names to the last completed scrub observed.
+	last map[string]zfs.ScanStatus
+	// counts maps pool names to the number of scrub completions observed for the pool.
+	counts map[string]uint64
+}
+
+// update records the scan of the pool, returning the number of scrub completions observed for the pool, and the last
+// completed scrub, if any has been observed. The first completed scrub observed for a pool is not counted, as it may
+// have finished before the exporter started.
+func (s *poolScrubCompletions) update(pool string, scan *zfs.ScanStatus) (uint64, zfs.ScanStatus, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	if scan != nil && scan.Function == zfs.ScanScrub && !scan.InProgress && !scan.EndTime.IsZero() {
+		if last, ok := s.last[pool]; ok && !last.EndTime.Equal(scan.EndTime) {
+			s.counts[pool]++
+		}
+		s.last[pool] = *scan
+	}
+	last, ok := s.last[pool]
+
+	return s.counts[pool], last, ok
+}
+
+// poolScanCollector reports the progress of scrubs and resilvers from `zpool status`.
+type poolScanCollector struct {
+	log    log.Logger
+	client zfs.Client
+	// scrubs records completed scrubs across scrapes, nil if not tracked.
+	scrubs *poolScrubCompletions
+}
+
+func (c *poolScanCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- poolScanProcessedRateDesc
+	ch <- poolScanIssuedRateDesc
+	ch <- poolResilverIssuedDesc
+	ch <- poolResilverTotalDesc
+	ch <- poolResilverRateDesc
+	ch <- poolResilverETADesc
+	ch <- poolDRAIDRebuildProgressDesc
+	if c.scrubs != nil {
+		ch <- poolScrubsCompletedDesc
+	}
+}
+
+func (c *poolScanCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool); err != nil && !isPoolMissing(c.log, `pool-scan`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *poolScanCollector) updatePoolMetrics(ch chan<- metric, pool string) error {
+	status, err := c.client.Pool(pool).Status()
+	if err != nil {
+		return err
+	}
+
+	var processedRate, issuedRate float64
+	if scan := status.Scan; scan != nil && scan.InProgress {
+		processedRate, issuedRate = scan.ScanRate, scan.IssueRate
+	}
+	ch <- metric{
+		name:       expandMetricName(poolScanProcessedRateDescName, pool),
+		prometheus: prometheus.MustNewConstMetric(poolScanProcessedRateDesc, prometheus.GaugeValue, processedRate, pool),
+	}
+	ch <- metric{
+		name:       expandMetricName(poolScanIssuedRateDescName, pool),
+		prometheus: prometheus.MustNewConstMetric(poolScanIssuedRateDesc, prometheus.GaugeValue, issuedRate, pool),
+	}
+	if scan := status.Scan; scan != nil && scan.InProgress && scan.Function == zfs.ScanResilver {
+		pushResilverMetrics(ch, pool, scan)
+	}
+	if scan := status.Scan; scan != nil && scan.InProgress && scan.TotalBytes > 0 &&
+		strings.HasPrefix(zfs.Vdev{Name: scan.RebuildVdev}.Layout(), `draid`) {
+		labelValues := []string{pool, scan.RebuildVdev}
+		progress := math.Min(float64(scan.ScannedBytes)/float64(scan.TotalBytes), 1)
+		ch <- metric{
+			name:       expandMetricName(poolDRAIDRebuildProgressDescName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(poolDRAIDRebuildProgressDesc, prometheus.GaugeValue, progress, labelValues...),
+		}
+	}
+	if c.scrubs != nil {
+		c.pushScrubsCompleted(ch, pool, status.Scan)
+	}
+
+	return nil
+}
+
+// pushResilverMetrics sends the progress of the running resilver of the pool, as reported by `zpool status`.
+func pushResilverMetrics(ch chan<- metric, pool string, scan *zfs.ScanStatus) {
+	ch <- metric{
+		name:       expandMetricName(poolResilverIssuedDescName, pool),
+		prometheus: prometheus.MustNewConstMetric(poolResilverIssuedDesc, prometheus.GaugeValue, float64(scan.IssuedBytes), pool),
+	}
+	ch <- metric{
+		name:       expandMetricName(poolResilverTotalDescName, pool),
+		prometheus: prometheus.MustNewConstMetric(poolResilverTotalDesc, prometheus.GaugeValue, float64(scan.TotalBytes), pool),
+	}
+	ch <- metric{
+		name:       expandMetricName(poolResilverRateDescName, pool),
+		prometheus: prometheus.MustNewConstMetric(poolResilverRateDesc, prometheus.GaugeValue, scan.IssueRate, pool),
+	}
+	if scan.Remaining > 0 {
+		ch <- metric{
+			name:       expandMetricName(poolResilverETADescName, pool),
+			prometheus: prometheus.MustNewConstMetric(poolResilverETADesc, prometheus.GaugeValue, scan.Remaining.Seconds(), pool),
+		}
+	}
+}
+
+// pushScrubsCompleted sends the count of scrub completions of the pool, with the completion time and error count of
+// the last scrub attached as an exemplar, which is exposed when the scrape negotiates the OpenMetrics format.
+func (c *poolScanCollector) pushScrubsCompleted(ch chan<- metric, pool string, scan *zfs.ScanStatus) {
+	count, last, ok := c.scrubs.update(pool, scan)
+	if !ok {
+		return
+	}
+	ch <- metric{
+		name: expandMetricName(poolScrubsCompletedDescName, pool),
+		prometheus: prometheus.MustNewMetricWithExemplars(
+			prometheus.MustNewConstMetric(poolScrubsCompletedDesc, prometheus.CounterValue, float64(count), pool),
+			prometheus.Exemplar{
+				Value:     1,
+				Labels:    prometheus.Labels{`errors`: strconv.FormatUint(last.Errors, 10)},
+				Timestamp: last.EndTime,
+			},
+		),
+	}
+}
+
+func newPoolScanCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	return &poolScanCollector{log: l, client: c}, nil
+}
+
+// newPoolScanCollectorFactory returns a factory for pool scan collectors that share the scrub completions observed
+// across scrapes.
+func newPoolScanCollectorFactory() factoryFunc {
+	scrubs := &poolScrubCompletions{
+		last:   make(map[string]zfs.ScanStatus),
+		counts: make(map[string]uint64),
+	}
+	return func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+		return &poolScanCollector{log: l, client: c, scrubs: scrubs}, nil
+	}
+}
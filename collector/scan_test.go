@@ -0,0 +1,201 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestPoolScanMetrics(t *testing.T) {
+	testCases := []struct {
+		name   string
+		status *zfs.Status
+		result string
+	}{
+		{
+			name: `resilver in progress`,
+			status: &zfs.Status{Scan: &zfs.ScanStatus{
+				Function:     zfs.ScanResilver,
+				InProgress:   true,
+				ScannedBytes: 1 << 40,
+				IssuedBytes:  800 << 30,
+				TotalBytes:   2 << 40,
+				ScanRate:     512 << 20,
+				IssueRate:    256 << 20,
+				Remaining:    time.Hour + 23*time.Minute + 45*time.Second,
+			}},
+			result: `# HELP zfs_pool_resilver_bytes_issued Amount of data in bytes for which the running resilver has issued I/O to the pool devices. Only reported while a resilver is running.
+# TYPE zfs_pool_resilver_bytes_issued gauge
+zfs_pool_resilver_bytes_issued{pool="testpool"} 8.589934592e+11
+# HELP zfs_pool_resilver_bytes_total Total amount of data in bytes to be scanned by the running resilver. Only reported while a resilver is running.
+# TYPE zfs_pool_resilver_bytes_total gauge
+zfs_pool_resilver_bytes_total{pool="testpool"} 2.199023255552e+12
+# HELP zfs_pool_resilver_eta_seconds Estimated time remaining in seconds until the running resilver completes. Only reported while a resilver is running, once an estimate is available.
+# TYPE zfs_pool_resilver_eta_seconds gauge
+zfs_pool_resilver_eta_seconds{pool="testpool"} 5025
+# HELP zfs_pool_resilver_rate_bytes_per_second Rate at which the running resilver is issuing I/O to the pool devices. Only reported while a resilver is running.
+# TYPE zfs_pool_resilver_rate_bytes_per_second gauge
+zfs_pool_resilver_rate_bytes_per_second{pool="testpool"} 2.68435456e+08
+# HELP zfs_pool_scan_issued_bytes_per_second Rate at which the running scrub or resilver is issuing I/O to the pool devices, 0 if no scan is running.
+# TYPE zfs_pool_scan_issued_bytes_per_second gauge
+zfs_pool_scan_issued_bytes_per_second{pool="testpool"} 2.68435456e+08
+# HELP zfs_pool_scan_processed_bytes_per_second Rate at which the running scrub or resilver is scanning pool metadata, 0 if no scan is running.
+# TYPE zfs_pool_scan_processed_bytes_per_second gauge
+zfs_pool_scan_processed_bytes_per_second{pool="testpool"} 5.36870912e+08
+`,
+		},
+		{
+			name: `dRAID rebuild in progress`,
+			status: &zfs.Status{Scan: &zfs.ScanStatus{
+				Function:     zfs.ScanResilver,
+				InProgress:   true,
+				RebuildVdev:  `draid2:4d:11c:1s-0`,
+				ScannedBytes: 1 << 40,
+				IssuedBytes:  512 << 30,
+				TotalBytes:   4 << 40,
+				ScanRate:     1 << 30,
+				IssueRate:    512 << 20,
+			}},
+			result: `# HELP zfs_pool_draid_rebuild_progress_ratio Progress of the running sequential rebuild of the dRAID vdev onto its distributed spare, as a ratio of the data scanned to the total. Only reported while a rebuild is running.
+# TYPE zfs_pool_draid_rebuild_progress_ratio gauge
+zfs_pool_draid_rebuild_progress_ratio{pool="testpool",vdev="draid2:4d:11c:1s-0"} 0.25
+# HELP zfs_pool_resilver_bytes_issued Amount of data in bytes for which the running resilver has issued I/O to the pool devices. Only reported while a resilver is running.
+# TYPE zfs_pool_resilver_bytes_issued gauge
+zfs_pool_resilver_bytes_issued{pool="testpool"} 5.49755813888e+11
+# HELP zfs_pool_resilver_bytes_total Total amount of data in bytes to be scanned by the running resilver. Only reported while a resilver is running.
+# TYPE zfs_pool_resilver_bytes_total gauge
+zfs_pool_resilver_bytes_total{pool="testpool"} 4.398046511104e+12
+# HELP zfs_pool_resilver_rate_bytes_per_second Rate at which the running resilver is issuing I/O to the pool devices. Only reported while a resilver is running.
+# TYPE zfs_pool_resilver_rate_bytes_per_second gauge
+zfs_pool_resilver_rate_bytes_per_second{pool="testpool"} 5.36870912e+08
+# HELP zfs_pool_scan_issued_bytes_per_second Rate at which the running scrub or resilver is issuing I/O to the pool devices, 0 if no scan is running.
+# TYPE zfs_pool_scan_issued_bytes_per_second gauge
+zfs_pool_scan_issued_bytes_per_second{pool="testpool"} 5.36870912e+08
+# HELP zfs_pool_scan_processed_bytes_per_second Rate at which the running scrub or resilver is scanning pool metadata, 0 if no scan is running.
+# TYPE zfs_pool_scan_processed_bytes_per_second gauge
+zfs_pool_scan_processed_bytes_per_second{pool="testpool"} 1.073741824e+09
+`,
+		},
+		{
+			name:   `scrub finished`,
+			status: &zfs.Status{Scan: &zfs.ScanStatus{Function: zfs.ScanScrub}},
+			result: `# HELP zfs_pool_scan_issued_bytes_per_second Rate at which the running scrub or resilver is issuing I/O to the pool devices, 0 if no scan is running.
+# TYPE zfs_pool_scan_issued_bytes_per_second gauge
+zfs_pool_scan_issued_bytes_per_second{pool="testpool"} 0
+# HELP zfs_pool_scan_processed_bytes_per_second Rate at which the running scrub or resilver is scanning pool metadata, 0 if no scan is running.
+# TYPE zfs_pool_scan_processed_bytes_per_second gauge
+zfs_pool_scan_processed_bytes_per_second{pool="testpool"} 0
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl, ctx := gomock.WithContext(context.Background(), t)
+			zfsClient := mock_zfs.NewMockClient(ctrl)
+			zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+			zfsPool := mock_zfs.NewMockPool(ctrl)
+			zfsPool.EXPECT().Status().Return(tc.status, nil).Times(1)
+			zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+			collector, err := NewZFS(defaultConfig(zfsClient))
+			if err != nil {
+				t.Fatal(err)
+			}
+			collector.Collectors = map[string]State{
+				`pool-scan`: {
+					Name:    "pool-scan",
+					Enabled: boolPointer(true),
+					factory: newPoolScanCollector,
+				},
+			}
+
+			metricNames := []string{
+				`zfs_pool_draid_rebuild_progress_ratio`,
+				`zfs_pool_resilver_bytes_issued`,
+				`zfs_pool_resilver_bytes_total`,
+				`zfs_pool_resilver_eta_seconds`,
+				`zfs_pool_resilver_rate_bytes_per_second`,
+				`zfs_pool_scan_issued_bytes_per_second`,
+				`zfs_pool_scan_processed_bytes_per_second`,
+			}
+			if err = callCollector(ctx, collector, []byte(tc.result), metricNames); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestPoolScanScrubsCompleted(t *testing.T) {
+	finished := func(day int) *zfs.Status {
+		return &zfs.Status{Scan: &zfs.ScanStatus{
+			Function: zfs.ScanScrub,
+			EndTime:  time.Date(2021, time.October, day, 10, 10, 0, 0, time.UTC),
+		}}
+	}
+	scrapes := []struct {
+		status *zfs.Status
+		result string
+	}{
+		{
+			status: &zfs.Status{},
+		},
+		{
+			status: finished(3),
+			result: `# HELP zfs_pool_scrubs_completed_total Number of scrub completions observed between scrapes since the exporter started. Not reported until a completed scrub has been observed.
+# TYPE zfs_pool_scrubs_completed_total counter
+zfs_pool_scrubs_completed_total{pool="testpool"} 0
+`,
+		},
+		{
+			status: &zfs.Status{Scan: &zfs.ScanStatus{Function: zfs.ScanScrub, InProgress: true}},
+			result: `# HELP zfs_pool_scrubs_completed_total Number of scrub completions observed between scrapes since the exporter started. Not reported until a completed scrub has been observed.
+# TYPE zfs_pool_scrubs_completed_total counter
+zfs_pool_scrubs_completed_total{pool="testpool"} 0
+`,
+		},
+		{
+			status: finished(10),
+			result: `# HELP zfs_pool_scrubs_completed_total Number of scrub completions observed between scrapes since the exporter started. Not reported until a completed scrub has been observed.
+# TYPE zfs_pool_scrubs_completed_total counter
+zfs_pool_scrubs_completed_total{pool="testpool"} 1
+`,
+		},
+		{
+			status: finished(10),
+			result: `# HELP zfs_pool_scrubs_completed_total Number of scrub completions observed between scrapes since the exporter started. Not reported until a completed scrub has been observed.
+# TYPE zfs_pool_scrubs_completed_total counter
+zfs_pool_scrubs_completed_total{pool="testpool"} 1
+`,
+		},
+	}
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool-scan`: {
+			Name:    "pool-scan",
+			Enabled: boolPointer(true),
+			factory: newPoolScanCollectorFactory(),
+		},
+	}
+
+	for _, scrape := range scrapes {
+		zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+		zfsPool := mock_zfs.NewMockPool(ctrl)
+		zfsPool.EXPECT().Status().Return(scrape.status, nil).Times(1)
+		zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+		if err = callCollector(ctx, collector, []byte(scrape.result), []string{`zfs_pool_scrubs_completed_total`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
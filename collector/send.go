@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const subsystemSend = `send`
+
+var (
+	// sendLogPaths are the paths of the `zfs send` logs followed by the send-progress collector.
+	sendLogPaths *[]string
+
+	sendLabels        = []string{`dataset`}
+	sendBytesDescName = prometheus.BuildFQName(namespace, subsystemSend, `bytes`)
+	sendBytesDesc     = prometheus.NewDesc(
+		sendBytesDescName,
+		`The amount of data in bytes sent from snapshots of the dataset, as logged by "zfs send". Decreases when a stream is restarted or the log is replaced.`,
+		sendLabels,
+		nil,
+	)
+	sendEstimatedDescName = prometheus.BuildFQName(namespace, subsystemSend, `estimated_bytes`)
+	sendEstimatedDesc     = prometheus.NewDesc(
+		sendEstimatedDescName,
+		`The estimated size in bytes of the streams sent from snapshots of the dataset, as logged by "zfs send".`,
+		sendLabels,
+		nil,
+	)
+)
+
+func init() {
+	registerCollector(`send-progress`, defaultDisabled, ``, nil, newSendProgressFactory())
+
+	sendLogPaths = kingpin.Flag(`collector.send-progress.path`, `Path of a log of "zfs send -v" or "zfs send -P" output to follow for the send-progress collector, may be specified multiple times.`).Strings()
+}
+
+// sendLog holds the position reached in a send log, and the progress parsed up to that position. It outlives the
+// collectors created for each scrape, so that each scrape only reads the lines appended since the last.
+type sendLog struct {
+	file   os.FileInfo
+	offset int64
+	parser *zfs.SendProgressParser
+}
+
+// sendLogs holds the state of each followed send log, by path.
+type sendLogs struct {
+	sync.Mutex
+	logs map[string]*sendLog
+}
+
+// read parses the lines appended to the log at path since the last read, returning the progress of each stream in the
+// log. The log is read from the start if it has been truncated or replaced, ie - by the next run of a replication job.
+// Lines that fail to parse are logged and skipped, so that a single corrupt line does not stall the log.
+func (s *sendLogs) read(l log.Logger, path string) ([]zfs.SendProgress, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		delete(s.logs, path)
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	sl, ok := s.logs[path]
+	if !ok || !os.SameFile(sl.file, info) || info.Size() < sl.offset {
+		sl = &sendLog{parser: zfs.NewSendProgressParser()}
+		s.logs[path] = sl
+	}
+	sl.file = info
+
+	if _, err = f.Seek(sl.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	// A trailing partial line is left to be read once complete.
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		data = data[:i+1]
+	} else {
+		data = nil
+	}
+	for _, line := range bytes.Split(bytes.TrimSuffix(data, []byte{'\n'}), []byte{'\n'}) {
+		if err = sl.parser.ParseLine(string(line)); err != nil {
+			_ = level.Warn(l).Log(`msg`, `Error parsing send log line, skipping`, `collector`, `send-progress`, `path`, path, `line`, line, `err`, err)
+		}
+	}
+	sl.offset += int64(len(data))
+
+	return sl.parser.Progress(), nil
+}
+
+// sendProgressCollector reports the progress of `zfs send` streams, parsed from logs of their verbose output.
+type sendProgressCollector struct {
+	log   log.Logger
+	paths []string
+	logs  *sendLogs
+}
+
+func (c *sendProgressCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- sendBytesDesc
+	ch <- sendEstimatedDesc
+}
+
+func (c *sendProgressCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	sent := make(map[string]uint64)
+	estimated := make(map[string]uint64)
+	for _, path := range c.paths {
+		progress, err := c.logs.read(c.log, path)
+		if err != nil {
+			// Logs are only present while replication jobs are configured to write them.
+			if errors.Is(err, fs.ErrNotExist) {
+				_ = level.Debug(c.log).Log(`msg`, `Send log does not exist, skipping`, `path`, path)
+				continue
+			}
+			return err
+		}
+		// Streams of several snapshots of a dataset (ie - from `zfs send -I`) are summed per dataset.
+		for _, p := range progress {
+			if excludes.MatchString(p.Snapshot) {
+				continue
+			}
+			sent[p.Dataset()] += p.SentBytes
+			estimated[p.Dataset()] += p.EstimatedBytes
+		}
+	}
+
+	for dataset := range sent {
+		ch <- metric{
+			name:       expandMetricName(sendBytesDescName, dataset),
+			prometheus: prometheus.MustNewConstMetric(sendBytesDesc, prometheus.GaugeValue, float64(sent[dataset]), dataset),
+		}
+		if estimated[dataset] == 0 {
+			continue
+		}
+		ch <- metric{
+			name:       expandMetricName(sendEstimatedDescName, dataset),
+			prometheus: prometheus.MustNewConstMetric(sendEstimatedDesc, prometheus.GaugeValue, float64(estimated[dataset]), dataset),
+		}
+	}
+
+	return nil
+}
+
+// newSendProgressFactory returns a factory for collectors sharing the state of the followed logs across scrapes.
+func newSendProgressFactory() factoryFunc {
+	logs := &sendLogs{logs: make(map[string]*sendLog)}
+	return func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+		return &sendProgressCollector{log: l, paths: *sendLogPaths, logs: logs}, nil
+	}
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestSendProgressMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `send.log`)
+	scrapes := []struct {
+		name   string
+		write  func() error
+		result string
+	}{
+		{
+			name:  `no log`,
+			write: func() error { return nil },
+		},
+		{
+			name: `stream started`,
+			write: func() error {
+				return os.WriteFile(path, []byte(`full send of tank/data@daily estimated size is 1.50G
+send from @daily to tank/data@hourly estimated size is 12.0M
+total estimated size is 1.51G
+TIME        SENT   SNAPSHOT tank/data@daily
+10:00:01   512M   tank/data@daily
+10:00:02   1.00G   tank/d`), 0o644)
+			},
+			result: `# HELP zfs_send_bytes The amount of data in bytes sent from snapshots of the dataset, as logged by "zfs send". Decreases when a stream is restarted or the log is replaced.
+# TYPE zfs_send_bytes gauge
+zfs_send_bytes{dataset="tank/data"} 5.36870912e+08
+# HELP zfs_send_estimated_bytes The estimated size in bytes of the streams sent from snapshots of the dataset, as logged by "zfs send".
+# TYPE zfs_send_estimated_bytes gauge
+zfs_send_estimated_bytes{dataset="tank/data"} 1.623195648e+09
+`,
+		},
+		{
+			name: `stream appended`,
+			write: func() error {
+				f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				_, err = f.WriteString(`ata@daily
+TIME        SENT   SNAPSHOT tank/data@hourly
+10:00:03   4.00M   tank/data@hourly
+`)
+				return err
+			},
+			result: `# HELP zfs_send_bytes The amount of data in bytes sent from snapshots of the dataset, as logged by "zfs send". Decreases when a stream is restarted or the log is replaced.
+# TYPE zfs_send_bytes gauge
+zfs_send_bytes{dataset="tank/data"} 1.077936128e+09
+# HELP zfs_send_estimated_bytes The estimated size in bytes of the streams sent from snapshots of the dataset, as logged by "zfs send".
+# TYPE zfs_send_estimated_bytes gauge
+zfs_send_estimated_bytes{dataset="tank/data"} 1.623195648e+09
+`,
+		},
+		{
+			name: `invalid line skipped`,
+			write: func() error {
+				f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				_, err = f.WriteString(`10:00:04   4.00Q   tank/data@hourly
+10:00:05   8.00M   tank/data@hourly
+`)
+				return err
+			},
+			result: `# HELP zfs_send_bytes The amount of data in bytes sent from snapshots of the dataset, as logged by "zfs send". Decreases when a stream is restarted or the log is replaced.
+# TYPE zfs_send_bytes gauge
+zfs_send_bytes{dataset="tank/data"} 1.082130432e+09
+# HELP zfs_send_estimated_bytes The estimated size in bytes of the streams sent from snapshots of the dataset, as logged by "zfs send".
+# TYPE zfs_send_estimated_bytes gauge
+zfs_send_estimated_bytes{dataset="tank/data"} 1.623195648e+09
+`,
+		},
+		{
+			name: `log replaced`,
+			write: func() error {
+				replacement := path + `.new`
+				if err := os.WriteFile(replacement, []byte("incremental\ttank/data@hourly\ttank/data@next\t8192\n10:00:01\t4096\ttank/data@next\n"), 0o644); err != nil {
+					return err
+				}
+				return os.Rename(replacement, path)
+			},
+			result: `# HELP zfs_send_bytes The amount of data in bytes sent from snapshots of the dataset, as logged by "zfs send". Decreases when a stream is restarted or the log is replaced.
+# TYPE zfs_send_bytes gauge
+zfs_send_bytes{dataset="tank/data"} 4096
+# HELP zfs_send_estimated_bytes The estimated size in bytes of the streams sent from snapshots of the dataset, as logged by "zfs send".
+# TYPE zfs_send_estimated_bytes gauge
+zfs_send_estimated_bytes{dataset="tank/data"} 8192
+`,
+		},
+	}
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := &sendLogs{logs: make(map[string]*sendLog)}
+	collector.Collectors = map[string]State{
+		`send-progress`: {
+			Name:    "send-progress",
+			Enabled: boolPointer(true),
+			factory: func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+				return &sendProgressCollector{log: l, paths: []string{path}, logs: logs}, nil
+			},
+		},
+	}
+
+	for _, scrape := range scrapes {
+		if err = scrape.write(); err != nil {
+			t.Fatal(err)
+		}
+		zfsClient.EXPECT().PoolNames().Return([]string{`tank`}, nil).Times(1)
+		if err = callCollector(ctx, collector, []byte(scrape.result), []string{`zfs_send_bytes`, `zfs_send_estimated_bytes`}); err != nil {
+			t.Fatalf("%s: %s", scrape.name, err)
+		}
+	}
+}
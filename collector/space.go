@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// spaceExclude is a regex matching the names of users and groups to exclude from the dataset-userspace collector.
+	spaceExclude *string
+	// spaceMaxEntries bounds the number of users, and of groups, reported per dataset, 0 is unlimited.
+	spaceMaxEntries *int
+
+	spaceKinds   = []zfs.SpaceKind{zfs.SpaceUser, zfs.SpaceGroup}
+	spaceMetrics = map[zfs.SpaceKind]spaceMetric{
+		zfs.SpaceUser:  newSpaceMetric(`user`),
+		zfs.SpaceGroup: newSpaceMetric(`group`),
+	}
+)
+
+// spaceMetric holds the descriptors of the used space and quota metrics for users or groups.
+type spaceMetric struct {
+	usedName  string
+	used      *prometheus.Desc
+	quotaName string
+	quota     *prometheus.Desc
+}
+
+// newSpaceMetric returns the descriptors for the identity, ie - `user` or `group`, which is also the label name.
+func newSpaceMetric(identity string) spaceMetric {
+	labels := []string{`name`, `pool`, identity}
+	m := spaceMetric{
+		usedName:  prometheus.BuildFQName(namespace, subsystemDataset, identity+`used_bytes`),
+		quotaName: prometheus.BuildFQName(namespace, subsystemDataset, identity+`quota_bytes`),
+	}
+	m.used = prometheus.NewDesc(
+		m.usedName,
+		fmt.Sprintf(`The amount of space in bytes consumed by the %s within the dataset.`, identity),
+		labels,
+		nil,
+	)
+	m.quota = prometheus.NewDesc(
+		m.quotaName,
+		fmt.Sprintf(`The quota in bytes of the %[1]s within the dataset. Not reported for %[1]ss without a quota.`, identity),
+		labels,
+		nil,
+	)
+	return m
+}
+
+func init() {
+	registerCollector(`dataset-userspace`, defaultDisabled, ``, nil, newSpaceCollector)
+
+	spaceExclude = kingpin.Flag(`collector.dataset-userspace.exclude`, `Exclude users and groups whose name matches the provided regex from the dataset-userspace collector (e.g. '^[0-9]+$' to exclude IDs without a name).`).String()
+	spaceMaxEntries = kingpin.Flag(`collector.dataset-userspace.max-entries`, `Maximum number of users, and of groups, reported per dataset by the dataset-userspace collector, keeping those consuming the most space. 0 is unlimited.`).Default(`1000`).Int()
+}
+
+// spaceCollector reports the space consumed by, and the quotas of, each user and group within filesystems, via
+// `zfs userspace` and `zfs groupspace`.
+type spaceCollector struct {
+	log        log.Logger
+	client     zfs.Client
+	exclude    *regexp.Regexp
+	maxEntries int
+}
+
+func (c *spaceCollector) describe(ch chan<- *prometheus.Desc) {
+	for _, kind := range spaceKinds {
+		ch <- spaceMetrics[kind].used
+		ch <- spaceMetrics[kind].quota
+	}
+}
+
+func (c *spaceCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool, excludes); err != nil && !isPoolMissing(c.log, `dataset-userspace`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *spaceCollector) updatePoolMetrics(ch chan<- metric, pool string, excludes regexpCollection) error {
+	datasets, err := c.client.Datasets(pool, zfs.DatasetFilesystem).Properties(`used`)
+	if err != nil {
+		return err
+	}
+
+	for _, dataset := range datasets {
+		name := dataset.DatasetName()
+		if excludes.MatchString(name) {
+			continue
+		}
+		for _, kind := range spaceKinds {
+			usage, err := c.client.Space(name, kind)
+			if err != nil {
+				// Datasets destroyed since they were listed are skipped.
+				if errors.Is(err, zfs.ErrPoolNotFound) || errors.Is(err, zfs.ErrDatasetNotFound) {
+					continue
+				}
+				return err
+			}
+			c.pushUsage(ch, pool, name, kind, usage)
+		}
+	}
+
+	return nil
+}
+
+// pushUsage sends the used space and quota of each user or group in usage, limited to the maxEntries consuming the most
+// space.
+func (c *spaceCollector) pushUsage(ch chan<- metric, pool, name string, kind zfs.SpaceKind, usage []zfs.SpaceUsage) {
+	if c.exclude != nil {
+		filtered := usage[:0]
+		for _, u := range usage {
+			if !c.exclude.MatchString(u.Name) {
+				filtered = append(filtered, u)
+			}
+		}
+		usage = filtered
+	}
+	if c.maxEntries > 0 && len(usage) > c.maxEntries {
+		sort.SliceStable(usage, func(i, j int) bool {
+			return usage[i].UsedBytes > usage[j].UsedBytes
+		})
+		_ = level.Warn(c.log).Log(`msg`, `Truncating dataset-userspace entries`, `dataset`, name, `kind`, kind, `entries`, len(usage), `max`, c.maxEntries)
+		usage = usage[:c.maxEntries]
+	}
+
+	m := spaceMetrics[kind]
+	for _, u := range usage {
+		labelValues := []string{name, pool, u.Name}
+		ch <- metric{
+			name:       expandMetricName(m.usedName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(m.used, prometheus.GaugeValue, float64(u.UsedBytes), labelValues...),
+		}
+		if u.QuotaBytes == 0 {
+			continue
+		}
+		ch <- metric{
+			name:       expandMetricName(m.quotaName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(m.quota, prometheus.GaugeValue, float64(u.QuotaBytes), labelValues...),
+		}
+	}
+}
+
+func newSpaceCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	collector := &spaceCollector{log: l, client: c, maxEntries: *spaceMaxEntries}
+	if *spaceExclude != `` {
+		exclude, err := regexp.Compile(*spaceExclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dataset-userspace exclude %q: %w", *spaceExclude, err)
+		}
+		collector.exclude = exclude
+	}
+	return collector, nil
+}
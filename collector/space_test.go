@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestSpaceMetrics(t *testing.T) {
+	testCases := []struct {
+		name       string
+		exclude    *regexp.Regexp
+		maxEntries int
+		users      []zfs.SpaceUsage
+		groups     []zfs.SpaceUsage
+		result     string
+	}{
+		{
+			name: `users and groups`,
+			users: []zfs.SpaceUsage{
+				{Type: `POSIX User`, Name: `alice`, UsedBytes: 1 << 30, QuotaBytes: 2 << 30},
+				{Type: `POSIX User`, Name: `1001`, UsedBytes: 4096},
+			},
+			groups: []zfs.SpaceUsage{
+				{Type: `POSIX Group`, Name: `staff`, UsedBytes: 8192, QuotaBytes: 1 << 20},
+			},
+			result: `# HELP zfs_dataset_groupquota_bytes The quota in bytes of the group within the dataset. Not reported for groups without a quota.
+# TYPE zfs_dataset_groupquota_bytes gauge
+zfs_dataset_groupquota_bytes{group="staff",name="testpool/home",pool="testpool"} 1.048576e+06
+# HELP zfs_dataset_groupused_bytes The amount of space in bytes consumed by the group within the dataset.
+# TYPE zfs_dataset_groupused_bytes gauge
+zfs_dataset_groupused_bytes{group="staff",name="testpool/home",pool="testpool"} 8192
+# HELP zfs_dataset_userquota_bytes The quota in bytes of the user within the dataset. Not reported for users without a quota.
+# TYPE zfs_dataset_userquota_bytes gauge
+zfs_dataset_userquota_bytes{name="testpool/home",pool="testpool",user="alice"} 2.147483648e+09
+# HELP zfs_dataset_userused_bytes The amount of space in bytes consumed by the user within the dataset.
+# TYPE zfs_dataset_userused_bytes gauge
+zfs_dataset_userused_bytes{name="testpool/home",pool="testpool",user="1001"} 4096
+zfs_dataset_userused_bytes{name="testpool/home",pool="testpool",user="alice"} 1.073741824e+09
+`,
+		},
+		{
+			name:    `excluded users`,
+			exclude: regexp.MustCompile(`^[0-9]+$`),
+			users: []zfs.SpaceUsage{
+				{Type: `POSIX User`, Name: `alice`, UsedBytes: 1 << 30},
+				{Type: `POSIX User`, Name: `1001`, UsedBytes: 4096},
+			},
+			result: `# HELP zfs_dataset_userused_bytes The amount of space in bytes consumed by the user within the dataset.
+# TYPE zfs_dataset_userused_bytes gauge
+zfs_dataset_userused_bytes{name="testpool/home",pool="testpool",user="alice"} 1.073741824e+09
+`,
+		},
+		{
+			name:       `entries capped`,
+			maxEntries: 2,
+			users: []zfs.SpaceUsage{
+				{Type: `POSIX User`, Name: `alice`, UsedBytes: 1024},
+				{Type: `POSIX User`, Name: `bob`, UsedBytes: 4096},
+				{Type: `POSIX User`, Name: `carol`, UsedBytes: 2048},
+			},
+			result: `# HELP zfs_dataset_userused_bytes The amount of space in bytes consumed by the user within the dataset.
+# TYPE zfs_dataset_userused_bytes gauge
+zfs_dataset_userused_bytes{name="testpool/home",pool="testpool",user="bob"} 4096
+zfs_dataset_userused_bytes{name="testpool/home",pool="testpool",user="carol"} 2048
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl, ctx := gomock.WithContext(context.Background(), t)
+			zfsClient := mock_zfs.NewMockClient(ctrl)
+			zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+			zfsDatasetProperties := mock_zfs.NewMockDatasetProperties(ctrl)
+			zfsDatasetProperties.EXPECT().DatasetName().Return(`testpool/home`).Times(1)
+			zfsDatasets := mock_zfs.NewMockDatasets(ctrl)
+			zfsDatasets.EXPECT().Properties(`used`).Return([]zfs.DatasetProperties{zfsDatasetProperties}, nil).Times(1)
+			zfsClient.EXPECT().Datasets(`testpool`, zfs.DatasetFilesystem).Return(zfsDatasets).Times(1)
+			zfsClient.EXPECT().Space(`testpool/home`, zfs.SpaceUser).Return(tc.users, nil).Times(1)
+			zfsClient.EXPECT().Space(`testpool/home`, zfs.SpaceGroup).Return(tc.groups, nil).Times(1)
+
+			collector, err := NewZFS(defaultConfig(zfsClient))
+			if err != nil {
+				t.Fatal(err)
+			}
+			collector.Collectors = map[string]State{
+				`dataset-userspace`: {
+					Name:    "dataset-userspace",
+					Enabled: boolPointer(true),
+					factory: func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+						return &spaceCollector{log: l, client: c, exclude: tc.exclude, maxEntries: tc.maxEntries}, nil
+					},
+				},
+			}
+
+			metricNames := []string{
+				`zfs_dataset_groupquota_bytes`,
+				`zfs_dataset_groupused_bytes`,
+				`zfs_dataset_userquota_bytes`,
+				`zfs_dataset_userused_bytes`,
+			}
+			if err = callCollector(ctx, collector, []byte(tc.result), metricNames); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -3,10 +3,14 @@ package collector
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/pdf/zfs_exporter/v2/zfs"
 )
 
+// unlimitedValue is reported by `zfs get -p` for limits that are not set.
+const unlimitedValue = `18446744073709551615`
+
 type poolHealthCode int
 
 const (
@@ -19,11 +23,93 @@ const (
 	poolSuspended
 )
 
-func transformNumeric(value string) (float64, error) {
+// healthCodeHelp describes the health status codes produced by transformHealthCode, for inclusion in metric help.
+var healthCodeHelp = fmt.Sprintf("[%d: %s, %d: %s, %d: %s, %d: %s, %d: %s, %d: %s, %d: %s]",
+	poolOnline, zfs.PoolOnline,
+	poolDegraded, zfs.PoolDegraded,
+	poolFaulted, zfs.PoolFaulted,
+	poolOffline, zfs.PoolOffline,
+	poolUnavail, zfs.PoolUnavail,
+	poolRemoved, zfs.PoolRemoved,
+	poolSuspended, zfs.PoolSuspended,
+)
+
+// humanSizeUnits are the multipliers of the unit suffixes of human-readable sizes.
+var humanSizeUnits = map[byte]float64{
+	'K': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+	'T': 1 << 40,
+	'P': 1 << 50,
+	'E': 1 << 60,
+}
+
+// TransformNumeric converts a numeric property value, treating `-` and `none` as 0. Human-readable sizes (ie - `1.50T`)
+// are also accepted, should values not be reported in parseable form. Returns ErrValueNotFinite for NaN or Inf.
+func TransformNumeric(value string) (float64, error) {
 	if value == `-` || value == `none` {
 		return 0, nil
 	}
-	return strconv.ParseFloat(value, 64)
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		if size, sizeErr := transformHuman(value); sizeErr == nil {
+			return size, nil
+		}
+		return v, err
+	}
+	return v, checkFinite(v, value)
+}
+
+// transformHuman converts a human-readable size, as reported by zfs/zpool without `-p`, to bytes. Units are powers of
+// 1024, optionally followed by `B` or `iB` (ie - `1.5K`, `1.5KB` and `1.5KiB` are equivalent).
+func transformHuman(value string) (float64, error) {
+	number := strings.TrimSpace(value)
+	binary := strings.HasSuffix(number, `iB`)
+	if binary {
+		number = strings.TrimSuffix(number, `iB`)
+	} else {
+		number = strings.TrimSuffix(number, `B`)
+	}
+	multiplier := 1.0
+	if len(number) > 0 {
+		if unit, ok := humanSizeUnits[number[len(number)-1]]; ok {
+			multiplier = unit
+			number = number[:len(number)-1]
+		}
+	}
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil || (binary && multiplier == 1) {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return v * multiplier, nil
+}
+
+// transformNumericAvailable transforms a numeric value like TransformNumeric, but returns ErrValueUnavailable for `-`,
+// for properties where an unavailable value is not equivalent to 0 (ie - `written@<snapshot>` of a dataset that does not
+// have the snapshot).
+func transformNumericAvailable(value string) (float64, error) {
+	if value == `-` {
+		return -1, ErrValueUnavailable
+	}
+	return TransformNumeric(value)
+}
+
+// transformLimit transforms a numeric limit like TransformNumeric, but returns ErrValueUnavailable when no limit is set
+// (`none`, or the maximum value reported by `zfs get -p`), as 0 would imply that nothing is permitted, and for `-`.
+func transformLimit(value string) (float64, error) {
+	if value == `-` || value == `none` || value == unlimitedValue {
+		return -1, ErrValueUnavailable
+	}
+	return TransformNumeric(value)
+}
+
+// transformInfo reports a string value as 1, for properties reported as labels, returning ErrValueUnavailable for unset
+// values (`-` or empty).
+func transformInfo(value string) (float64, error) {
+	if value == `-` || value == `` {
+		return -1, ErrValueUnavailable
+	}
+	return 1, nil
 }
 
 func transformHealthCode(status string) (float64, error) {
@@ -50,7 +136,26 @@ func transformHealthCode(status string) (float64, error) {
 	return float64(result), nil
 }
 
-func transformBool(value string) (float64, error) {
+// deriveExists reports whether the named property has a value, ie - is not `-` or empty.
+func deriveExists(name string) deriveFunc {
+	return func(values map[string]string) (float64, bool, error) {
+		if v := values[name]; v == `` || v == `-` {
+			return 0, true, nil
+		}
+		return 1, true, nil
+	}
+}
+
+// deriveBool reports the named property as a boolean, for properties whose name varies by platform.
+func deriveBool(name string) deriveFunc {
+	return func(values map[string]string) (float64, bool, error) {
+		v, err := TransformBool(values[name])
+		return v, err == nil, err
+	}
+}
+
+// TransformBool converts a boolean property value (ie - on/off, yes/no) to 1 or 0
+func TransformBool(value string) (float64, error) {
 	switch value {
 	case `on`, `yes`, `enabled`, `active`:
 		return 1, nil
@@ -61,25 +166,71 @@ func transformBool(value string) (float64, error) {
 	return -1, fmt.Errorf(`could not convert '%s' to bool`, value)
 }
 
-func transformPercentage(value string) (float64, error) {
-	if len(value) > 0 && value[len(value)-1] == '%' {
-		value = value[:len(value)-1]
+// transformEnum returns a transform that converts a property with a fixed set of values to the position of the value
+// in values, for use with enumHelp. Returns ErrValueUnavailable for `-`, as reported for datasets that do not support
+// the property.
+func transformEnum(values ...string) transformFunc {
+	return func(value string) (float64, error) {
+		if value == `-` {
+			return -1, ErrValueUnavailable
+		}
+		for i, v := range values {
+			if v == value {
+				return float64(i), nil
+			}
+		}
+
+		return -1, fmt.Errorf(`could not convert '%s' to one of: %s`, value, strings.Join(values, `, `))
+	}
+}
+
+// enumHelp describes the codes produced by transformEnum for values, for inclusion in metric help.
+func enumHelp(values ...string) string {
+	codes := make([]string, len(values))
+	for i, v := range values {
+		codes[i] = fmt.Sprintf("%d: %s", i, v)
 	}
-	v, err := transformNumeric(value)
+
+	return `[` + strings.Join(codes, `, `) + `]`
+}
+
+// TransformPercentage converts a percentage property value, with or without a `%` suffix, to a ratio. Returns
+// ErrValueUnavailable for `-`, as reported when the value is unavailable (ie - fragmentation without the
+// spacemap_histogram feature), rather than a value of 0 that would disrupt alerts and rate calculations.
+func TransformPercentage(value string) (float64, error) {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), `%`))
+	if value == `-` || value == `` {
+		return -1, ErrValueUnavailable
+	}
+	v, err := strconv.ParseFloat(value, 64)
 	if err != nil {
 		return -1, err
 	}
+	if err = checkFinite(v, value); err != nil {
+		return -1, err
+	}
 
 	return v / 100, nil
 }
 
-func transformMultiplier(value string) (float64, error) {
-	if len(value) > 0 && value[len(value)-1] == 'x' {
-		value = value[:len(value)-1]
+// TransformMultiplier converts a multiplier property value, with or without an `x` suffix (ie - `1.23x`), to its inverse
+// ratio. Returns ErrValueUnavailable for `-`, and ErrValueNotFinite for multipliers of 0, NaN or Inf, which have no
+// finite inverse.
+func TransformMultiplier(value string) (float64, error) {
+	number := strings.TrimSuffix(strings.TrimSpace(value), `x`)
+	if number == `-` {
+		return -1, ErrValueUnavailable
 	}
-	v, err := transformNumeric(value)
+	v, err := strconv.ParseFloat(number, 64)
 	if err != nil {
 		return -1, err
 	}
-	return 1 / v, nil
+	if err = checkFinite(v, value); err != nil {
+		return -1, err
+	}
+	ratio := 1 / v
+	if err = checkFinite(ratio, value); err != nil {
+		return -1, err
+	}
+	return ratio, nil
 }
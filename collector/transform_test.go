@@ -0,0 +1,247 @@
+package collector
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func TestTransformHuman(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  float64
+	}{
+		{value: `512`, want: 512},
+		{value: `0B`, want: 0},
+		{value: `512B`, want: 512},
+		{value: `1K`, want: 1 << 10},
+		{value: `1.50K`, want: 1.5 * (1 << 10)},
+		{value: `12M`, want: 12 << 20},
+		{value: `2.25M`, want: 2.25 * (1 << 20)},
+		{value: `3G`, want: 3 << 30},
+		{value: `0.5G`, want: 1 << 29},
+		{value: `1.5T`, want: 1.5 * (1 << 40)},
+		{value: `4T`, want: 4 << 40},
+		{value: `1P`, want: 1 << 50},
+		{value: `1.25P`, want: 1.25 * (1 << 50)},
+		{value: `2E`, want: 2 << 60},
+		{value: `1.5KB`, want: 1.5 * (1 << 10)},
+		{value: `1.5KiB`, want: 1.5 * (1 << 10)},
+		{value: ` 2G `, want: 2 << 30},
+	}
+
+	for _, tc := range testCases {
+		got, err := transformHuman(tc.value)
+		if err != nil {
+			t.Errorf("transformHuman(%q) unexpected error: %v", tc.value, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("transformHuman(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+
+	for _, value := range []string{``, `K`, `1.5X`, `1.5k`, `T1`, `1.5iB`, `1.5i`, `1.5Ki`} {
+		if _, err := transformHuman(value); err == nil {
+			t.Errorf("transformHuman(%q) got no error, want error", value)
+		}
+	}
+}
+
+func TestTransformNumericHuman(t *testing.T) {
+	// Parseable values are preferred, human-readable sizes are a fallback.
+	testCases := []struct {
+		value string
+		want  float64
+	}{
+		{value: `1649267441664`, want: 1649267441664},
+		{value: `1.5T`, want: 1.5 * (1 << 40)},
+		{value: `-`, want: 0},
+	}
+
+	for _, tc := range testCases {
+		got, err := TransformNumeric(tc.value)
+		if err != nil {
+			t.Errorf("TransformNumeric(%q) unexpected error: %v", tc.value, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("TransformNumeric(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+
+	if _, err := TransformNumeric(`invalid`); err == nil {
+		t.Errorf("TransformNumeric(%q) got no error, want error", `invalid`)
+	}
+}
+
+func TestTransformPercentage(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    float64
+		wantErr error
+	}{
+		{value: `37`, want: 0.37},
+		{value: `37%`, want: 0.37},
+		{value: `0`, want: 0},
+		{value: `0%`, want: 0},
+		{value: `100%`, want: 1},
+		{value: `12.5%`, want: 0.125},
+		{value: ` 37% `, want: 0.37},
+		{value: `-`, wantErr: ErrValueUnavailable},
+		{value: `-%`, wantErr: ErrValueUnavailable},
+		{value: ``, wantErr: ErrValueUnavailable},
+	}
+
+	for _, tc := range testCases {
+		got, err := TransformPercentage(tc.value)
+		if err != tc.wantErr {
+			t.Errorf("TransformPercentage(%q) got error %v, want %v", tc.value, err, tc.wantErr)
+			continue
+		}
+		if err == nil && got != tc.want {
+			t.Errorf("TransformPercentage(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+
+	if _, err := TransformPercentage(`invalid`); err == nil || err == ErrValueUnavailable {
+		t.Errorf("TransformPercentage(%q) got error %v, want parse error", `invalid`, err)
+	}
+}
+
+func TestTransformMultiplier(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  float64
+	}{
+		{value: `1.50x`, want: 1 / 1.5},
+		{value: `1.50`, want: 1 / 1.5},
+		{value: `2.00x`, want: 0.5},
+		{value: `1.00x`, want: 1},
+		{value: `1.23x`, want: 1 / 1.23},
+	}
+
+	for _, tc := range testCases {
+		got, err := TransformMultiplier(tc.value)
+		if err != nil {
+			t.Errorf("TransformMultiplier(%q) unexpected error: %v", tc.value, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("TransformMultiplier(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+
+	if _, err := TransformMultiplier(`-`); err != ErrValueUnavailable {
+		t.Errorf("TransformMultiplier(%q) got error %v, want %v", `-`, err, ErrValueUnavailable)
+	}
+	for _, value := range []string{``, `x`, `invalid`} {
+		if _, err := TransformMultiplier(value); err == nil || errors.Is(err, ErrValueNotFinite) {
+			t.Errorf("TransformMultiplier(%q) got error %v, want parse error", value, err)
+		}
+	}
+}
+
+func TestTransformNotFinite(t *testing.T) {
+	transforms := map[string]transformFunc{
+		`TransformNumeric`:    TransformNumeric,
+		`TransformPercentage`: TransformPercentage,
+		`TransformMultiplier`: TransformMultiplier,
+	}
+	values := []string{`nan`, `NaN`, `inf`, `-Inf`}
+
+	for name, transform := range transforms {
+		for _, value := range values {
+			if _, err := transform(value); !errors.Is(err, ErrValueNotFinite) {
+				t.Errorf("%s(%q) got error %v, want %v", name, value, err, ErrValueNotFinite)
+			}
+		}
+	}
+
+	// Multipliers of 0 have no finite inverse.
+	if _, err := TransformMultiplier(`0.00x`); !errors.Is(err, ErrValueNotFinite) {
+		t.Errorf("TransformMultiplier(%q) got error %v, want %v", `0.00x`, err, ErrValueNotFinite)
+	}
+}
+
+func TestPropertyStorePushNotFinite(t *testing.T) {
+	store := propertyStore{
+		defaultSubsystem: subsystemPool,
+		defaultLabels:    poolLabels,
+		store: map[string]property{
+			`dedupratio`: newProperty(subsystemPool, `deduplication_ratio`, `Test.`, TransformMultiplier, poolLabels...),
+			`size`:       newProperty(subsystemPool, `size_bytes`, `Test.`, TransformNumeric, poolLabels...),
+		},
+	}
+	ch := make(chan metric, 2)
+	values := map[string]string{`dedupratio`: `0.00x`, `size`: `1024`}
+
+	// The non-finite value is skipped, without failing the remaining properties.
+	if err := store.push(log.NewNopLogger(), `pool`, ch, []string{`dedupratio`, `size`}, values, `testpool`); err != nil {
+		t.Fatal(err)
+	}
+	close(ch)
+	var names []string
+	for m := range ch {
+		names = append(names, m.name)
+	}
+	if want := []string{expandMetricName(`zfs_pool_size_bytes`, `testpool`)}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got metrics %v, want %v", names, want)
+	}
+}
+
+func TestTransformEnum(t *testing.T) {
+	transform := transformEnum(`off`, `on`, `noauto`)
+	testCases := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: `off`, want: 0},
+		{value: `on`, want: 1},
+		{value: `noauto`, want: 2},
+		{value: `unknown`, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := transform(tc.value)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("transformEnum(%q) got error %v, want error: %t", tc.value, err, tc.wantErr)
+			continue
+		}
+		if err == nil && got != tc.want {
+			t.Errorf("transformEnum(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+
+	if _, err := transform(`-`); err != ErrValueUnavailable {
+		t.Errorf("transformEnum(%q) got error %v, want %v", `-`, err, ErrValueUnavailable)
+	}
+}
+
+func TestTransformLimit(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    float64
+		wantErr error
+	}{
+		{value: `100`, want: 100},
+		{value: `0`, want: 0},
+		{value: `none`, wantErr: ErrValueUnavailable},
+		{value: `18446744073709551615`, wantErr: ErrValueUnavailable},
+		{value: `-`, wantErr: ErrValueUnavailable},
+	}
+
+	for _, tc := range testCases {
+		got, err := transformLimit(tc.value)
+		if err != tc.wantErr {
+			t.Errorf("transformLimit(%q) got error %v, want %v", tc.value, err, tc.wantErr)
+			continue
+		}
+		if err == nil && got != tc.want {
+			t.Errorf("transformLimit(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
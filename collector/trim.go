@@ -0,0 +1,227 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type trimStateCode int
+
+const (
+	trimNone trimStateCode = iota
+	trimActive
+	trimSuspended
+	trimComplete
+)
+
+var (
+	trimStateCodes = map[string]trimStateCode{
+		zfs.TrimNone:      trimNone,
+		zfs.TrimActive:    trimActive,
+		zfs.TrimSuspended: trimSuspended,
+		zfs.TrimComplete:  trimComplete,
+	}
+
+	poolTrimInProgressDescName = prometheus.BuildFQName(namespace, subsystemPool, `trim_in_progress`)
+	poolTrimInProgressDesc     = prometheus.NewDesc(
+		poolTrimInProgressDescName,
+		`Whether any device of the pool is being trimmed [0: not trimming, 1: trimming].`,
+		poolLabels,
+		nil,
+	)
+	poolTrimProgressDescName = prometheus.BuildFQName(namespace, subsystemPool, `trim_progress_ratio`)
+	poolTrimProgressDesc     = prometheus.NewDesc(
+		poolTrimProgressDescName,
+		`Average progress of the current or last trim of the pool devices that have been trimmed.`,
+		poolLabels,
+		nil,
+	)
+	poolTrimCompletedDescName = prometheus.BuildFQName(namespace, subsystemPool, `trim_last_completed_timestamp_seconds`)
+	poolTrimCompletedDesc     = prometheus.NewDesc(
+		poolTrimCompletedDescName,
+		`Time at which a trim of a device of the pool last completed, as seconds since the Unix epoch.`,
+		poolLabels,
+		nil,
+	)
+	poolTrimPendingDescName = prometheus.BuildFQName(namespace, subsystemPool, `trim_pending_bytes`)
+	poolTrimPendingDesc     = prometheus.NewDesc(
+		poolTrimPendingDescName,
+		`Approximate amount of space in bytes of the pool devices that is not covered by their current or last trim, derived from the trim progress and size of each device that supports trim.`,
+		poolLabels,
+		nil,
+	)
+	poolVdevTrimStateDescName = prometheus.BuildFQName(namespace, subsystemPool, `vdev_trim_state`)
+	poolVdevTrimStateDesc     = prometheus.NewDesc(
+		poolVdevTrimStateDescName,
+		fmt.Sprintf("Trim state of the pool device [%d: %s, %d: %s, %d: %s, %d: %s].",
+			trimNone, zfs.TrimNone,
+			trimActive, zfs.TrimActive,
+			trimSuspended, zfs.TrimSuspended,
+			trimComplete, zfs.TrimComplete,
+		),
+		poolDeviceLabels,
+		nil,
+	)
+)
+
+func init() {
+	registerCollector(`pool-trim`, defaultDisabled, ``, nil, newPoolTrimCollector)
+}
+
+// poolTrimCollector reports the trim state of each pool and its devices from `zpool status -t`.
+type poolTrimCollector struct {
+	log    log.Logger
+	client zfs.Client
+}
+
+func (c *poolTrimCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- poolTrimInProgressDesc
+	ch <- poolTrimProgressDesc
+	ch <- poolTrimCompletedDesc
+	ch <- poolTrimPendingDesc
+	ch <- poolVdevTrimStateDesc
+}
+
+func (c *poolTrimCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool); err != nil && !isPoolMissing(c.log, `pool-trim`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *poolTrimCollector) updatePoolMetrics(ch chan<- metric, pool string) error {
+	zfsPool := c.client.Pool(pool)
+	status, err := zfsPool.Status(zfs.StatusTrim)
+	if err != nil {
+		return err
+	}
+
+	var (
+		inProgress, progress, completed float64
+		trimmed                         int
+		supported                       []zfs.Vdev
+	)
+	for _, vdev := range trimmedVdevs(status.Vdevs) {
+		code, ok := trimStateCodes[vdev.Trim.State]
+		if !ok {
+			// Devices that do not support trim.
+			continue
+		}
+		supported = append(supported, vdev)
+		labelValues := []string{pool, vdev.Name}
+		ch <- metric{
+			name:       expandMetricName(poolVdevTrimStateDescName, labelValues...),
+			prometheus: prometheus.MustNewConstMetric(poolVdevTrimStateDesc, prometheus.GaugeValue, float64(code), labelValues...),
+		}
+
+		switch code {
+		case trimNone:
+			continue
+		case trimActive:
+			inProgress = 1
+		case trimComplete:
+			if t := float64(vdev.Trim.Time.Unix()); t > completed {
+				completed = t
+			}
+		}
+		progress += vdev.Trim.Progress
+		trimmed++
+	}
+
+	ch <- metric{
+		name:       expandMetricName(poolTrimInProgressDescName, pool),
+		prometheus: prometheus.MustNewConstMetric(poolTrimInProgressDesc, prometheus.GaugeValue, inProgress, pool),
+	}
+	// Pools that have never been trimmed report no progress.
+	if trimmed > 0 {
+		ch <- metric{
+			name:       expandMetricName(poolTrimProgressDescName, pool),
+			prometheus: prometheus.MustNewConstMetric(poolTrimProgressDesc, prometheus.GaugeValue, progress/float64(trimmed), pool),
+		}
+	}
+	if completed > 0 {
+		ch <- metric{
+			name:       expandMetricName(poolTrimCompletedDescName, pool),
+			prometheus: prometheus.MustNewConstMetric(poolTrimCompletedDesc, prometheus.GaugeValue, completed, pool),
+		}
+	}
+	// Device sizes are only queried for pools with devices that support trim, which report nothing otherwise.
+	if len(supported) == 0 {
+		return nil
+	}
+	vdevs, err := zfsPool.Vdevs()
+	if err != nil {
+		return err
+	}
+	if pending, ok := trimPendingBytes(supported, vdevs); ok {
+		ch <- metric{
+			name:       expandMetricName(poolTrimPendingDescName, pool),
+			prometheus: prometheus.MustNewConstMetric(poolTrimPendingDesc, prometheus.GaugeValue, pending, pool),
+		}
+	}
+
+	return nil
+}
+
+// trimPendingBytes sums the space of the trimmed devices that is not covered by their current or last trim, given the
+// sizes of the pool vdevs, reporting false if the size of none of the devices is known. Untrimmed devices are pending
+// in full.
+func trimPendingBytes(trimmed []zfs.Vdev, vdevs []zfs.VdevProperties) (float64, bool) {
+	sizes := make(map[string]float64, len(vdevs))
+	for _, vdev := range vdevs {
+		if size, err := strconv.ParseFloat(vdev.Properties[`size`], 64); err == nil {
+			sizes[vdev.Name] = size
+		}
+	}
+
+	var (
+		pending float64
+		found   bool
+	)
+	for _, vdev := range trimmed {
+		size, ok := sizes[vdev.Name]
+		if !ok {
+			continue
+		}
+		pending += size * (1 - vdev.Trim.Progress)
+		found = true
+	}
+
+	return pending, found
+}
+
+// trimmedVdevs returns the vdevs reporting a trim state, which are the leaf vdevs, from the vdev tree.
+func trimmedVdevs(vdevs []zfs.Vdev) []zfs.Vdev {
+	var result []zfs.Vdev
+	for _, vdev := range vdevs {
+		if vdev.Trim != nil {
+			result = append(result, vdev)
+		}
+		result = append(result, trimmedVdevs(vdev.Children)...)
+	}
+	return result
+}
+
+func newPoolTrimCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	return &poolTrimCollector{log: l, client: c}, nil
+}
@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestPoolTrimMetrics(t *testing.T) {
+	completed := time.Date(2021, time.October, 10, 9, 30, 0, 0, time.UTC)
+	testCases := []struct {
+		name  string
+		vdevs []zfs.Vdev
+		// sizes are the vdevs reported by zpool list, which are only queried for pools with devices that support trim.
+		sizes         []zfs.VdevProperties
+		metricResults string
+	}{
+		{
+			name: `trim in progress`,
+			vdevs: []zfs.Vdev{
+				{Name: `mirror-0`, Class: zfs.VdevClassData, State: `ONLINE`, Children: []zfs.Vdev{
+					{Name: `nvme0n1`, Class: zfs.VdevClassData, State: `ONLINE`, Trim: &zfs.VdevTrim{State: zfs.TrimActive, Progress: 0.25, Time: completed.Add(time.Hour)}},
+					{Name: `nvme1n1`, Class: zfs.VdevClassData, State: `ONLINE`, Trim: &zfs.VdevTrim{State: zfs.TrimComplete, Progress: 1, Time: completed}},
+				}},
+				{Name: `mirror-1`, Class: zfs.VdevClassData, State: `ONLINE`, Children: []zfs.Vdev{
+					{Name: `nvme2n1`, Class: zfs.VdevClassData, State: `ONLINE`, Trim: &zfs.VdevTrim{State: zfs.TrimSuspended, Progress: 0.6, Time: completed}},
+					{Name: `nvme3n1`, Class: zfs.VdevClassData, State: `ONLINE`, Trim: &zfs.VdevTrim{State: zfs.TrimNone}},
+				}},
+				{Name: `sda`, Class: zfs.VdevClassLog, State: `ONLINE`, Trim: &zfs.VdevTrim{State: zfs.TrimUnsupported}},
+			},
+			sizes: []zfs.VdevProperties{
+				{Name: `mirror-0`, Class: zfs.VdevClassData, Properties: map[string]string{`size`: `992137445376`, `allocated`: `412316860416`}},
+				{Name: `nvme0n1`, Class: zfs.VdevClassData, Leaf: true, Properties: map[string]string{`size`: `1000000000000`, `allocated`: `-`}},
+				{Name: `nvme1n1`, Class: zfs.VdevClassData, Leaf: true, Properties: map[string]string{`size`: `1000000000000`, `allocated`: `-`}},
+				{Name: `mirror-1`, Class: zfs.VdevClassData, Properties: map[string]string{`size`: `992137445376`, `allocated`: `103079215104`}},
+				{Name: `nvme2n1`, Class: zfs.VdevClassData, Leaf: true, Properties: map[string]string{`size`: `1000000000000`, `allocated`: `-`}},
+				{Name: `nvme3n1`, Class: zfs.VdevClassData, Leaf: true, Properties: map[string]string{`size`: `1000000000000`, `allocated`: `-`}},
+				{Name: `sda`, Class: zfs.VdevClassLog, Properties: map[string]string{`size`: `16642998272`, `allocated`: `1048576`}},
+			},
+			metricResults: `# HELP zfs_pool_trim_in_progress Whether any device of the pool is being trimmed [0: not trimming, 1: trimming].
+# TYPE zfs_pool_trim_in_progress gauge
+zfs_pool_trim_in_progress{pool="testpool"} 1
+# HELP zfs_pool_trim_last_completed_timestamp_seconds Time at which a trim of a device of the pool last completed, as seconds since the Unix epoch.
+# TYPE zfs_pool_trim_last_completed_timestamp_seconds gauge
+zfs_pool_trim_last_completed_timestamp_seconds{pool="testpool"} 1.6338582e+09
+# HELP zfs_pool_trim_pending_bytes Approximate amount of space in bytes of the pool devices that is not covered by their current or last trim, derived from the trim progress and size of each device that supports trim.
+# TYPE zfs_pool_trim_pending_bytes gauge
+zfs_pool_trim_pending_bytes{pool="testpool"} 2.15e+12
+# HELP zfs_pool_trim_progress_ratio Average progress of the current or last trim of the pool devices that have been trimmed.
+# TYPE zfs_pool_trim_progress_ratio gauge
+zfs_pool_trim_progress_ratio{pool="testpool"} 0.6166666666666667
+# HELP zfs_pool_vdev_trim_state Trim state of the pool device [0: untrimmed, 1: active, 2: suspended, 3: complete].
+# TYPE zfs_pool_vdev_trim_state gauge
+zfs_pool_vdev_trim_state{device="nvme0n1",pool="testpool"} 1
+zfs_pool_vdev_trim_state{device="nvme1n1",pool="testpool"} 3
+zfs_pool_vdev_trim_state{device="nvme2n1",pool="testpool"} 2
+zfs_pool_vdev_trim_state{device="nvme3n1",pool="testpool"} 0
+`,
+		},
+		{
+			name: `never trimmed`,
+			vdevs: []zfs.Vdev{
+				{Name: `nvme0n1`, Class: zfs.VdevClassData, State: `ONLINE`, Trim: &zfs.VdevTrim{State: zfs.TrimNone}},
+			},
+			sizes: []zfs.VdevProperties{
+				{Name: `nvme0n1`, Class: zfs.VdevClassData, Properties: map[string]string{`size`: `498216206336`, `allocated`: `107374182400`}},
+			},
+			metricResults: `# HELP zfs_pool_trim_in_progress Whether any device of the pool is being trimmed [0: not trimming, 1: trimming].
+# TYPE zfs_pool_trim_in_progress gauge
+zfs_pool_trim_in_progress{pool="testpool"} 0
+# HELP zfs_pool_trim_pending_bytes Approximate amount of space in bytes of the pool devices that is not covered by their current or last trim, derived from the trim progress and size of each device that supports trim.
+# TYPE zfs_pool_trim_pending_bytes gauge
+zfs_pool_trim_pending_bytes{pool="testpool"} 4.98216206336e+11
+# HELP zfs_pool_vdev_trim_state Trim state of the pool device [0: untrimmed, 1: active, 2: suspended, 3: complete].
+# TYPE zfs_pool_vdev_trim_state gauge
+zfs_pool_vdev_trim_state{device="nvme0n1",pool="testpool"} 0
+`,
+		},
+		{
+			name: `trim unsupported`,
+			vdevs: []zfs.Vdev{
+				{Name: `sda`, Class: zfs.VdevClassData, State: `ONLINE`, Trim: &zfs.VdevTrim{State: zfs.TrimUnsupported}},
+			},
+			metricResults: `# HELP zfs_pool_trim_in_progress Whether any device of the pool is being trimmed [0: not trimming, 1: trimming].
+# TYPE zfs_pool_trim_in_progress gauge
+zfs_pool_trim_in_progress{pool="testpool"} 0
+`,
+		},
+		{
+			name: `no trim state`,
+			vdevs: []zfs.Vdev{
+				{Name: `sda`, Class: zfs.VdevClassData, State: `ONLINE`},
+			},
+			metricResults: `# HELP zfs_pool_trim_in_progress Whether any device of the pool is being trimmed [0: not trimming, 1: trimming].
+# TYPE zfs_pool_trim_in_progress gauge
+zfs_pool_trim_in_progress{pool="testpool"} 0
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl, ctx := gomock.WithContext(context.Background(), t)
+			zfsClient := mock_zfs.NewMockClient(ctrl)
+			zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+			zfsPool := mock_zfs.NewMockPool(ctrl)
+			zfsPool.EXPECT().Status(zfs.StatusTrim).Return(&zfs.Status{Vdevs: tc.vdevs}, nil).Times(1)
+			if tc.sizes != nil {
+				zfsPool.EXPECT().Vdevs().Return(tc.sizes, nil).Times(1)
+			}
+			zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+			collector, err := NewZFS(defaultConfig(zfsClient))
+			if err != nil {
+				t.Fatal(err)
+			}
+			collector.Collectors = map[string]State{
+				`pool-trim`: {
+					Name:    "pool-trim",
+					Enabled: boolPointer(true),
+					factory: newPoolTrimCollector,
+				},
+			}
+
+			metricNames := []string{
+				`zfs_pool_trim_in_progress`,
+				`zfs_pool_trim_last_completed_timestamp_seconds`,
+				`zfs_pool_trim_pending_bytes`,
+				`zfs_pool_trim_progress_ratio`,
+				`zfs_pool_vdev_trim_state`,
+			}
+			if err = callCollector(ctx, collector, []byte(tc.metricResults), metricNames); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
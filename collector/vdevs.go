@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const subsystemVdev = `vdev`
+
+var (
+	vdevLabels     = []string{`pool`, `vdev`, `class`}
+	vdevProperties = propertyStore{
+		defaultSubsystem: subsystemVdev,
+		defaultLabels:    vdevLabels,
+		store: map[string]property{
+			`size`: newProperty(
+				subsystemVdev,
+				`size_bytes`,
+				`Total size in bytes of the top-level vdev.`,
+				transformNumericAvailable,
+				vdevLabels...,
+			),
+			`allocated`: newProperty(
+				subsystemVdev,
+				`allocated_bytes`,
+				`Amount of storage space in bytes allocated on the top-level vdev.`,
+				transformNumericAvailable,
+				vdevLabels...,
+			),
+			`free`: newProperty(
+				subsystemVdev,
+				`free_bytes`,
+				`Amount of storage space in bytes free on the top-level vdev.`,
+				transformNumericAvailable,
+				vdevLabels...,
+			),
+			`fragmentation`: newProperty(
+				subsystemVdev,
+				`fragmentation_ratio`,
+				`The fragmentation ratio of the free space of the top-level vdev.`,
+				TransformPercentage,
+				vdevLabels...,
+			),
+			`capacity`: newProperty(
+				subsystemVdev,
+				`capacity_ratio`,
+				`Ratio of the top-level vdev space used.`,
+				TransformPercentage,
+				vdevLabels...,
+			),
+		},
+	}
+)
+
+func init() {
+	registerCollector(`pool-vdevs`, defaultDisabled, ``, nil, newPoolVdevsCollector)
+}
+
+// poolVdevsCollector reports the space of each top-level vdev of each pool from `zpool list -v`, to identify unbalanced
+// vdevs, ie - where a vdev added to a nearly full pool receives most new writes.
+type poolVdevsCollector struct {
+	log    log.Logger
+	client zfs.Client
+}
+
+func (c *poolVdevsCollector) describe(ch chan<- *prometheus.Desc) {
+	for _, k := range vdevProperties.names() {
+		prop, err := vdevProperties.find(k)
+		if err != nil {
+			continue
+		}
+		ch <- prop.desc
+	}
+}
+
+func (c *poolVdevsCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(pools))
+	for _, pool := range pools {
+		wg.Add(1)
+		go func(pool string) {
+			if err := c.updatePoolMetrics(ch, pool); err != nil && !isPoolMissing(c.log, `pool-vdevs`, pool, err) {
+				errChan <- err
+			}
+			wg.Done()
+		}(pool)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (c *poolVdevsCollector) updatePoolMetrics(ch chan<- metric, pool string) error {
+	vdevs, err := c.client.Pool(pool).Vdevs()
+	if err != nil {
+		return err
+	}
+
+	for _, vdev := range vdevs {
+		if vdev.Leaf {
+			continue
+		}
+		if err = vdevProperties.push(c.log, `pool-vdevs`, ch, vdevProperties.names(), vdev.Properties, pool, vdev.Name, vdev.Class); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newPoolVdevsCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	return &poolVdevsCollector{log: l, client: c}, nil
+}
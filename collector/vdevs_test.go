@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestPoolVdevsMetrics(t *testing.T) {
+	// An unbalanced pool, where the second mirror was added once the first was nearly full, and a log device.
+	const result = `# HELP zfs_vdev_allocated_bytes Amount of storage space in bytes allocated on the top-level vdev.
+# TYPE zfs_vdev_allocated_bytes gauge
+zfs_vdev_allocated_bytes{class="data",pool="testpool",vdev="mirror-0"} 1.793148370944e+12
+zfs_vdev_allocated_bytes{class="data",pool="testpool",vdev="mirror-1"} 3.88695392256e+11
+zfs_vdev_allocated_bytes{class="log",pool="testpool",vdev="nvme0n1"} 1.048576e+06
+# HELP zfs_vdev_fragmentation_ratio The fragmentation ratio of the free space of the top-level vdev.
+# TYPE zfs_vdev_fragmentation_ratio gauge
+zfs_vdev_fragmentation_ratio{class="data",pool="testpool",vdev="mirror-0"} 0.62
+zfs_vdev_fragmentation_ratio{class="data",pool="testpool",vdev="mirror-1"} 0.08
+zfs_vdev_fragmentation_ratio{class="log",pool="testpool",vdev="nvme0n1"} 0
+# HELP zfs_vdev_size_bytes Total size in bytes of the top-level vdev.
+# TYPE zfs_vdev_size_bytes gauge
+zfs_vdev_size_bytes{class="data",pool="testpool",vdev="mirror-0"} 1.992864825344e+12
+zfs_vdev_size_bytes{class="data",pool="testpool",vdev="mirror-1"} 1.992864825344e+12
+zfs_vdev_size_bytes{class="log",pool="testpool",vdev="nvme0n1"} 1.6642998272e+10
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Vdevs().Return([]zfs.VdevProperties{
+		{Name: `mirror-0`, Class: zfs.VdevClassData, Properties: map[string]string{
+			`size`: `1992864825344`, `allocated`: `1793148370944`, `free`: `199716454400`, `fragmentation`: `62`, `capacity`: `89`,
+		}},
+		{Name: `mirror-1`, Class: zfs.VdevClassData, Properties: map[string]string{
+			`size`: `1992864825344`, `allocated`: `388695392256`, `free`: `1604169433088`, `fragmentation`: `8`, `capacity`: `19`,
+		}},
+		{Name: `sda`, Class: zfs.VdevClassData, Leaf: true, Properties: map[string]string{
+			`size`: `2000398934016`, `allocated`: `-`, `free`: `-`, `fragmentation`: `-`, `capacity`: `-`,
+		}},
+		{Name: `nvme0n1`, Class: zfs.VdevClassLog, Properties: map[string]string{
+			`size`: `16642998272`, `allocated`: `1048576`, `free`: `16641949696`, `fragmentation`: `0`, `capacity`: `0`,
+		}},
+	}, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool-vdevs`: {
+			Name:    `pool-vdevs`,
+			Enabled: boolPointer(true),
+			factory: newPoolVdevsCollector,
+		},
+	}
+
+	metricNames := []string{
+		`zfs_vdev_allocated_bytes`,
+		`zfs_vdev_fragmentation_ratio`,
+		`zfs_vdev_size_bytes`,
+	}
+	if err = callCollector(ctx, collector, []byte(result), metricNames); err != nil {
+		t.Fatal(err)
+	}
+}
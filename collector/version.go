@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"errors"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	versionInfoDescName = prometheus.BuildFQName(namespace, ``, `version_info`)
+	versionInfoDesc     = prometheus.NewDesc(
+		versionInfoDescName,
+		`Version of the installed ZFS userland tools and kernel module.`,
+		[]string{`version`, `userland`, `kernel`},
+		nil,
+	)
+)
+
+func init() {
+	registerCollector(`version`, defaultEnabled, ``, nil, newVersionCollector)
+}
+
+type versionCollector struct {
+	log    log.Logger
+	client zfs.Client
+}
+
+func (c *versionCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- versionInfoDesc
+}
+
+func (c *versionCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	v, err := c.client.Version()
+	if err != nil && !errors.Is(err, zfs.ErrVersionUnknown) {
+		return err
+	}
+
+	labelValues := []string{v.String(), v.Userland, v.Kernel}
+	// Versions prior to 0.8 cannot report their version, so are reported as unknown rather than failing every scrape.
+	if err != nil {
+		labelValues = []string{`unknown`, ``, ``}
+	}
+	ch <- metric{
+		name:       expandMetricName(versionInfoDescName, labelValues...),
+		prometheus: prometheus.MustNewConstMetric(versionInfoDesc, prometheus.GaugeValue, 1, labelValues...),
+	}
+
+	return nil
+}
+
+func newVersionCollector(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+	return &versionCollector{log: l, client: c}, nil
+}
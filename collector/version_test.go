@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+)
+
+func TestVersionMetrics(t *testing.T) {
+	const result = `# HELP zfs_version_info Version of the installed ZFS userland tools and kernel module.
+# TYPE zfs_version_info gauge
+zfs_version_info{kernel="zfs-kmod-2.2.2-1",userland="zfs-2.2.2-1",version="2.2.2"} 1
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsClient.EXPECT().Version().Return(zfs.Version{
+		Major:    2,
+		Minor:    2,
+		Patch:    2,
+		Userland: `zfs-2.2.2-1`,
+		Kernel:   `zfs-kmod-2.2.2-1`,
+	}, nil).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`version`: {
+			Name:    "version",
+			Enabled: boolPointer(true),
+			factory: newVersionCollector,
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_version_info`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVersionMetricsUnknown(t *testing.T) {
+	const result = `# HELP zfs_version_info Version of the installed ZFS userland tools and kernel module.
+# TYPE zfs_version_info gauge
+zfs_version_info{kernel="",userland="",version="unknown"} 1
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsClient.EXPECT().Version().Return(zfs.Version{}, zfs.ErrVersionUnknown).Times(1)
+
+	collector, err := NewZFS(defaultConfig(zfsClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`version`: {
+			Name:    "version",
+			Enabled: boolPointer(true),
+			factory: newVersionCollector,
+		},
+	}
+
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_version_info`}); err != nil {
+		t.Fatal(err)
+	}
+}
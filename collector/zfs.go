@@ -2,10 +2,14 @@ package collector
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
 	"regexp"
 	"sort"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/log"
@@ -14,6 +18,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// errCollectorRunning is returned for a collector that is skipped because a run that exceeded the collector timeout
+// has not yet completed.
+var errCollectorRunning = errors.New(`previous run exceeded the collector timeout and is still running`)
+
 type regexpCollection []*regexp.Regexp
 
 func (c regexpCollection) MatchString(input string) bool {
@@ -30,10 +38,21 @@ func (c regexpCollection) MatchString(input string) bool {
 type ZFSConfig struct {
 	DisableMetrics bool
 	Deadline       time.Duration
-	Pools          []string
-	Excludes       []string
-	Logger         log.Logger
-	ZFSClient      zfs.Client
+	// CollectorTimeout is the maximum duration of each collector run, after which the collector is abandoned and
+	// reported as failed, disabled if zero
+	CollectorTimeout time.Duration
+	Pools            []string
+	// Includes are regexes matching the names of pools to collect, all pools are collected if empty. Datasets of the
+	// included pools are then filtered by Excludes
+	Includes  []string
+	Excludes  []string
+	Logger    log.Logger
+	ZFSClient zfs.Client
+	// ModulePath is a path that exists only while the ZFS kernel module is loaded (ie - `/sys/module/zfs`). When set,
+	// collectors are skipped while the path does not exist, rather than failing, disabled if empty
+	ModulePath string
+	// DurationBuckets are the buckets of the collector duration histogram, defaults to DefaultDurationBuckets
+	DurationBuckets []float64
 }
 
 // ZFS collector
@@ -43,17 +62,33 @@ type ZFS struct {
 	client         zfs.Client
 	disableMetrics bool
 	deadline       time.Duration
-	cache          *metricCache
-	ready          chan struct{}
-	logger         log.Logger
-	excludes       regexpCollection
+	timeout        time.Duration
+	// abandoned holds the names of collectors whose runs exceeded the collector timeout and are still running.
+	abandoned sync.Map
+	cache     *metricCache
+	ready     chan struct{}
+	logger    log.Logger
+	includes  regexpCollection
+	excludes  regexpCollection
+	durations *prometheus.HistogramVec
+	// modulePath is checked before each scrape, and moduleMissing holds the result of the last check, so that only
+	// changes are logged.
+	modulePath    string
+	moduleMissing atomic.Bool
 }
 
 // Describe implements the prometheus.Collector interface.
 func (c *ZFS) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upDesc
+	ch <- scrapeErrorDesc
+	if c.modulePath != `` {
+		ch <- moduleLoadedDesc
+	}
 	if !c.disableMetrics {
 		ch <- scrapeDurationDesc
 		ch <- scrapeSuccessDesc
+		ch <- collectedMetricsDesc
+		c.durations.Describe(ch)
 	}
 
 	for _, state := range c.Collectors {
@@ -61,7 +96,7 @@ func (c *ZFS) Describe(ch chan<- *prometheus.Desc) {
 			continue
 		}
 
-		collector, err := state.factory(c.logger, c.client, strings.Split(*state.Properties, `,`))
+		collector, err := state.factory(c.logger, c.client, state.properties())
 		if err != nil {
 			continue
 		}
@@ -75,6 +110,7 @@ func (c *ZFS) Collect(ch chan<- prometheus.Metric) {
 	case <-c.ready:
 	default:
 		c.sendCached(ch, make(map[string]struct{}))
+		c.sendDurations(ch)
 		return
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), c.deadline)
@@ -97,9 +133,18 @@ func (c *ZFS) Collect(ch chan<- prometheus.Metric) {
 
 	}
 
-	// Close the proxy channel upon collector completion.
+	// Report whether any collector failed, and close the proxy channel upon collector completion.
+	var failed atomic.Bool
 	go func() {
 		wg.Wait()
+		var scrapeError float64
+		if failed.Load() {
+			scrapeError = 1
+		}
+		proxy <- metric{
+			name:       scrapeErrorDescName,
+			prometheus: prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, scrapeError),
+		}
 		close(proxy)
 	}()
 
@@ -121,30 +166,65 @@ func (c *ZFS) Collect(ch chan<- prometheus.Metric) {
 		c.ready <- struct{}{}
 	}()
 
-	pools, poolErr := c.getPools(c.Pools)
+	// The pools are listed before any collector runs, so zfs_up is always reported, even when ZFS is unavailable (ie -
+	// the kernel module is not loaded) and every collector fails. When the module is known not to be loaded, the pools
+	// are not listed and collectors are skipped without error.
+	loaded := c.checkModule(proxy)
+	var (
+		pools   []string
+		poolErr error
+		up      float64
+	)
+	if loaded {
+		pools, poolErr = c.getPools(c.Pools)
+		up = 1
+		if poolErr != nil {
+			_ = level.Error(c.logger).Log("msg", "Listing pools", "err", poolErr)
+			up = 0
+		}
+	}
+	proxy <- metric{
+		name:       upDescName,
+		prometheus: prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up),
+	}
 
 	for name, state := range c.Collectors {
-		if !*state.Enabled {
+		if !*state.Enabled || !loaded {
 			wg.Done()
 			continue
 		}
 
 		if poolErr != nil {
-			c.publishCollectorMetrics(ctx, name, poolErr, 0, proxy)
+			failed.Store(true)
+			c.publishCollectorMetrics(ctx, name, poolErr, 0, 0, proxy)
+			wg.Done()
+			continue
+		}
+
+		if _, ok := c.abandoned.Load(name); ok {
+			failed.Store(true)
+			c.publishCollectorMetrics(ctx, name, errCollectorRunning, 0, 0, proxy)
 			wg.Done()
 			continue
 		}
 
-		collector, err := state.factory(c.logger, c.client, strings.Split(*state.Properties, `,`))
+		// Commands run by the collector are killed once it exceeds the timeout, so that it is not abandoned for long.
+		runCtx, cancel := c.collectorContext()
+		collector, err := state.factory(c.logger, zfs.WithContext(runCtx, c.client), state.properties())
 		if err != nil {
+			cancel()
 			_ = level.Error(c.logger).Log("Error instantiating collector", "collector", name, "err", err)
+			failed.Store(true)
 			wg.Done()
 			continue
 		}
-		go func(name string, collector Collector) {
-			c.execute(ctx, name, collector, proxy, pools)
+		go func(name string, collector Collector, runCtx context.Context, cancel context.CancelFunc) {
+			defer cancel()
+			if !c.execute(ctx, runCtx, name, collector, proxy, pools) {
+				failed.Store(true)
+			}
 			wg.Done()
-		}(name, collector)
+		}(name, collector, runCtx, cancel)
 	}
 
 	// Wait for completion or timeout
@@ -161,6 +241,43 @@ func (c *ZFS) Collect(ch chan<- prometheus.Metric) {
 	}
 	// Ensure there are no in-flight writes to the upstream channel
 	<-finalized
+	c.sendDurations(ch)
+}
+
+// checkModule reports whether the ZFS kernel module is loaded, sending zfs_module_loaded when a module path is
+// configured. Only changes are logged, so that hosts where ZFS is optional do not log errors on every scrape.
+func (c *ZFS) checkModule(ch chan<- metric) bool {
+	if c.modulePath == `` {
+		return true
+	}
+	_, err := os.Stat(c.modulePath)
+	loaded := err == nil
+	if c.moduleMissing.Swap(!loaded) == loaded {
+		if loaded {
+			_ = level.Info(c.logger).Log("msg", "ZFS kernel module loaded, resuming collectors", "path", c.modulePath)
+		} else {
+			_ = level.Warn(c.logger).Log("msg", "ZFS kernel module not loaded, skipping collectors", "path", c.modulePath, "err", err)
+		}
+	}
+
+	var value float64
+	if loaded {
+		value = 1
+	}
+	ch <- metric{
+		name:       moduleLoadedDescName,
+		prometheus: prometheus.MustNewConstMetric(moduleLoadedDesc, prometheus.GaugeValue, value),
+	}
+
+	return loaded
+}
+
+// sendDurations sends the collector duration histogram, which accumulates across scrapes and so is not cached.
+func (c *ZFS) sendDurations(ch chan<- prometheus.Metric) {
+	if c.disableMetrics {
+		return
+	}
+	c.durations.Collect(ch)
 }
 
 // sendCached values that do not appear in the current cacheIndex.
@@ -180,9 +297,9 @@ func (c *ZFS) getPools(pools []string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	// Return all pools if not explicitly configured.
+	// Return all included pools if not explicitly configured.
 	if len(pools) == 0 {
-		return poolNames, nil
+		return c.includedPools(poolNames), nil
 	}
 
 	// Configured pools may not exist, so append available pools as they're found, rather than allocating up front.
@@ -201,18 +318,101 @@ func (c *ZFS) getPools(pools []string) ([]string, error) {
 		}
 	}
 
-	return result, nil
+	return c.includedPools(result), nil
+}
+
+// includedPools filters pools to those matching the includes, if any are configured.
+func (c *ZFS) includedPools(pools []string) []string {
+	if len(c.includes) == 0 {
+		return pools
+	}
+	result := make([]string, 0, len(pools))
+	for _, pool := range pools {
+		if c.includes.MatchString(pool) {
+			result = append(result, pool)
+		}
+	}
+
+	return result
+}
+
+// collectorContext returns the context of a single collector run, which is done once the collector timeout is exceeded,
+// if configured.
+func (c *ZFS) collectorContext() (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(context.Background(), c.timeout)
+	}
+	return context.WithCancel(context.Background())
 }
 
-func (c *ZFS) execute(ctx context.Context, name string, collector Collector, ch chan<- metric, pools []string) {
+// execute runs the collector, forwarding its metrics to ch, and reports whether it succeeded. The collector is abandoned
+// once runCtx is done.
+func (c *ZFS) execute(ctx, runCtx context.Context, name string, collector Collector, ch chan<- metric, pools []string) bool {
+	// Count samples as they pass through, so that silent metric loss is observable. Once the collector is abandoned,
+	// further samples are discarded, as the scrape may have completed.
+	samples := make(chan metric)
+	abandon := make(chan struct{})
+	abandoned := make(chan struct{})
+	done := make(chan struct{})
+	count := 0
+	go func(abandon <-chan struct{}) {
+		defer close(done)
+		forward := true
+		for {
+			select {
+			case metric, ok := <-samples:
+				if !ok {
+					return
+				}
+				if !forward {
+					continue
+				}
+				count++
+				ch <- metric
+			case <-abandon:
+				forward, abandon = false, nil
+				close(abandoned)
+			}
+		}
+	}(abandon)
+
 	begin := time.Now()
-	err := collector.update(ch, pools, c.excludes)
+	result := make(chan error, 1)
+	go func() {
+		err := collector.update(samples, pools, c.excludes)
+		close(samples)
+		result <- err
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+		<-done
+	case <-runCtx.Done():
+		// The commands of the collector are killed, but the collector itself can not be interrupted, so it is left to
+		// complete in the background, and skipped by subsequent scrapes until it does.
+		c.abandoned.Store(name, struct{}{})
+		go func() {
+			<-done
+			c.abandoned.Delete(name)
+		}()
+		close(abandon)
+		// The collector may complete as it is abandoned, in which case the forwarder returns without acknowledging.
+		select {
+		case <-abandoned:
+		case <-done:
+		}
+		err = fmt.Errorf("collector exceeded timeout of %s", c.timeout)
+	}
 	duration := time.Since(begin)
+	c.durations.WithLabelValues(name).Observe(duration.Seconds())
 
-	c.publishCollectorMetrics(ctx, name, err, duration, ch)
+	return c.publishCollectorMetrics(ctx, name, err, duration, count, ch)
 }
 
-func (c *ZFS) publishCollectorMetrics(ctx context.Context, name string, err error, duration time.Duration, ch chan<- metric) {
+// publishCollectorMetrics logs the outcome of the collector, sending its duration and success metrics unless disabled,
+// and reports whether it succeeded.
+func (c *ZFS) publishCollectorMetrics(ctx context.Context, name string, err error, duration time.Duration, count int, ch chan<- metric) bool {
 	var success float64
 
 	if err != nil {
@@ -235,7 +435,7 @@ func (c *ZFS) publishCollectorMetrics(ctx context.Context, name string, err erro
 	}
 
 	if c.disableMetrics {
-		return
+		return success == 1
 	}
 	ch <- metric{
 		name:       scrapeDurationDescName,
@@ -245,27 +445,84 @@ func (c *ZFS) publishCollectorMetrics(ctx context.Context, name string, err erro
 		name:       scrapeSuccessDescName,
 		prometheus: prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, name),
 	}
+	ch <- metric{
+		name:       expandMetricName(collectedMetricsDescName, name),
+		prometheus: prometheus.MustNewConstMetric(collectedMetricsDesc, prometheus.GaugeValue, float64(count), name),
+	}
+
+	return success == 1
+}
+
+// durationBuckets returns the buckets of the collector duration histogram sorted and without duplicates, as the
+// histogram panics on the first observation otherwise, or DefaultDurationBuckets if none are provided.
+func durationBuckets(buckets []float64) ([]float64, error) {
+	if len(buckets) == 0 {
+		return DefaultDurationBuckets, nil
+	}
+	sorted := make([]float64, 0, len(buckets))
+	for _, bucket := range buckets {
+		if math.IsNaN(bucket) {
+			return nil, fmt.Errorf("invalid duration bucket %v", bucket)
+		}
+		sorted = append(sorted, bucket)
+	}
+	sort.Float64s(sorted)
+	result := sorted[:1]
+	for _, bucket := range sorted[1:] {
+		if bucket != result[len(result)-1] {
+			result = append(result, bucket)
+		}
+	}
+	return result, nil
 }
 
 // NewZFS instantiates a ZFS collector with the provided ZFSConfig
 func NewZFS(config ZFSConfig) (*ZFS, error) {
+	for _, pool := range config.Pools {
+		if err := zfs.ValidatePoolName(pool); err != nil {
+			return nil, err
+		}
+	}
 	sort.Strings(config.Pools)
 	sort.Strings(config.Excludes)
+	includes := make(regexpCollection, len(config.Includes))
+	for i, v := range config.Includes {
+		include, err := regexp.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include %q: %w", v, err)
+		}
+		includes[i] = include
+	}
 	excludes := make(regexpCollection, len(config.Excludes))
 	for i, v := range config.Excludes {
 		excludes[i] = regexp.MustCompile(v)
 	}
+	buckets, err := durationBuckets(config.DurationBuckets)
+	if err != nil {
+		return nil, err
+	}
 	ready := make(chan struct{}, 1)
 	ready <- struct{}{}
 	return &ZFS{
 		disableMetrics: config.DisableMetrics,
 		client:         config.ZFSClient,
 		deadline:       config.Deadline,
+		timeout:        config.CollectorTimeout,
 		Pools:          config.Pools,
 		Collectors:     collectorStates,
+		includes:       includes,
 		excludes:       excludes,
 		cache:          newMetricCache(),
 		ready:          ready,
-		logger:         config.Logger,
+		logger:         newWarnedLogger(config.Logger),
+		modulePath:     config.ModulePath,
+		durations: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    scrapeDurationHistogramName,
+				Help:    `zfs_exporter: Distribution of collector scrape durations.`,
+				Buckets: buckets,
+			},
+			[]string{`collector`},
+		),
 	}, nil
 }
@@ -2,11 +2,26 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/go-kit/log"
 	"github.com/golang/mock/gomock"
+	"github.com/pdf/zfs_exporter/v2/zfs"
 	"github.com/pdf/zfs_exporter/v2/zfs/mock_zfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestZFSCollectInvalidPools(t *testing.T) {
@@ -41,3 +56,703 @@ zfs_scrape_collector_success{collector="pool"} 0
 		t.Fatal(err)
 	}
 }
+
+func TestZFSUp(t *testing.T) {
+	testCases := []struct {
+		name    string
+		poolErr error
+		want    []string
+	}{
+		{
+			name:    `pools unavailable`,
+			poolErr: errors.New(`The ZFS modules are not loaded.`),
+			want:    []string{"zfs_up 0\n", "zfs_scrape_error 1\n", `zfs_scrape_collector_success{collector="pool"} 0`},
+		},
+		{
+			name: `pools available`,
+			want: []string{"zfs_up 1\n", "zfs_scrape_error 0\n", `zfs_scrape_collector_success{collector="pool"} 1`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			zfsClient := mock_zfs.NewMockClient(ctrl)
+			if tc.poolErr != nil {
+				zfsClient.EXPECT().PoolNames().Return(nil, tc.poolErr).Times(1)
+			} else {
+				zfsClient.EXPECT().PoolNames().Return([]string{}, nil).Times(1)
+			}
+
+			config := defaultConfig(zfsClient)
+			config.DisableMetrics = false
+			collector, err := NewZFS(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			collector.Collectors = map[string]State{
+				`pool`: {
+					Name:       "pool",
+					Enabled:    boolPointer(true),
+					Properties: stringPointer(`allocated`),
+					factory:    newPoolCollector,
+				},
+			}
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(collector)
+
+			server := httptest.NewServer(NewMetricsHandler(registry))
+			defer server.Close()
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("got output without %q:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+func TestZFSModuleLoaded(t *testing.T) {
+	modulePath := filepath.Join(t.TempDir(), `zfs`)
+	scrapes := []struct {
+		name   string
+		loaded bool
+		want   []string
+	}{
+		{
+			name: `not loaded`,
+			want: []string{"zfs_module_loaded 0\n", "zfs_up 0\n", "zfs_scrape_error 0\n"},
+		},
+		{
+			name:   `loaded`,
+			loaded: true,
+			want:   []string{"zfs_module_loaded 1\n", "zfs_up 1\n", "zfs_scrape_error 0\n", `zfs_scrape_collector_success{collector="pool"} 1`},
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	config := defaultConfig(zfsClient)
+	config.DisableMetrics = false
+	config.ModulePath = modulePath
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated`),
+			factory:    newPoolCollector,
+		},
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	server := httptest.NewServer(NewMetricsHandler(registry))
+	defer server.Close()
+
+	// The module is loaded after the first scrape, and collection resumes without restarting.
+	for _, scrape := range scrapes {
+		if scrape.loaded {
+			if err = os.Mkdir(modulePath, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			zfsClient.EXPECT().PoolNames().Return([]string{}, nil).Times(1)
+		}
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range scrape.want {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("%s: got output without %q:\n%s", scrape.name, want, body)
+			}
+		}
+		if !scrape.loaded && strings.Contains(string(body), `zfs_scrape_collector_success`) {
+			t.Errorf("%s: got collector metrics while the module is not loaded:\n%s", scrape.name, body)
+		}
+	}
+}
+
+func TestZFSPoolSelection(t *testing.T) {
+	const header = `# HELP zfs_dataset_used_bytes The amount of space in bytes consumed by this dataset and all its descendents.
+# TYPE zfs_dataset_used_bytes gauge
+`
+	datasets := map[string][]string{
+		`prod`:  {`prod/db`, `prod/scratch`},
+		`test1`: {`test1/db`},
+		`test2`: {`test2/db`},
+	}
+	testCases := []struct {
+		name     string
+		includes []string
+		excludes []string
+		queried  []string
+		result   string
+	}{
+		{
+			name:     `include only`,
+			includes: []string{`^prod$`},
+			queried:  []string{`prod`},
+			result: header + `zfs_dataset_used_bytes{name="prod/db",pool="prod",type="filesystem"} 1024
+zfs_dataset_used_bytes{name="prod/scratch",pool="prod",type="filesystem"} 1024
+`,
+		},
+		{
+			name:     `exclude only`,
+			excludes: []string{`/scratch$`},
+			queried:  []string{`prod`, `test1`, `test2`},
+			result: header + `zfs_dataset_used_bytes{name="prod/db",pool="prod",type="filesystem"} 1024
+zfs_dataset_used_bytes{name="test1/db",pool="test1",type="filesystem"} 1024
+zfs_dataset_used_bytes{name="test2/db",pool="test2",type="filesystem"} 1024
+`,
+		},
+		{
+			name:     `include and exclude`,
+			includes: []string{`^prod$`, `^test1$`},
+			excludes: []string{`/scratch$`, `^test1/`},
+			queried:  []string{`prod`, `test1`},
+			result: header + `zfs_dataset_used_bytes{name="prod/db",pool="prod",type="filesystem"} 1024
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl, ctx := gomock.WithContext(context.Background(), t)
+			zfsClient := mock_zfs.NewMockClient(ctrl)
+			zfsClient.EXPECT().PoolNames().Return([]string{`prod`, `test1`, `test2`}, nil).Times(1)
+			zfsClient.EXPECT().Version().Return(zfs.Version{Major: 2, Minor: 2, Patch: 2}, nil).AnyTimes()
+			for _, pool := range tc.queried {
+				names := datasets[pool]
+				results := make([]zfs.DatasetProperties, len(names))
+				for i, name := range names {
+					zfsDatasetProperties := mock_zfs.NewMockDatasetProperties(ctrl)
+					zfsDatasetProperties.EXPECT().DatasetName().Return(name).AnyTimes()
+					zfsDatasetProperties.EXPECT().Properties().Return(map[string]string{`used`: `1024`}).AnyTimes()
+					results[i] = zfsDatasetProperties
+				}
+				zfsDatasets := mock_zfs.NewMockDatasets(ctrl)
+				zfsDatasets.EXPECT().Properties([]string{`used`}).Return(results, nil).Times(1)
+				zfsClient.EXPECT().Datasets(pool, zfs.DatasetFilesystem).Return(zfsDatasets).Times(1)
+			}
+
+			config := defaultConfig(zfsClient)
+			config.Includes = tc.includes
+			config.Excludes = tc.excludes
+			collector, err := NewZFS(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			collector.Collectors = map[string]State{
+				`dataset-filesystem`: {
+					Name:       "dataset-filesystem",
+					Enabled:    boolPointer(true),
+					Properties: stringPointer(`used`),
+					factory:    newFilesystemCollector,
+				},
+			}
+
+			if err = callCollector(ctx, collector, []byte(tc.result), []string{`zfs_dataset_used_bytes`}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNewZFSInvalidInclude(t *testing.T) {
+	config := defaultConfig(nil)
+	config.Includes = []string{`(`}
+	if _, err := NewZFS(config); err == nil {
+		t.Fatal(`expected error, got nil`)
+	}
+}
+
+func TestZFSCollectedMetrics(t *testing.T) {
+	const result = `# HELP zfs_collected_metrics_total zfs_exporter: Number of metric samples produced by a collector in the last scrape.
+# TYPE zfs_collected_metrics_total gauge
+zfs_collected_metrics_total{collector="pool"} 3
+`
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`allocated`: `1024`, `size`: `2048`}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Properties(`allocated`, `size`).Return(zfsPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	config := defaultConfig(zfsClient)
+	config.DisableMetrics = false
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated,size`),
+			factory:    newPoolCollector,
+		},
+	}
+
+	// Two property samples, plus zfs_pool_up.
+	if err = callCollector(ctx, collector, []byte(result), []string{`zfs_collected_metrics_total`}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var blockingDesc = prometheus.NewDesc(`zfs_test_blocking`, `Test metric reported by the blocking collector.`, nil, nil)
+
+// blockingCollector reports a metric once released, simulating a collector that hangs.
+type blockingCollector struct {
+	release <-chan struct{}
+	runs    *int32
+}
+
+func (c blockingCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- blockingDesc
+}
+
+func (c blockingCollector) update(ch chan<- metric, pools []string, excludes regexpCollection) error {
+	atomic.AddInt32(c.runs, 1)
+	<-c.release
+	ch <- metric{
+		name:       `zfs_test_blocking`,
+		prometheus: prometheus.MustNewConstMetric(blockingDesc, prometheus.GaugeValue, 1),
+	}
+	return nil
+}
+
+func TestZFSCollectorTimeout(t *testing.T) {
+	const (
+		blockedResult = `# HELP zfs_pool_allocated_bytes Amount of storage in bytes used within the pool.
+# TYPE zfs_pool_allocated_bytes gauge
+zfs_pool_allocated_bytes{pool="testpool"} 1024
+# HELP zfs_scrape_collector_success zfs_exporter: Whether a collector succeeded.
+# TYPE zfs_scrape_collector_success gauge
+zfs_scrape_collector_success{collector="pool"} 1
+zfs_scrape_collector_success{collector="slow"} 0
+`
+		releasedResult = `# HELP zfs_pool_allocated_bytes Amount of storage in bytes used within the pool.
+# TYPE zfs_pool_allocated_bytes gauge
+zfs_pool_allocated_bytes{pool="testpool"} 1024
+# HELP zfs_scrape_collector_success zfs_exporter: Whether a collector succeeded.
+# TYPE zfs_scrape_collector_success gauge
+zfs_scrape_collector_success{collector="pool"} 1
+zfs_scrape_collector_success{collector="slow"} 1
+# HELP zfs_test_blocking Test metric reported by the blocking collector.
+# TYPE zfs_test_blocking gauge
+zfs_test_blocking 1
+`
+	)
+
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	config := defaultConfig(zfsClient)
+	config.DisableMetrics = false
+	config.CollectorTimeout = 50 * time.Millisecond
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	var runs int32
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated`),
+			factory:    newPoolCollector,
+		},
+		`slow`: {
+			Name:    "slow",
+			Enabled: boolPointer(true),
+			factory: func(l log.Logger, c zfs.Client, props []string) (Collector, error) {
+				return blockingCollector{release: release, runs: &runs}, nil
+			},
+		},
+	}
+	names := []string{`zfs_pool_allocated_bytes`, `zfs_scrape_collector_success`, `zfs_test_blocking`}
+
+	scrape := func(result string) {
+		zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+		zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+		zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`allocated`: `1024`}).Times(1)
+		zfsPool := mock_zfs.NewMockPool(ctrl)
+		zfsPool.EXPECT().Properties(`allocated`).Return(zfsPoolProperties, nil).Times(1)
+		zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+		if err := callCollector(ctx, collector, []byte(result), names); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The slow collector times out, then is skipped while the abandoned run is still blocked.
+	scrape(blockedResult)
+	scrape(blockedResult)
+	if got := atomic.LoadInt32(&runs); got != 1 {
+		t.Fatalf("got %d runs of the slow collector, want 1", got)
+	}
+
+	// Once released, the abandoned run completes, discarding its metric, and the collector is run again.
+	close(release)
+	for {
+		if _, ok := collector.abandoned.Load(`slow`); !ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		case <-time.After(time.Millisecond):
+		}
+	}
+	scrape(releasedResult)
+}
+
+// hangingRunner lists a single pool, and blocks every other command until its context is done.
+type hangingRunner struct {
+	runs *int32
+}
+
+// Run implements the zfs.CommandRunner interface
+func (r hangingRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	if key := strings.Join(append([]string{name}, args...), ` `); key == `zpool list -Ho name` {
+		return poolRunnerOutput{Reader: strings.NewReader("testpool\n")}, nil
+	}
+	atomic.AddInt32(r.runs, 1)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestZFSCollectorTimeoutKillsCommands(t *testing.T) {
+	const result = `# HELP zfs_scrape_collector_success zfs_exporter: Whether a collector succeeded.
+# TYPE zfs_scrape_collector_success gauge
+zfs_scrape_collector_success{collector="pool"} 0
+`
+
+	var runs int32
+	config := defaultConfig(zfs.New(zfs.Config{Runner: hangingRunner{runs: &runs}}))
+	config.DisableMetrics = false
+	config.CollectorTimeout = 50 * time.Millisecond
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated`),
+			factory:    newPoolCollector,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := int32(1); i <= 2; i++ {
+		if err = callCollector(ctx, collector, []byte(result), []string{`zfs_scrape_collector_success`}); err != nil {
+			t.Fatal(err)
+		}
+		// The hung command is killed at the timeout, so the abandoned run completes without intervention.
+		for {
+			if _, ok := collector.abandoned.Load(`pool`); !ok {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatal(ctx.Err())
+			case <-time.After(time.Millisecond):
+			}
+		}
+		if got := atomic.LoadInt32(&runs); got != i {
+			t.Fatalf("got %d commands run, want %d", got, i)
+		}
+	}
+}
+
+func TestZFSDurationHistogramBuckets(t *testing.T) {
+	const result = `# HELP zfs_scrape_collector_duration_histogram_seconds zfs_exporter: Distribution of collector scrape durations.
+# TYPE zfs_scrape_collector_duration_histogram_seconds histogram
+zfs_scrape_collector_duration_histogram_seconds_bucket{collector="pool",le="0.001"} 1
+zfs_scrape_collector_duration_histogram_seconds_bucket{collector="pool",le="0.1"} 2
+zfs_scrape_collector_duration_histogram_seconds_bucket{collector="pool",le="10"} 4
+zfs_scrape_collector_duration_histogram_seconds_bucket{collector="pool",le="+Inf"} 5
+zfs_scrape_collector_duration_histogram_seconds_sum{collector="pool"} 37.5505
+zfs_scrape_collector_duration_histogram_seconds_count{collector="pool"} 5
+`
+
+	ctrl := gomock.NewController(t)
+	config := defaultConfig(mock_zfs.NewMockClient(ctrl))
+	config.DurationBuckets = []float64{.001, .1, 10}
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, duration := range []float64{.0005, .05, 2.5, 5, 30} {
+		collector.durations.WithLabelValues(`pool`).Observe(duration)
+	}
+
+	if err = testutil.CollectAndCompare(collector.durations, strings.NewReader(result)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZFSDurationHistogramUnsortedBuckets(t *testing.T) {
+	const result = `# HELP zfs_scrape_collector_duration_histogram_seconds zfs_exporter: Distribution of collector scrape durations.
+# TYPE zfs_scrape_collector_duration_histogram_seconds histogram
+zfs_scrape_collector_duration_histogram_seconds_bucket{collector="pool",le="0.001"} 1
+zfs_scrape_collector_duration_histogram_seconds_bucket{collector="pool",le="0.1"} 2
+zfs_scrape_collector_duration_histogram_seconds_bucket{collector="pool",le="10"} 3
+zfs_scrape_collector_duration_histogram_seconds_bucket{collector="pool",le="+Inf"} 3
+zfs_scrape_collector_duration_histogram_seconds_sum{collector="pool"} 5.0505
+zfs_scrape_collector_duration_histogram_seconds_count{collector="pool"} 3
+`
+
+	ctrl := gomock.NewController(t)
+	config := defaultConfig(mock_zfs.NewMockClient(ctrl))
+	config.DurationBuckets = []float64{10, .1, .001, .1}
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, duration := range []float64{.0005, .05, 5} {
+		collector.durations.WithLabelValues(`pool`).Observe(duration)
+	}
+
+	if err = testutil.CollectAndCompare(collector.durations, strings.NewReader(result)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewZFSInvalidDurationBuckets(t *testing.T) {
+	config := defaultConfig(nil)
+	config.DurationBuckets = []float64{.1, math.NaN()}
+	if _, err := NewZFS(config); err == nil {
+		t.Fatal(`expected error, got nil`)
+	}
+}
+
+func TestZFSDurationHistogramObserved(t *testing.T) {
+	ctrl, ctx := gomock.WithContext(context.Background(), t)
+	zfsClient := mock_zfs.NewMockClient(ctrl)
+	zfsClient.EXPECT().PoolNames().Return([]string{`testpool`}, nil).Times(1)
+	zfsPoolProperties := mock_zfs.NewMockPoolProperties(ctrl)
+	zfsPoolProperties.EXPECT().Properties().Return(map[string]string{`allocated`: `1024`}).Times(1)
+	zfsPool := mock_zfs.NewMockPool(ctrl)
+	zfsPool.EXPECT().Properties(`allocated`).Return(zfsPoolProperties, nil).Times(1)
+	zfsClient.EXPECT().Pool(`testpool`).Return(zfsPool).Times(1)
+
+	config := defaultConfig(zfsClient)
+	config.DisableMetrics = false
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       "pool",
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated`),
+			factory:    newPoolCollector,
+		},
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+	result := make(chan error)
+	go func() {
+		families, err := registry.Gather()
+		if err == nil {
+			err = fmt.Errorf("metric %s not found", scrapeDurationHistogramName)
+			for _, family := range families {
+				if family.GetName() != scrapeDurationHistogramName {
+					continue
+				}
+				err = nil
+				if got := family.GetMetric()[0].GetHistogram().GetSampleCount(); got != 1 {
+					err = fmt.Errorf("got %d observations, want 1", got)
+				}
+			}
+		}
+		result <- err
+	}()
+
+	select {
+	case err = <-result:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+}
+
+func TestNewZFSInvalidPool(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	config := defaultConfig(mock_zfs.NewMockClient(ctrl))
+	config.Pools = []string{`testpool`, `-o testpool`}
+
+	if _, err := NewZFS(config); err == nil {
+		t.Fatal(`expected error for invalid pool name`)
+	}
+}
+
+// slowRunner returns canned output for commands, keyed by the full command line, after a fixed delay.
+type slowRunner struct {
+	delay  time.Duration
+	output map[string]string
+}
+
+// Run implements the zfs.CommandRunner interface
+func (r slowRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	time.Sleep(r.delay)
+	key := strings.Join(append([]string{name}, args...), ` `)
+	return io.NopCloser(strings.NewReader(r.output[key])), nil
+}
+
+// peakRunner records the peak number of commands running concurrently through the wrapped runner.
+type peakRunner struct {
+	runner  zfs.CommandRunner
+	mu      sync.Mutex
+	running int
+	peak    int
+	calls   int
+}
+
+// Run implements the zfs.CommandRunner interface
+func (r *peakRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	r.mu.Lock()
+	r.running++
+	r.calls++
+	if r.running > r.peak {
+		r.peak = r.running
+	}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running--
+		r.mu.Unlock()
+	}()
+	return r.runner.Run(ctx, name, args...)
+}
+
+func TestZFSCollectMaxConcurrency(t *testing.T) {
+	const limit = 2
+	pools := []string{`pool0`, `pool1`, `pool2`, `pool3`}
+	slow := slowRunner{
+		delay:  5 * time.Millisecond,
+		output: map[string]string{`zpool list -Ho name`: strings.Join(pools, "\n") + "\n"},
+	}
+	for _, pool := range pools {
+		slow.output[`zpool get -Hpo name,property,value allocated `+pool] = pool + "\tallocated\t1024\n"
+		slow.output[`zpool iostat -Hp `+pool] = pool + "\t1024\t3072\t50\t20\t409600\t81920\n"
+	}
+	runner := &peakRunner{runner: slow}
+
+	config := defaultConfig(zfs.New(zfs.Config{Runner: runner, MaxConcurrency: limit}))
+	collector, err := NewZFS(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Collectors = map[string]State{
+		`pool`: {
+			Name:       `pool`,
+			Enabled:    boolPointer(true),
+			Properties: stringPointer(`allocated`),
+			factory:    newPoolCollector,
+		},
+		`pool-iostat`: {
+			Name:    `pool-iostat`,
+			Enabled: boolPointer(true),
+			factory: newPoolIOStatCollector,
+		},
+		`pool-queues`: {
+			Name:    `pool-queues`,
+			Enabled: boolPointer(true),
+			factory: newPoolQueuesCollector,
+		},
+	}
+
+	if n := testutil.CollectAndCount(collector); n == 0 {
+		t.Fatal(`no metrics collected`)
+	}
+	if runner.calls <= limit {
+		t.Fatalf("got %d commands, want more than %d to exercise the limit", runner.calls, limit)
+	}
+	if runner.peak > limit {
+		t.Fatalf("got %d concurrent commands across collectors, want at most %d", runner.peak, limit)
+	}
+}
+
+// BenchmarkZFSCollectConcurrency compares the wall-clock time of collecting the pool and pool-iostat collectors from
+// several pools with commands executed serially, against executing them concurrently.
+func BenchmarkZFSCollectConcurrency(b *testing.B) {
+	pools := []string{`pool0`, `pool1`, `pool2`, `pool3`}
+	runner := slowRunner{
+		delay:  10 * time.Millisecond,
+		output: map[string]string{`zpool list -Ho name`: strings.Join(pools, "\n") + "\n"},
+	}
+	for _, pool := range pools {
+		runner.output[`zpool get -Hpo name,property,value allocated `+pool] = pool + "\tallocated\t1024\n"
+		runner.output[`zpool iostat -Hp `+pool] = pool + "\t1024\t3072\t50\t20\t409600\t81920\n"
+	}
+
+	for _, bc := range []struct {
+		name  string
+		limit int
+	}{
+		{name: `serial`, limit: 1},
+		{name: `concurrent`, limit: 0},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			config := defaultConfig(zfs.New(zfs.Config{Runner: runner, MaxConcurrency: bc.limit}))
+			collector, err := NewZFS(config)
+			if err != nil {
+				b.Fatal(err)
+			}
+			collector.Collectors = map[string]State{
+				`pool`: {
+					Name:       `pool`,
+					Enabled:    boolPointer(true),
+					Properties: stringPointer(`allocated`),
+					factory:    newPoolCollector,
+				},
+				`pool-iostat`: {
+					Name:    `pool-iostat`,
+					Enabled: boolPointer(true),
+					factory: newPoolIOStatCollector,
+				},
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if n := testutil.CollectAndCount(collector); n == 0 {
+					b.Fatal(`no metrics collected`)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/activation"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/exporter-toolkit/web"
+)
+
+// shutdownTimeout bounds the time given to in-flight requests to complete once a shutdown signal is received.
+var shutdownTimeout = 5 * time.Second
+
+// listen opens the listeners configured by flags, which are either the systemd socket activated listeners or the TCP
+// listen addresses, along with a unix domain socket listener at socketPath if not empty.
+func listen(flags *web.FlagConfig, socketPath string, logger log.Logger) ([]net.Listener, error) {
+	var (
+		listeners []net.Listener
+		err       error
+	)
+	if flags.WebSystemdSocket != nil && *flags.WebSystemdSocket {
+		_ = level.Info(logger).Log("msg", "Listening on systemd activated listeners instead of port listeners")
+		if listeners, err = activation.Listeners(); err != nil {
+			return nil, err
+		}
+		if len(listeners) == 0 {
+			return nil, errors.New("no socket activation file descriptors found")
+		}
+	} else if flags.WebListenAddresses != nil {
+		for _, address := range *flags.WebListenAddresses {
+			listener, err := net.Listen("tcp", address)
+			if err != nil {
+				closeListeners(listeners)
+				return nil, err
+			}
+			listeners = append(listeners, listener)
+		}
+	}
+
+	if socketPath != "" {
+		listener, err := listenSocket(socketPath)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	if len(listeners) == 0 {
+		return nil, web.ErrNoListeners
+	}
+	return listeners, nil
+}
+
+// listenSocket listens on a unix domain socket at path, replacing any stale socket left by a previous process. Files
+// that are not sockets are never replaced.
+func listenSocket(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("listen socket %s exists and is not a socket", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, listener := range listeners {
+		_ = listener.Close()
+	}
+}
+
+// serve serves requests on all listeners until a signal is received, then shuts the server down, closing all listeners,
+// calling cancel to terminate in-flight commands, and waiting up to shutdownTimeout for in-flight requests to complete.
+// Requests still in flight after the timeout are logged and their connections closed, as the shutdown is otherwise
+// clean.
+func serve(server *http.Server, listeners []net.Listener, flags *web.FlagConfig, logger log.Logger, signals <-chan os.Signal, cancel context.CancelFunc) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- web.ServeMultiple(listeners, server, flags, logger)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		_ = level.Info(logger).Log("msg", "Shutting down", "signal", sig)
+	}
+
+	// Commands are killed, rather than left running after exit, and the scrapes waiting on them complete with errors.
+	cancel()
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(ctx); err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		_ = level.Warn(logger).Log("msg", "Timed out waiting for in-flight requests, closing connections", "timeout", shutdownTimeout)
+		_ = server.Close()
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
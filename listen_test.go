@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pdf/zfs_exporter/v2/collector"
+	"github.com/pdf/zfs_exporter/v2/zfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
+)
+
+func TestListenSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), `zfs_exporter.sock`)
+	// A stale socket left by a previous process is replaced.
+	stale, err := net.Listen(`unix`, socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	configFile, systemdSocket := ``, false
+	flags := &web.FlagConfig{WebListenAddresses: &[]string{}, WebSystemdSocket: &systemdSocket, WebConfigFile: &configFile}
+	listeners, err := listen(flags, socketPath, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 1 {
+		t.Fatalf("got %d listeners, want 1", len(listeners))
+	}
+
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: `test_total`, Help: `Test counter.`})
+	counter.Inc()
+	registry.MustRegister(counter)
+	mux := http.NewServeMux()
+	mux.Handle(`/metrics`, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux}
+	go func() {
+		_ = web.ServeMultiple(listeners, server, flags, log.NewNopLogger())
+	}()
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, `unix`, socketPath)
+		},
+	}}
+	resp, err := client.Get(`http://localhost/metrics`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if !strings.Contains(string(body), "test_total 1\n") {
+		t.Fatalf("metric test_total not found in response:\n%s", body)
+	}
+}
+
+func TestListenSocketNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `zfs_exporter.sock`)
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := listenSocket(path); err == nil {
+		t.Fatal(`expected error for existing file that is not a socket`)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("file was removed: %v", err)
+	}
+}
+
+func TestListenNoListeners(t *testing.T) {
+	systemdSocket := false
+	flags := &web.FlagConfig{WebListenAddresses: &[]string{}, WebSystemdSocket: &systemdSocket}
+	if _, err := listen(flags, ``, log.NewNopLogger()); err != web.ErrNoListeners {
+		t.Fatalf("got error %v, want %v", err, web.ErrNoListeners)
+	}
+}
+
+func TestServeMultipleAddresses(t *testing.T) {
+	addresses := []string{`127.0.0.1:0`, `[::1]:0`}
+	if listener, err := net.Listen(`tcp`, `[::1]:0`); err != nil {
+		// Hosts without IPv6 still bind multiple addresses.
+		addresses[1] = `127.0.0.1:0`
+	} else {
+		_ = listener.Close()
+	}
+
+	configFile, systemdSocket := ``, false
+	flags := &web.FlagConfig{WebListenAddresses: &addresses, WebSystemdSocket: &systemdSocket, WebConfigFile: &configFile}
+	listeners, err := listen(flags, ``, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != len(addresses) {
+		t.Fatalf("got %d listeners, want %d", len(listeners), len(addresses))
+	}
+
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: `test_total`, Help: `Test counter.`})
+	counter.Inc()
+	registry.MustRegister(counter)
+	mux := http.NewServeMux()
+	mux.Handle(`/metrics`, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux}
+	signals := make(chan os.Signal, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- serve(server, listeners, flags, log.NewNopLogger(), signals, func() {})
+	}()
+
+	for _, listener := range listeners {
+		resp, err := http.Get(`http://` + listener.Addr().String() + `/metrics`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), "test_total 1\n") {
+			t.Fatalf("metric test_total not found in response from %s:\n%s", listener.Addr(), body)
+		}
+	}
+
+	// All listeners are closed on shutdown.
+	signals <- os.Interrupt
+	if err = <-result; err != nil {
+		t.Fatalf("got error %v on shutdown, want nil", err)
+	}
+	for _, listener := range listeners {
+		if conn, err := net.Dial(`tcp`, listener.Addr().String()); err == nil {
+			_ = conn.Close()
+			t.Fatalf("listener %s still accepting connections after shutdown", listener.Addr())
+		}
+	}
+}
+
+func TestServeBasicAuth(t *testing.T) {
+	// The bcrypt hash of the password `scrape`.
+	const config = "basic_auth_users:\n  prometheus: $2a$04$KEzTkfilYuTTrvnYwMFL2.aNyKoZKxdhZLQTQBS8KS8Tui2nXEmrC\n"
+	configFile := filepath.Join(t.TempDir(), `web-config.yml`)
+	if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	addresses, systemdSocket := []string{`127.0.0.1:0`}, false
+	flags := &web.FlagConfig{WebListenAddresses: &addresses, WebSystemdSocket: &systemdSocket, WebConfigFile: &configFile}
+	listeners, err := listen(flags, ``, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(`/metrics`, promhttp.HandlerFor(prometheus.NewRegistry(), promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux}
+	signals := make(chan os.Signal, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- serve(server, listeners, flags, log.NewNopLogger(), signals, func() {})
+	}()
+	// Connections dialed but left unused by the client would otherwise delay the shutdown until they time out.
+	transport := &http.Transport{}
+	client := &http.Client{Transport: transport}
+	defer func() {
+		transport.CloseIdleConnections()
+		signals <- os.Interrupt
+		if err := <-result; err != nil {
+			t.Fatalf("got error %v on shutdown, want nil", err)
+		}
+	}()
+
+	testCases := []struct {
+		name       string
+		user       string
+		password   string
+		wantStatus int
+	}{
+		{name: `unauthenticated`, wantStatus: http.StatusUnauthorized},
+		{name: `wrong password`, user: `prometheus`, password: `wrong`, wantStatus: http.StatusUnauthorized},
+		{name: `authenticated`, user: `prometheus`, password: `scrape`, wantStatus: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, `http://`+listeners[0].Addr().String()+`/metrics`, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.user != `` {
+				req.SetBasicAuth(tc.user, tc.password)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { shutdownTimeout = timeout }(shutdownTimeout)
+	shutdownTimeout = 50 * time.Millisecond
+
+	addresses, configFile, systemdSocket := []string{`127.0.0.1:0`}, ``, false
+	flags := &web.FlagConfig{WebListenAddresses: &addresses, WebSystemdSocket: &systemdSocket, WebConfigFile: &configFile}
+	listeners, err := listen(flags, ``, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request never completes, so the shutdown times out.
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	mux := http.NewServeMux()
+	mux.HandleFunc(`/metrics`, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	server := &http.Server{Handler: mux}
+	signals := make(chan os.Signal, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- serve(server, listeners, flags, log.NewNopLogger(), signals, func() {})
+	}()
+	go func() {
+		if resp, err := http.Get(`http://` + listeners[0].Addr().String() + `/metrics`); err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+
+	signals <- os.Interrupt
+	if err = <-result; err != nil {
+		t.Fatalf("got error %v on shutdown, want nil", err)
+	}
+}
+
+// cancelRunner lists a single pool, and blocks every other command until its context is done, reporting the context
+// error.
+type cancelRunner struct {
+	started chan struct{}
+	errs    chan error
+}
+
+// Run implements the zfs.CommandRunner interface
+func (r cancelRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	if strings.Join(append([]string{name}, args...), ` `) == `zpool list -Ho name` {
+		return io.NopCloser(strings.NewReader("testpool\n")), nil
+	}
+	select {
+	case r.started <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	select {
+	case r.errs <- ctx.Err():
+	default:
+	}
+	return nil, ctx.Err()
+}
+
+func TestServeShutdownCancelsCommands(t *testing.T) {
+	addresses, systemdSocket := []string{`127.0.0.1:0`}, false
+	configFile := ``
+	flags := &web.FlagConfig{WebListenAddresses: &addresses, WebSystemdSocket: &systemdSocket, WebConfigFile: &configFile}
+	listeners, err := listen(flags, ``, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner := cancelRunner{started: make(chan struct{}, 1), errs: make(chan error, 1)}
+	commandCtx, cancelCommands := context.WithCancel(context.Background())
+	defer cancelCommands()
+	c, err := collector.NewZFS(collector.ZFSConfig{
+		Deadline:  time.Minute,
+		Logger:    log.NewNopLogger(),
+		ZFSClient: zfs.New(zfs.Config{Runner: runner, Context: commandCtx}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := c.Collectors[`pool-features`]
+	enabled := true
+	state.Enabled = &enabled
+	c.Collectors = map[string]collector.State{`pool-features`: state}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	mux := http.NewServeMux()
+	mux.Handle(`/metrics`, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux}
+	signals := make(chan os.Signal, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- serve(server, listeners, flags, log.NewNopLogger(), signals, cancelCommands)
+	}()
+
+	scrape := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(`http://` + listeners[0].Addr().String() + `/metrics`)
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+		}
+		scrape <- err
+	}()
+	select {
+	case <-runner.started:
+	case <-time.After(10 * time.Second):
+		t.Fatal(`timed out waiting for the scrape to execute a command`)
+	}
+
+	// The in-flight command is cancelled on shutdown, and the scrape completes before the server exits.
+	signals <- os.Interrupt
+	select {
+	case err = <-runner.errs:
+		if err != context.Canceled {
+			t.Fatalf("got command context error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal(`timed out waiting for the in-flight command to be cancelled`)
+	}
+	if err = <-result; err != nil {
+		t.Fatalf("got error %v on shutdown, want nil", err)
+	}
+	if err = <-scrape; err != nil {
+		t.Fatalf("got error %v from in-flight scrape, want nil", err)
+	}
+}
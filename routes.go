@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/common/version"
+	"github.com/prometheus/exporter-toolkit/web"
+)
+
+// routeConfig holds the handlers served by the exporter, and the paths they are served under.
+type routeConfig struct {
+	// prefix is prepended to the path of every route, ie - when reverse-proxied under a subpath
+	prefix string
+	// metricsPath is the path of the metrics handler, relative to the prefix
+	metricsPath string
+	metrics     http.Handler
+	collectors  http.Handler
+	healthy     http.Handler
+	ready       http.Handler
+}
+
+// newRouter returns a mux serving the routes of the exporter under the configured prefix, with a landing page linking
+// to them at the prefix itself, unless metrics are served there.
+func newRouter(config routeConfig) (*http.ServeMux, error) {
+	if !strings.HasPrefix(config.metricsPath, "/") {
+		return nil, fmt.Errorf("telemetry path %q must begin with /", config.metricsPath)
+	}
+	if config.prefix != "" && !strings.HasPrefix(config.prefix, "/") {
+		return nil, fmt.Errorf("route prefix %q must begin with /", config.prefix)
+	}
+	prefix := strings.TrimRight(config.prefix, "/")
+
+	mux := http.NewServeMux()
+	mux.Handle(prefix+config.metricsPath, config.metrics)
+	mux.Handle(prefix+collectorsPath, config.collectors)
+	mux.Handle(prefix+healthyPath, config.healthy)
+	mux.Handle(prefix+readyPath, config.ready)
+	if config.metricsPath == "/" {
+		return mux, nil
+	}
+
+	landingPage, err := web.NewLandingPage(web.LandingConfig{
+		Name:        "ZFS Exporter",
+		Description: "Prometheus ZFS Exporter",
+		Version:     version.Info(),
+		Links: []web.LandingLinks{
+			{
+				Address: prefix + config.metricsPath,
+				Text:    "Metrics",
+			},
+			{
+				Address: prefix + collectorsPath,
+				Text:    "Collectors",
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The landing page pattern matches every path under the prefix that has no other route, which are not found.
+	root := prefix + "/"
+	mux.Handle(root, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != root {
+			http.NotFound(w, r)
+			return
+		}
+		landingPage.ServeHTTP(w, r)
+	}))
+
+	return mux, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	testCases := []struct {
+		name        string
+		prefix      string
+		metricsPath string
+		found       []string
+		notFound    []string
+		links       []string
+	}{
+		{
+			name:        `default`,
+			prefix:      `/`,
+			metricsPath: `/metrics`,
+			found:       []string{`/`, `/metrics`, `/collectors`, `/-/healthy`, `/-/ready`},
+			notFound:    []string{`/zfs/metrics`, `/other`},
+			links:       []string{`href="/metrics"`, `href="/collectors"`},
+		},
+		{
+			name:        `custom telemetry path`,
+			prefix:      `/`,
+			metricsPath: `/zfs-metrics`,
+			found:       []string{`/`, `/zfs-metrics`},
+			notFound:    []string{`/metrics`},
+			links:       []string{`href="/zfs-metrics"`},
+		},
+		{
+			name:        `route prefix`,
+			prefix:      `/zfs/`,
+			metricsPath: `/metrics`,
+			found:       []string{`/zfs/`, `/zfs/metrics`, `/zfs/collectors`, `/zfs/-/healthy`, `/zfs/-/ready`},
+			notFound:    []string{`/`, `/metrics`, `/collectors`, `/zfs/other`},
+			links:       []string{`href="/zfs/metrics"`, `href="/zfs/collectors"`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := func(name string) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = io.WriteString(w, name)
+				})
+			}
+			router, err := newRouter(routeConfig{
+				prefix:      tc.prefix,
+				metricsPath: tc.metricsPath,
+				metrics:     handler(`metrics`),
+				collectors:  handler(`collectors`),
+				healthy:     handler(`healthy`),
+				ready:       handler(`ready`),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			get := func(path string) (int, string) {
+				resp, err := http.Get(server.URL + path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resp.Body.Close()
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return resp.StatusCode, string(body)
+			}
+
+			for _, path := range tc.found {
+				if status, _ := get(path); status != http.StatusOK {
+					t.Errorf("GET %s got status %d, want %d", path, status, http.StatusOK)
+				}
+			}
+			for _, path := range tc.notFound {
+				if status, _ := get(path); status != http.StatusNotFound {
+					t.Errorf("GET %s got status %d, want %d", path, status, http.StatusNotFound)
+				}
+			}
+			if _, body := get(strings.TrimRight(tc.prefix, `/`) + tc.metricsPath); body != `metrics` {
+				t.Errorf("got metrics body %q, want %q", body, `metrics`)
+			}
+			_, landing := get(strings.TrimRight(tc.prefix, `/`) + `/`)
+			for _, link := range tc.links {
+				if !strings.Contains(landing, link) {
+					t.Errorf("got landing page without %s:\n%s", link, landing)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterInvalidPaths(t *testing.T) {
+	for _, config := range []routeConfig{
+		{prefix: `/`, metricsPath: `metrics`},
+		{prefix: `zfs`, metricsPath: `/metrics`},
+	} {
+		if _, err := newRouter(config); err == nil {
+			t.Errorf("newRouter(prefix %q, metrics path %q) got no error, want error", config.prefix, config.metricsPath)
+		}
+	}
+}
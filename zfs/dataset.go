@@ -1,6 +1,8 @@
 package zfs
 
 import (
+	"context"
+	"errors"
 	"strings"
 )
 
@@ -17,8 +19,10 @@ const (
 )
 
 type datasetsImpl struct {
-	pool string
-	kind DatasetKind
+	runner    CommandRunner
+	delimiter rune
+	pool      string
+	kind      DatasetKind
 }
 
 func (d datasetsImpl) Pool() string {
@@ -29,14 +33,49 @@ func (d datasetsImpl) Kind() DatasetKind {
 	return d.kind
 }
 
+// Properties returns the properties of every dataset of the kind in the pool. Datasets destroyed while the command runs
+// (ie - by snapshot rotation) are skipped, rather than failing the pool.
 func (d datasetsImpl) Properties(props ...string) ([]DatasetProperties, error) {
 	handler := newDatasetHandler()
-	if err := execute(d.pool, handler, `zfs`, `get`, `-Hprt`, string(d.kind), `-o`, `name,property,value`, strings.Join(props, `,`)); err != nil {
+	err := execute(context.Background(), d.runner, d.delimiter, d.pool, handler, `zfs`, `get`, `-Hprt`, string(d.kind), `-o`, `name,property,value`, strings.Join(props, `,`))
+	if errors.Is(err, ErrDatasetNotFound) {
+		for _, name := range missingDatasets(err) {
+			delete(handler.store, name)
+		}
+		err = nil
+	}
+	if err != nil {
 		return nil, err
 	}
 	return handler.datasets(), nil
 }
 
+// DatasetNames returns the names of all datasets of the given kinds across all pools, via `zfs list`, which is
+// considerably cheaper than fetching their properties.
+func (z clientImpl) DatasetNames(kinds ...DatasetKind) ([]string, error) {
+	types := make([]string, len(kinds))
+	for i, kind := range kinds {
+		types[i] = string(kind)
+	}
+	names := make([]string, 0)
+	err := executeLines(context.Background(), z.runner, func(line string) error {
+		if line == `` {
+			return ErrInvalidOutput
+		}
+		names = append(names, line)
+		return nil
+	}, `zfs`, `list`, `-Hp`, `-t`, strings.Join(types, `,`), `-o`, `name`)
+	// As for Properties, datasets destroyed while the command runs are skipped.
+	if errors.Is(err, ErrDatasetNotFound) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
 type datasetPropertiesImpl struct {
 	datasetName string
 	properties  map[string]string
@@ -57,7 +96,7 @@ type datasetHandler struct {
 
 // processLine implements the handler interface
 func (h *datasetHandler) processLine(pool string, line []string) error {
-	if len(line) != 3 || !strings.HasPrefix(line[0], pool) {
+	if len(line) != 3 || !inPool(pool, line[0]) {
 		return ErrInvalidOutput
 	}
 	if _, ok := h.store[line[0]]; !ok {
@@ -67,6 +106,16 @@ func (h *datasetHandler) processLine(pool string, line []string) error {
 	return nil
 }
 
+// inPool reports whether the named dataset or snapshot belongs to the pool, so that the output of a pool sharing a
+// name prefix (ie - `tank` and `tank2`) is not attributed to it.
+func inPool(pool, name string) bool {
+	if !strings.HasPrefix(name, pool) {
+		return false
+	}
+	rest := name[len(pool):]
+	return rest == `` || rest[0] == '/' || rest[0] == '@'
+}
+
 func (h *datasetHandler) datasets() []DatasetProperties {
 	result := make([]DatasetProperties, len(h.store))
 	i := 0
@@ -84,10 +133,12 @@ func newDatasetPropertiesImpl(name string) *datasetPropertiesImpl {
 	}
 }
 
-func newDatasetsImpl(pool string, kind DatasetKind) datasetsImpl {
+func newDatasetsImpl(runner CommandRunner, delimiter rune, pool string, kind DatasetKind) datasetsImpl {
 	return datasetsImpl{
-		pool: pool,
-		kind: kind,
+		runner:    runner,
+		delimiter: delimiter,
+		pool:      pool,
+		kind:      kind,
 	}
 }
 
@@ -0,0 +1,62 @@
+package zfs
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// eventTimeLayout is the format of event timestamps in `zpool events` output, in the local time of the host.
+const eventTimeLayout = `Jan _2 2006 15:04:05.000000000`
+
+// eventClassPrefixes are the prefixes of ZFS event classes, which are trimmed to produce the short class name.
+var eventClassPrefixes = []string{
+	`ereport.fs.zfs.`,
+	`resource.fs.zfs.`,
+	`sysevent.fs.zfs.`,
+}
+
+// Event is an entry from the ZFS event log
+type Event struct {
+	// Time the event occurred
+	Time time.Time
+	// Class of the event without the ZFS prefix, ie - `checksum` for `ereport.fs.zfs.checksum`
+	Class string
+}
+
+// Events returns the entries of the ZFS event log for the pool via `zpool events`, oldest first. The log is a bounded
+// buffer in the kernel, so older events are discarded as new events arrive.
+func (p poolImpl) Events() ([]Event, error) {
+	events := make([]Event, 0)
+	err := executeLines(context.Background(), p.runner, func(line string) error {
+		event, err := parseEvent(line, p.location)
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+		return nil
+	}, `zpool`, `events`, `-H`, p.name)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// parseEvent parses a line of `zpool events -H` output, consisting of the timestamp and class separated by a tab. The
+// timestamp is in the time zone of the host, loc.
+func parseEvent(line string, loc *time.Location) (Event, error) {
+	timestamp, class, ok := strings.Cut(line, "\t")
+	if !ok {
+		return Event{}, ErrInvalidOutput
+	}
+	t, err := time.ParseInLocation(eventTimeLayout, strings.TrimSpace(timestamp), loc)
+	if err != nil {
+		return Event{}, err
+	}
+	class = strings.TrimSpace(class)
+	for _, prefix := range eventClassPrefixes {
+		class = strings.TrimPrefix(class, prefix)
+	}
+
+	return Event{Time: t, Class: class}, nil
+}
@@ -0,0 +1,77 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const fixtureZpoolEvents = "Oct  9 2021 23:59:59.000000001\tsysevent.fs.zfs.history_event\n" +
+	"Oct 10 2021 10:00:00.123456789\tereport.fs.zfs.checksum\n" +
+	"Oct 10 2021 10:00:00.123456789\tereport.fs.zfs.checksum\n" +
+	"Oct 10 2021 10:00:01.000000000\tereport.fs.zfs.io\n" +
+	"Oct 10 2021 10:00:02.500000000\tereport.fs.zfs.probe_failure\n" +
+	"Oct 10 2021 10:00:03.000000000\tresource.fs.zfs.statechange\n" +
+	"Oct 10 2021 10:00:04.000000000\tereport.fs.zfs.vdev.open_failed\n"
+
+func TestPoolEvents(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zpool events -H testpool`: fixtureZpoolEvents,
+		},
+	}
+
+	events, err := New(Config{Runner: runner}).Pool(`testpool`).Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, min, sec, nsec int) time.Time {
+		return time.Date(2021, time.October, day, hour, min, sec, nsec, time.Local)
+	}
+	want := []Event{
+		{Time: at(9, 23, 59, 59, 1), Class: `history_event`},
+		{Time: at(10, 10, 0, 0, 123456789), Class: `checksum`},
+		{Time: at(10, 10, 0, 0, 123456789), Class: `checksum`},
+		{Time: at(10, 10, 0, 1, 0), Class: `io`},
+		{Time: at(10, 10, 0, 2, 500000000), Class: `probe_failure`},
+		{Time: at(10, 10, 0, 3, 0), Class: `statechange`},
+		{Time: at(10, 10, 0, 4, 0), Class: `vdev.open_failed`},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("got events %+v, want %+v", events, want)
+	}
+}
+
+func TestPoolEventsLocation(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zpool events -H testpool`: "Oct 10 2021 10:00:00.000000000\tereport.fs.zfs.checksum\n",
+		},
+	}
+
+	loc := time.FixedZone(`PDT`, -7*60*60)
+	events, err := New(Config{Runner: runner, Location: loc}).Pool(`testpool`).Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, time.October, 10, 17, 0, 0, 0, time.UTC); len(events) != 1 || !events[0].Time.Equal(want) {
+		t.Fatalf("got events %+v, want one at %s", events, want)
+	}
+}
+
+func TestPoolEventsInvalidOutput(t *testing.T) {
+	for _, output := range []string{
+		"Oct 10 2021 10:00:00.123456789 ereport.fs.zfs.checksum\n",
+		"yesterday\tereport.fs.zfs.checksum\n",
+	} {
+		runner := &fakeRunner{
+			output: map[string]string{
+				`zpool events -H testpool`: output,
+			},
+		}
+
+		if _, err := New(Config{Runner: runner}).Pool(`testpool`).Events(); err == nil {
+			t.Errorf("expected error for output %q", output)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package zfs
+
+import (
+	"context"
+	"strconv"
+)
+
+// iostatFieldsPerRecord is the number of fields in `zpool iostat -H` output: name, alloc, free, read/write operations
+// and read/write bandwidth. Columns beyond these (ie - added by other versions or platforms) follow them, and are
+// ignored by IOStat.
+const iostatFieldsPerRecord = 7
+
+// IOQueue holds the number of pending and active I/Os for a single ZFS I/O queue
+type IOQueue struct {
+	// Name of the queue, one of IOQueueNames
+	Name string
+	// Pending is the number of I/Os waiting in the queue
+	Pending uint64
+	// Active is the number of I/Os issued to the devices from the queue
+	Active uint64
+}
+
+// IOQueueNames lists the queues reported by `zpool iostat -q`, in column order. Older versions report a prefix of
+// these queues.
+var IOQueueNames = []string{
+	`sync_read`,
+	`sync_write`,
+	`async_read`,
+	`async_write`,
+	`scrub`,
+	`trim`,
+	`rebuild`,
+}
+
+// iostatProperties names the fields following the pool name in `zpool iostat -H` output.
+var iostatProperties = []string{
+	`allocated`,
+	`free`,
+	`read_operations`,
+	`write_operations`,
+	`read_bandwidth`,
+	`write_bandwidth`,
+}
+
+// IOStat returns I/O statistics for the pool via `zpool iostat`. When an interval is configured, two samples are taken
+// separated by the interval and only the second is returned, otherwise the averages since boot are returned. The
+// number of columns may vary by version, so records need only have as many fields as the first, and the leading
+// columns are mapped to iostatProperties by position.
+func (p poolImpl) IOStat() (PoolProperties, error) {
+	handler := newPoolIOStatImpl()
+	if err := executeFields(context.Background(), p.runner, p.delimiter, p.name, handler, 0, `zpool`, p.iostatArgs(`-Hp`)...); err != nil {
+		return handler, err
+	}
+	return handler, nil
+}
+
+// IOQueues returns the pending and active I/O counts for each of the pool I/O queues via `zpool iostat -q`, sampled in
+// the same manner as IOStat. The number of queues varies by version, so the queue columns are validated by the handler.
+func (p poolImpl) IOQueues() ([]IOQueue, error) {
+	handler := &poolIOQueuesImpl{}
+	if err := executeFields(context.Background(), p.runner, p.delimiter, p.name, handler, 0, `zpool`, p.iostatArgs(`-Hpq`)...); err != nil {
+		return nil, err
+	}
+	return handler.queues, nil
+}
+
+func (p poolImpl) iostatArgs(flags string) []string {
+	args := []string{`iostat`, flags, p.name}
+	if p.iostatInterval > 0 {
+		args = append(args, strconv.FormatFloat(p.iostatInterval.Seconds(), 'f', -1, 64), `2`)
+	}
+	return args
+}
+
+type poolIOStatImpl struct {
+	properties map[string]string
+}
+
+func (p *poolIOStatImpl) Properties() map[string]string {
+	return p.properties
+}
+
+// processLine implements the handler interface. Each sample replaces the previous one, so that when sampling over an
+// interval the leading since-boot sample is discarded in favour of the interval sample.
+func (p *poolIOStatImpl) processLine(pool string, line []string) error {
+	if len(line) < iostatFieldsPerRecord || line[0] != pool {
+		return ErrInvalidOutput
+	}
+	for i, value := range line[1:iostatFieldsPerRecord] {
+		// Statistics unavailable for the pool are reported as `-`.
+		if value == `-` {
+			delete(p.properties, iostatProperties[i])
+			continue
+		}
+		p.properties[iostatProperties[i]] = value
+	}
+
+	return nil
+}
+
+func newPoolIOStatImpl() *poolIOStatImpl {
+	return &poolIOStatImpl{
+		properties: make(map[string]string),
+	}
+}
+
+type poolIOQueuesImpl struct {
+	queues []IOQueue
+}
+
+// processLine implements the handler interface. As for IOStat, only the final sample is retained.
+func (p *poolIOQueuesImpl) processLine(pool string, line []string) error {
+	if len(line) < iostatFieldsPerRecord || line[0] != pool {
+		return ErrInvalidOutput
+	}
+	columns := line[iostatFieldsPerRecord:]
+	if len(columns)%2 != 0 || len(columns)/2 > len(IOQueueNames) {
+		return ErrInvalidOutput
+	}
+
+	queues := make([]IOQueue, 0, len(columns)/2)
+	for i := 0; i < len(columns); i += 2 {
+		// Queues unavailable for the pool are reported as `-`.
+		if columns[i] == `-` || columns[i+1] == `-` {
+			continue
+		}
+		pending, err := strconv.ParseUint(columns[i], 10, 64)
+		if err != nil {
+			return err
+		}
+		active, err := strconv.ParseUint(columns[i+1], 10, 64)
+		if err != nil {
+			return err
+		}
+		queues = append(queues, IOQueue{Name: IOQueueNames[i/2], Pending: pending, Active: active})
+	}
+	p.queues = queues
+
+	return nil
+}
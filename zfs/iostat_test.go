@@ -0,0 +1,150 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Two samples, the first reporting averages since boot, and the second reporting the interval.
+const fixtureZpoolIOStatInterval = "testpool\t1024\t3072\t50\t20\t409600\t81920\n" +
+	"testpool\t1024\t3072\t4\t-\t16384\t0\n"
+
+func TestPoolIOStat(t *testing.T) {
+	testCases := []struct {
+		name     string
+		interval time.Duration
+		cmd      string
+		output   string
+		want     map[string]string
+	}{
+		{
+			name:   `since boot`,
+			cmd:    `zpool iostat -Hp testpool`,
+			output: "testpool\t1024\t3072\t50\t20\t409600\t81920\n",
+			want: map[string]string{
+				`allocated`:        `1024`,
+				`free`:             `3072`,
+				`read_operations`:  `50`,
+				`write_operations`: `20`,
+				`read_bandwidth`:   `409600`,
+				`write_bandwidth`:  `81920`,
+			},
+		},
+		{
+			// Additional columns, ie - wait latencies, follow the standard columns.
+			name:   `extended columns`,
+			cmd:    `zpool iostat -Hp testpool`,
+			output: "testpool\t1024\t3072\t50\t20\t409600\t81920\t125000\t250000\n",
+			want: map[string]string{
+				`allocated`:        `1024`,
+				`free`:             `3072`,
+				`read_operations`:  `50`,
+				`write_operations`: `20`,
+				`read_bandwidth`:   `409600`,
+				`write_bandwidth`:  `81920`,
+			},
+		},
+		{
+			name:     `interval`,
+			interval: 1500 * time.Millisecond,
+			cmd:      `zpool iostat -Hp testpool 1.5 2`,
+			output:   fixtureZpoolIOStatInterval,
+			want: map[string]string{
+				`allocated`:       `1024`,
+				`free`:            `3072`,
+				`read_operations`: `4`,
+				`read_bandwidth`:  `16384`,
+				`write_bandwidth`: `0`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{output: map[string]string{tc.cmd: tc.output}}
+			client := New(Config{Runner: runner, IOStatInterval: tc.interval})
+
+			result, err := client.Pool(`testpool`).IOStat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := result.Properties(); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got properties %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPoolIOStatInvalidOutput(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+	}{
+		{name: `too few columns`, output: "testpool\t1024\t3072\n"},
+		{name: `inconsistent columns`, output: "testpool\t1024\t3072\t50\t20\t409600\t81920\t125000\ntestpool\t1024\t3072\t4\t0\t16384\t0\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{
+				output: map[string]string{
+					`zpool iostat -Hp testpool`: tc.output,
+				},
+			}
+			client := New(Config{Runner: runner})
+
+			if _, err := client.Pool(`testpool`).IOStat(); err == nil {
+				t.Fatal(`expected error for invalid output`)
+			}
+		})
+	}
+}
+
+func TestPoolIOQueues(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   []IOQueue
+	}{
+		{
+			name:   `five queues`,
+			output: "testpool\t1024\t3072\t4\t2\t16384\t8192\t0\t1\t0\t0\t0\t0\t120\t10\t0\t0\n",
+			want: []IOQueue{
+				{Name: `sync_read`, Pending: 0, Active: 1},
+				{Name: `sync_write`},
+				{Name: `async_read`},
+				{Name: `async_write`, Pending: 120, Active: 10},
+				{Name: `scrub`},
+			},
+		},
+		{
+			name:   `unavailable queue`,
+			output: "testpool\t1024\t3072\t4\t2\t16384\t8192\t0\t1\t0\t0\t0\t0\t120\t10\t0\t0\t-\t-\n",
+			want: []IOQueue{
+				{Name: `sync_read`, Pending: 0, Active: 1},
+				{Name: `sync_write`},
+				{Name: `async_read`},
+				{Name: `async_write`, Pending: 120, Active: 10},
+				{Name: `scrub`},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{output: map[string]string{`zpool iostat -Hpq testpool`: tc.output}}
+			client := New(Config{Runner: runner})
+
+			queues, err := client.Pool(`testpool`).IOQueues()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(queues, tc.want) {
+				t.Fatalf("got queues %+v, want %+v", queues, tc.want)
+			}
+		})
+	}
+}
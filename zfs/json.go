@@ -0,0 +1,71 @@
+package zfs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+const (
+	// jsonMajor and jsonMinor are the first OpenZFS release supporting JSON output via the `-j` flag.
+	jsonMajor = 2
+	jsonMinor = 3
+)
+
+// poolGetJSON is the structure of `zpool get -j` output.
+type poolGetJSON struct {
+	Pools map[string]struct {
+		Name       string `json:"name"`
+		Properties map[string]struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"properties"`
+	} `json:"pools"`
+}
+
+// executeJSON runs a command producing JSON output, and decodes it into v.
+func executeJSON(ctx context.Context, runner CommandRunner, v interface{}, cmd string, args ...string) error {
+	out, err := runner.Run(ctx, cmd, args...)
+	if err == nil {
+		err = json.NewDecoder(out).Decode(v)
+		if err == nil {
+			// Consume any trailing output, so that the command is not terminated by closing the output early.
+			_, err = io.Copy(io.Discard, out)
+		}
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	err = classifyError(``, err)
+	instrument(commandName(cmd, args), err)
+
+	return err
+}
+
+// jsonString returns the string representation of a JSON value, which may be encoded as a string or a number.
+func jsonString(raw json.RawMessage) (string, error) {
+	if len(raw) > 0 && raw[0] == '"' {
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// processJSON populates the properties from decoded `zpool get -j` output.
+func (p *poolPropertiesImpl) processJSON(pool string, result poolGetJSON) error {
+	data, ok := result.Pools[pool]
+	if !ok || data.Name != pool {
+		return ErrInvalidOutput
+	}
+	for name, prop := range data.Properties {
+		value, err := jsonString(prop.Value)
+		if err != nil {
+			return err
+		}
+		p.properties[name] = value
+	}
+
+	return nil
+}
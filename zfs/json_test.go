@@ -0,0 +1,114 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	fixtureZpoolVersion22 = "zfs-2.2.2-1\nzfs-kmod-2.2.2-1\n"
+	fixtureZpoolVersion23 = "zfs-2.3.0-1\nzfs-kmod-2.3.0-1\n"
+	fixtureZpoolGetJSON   = `{
+  "output_version": {
+    "command": "zpool get",
+    "vers_major": 0,
+    "vers_minor": 1
+  },
+  "pools": {
+    "testpool1": {
+      "name": "testpool1",
+      "type": "POOL",
+      "state": "ONLINE",
+      "pool_guid": "2306152093564781460",
+      "txg": "4",
+      "spa_version": "5000",
+      "zpl_version": "5",
+      "properties": {
+        "allocated": {
+          "value": "1024",
+          "source": {
+            "type": "NONE",
+            "data": "-"
+          }
+        },
+        "health": {
+          "value": "ONLINE",
+          "source": {
+            "type": "NONE",
+            "data": "-"
+          }
+        },
+        "fragmentation": {
+          "value": 5,
+          "source": {
+            "type": "NONE",
+            "data": "-"
+          }
+        }
+      }
+    }
+  }
+}
+`
+)
+
+func TestPoolPropertiesJSON(t *testing.T) {
+	want := map[string]string{
+		`allocated`:     `1024`,
+		`health`:        `ONLINE`,
+		`fragmentation`: `5`,
+	}
+
+	testCases := []struct {
+		name    string
+		version string
+		get     map[string]string
+	}{
+		{
+			name:    `json`,
+			version: fixtureZpoolVersion23,
+			get: map[string]string{
+				`zpool get -jp allocated,health,fragmentation testpool1`: fixtureZpoolGetJSON,
+			},
+		},
+		{
+			name:    `fallback`,
+			version: fixtureZpoolVersion22,
+			get: map[string]string{
+				`zpool get -Hpo name,property,value allocated,health,fragmentation testpool1`: fixtureZpoolGet,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{output: map[string]string{`zpool version`: tc.version}}
+			for k, v := range tc.get {
+				runner.output[k] = v
+			}
+			client := New(Config{Runner: runner})
+
+			props, err := client.Pool(`testpool1`).Properties(`allocated`, `health`, `fragmentation`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(props.Properties(), want) {
+				t.Fatalf("got properties %v, want %v", props.Properties(), want)
+			}
+		})
+	}
+}
+
+func TestPoolPropertiesJSONInvalidOutput(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zpool version`:                  fixtureZpoolVersion23,
+			`zpool get -jp health otherpool`: fixtureZpoolGetJSON,
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	if _, err := client.Pool(`otherpool`).Properties(`health`); err != ErrInvalidOutput {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidOutput)
+	}
+}
@@ -0,0 +1,67 @@
+package zfs
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = `zfs`
+	metricsSubsystem = `command`
+)
+
+var (
+	commandInvocations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      `invocations_total`,
+			Help:      `zfs_exporter: Number of zfs/zpool commands executed.`,
+		},
+		[]string{`command`},
+	)
+	commandErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      `errors_total`,
+			Help:      `zfs_exporter: Number of zfs/zpool commands that returned an error.`,
+		},
+		[]string{`command`},
+	)
+	commandDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      `duration_seconds`,
+			Help:      `zfs_exporter: Duration of zfs/zpool commands, from starting the command until its output has been consumed.`,
+			Buckets:   commandDurationBuckets,
+		},
+		[]string{`command`},
+	)
+
+	// commandDurationBuckets span commands that return immediately, to `zpool iostat` sampling over an interval.
+	commandDurationBuckets = []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+)
+
+// Instrumentation returns the collectors that expose metrics about command execution by the client
+func Instrumentation() []prometheus.Collector {
+	return []prometheus.Collector{commandInvocations, commandErrors, commandDuration}
+}
+
+// commandName returns the base command (binary and subcommand) used to label instrumentation.
+func commandName(cmd string, args []string) string {
+	if len(args) == 0 || strings.HasPrefix(args[0], `-`) {
+		return cmd
+	}
+	return cmd + ` ` + args[0]
+}
+
+// instrument records an invocation of the named command, and an error if err is non-nil.
+func instrument(name string, err error) {
+	commandInvocations.WithLabelValues(name).Inc()
+	if err != nil {
+		commandErrors.WithLabelValues(name).Inc()
+	}
+}
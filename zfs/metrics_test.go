@@ -0,0 +1,110 @@
+package zfs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCommandName(t *testing.T) {
+	testCases := []struct {
+		cmd  string
+		args []string
+		want string
+	}{
+		{cmd: `zpool`, args: []string{`get`, `-Hpo`, `name,property,value`}, want: `zpool get`},
+		{cmd: `zpool`, args: []string{`list`, `-Ho`, `name`}, want: `zpool list`},
+		{cmd: `zfs`, args: []string{`get`, `-Hprt`, `filesystem`}, want: `zfs get`},
+		{cmd: `zpool`, args: []string{`-V`}, want: `zpool`},
+		{cmd: `zpool`, want: `zpool`},
+	}
+
+	for _, tc := range testCases {
+		if got := commandName(tc.cmd, tc.args); got != tc.want {
+			t.Errorf("commandName(%q, %q) = %q, want %q", tc.cmd, tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestExecuteInstrumentation(t *testing.T) {
+	testCases := []struct {
+		name       string
+		cmd        string
+		args       []string
+		wantErrors float64
+	}{
+		{
+			name:       `success`,
+			cmd:        `sh`,
+			args:       []string{`-c`, `printf '%s\tsize\t1024\n' "$0"`},
+			wantErrors: 0,
+		},
+		{
+			name:       `failure`,
+			cmd:        `sh`,
+			args:       []string{`-c`, `exit 1`},
+			wantErrors: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			label := commandName(tc.cmd, tc.args)
+			invocationsBefore := testutil.ToFloat64(commandInvocations.WithLabelValues(label))
+			errorsBefore := testutil.ToFloat64(commandErrors.WithLabelValues(label))
+
+			err := execute(context.Background(), NewExecRunner(nil), defaultDelimiter, `testpool`, newPoolPropertiesImpl(), tc.cmd, tc.args...)
+			if (err != nil) != (tc.wantErrors > 0) {
+				t.Fatalf("unexpected error result: %v", err)
+			}
+
+			if got := testutil.ToFloat64(commandInvocations.WithLabelValues(label)) - invocationsBefore; got != 1 {
+				t.Errorf("invocations incremented by %v, want 1", got)
+			}
+			if got := testutil.ToFloat64(commandErrors.WithLabelValues(label)) - errorsBefore; got != tc.wantErrors {
+				t.Errorf("errors incremented by %v, want %v", got, tc.wantErrors)
+			}
+		})
+	}
+}
+
+// commandDurationCount returns the number of durations observed for the named command.
+func commandDurationCount(t *testing.T, name string) uint64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := commandDuration.WithLabelValues(name).(prometheus.Metric).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestCommandDuration(t *testing.T) {
+	fake := &fakeRunner{
+		output: map[string]string{
+			`/usr/local/sbin/zpool list -Ho name`:                                                      fixtureZpoolList,
+			`/usr/local/sbin/zfs get -Hprt filesystem -o name,property,value used,available testpool1`: fixtureZfsGet,
+		},
+	}
+	client := New(Config{Runner: fake, ZpoolPath: `/usr/local/sbin/zpool`, ZFSPath: `/usr/local/sbin/zfs`, MaxConcurrency: 1})
+
+	zpoolBefore := commandDurationCount(t, `zpool list`)
+	zfsBefore := commandDurationCount(t, `zfs get`)
+	for i := 0; i < 2; i++ {
+		if _, err := client.PoolNames(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.Datasets(`testpool1`, DatasetFilesystem).Properties(`used`, `available`); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := commandDurationCount(t, `zpool list`) - zpoolBefore; got != 2 {
+		t.Errorf("zpool list durations observed %d times, want 2", got)
+	}
+	if got := commandDurationCount(t, `zfs get`) - zfsBefore; got != 1 {
+		t.Errorf("zfs get durations observed %d times, want 1", got)
+	}
+}
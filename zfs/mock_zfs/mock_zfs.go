@@ -5,6 +5,8 @@
 package mock_zfs
 
 import (
+	context "context"
+	io "io"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,6 +36,25 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
+// DatasetNames mocks base method.
+func (m *MockClient) DatasetNames(kinds ...zfs.DatasetKind) ([]string, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range kinds {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DatasetNames", varargs...)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DatasetNames indicates an expected call of DatasetNames.
+func (mr *MockClientMockRecorder) DatasetNames(kinds ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DatasetNames", reflect.TypeOf((*MockClient)(nil).DatasetNames), kinds...)
+}
+
 // Datasets mocks base method.
 func (m *MockClient) Datasets(pool string, kind zfs.DatasetKind) zfs.Datasets {
 	m.ctrl.T.Helper()
@@ -62,6 +83,25 @@ func (mr *MockClientMockRecorder) Pool(name interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pool", reflect.TypeOf((*MockClient)(nil).Pool), name)
 }
 
+// PoolList mocks base method.
+func (m *MockClient) PoolList(props ...string) (map[string]zfs.PoolProperties, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range props {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PoolList", varargs...)
+	ret0, _ := ret[0].(map[string]zfs.PoolProperties)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PoolList indicates an expected call of PoolList.
+func (mr *MockClientMockRecorder) PoolList(props ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PoolList", reflect.TypeOf((*MockClient)(nil).PoolList), props...)
+}
+
 // PoolNames mocks base method.
 func (m *MockClient) PoolNames() ([]string, error) {
 	m.ctrl.T.Helper()
@@ -77,6 +117,51 @@ func (mr *MockClientMockRecorder) PoolNames() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PoolNames", reflect.TypeOf((*MockClient)(nil).PoolNames))
 }
 
+// Space mocks base method.
+func (m *MockClient) Space(dataset string, kind zfs.SpaceKind) ([]zfs.SpaceUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Space", dataset, kind)
+	ret0, _ := ret[0].([]zfs.SpaceUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Space indicates an expected call of Space.
+func (mr *MockClientMockRecorder) Space(dataset, kind interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Space", reflect.TypeOf((*MockClient)(nil).Space), dataset, kind)
+}
+
+// UnhealthyPools mocks base method.
+func (m *MockClient) UnhealthyPools() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnhealthyPools")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnhealthyPools indicates an expected call of UnhealthyPools.
+func (mr *MockClientMockRecorder) UnhealthyPools() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnhealthyPools", reflect.TypeOf((*MockClient)(nil).UnhealthyPools))
+}
+
+// Version mocks base method.
+func (m *MockClient) Version() (zfs.Version, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Version")
+	ret0, _ := ret[0].(zfs.Version)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Version indicates an expected call of Version.
+func (mr *MockClientMockRecorder) Version() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Version", reflect.TypeOf((*MockClient)(nil).Version))
+}
+
 // MockPool is a mock of Pool interface.
 type MockPool struct {
 	ctrl     *gomock.Controller
@@ -100,6 +185,96 @@ func (m *MockPool) EXPECT() *MockPoolMockRecorder {
 	return m.recorder
 }
 
+// BlockSizeHistogram mocks base method.
+func (m *MockPool) BlockSizeHistogram() ([]zfs.BlockSizeBucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockSizeHistogram")
+	ret0, _ := ret[0].([]zfs.BlockSizeBucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockSizeHistogram indicates an expected call of BlockSizeHistogram.
+func (mr *MockPoolMockRecorder) BlockSizeHistogram() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockSizeHistogram", reflect.TypeOf((*MockPool)(nil).BlockSizeHistogram))
+}
+
+// Events mocks base method.
+func (m *MockPool) Events() ([]zfs.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Events")
+	ret0, _ := ret[0].([]zfs.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Events indicates an expected call of Events.
+func (mr *MockPoolMockRecorder) Events() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Events", reflect.TypeOf((*MockPool)(nil).Events))
+}
+
+// Features mocks base method.
+func (m *MockPool) Features() (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Features")
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Features indicates an expected call of Features.
+func (mr *MockPoolMockRecorder) Features() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Features", reflect.TypeOf((*MockPool)(nil).Features))
+}
+
+// Health mocks base method.
+func (m *MockPool) Health() (zfs.PoolStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Health")
+	ret0, _ := ret[0].(zfs.PoolStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Health indicates an expected call of Health.
+func (mr *MockPoolMockRecorder) Health() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Health", reflect.TypeOf((*MockPool)(nil).Health))
+}
+
+// IOQueues mocks base method.
+func (m *MockPool) IOQueues() ([]zfs.IOQueue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IOQueues")
+	ret0, _ := ret[0].([]zfs.IOQueue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IOQueues indicates an expected call of IOQueues.
+func (mr *MockPoolMockRecorder) IOQueues() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IOQueues", reflect.TypeOf((*MockPool)(nil).IOQueues))
+}
+
+// IOStat mocks base method.
+func (m *MockPool) IOStat() (zfs.PoolProperties, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IOStat")
+	ret0, _ := ret[0].(zfs.PoolProperties)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IOStat indicates an expected call of IOStat.
+func (mr *MockPoolMockRecorder) IOStat() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IOStat", reflect.TypeOf((*MockPool)(nil).IOStat))
+}
+
 // Name mocks base method.
 func (m *MockPool) Name() string {
 	m.ctrl.T.Helper()
@@ -133,6 +308,59 @@ func (mr *MockPoolMockRecorder) Properties(props ...interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Properties", reflect.TypeOf((*MockPool)(nil).Properties), props...)
 }
 
+// RootProperties mocks base method.
+func (m *MockPool) RootProperties(props ...string) (zfs.PoolProperties, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range props {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "RootProperties", varargs...)
+	ret0, _ := ret[0].(zfs.PoolProperties)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RootProperties indicates an expected call of RootProperties.
+func (mr *MockPoolMockRecorder) RootProperties(props ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RootProperties", reflect.TypeOf((*MockPool)(nil).RootProperties), props...)
+}
+
+// Status mocks base method.
+func (m *MockPool) Status(options ...zfs.StatusOption) (*zfs.Status, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Status", varargs...)
+	ret0, _ := ret[0].(*zfs.Status)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockPoolMockRecorder) Status(options ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockPool)(nil).Status), options...)
+}
+
+// Vdevs mocks base method.
+func (m *MockPool) Vdevs() ([]zfs.VdevProperties, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Vdevs")
+	ret0, _ := ret[0].([]zfs.VdevProperties)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Vdevs indicates an expected call of Vdevs.
+func (mr *MockPoolMockRecorder) Vdevs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Vdevs", reflect.TypeOf((*MockPool)(nil).Vdevs))
+}
+
 // MockPoolProperties is a mock of PoolProperties interface.
 type MockPoolProperties struct {
 	ctrl     *gomock.Controller
@@ -291,6 +519,49 @@ func (mr *MockDatasetPropertiesMockRecorder) Properties() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Properties", reflect.TypeOf((*MockDatasetProperties)(nil).Properties))
 }
 
+// MockCommandRunner is a mock of CommandRunner interface.
+type MockCommandRunner struct {
+	ctrl     *gomock.Controller
+	recorder *MockCommandRunnerMockRecorder
+}
+
+// MockCommandRunnerMockRecorder is the mock recorder for MockCommandRunner.
+type MockCommandRunnerMockRecorder struct {
+	mock *MockCommandRunner
+}
+
+// NewMockCommandRunner creates a new mock instance.
+func NewMockCommandRunner(ctrl *gomock.Controller) *MockCommandRunner {
+	mock := &MockCommandRunner{ctrl: ctrl}
+	mock.recorder = &MockCommandRunnerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCommandRunner) EXPECT() *MockCommandRunnerMockRecorder {
+	return m.recorder
+}
+
+// Run mocks base method.
+func (m *MockCommandRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, name}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Run", varargs...)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Run indicates an expected call of Run.
+func (mr *MockCommandRunnerMockRecorder) Run(ctx, name interface{}, args ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, name}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Run", reflect.TypeOf((*MockCommandRunner)(nil).Run), varargs...)
+}
+
 // Mockhandler is a mock of handler interface.
 type Mockhandler struct {
 	ctrl     *gomock.Controller
@@ -0,0 +1,35 @@
+package zfs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// poolNamePattern matches the characters permitted in pool names, which must begin with a letter.
+	poolNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.: -]*$`)
+	// reservedPoolPrefixes may not begin pool names.
+	reservedPoolPrefixes = []string{`mirror`, `raidz`, `draid`, `spare`}
+	// reservedPoolNames may not be used as pool names, though names may begin with them.
+	reservedPoolNames = []string{`log`}
+)
+
+// ValidatePoolName checks that name is a valid ZFS pool name, so that names from untrusted input may be safely passed
+// to commands.
+func ValidatePoolName(name string) error {
+	if !poolNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid pool name %q: must begin with a letter, and contain only alphanumeric characters, underscore, hyphen, period, colon and space", name)
+	}
+	for _, reserved := range reservedPoolPrefixes {
+		if strings.HasPrefix(name, reserved) {
+			return fmt.Errorf("invalid pool name %q: names beginning with %q are reserved", name, reserved)
+		}
+	}
+	for _, reserved := range reservedPoolNames {
+		if name == reserved {
+			return fmt.Errorf("invalid pool name %q: the name is reserved", name)
+		}
+	}
+	return nil
+}
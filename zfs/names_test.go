@@ -0,0 +1,67 @@
+package zfs
+
+import "testing"
+
+func TestValidatePoolName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: `tank`},
+		{name: `tank-01`},
+		{name: `tank.backup`},
+		{name: `tank:ssd`},
+		{name: `my tank`},
+		{name: ``, wantErr: true},
+		{name: `1tank`, wantErr: true},
+		{name: `-o`, wantErr: true},
+		{name: `tank;rm`, wantErr: true},
+		{name: `tank/data`, wantErr: true},
+		{name: `mirror1`, wantErr: true},
+		{name: `log`, wantErr: true},
+		{name: `logs`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if err := ValidatePoolName(tc.name); (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPoolPropertiesSpecialNames(t *testing.T) {
+	testCases := []struct {
+		pool    string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{pool: `tank-01`, output: "tank-01\thealth\tONLINE\n", want: `ONLINE`},
+		{pool: `tank-01.old`, output: "tank-01.old\thealth\tDEGRADED\n", want: `DEGRADED`},
+		{pool: `tank:ssd`, output: "tank:ssd\thealth\tFAULTED\n", want: `FAULTED`},
+		{pool: `my tank`, output: "my tank\thealth\tONLINE\n", want: `ONLINE`},
+		{pool: `tank-01`, output: "tank-01.old\thealth\tDEGRADED\n", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.pool, func(t *testing.T) {
+			runner := &fakeRunner{
+				output: map[string]string{
+					`zpool get -Hpo name,property,value health ` + tc.pool: tc.output,
+				},
+			}
+
+			props, err := New(Config{Runner: runner}).Pool(tc.pool).Properties(`health`)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tc.wantErr)
+			}
+			if err == nil && props.Properties()[`health`] != tc.want {
+				t.Fatalf("got health %q, want %q", props.Properties()[`health`], tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build freebsd
+
+package zfs
+
+const (
+	// DefaultZpoolPath is the default path to the zpool command
+	DefaultZpoolPath = `/sbin/zpool`
+	// DefaultZFSPath is the default path to the zfs command
+	DefaultZFSPath = `/sbin/zfs`
+)
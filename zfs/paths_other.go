@@ -0,0 +1,10 @@
+//go:build !freebsd
+
+package zfs
+
+const (
+	// DefaultZpoolPath is the default path to the zpool command, which is resolved via PATH
+	DefaultZpoolPath = `zpool`
+	// DefaultZFSPath is the default path to the zfs command, which is resolved via PATH
+	DefaultZFSPath = `zfs`
+)
@@ -2,8 +2,9 @@ package zfs
 
 import (
 	"bufio"
-	"os/exec"
+	"context"
 	"strings"
+	"time"
 )
 
 // PoolStatus enum contains status text
@@ -26,8 +27,42 @@ const (
 	PoolSuspended PoolStatus = `SUSPENDED`
 )
 
+// PoolListProperties are the pool properties that may be fetched for all pools at once via Client.PoolList, which are
+// available as `zpool list` columns on all supported versions.
+var PoolListProperties = []string{
+	`allocated`,
+	`capacity`,
+	`dedupratio`,
+	`expandsize`,
+	`fragmentation`,
+	`free`,
+	`freeing`,
+	`health`,
+	`leaked`,
+	`readonly`,
+	`size`,
+}
+
+// featurePrefix prefixes the names of the properties reporting the state of feature flags.
+const featurePrefix = `feature@`
+
+const (
+	// FeatureDisabled enum entry, the feature is not enabled and may be enabled by `zpool upgrade`
+	FeatureDisabled = `disabled`
+	// FeatureEnabled enum entry, the feature is enabled but has not yet changed the on-disk format
+	FeatureEnabled = `enabled`
+	// FeatureActive enum entry, the on-disk format has changed, and the pool may not be imported by implementations
+	// that do not support the feature
+	FeatureActive = `active`
+)
+
 type poolImpl struct {
-	name string
+	runner         CommandRunner
+	delimiter      rune
+	name           string
+	json           bool
+	iostatInterval time.Duration
+	location       *time.Location
 }
 
 func (p poolImpl) Name() string {
@@ -36,12 +71,57 @@ func (p poolImpl) Name() string {
 
 func (p poolImpl) Properties(props ...string) (PoolProperties, error) {
 	handler := newPoolPropertiesImpl()
-	if err := execute(p.name, handler, `zpool`, `get`, `-Hpo`, `name,property,value`, strings.Join(props, `,`)); err != nil {
+	if p.json {
+		var result poolGetJSON
+		if err := executeJSON(context.Background(), p.runner, &result, `zpool`, `get`, `-jp`, strings.Join(props, `,`), p.name); err != nil {
+			return handler, err
+		}
+		return handler, handler.processJSON(p.name, result)
+	}
+	if err := execute(context.Background(), p.runner, p.delimiter, p.name, handler, `zpool`, `get`, `-Hpo`, `name,property,value`, strings.Join(props, `,`)); err != nil {
+		return handler, err
+	}
+	return handler, nil
+}
+
+// RootProperties returns properties of the root dataset of the pool, which shares the name of the pool.
+func (p poolImpl) RootProperties(props ...string) (PoolProperties, error) {
+	handler := newPoolPropertiesImpl()
+	if err := execute(context.Background(), p.runner, p.delimiter, p.name, handler, `zfs`, `get`, `-Hpo`, `name,property,value`, strings.Join(props, `,`)); err != nil {
 		return handler, err
 	}
 	return handler, nil
 }
 
+// Features returns the state of each feature flag of the pool, keyed by feature name without the `feature@` prefix.
+// Pools with a legacy on-disk version, which predate feature flags, have no features.
+func (p poolImpl) Features() (map[string]string, error) {
+	props, err := p.Properties(`all`)
+	if err != nil {
+		return nil, err
+	}
+	features := make(map[string]string)
+	for name, value := range props.Properties() {
+		if feature := strings.TrimPrefix(name, featurePrefix); feature != name {
+			features[feature] = value
+		}
+	}
+	return features, nil
+}
+
+// Health returns the health of the pool via `zpool list`, which is lighter weight than querying properties.
+func (p poolImpl) Health() (PoolStatus, error) {
+	var health PoolStatus
+	err := executeLines(context.Background(), p.runner, func(line string) error {
+		health = PoolStatus(line)
+		return nil
+	}, `zpool`, `list`, `-Ho`, `health`, p.name)
+	if err == nil && health == `` {
+		err = ErrInvalidOutput
+	}
+	return health, err
+}
+
 type poolPropertiesImpl struct {
 	properties map[string]string
 }
@@ -60,33 +140,73 @@ func (p *poolPropertiesImpl) processLine(pool string, line []string) error {
 	return nil
 }
 
+// poolList returns the properties of every pool via `zpool list`, which reports a pool per line, with the name followed
+// by a column per property.
+func poolList(ctx context.Context, runner CommandRunner, delimiter rune, props []string) (map[string]PoolProperties, error) {
+	handler := &poolListHandler{props: props, pools: make(map[string]PoolProperties)}
+	if err := executeFields(ctx, runner, delimiter, ``, handler, len(props)+1, `zpool`, `list`, `-Hpo`, `name,`+strings.Join(props, `,`)); err != nil {
+		return nil, err
+	}
+	return handler.pools, nil
+}
+
+// poolListHandler handles parsing of `zpool list` output into the properties of each pool
+type poolListHandler struct {
+	props []string
+	pools map[string]PoolProperties
+}
+
+// processLine implements the handler interface
+func (h *poolListHandler) processLine(_ string, line []string) error {
+	if len(line) != len(h.props)+1 {
+		return ErrInvalidOutput
+	}
+	pool := newPoolPropertiesImpl()
+	for i, prop := range h.props {
+		pool.properties[prop] = line[i+1]
+	}
+	h.pools[line[0]] = pool
+
+	return nil
+}
+
 // PoolNames returns a list of available pool names
-func poolNames() ([]string, error) {
+func poolNames(ctx context.Context, runner CommandRunner) ([]string, error) {
+	pools, err := listPoolNames(ctx, runner, `zpool`, `list`, `-Ho`, `name`)
+	instrument(commandName(`zpool`, []string{`list`}), err)
+	return pools, err
+}
+
+func listPoolNames(ctx context.Context, runner CommandRunner, name string, args ...string) ([]string, error) {
 	pools := make([]string, 0)
-	cmd := exec.Command(`zpool`, `list`, `-Ho`, `name`)
-	out, err := cmd.StdoutPipe()
+	out, err := runner.Run(ctx, name, args...)
 	if err != nil {
 		return nil, err
 	}
 	scanner := bufio.NewScanner(out)
 
-	if err = cmd.Start(); err != nil {
-		return nil, err
-	}
-
 	for scanner.Scan() {
 		pools = append(pools, scanner.Text())
 	}
-	if err = cmd.Wait(); err != nil {
+	err = scanner.Err()
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
 		return nil, err
 	}
 
 	return pools, nil
 }
 
-func newPoolImpl(name string) poolImpl {
+func newPoolImpl(runner CommandRunner, delimiter rune, name string, json bool, iostatInterval time.Duration, location *time.Location) poolImpl {
 	return poolImpl{
-		name: name,
+		runner:         runner,
+		delimiter:      delimiter,
+		name:           name,
+		json:           json,
+		iostatInterval: iostatInterval,
+		location:       location,
 	}
 }
 
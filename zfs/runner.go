@@ -0,0 +1,417 @@
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// execRunner runs commands on the local host.
+type execRunner struct {
+	logger log.Logger
+}
+
+// Run implements the CommandRunner interface
+func (r execRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	_ = level.Debug(r.logger).Log("msg", "Executing command", "argv", fmt.Sprintf("%q", cmd.Args))
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &stderrBuffer{}
+	cmd.Stderr = stderr
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &commandOutput{ReadCloser: out, cmd: cmd, stderr: stderr}, nil
+}
+
+// commandOutput streams the output of a running command, and reaps the command on Close.
+type commandOutput struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *stderrBuffer
+}
+
+// Close waits for the command to exit, returning any error from the command along with its error output. The output is
+// closed first, so that a command blocked writing output that will not be read exits rather than blocking Wait
+// indefinitely.
+func (o *commandOutput) Close() error {
+	_ = o.ReadCloser.Close()
+	err := o.cmd.Wait()
+	if msg := strings.TrimSpace(o.stderr.String()); err != nil && msg != `` {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
+
+// maxStderr is the maximum amount of error output retained from a command.
+const maxStderr = 4096
+
+// stderrBuffer retains the first maxStderr bytes of the error output of a command, discarding the remainder.
+type stderrBuffer struct {
+	buf bytes.Buffer
+}
+
+// Write implements the io.Writer interface
+func (b *stderrBuffer) Write(p []byte) (int, error) {
+	if remaining := maxStderr - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *stderrBuffer) String() string {
+	return b.buf.String()
+}
+
+// sshRunner runs commands on a remote host via ssh.
+type sshRunner struct {
+	target string
+	runner CommandRunner
+}
+
+// Run implements the CommandRunner interface
+func (r sshRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	remote := make([]string, 0, len(args)+1)
+	remote = append(remote, shellQuote(name))
+	for _, arg := range args {
+		remote = append(remote, shellQuote(arg))
+	}
+
+	return r.runner.Run(ctx, `ssh`, `-o`, `BatchMode=yes`, r.target, `--`, strings.Join(remote, ` `))
+}
+
+// sudoRunner runs commands via sudo, without prompting for a password.
+type sudoRunner struct {
+	runner CommandRunner
+}
+
+// Run implements the CommandRunner interface
+func (r sudoRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	return r.runner.Run(ctx, `sudo`, append([]string{`-n`, name}, args...)...)
+}
+
+// pathRunner substitutes configured paths for command names.
+type pathRunner struct {
+	paths  map[string]string
+	runner CommandRunner
+}
+
+// Run implements the CommandRunner interface
+func (r pathRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	if path, ok := r.paths[name]; ok {
+		name = path
+	}
+	return r.runner.Run(ctx, name, args...)
+}
+
+// limitRunner bounds the number of commands executing concurrently, across all collectors and pools.
+type limitRunner struct {
+	slots  chan struct{}
+	runner CommandRunner
+}
+
+// Run implements the CommandRunner interface. A slot is held until the output is closed.
+func (r limitRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	select {
+	case r.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	out, err := r.runner.Run(ctx, name, args...)
+	if err != nil {
+		<-r.slots
+		return nil, err
+	}
+
+	return &limitedOutput{ReadCloser: out, slots: r.slots}, nil
+}
+
+// limitedOutput releases the slot held by a command when its output is closed.
+type limitedOutput struct {
+	io.ReadCloser
+	slots chan struct{}
+	once  sync.Once
+}
+
+// Close implements the io.Closer interface
+func (o *limitedOutput) Close() error {
+	err := o.ReadCloser.Close()
+	o.once.Do(func() {
+		<-o.slots
+	})
+	return err
+}
+
+// contextRunner kills commands when a parent context is done, in addition to the context of each command.
+type contextRunner struct {
+	parent context.Context
+	runner CommandRunner
+}
+
+// Run implements the CommandRunner interface. The parent is watched until the output is closed.
+func (r contextRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-r.parent.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+	release := func() {
+		close(stop)
+		cancel()
+	}
+	if err := r.parent.Err(); err != nil {
+		release()
+		return nil, err
+	}
+	out, err := r.runner.Run(ctx, name, args...)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	return &contextOutput{ReadCloser: out, release: release}, nil
+}
+
+// contextOutput stops watching the parent context of a command when its output is closed.
+type contextOutput struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+// Close implements the io.Closer interface
+func (o *contextOutput) Close() error {
+	err := o.ReadCloser.Close()
+	o.once.Do(o.release)
+	return err
+}
+
+// timedRunner observes the duration of each command, labeled by its base command.
+type timedRunner struct {
+	runner CommandRunner
+}
+
+// Run implements the CommandRunner interface. The command is timed until the output is closed.
+func (r timedRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	start := time.Now()
+	out, err := r.runner.Run(ctx, name, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timedOutput{ReadCloser: out, name: commandName(name, args), start: start}, nil
+}
+
+// timedOutput observes the duration of a command when its output is closed.
+type timedOutput struct {
+	io.ReadCloser
+	name  string
+	start time.Time
+	once  sync.Once
+}
+
+// Close implements the io.Closer interface
+func (o *timedOutput) Close() error {
+	err := o.ReadCloser.Close()
+	o.once.Do(func() {
+		commandDuration.WithLabelValues(o.name).Observe(time.Since(o.start).Seconds())
+	})
+	return err
+}
+
+// retryRunner retries commands that fail with a retryable error, with exponential backoff between attempts. The output
+// of each attempt is buffered until the command exits, so that the output of a failed attempt is never processed.
+type retryRunner struct {
+	retries   int
+	backoff   time.Duration
+	retryable []string
+	runner    CommandRunner
+}
+
+// Run implements the CommandRunner interface
+func (r retryRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	backoff := r.backoff
+	for attempt := 0; ; attempt++ {
+		out, err := r.attempt(ctx, name, args...)
+		if err == nil || attempt >= r.retries || !r.isRetryable(ctx, err) {
+			if out == nil {
+				return nil, err
+			}
+			return out, nil
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// attempt runs the command to completion, returning its buffered output, and any error from starting or running the
+// command. The output is nil if the command could not be started.
+func (r retryRunner) attempt(ctx context.Context, name string, args ...string) (*bufferedOutput, error) {
+	out, err := r.runner.Run(ctx, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return &bufferedOutput{Reader: bytes.NewReader(buf.Bytes()), err: err}, err
+}
+
+// isRetryable reports whether err matches a retryable error, and is not caused by a missing pool or insufficient
+// permissions, which retrying cannot resolve.
+func (r retryRunner) isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	msg := err.Error()
+	for _, nonRetryable := range []string{`permission denied`, poolNotFoundMessage, datasetNotFoundMessage} {
+		if strings.Contains(msg, nonRetryable) {
+			return false
+		}
+	}
+	for _, retryable := range r.retryable {
+		if strings.Contains(msg, retryable) {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedOutput is the output of a command that has exited, returning the error from the command on Close.
+type bufferedOutput struct {
+	*bytes.Reader
+	err error
+}
+
+// Close implements the io.Closer interface
+func (o *bufferedOutput) Close() error {
+	return o.err
+}
+
+// fixtureRunner reads the output of commands from files in a directory, rather than executing them.
+type fixtureRunner struct {
+	dir string
+}
+
+// fixtureNameReplacer escapes command lines for use as file names.
+var fixtureNameReplacer = strings.NewReplacer(`%`, `%25`, `/`, `%2F`)
+
+// Run implements the CommandRunner interface
+func (r fixtureRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file := FixtureName(name, args...)
+	out, err := os.Open(filepath.Join(r.dir, file))
+	if err != nil {
+		return nil, fmt.Errorf("no fixture for command %q: %w", file, err)
+	}
+
+	return out, nil
+}
+
+// shellQuote quotes s for safe interpretation by a POSIX shell on the remote host.
+func shellQuote(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `'\''`) + `'`
+}
+
+// NewExecRunner returns a CommandRunner that executes commands on the local host, logging each command line at debug
+// level.
+func NewExecRunner(logger log.Logger) CommandRunner {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	return execRunner{logger: logger}
+}
+
+// NewSudoRunner returns a CommandRunner that executes commands via `sudo -n` using the provided runner. The sudoers
+// policy must permit the user running the exporter to execute the commands without a password.
+func NewSudoRunner(runner CommandRunner) CommandRunner {
+	return sudoRunner{runner: runner}
+}
+
+// CheckSudo verifies that sudo permits the named command to be executed without a password, using the provided
+// runner to execute sudo.
+func CheckSudo(ctx context.Context, runner CommandRunner, name string) error {
+	out, err := runner.Run(ctx, `sudo`, `-n`, `-l`, name)
+	if err == nil {
+		_, err = io.Copy(io.Discard, out)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("sudo does not permit executing %s without a password, sudoers must be configured with NOPASSWD for the command: %w", name, err)
+	}
+	return nil
+}
+
+// NewFixtureRunner returns a CommandRunner that reads the output of each command from a file in dir named by
+// FixtureName, rather than executing it, ie - to reproduce the metrics of a host from output captured offline.
+// Commands without a file fail.
+func NewFixtureRunner(dir string) CommandRunner {
+	return fixtureRunner{dir: dir}
+}
+
+// FixtureName returns the name of the file read by a fixture runner for a command, which is the command line joined by
+// spaces, with `%` and `/` escaped as `%25` and `%2F`. Only the base name of the command is used, so that fixtures are
+// independent of the path to the zpool/zfs commands.
+func FixtureName(name string, args ...string) string {
+	return fixtureNameReplacer.Replace(strings.Join(append([]string{filepath.Base(name)}, args...), ` `))
+}
+
+// NewLimitRunner returns a CommandRunner that executes at most limit commands concurrently using the provided runner,
+// blocking further commands until the output of a running command is closed.
+func NewLimitRunner(limit int, runner CommandRunner) CommandRunner {
+	return limitRunner{slots: make(chan struct{}, limit), runner: runner}
+}
+
+// NewContextRunner returns a CommandRunner that executes commands using the provided runner, killing them when parent
+// is done, ie - to terminate in-flight commands on shutdown. Commands are not started once parent is done.
+func NewContextRunner(parent context.Context, runner CommandRunner) CommandRunner {
+	return contextRunner{parent: parent, runner: runner}
+}
+
+// NewRetryRunner returns a CommandRunner that retries commands failing with an error containing any of the retryable
+// messages up to retries times using the provided runner, waiting backoff before the first retry and doubling it
+// before each subsequent retry. Output is buffered in memory until each command exits. Errors caused by a missing pool
+// or insufficient permissions are never retried.
+func NewRetryRunner(retries int, backoff time.Duration, retryable []string, runner CommandRunner) CommandRunner {
+	return retryRunner{retries: retries, backoff: backoff, retryable: retryable, runner: runner}
+}
+
+// NewSSHRunner returns a CommandRunner that executes commands on the target host (in `[user@]host` form) via ssh,
+// using the provided runner to execute ssh. Non-interactive authentication (ie - keys or an agent) must be configured
+// for the user running the exporter.
+func NewSSHRunner(target string, runner CommandRunner) CommandRunner {
+	return sshRunner{target: target, runner: runner}
+}
@@ -0,0 +1,430 @@
+package zfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+func TestSSHRunner(t *testing.T) {
+	testCases := []struct {
+		name   string
+		target string
+		cmd    string
+		args   []string
+		want   []string
+	}{
+		{
+			name:   `simple`,
+			target: `root@storage1`,
+			cmd:    `zpool`,
+			args:   []string{`list`, `-Ho`, `name`},
+			want:   []string{`ssh`, `-o`, `BatchMode=yes`, `root@storage1`, `--`, `'zpool' 'list' '-Ho' 'name'`},
+		},
+		{
+			name:   `quoted`,
+			target: `storage1`,
+			cmd:    `zpool`,
+			args:   []string{`get`, `health`, `my pool's; rm -rf /`},
+			want:   []string{`ssh`, `-o`, `BatchMode=yes`, `storage1`, `--`, `'zpool' 'get' 'health' 'my pool'\''s; rm -rf /'`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeRunner{}
+			r := sshRunner{target: tc.target, runner: fake}
+			if _, err := r.Run(context.Background(), tc.cmd, tc.args...); err != nil {
+				t.Fatal(err)
+			}
+			if len(fake.calls) != 1 || !reflect.DeepEqual(fake.calls[0], tc.want) {
+				t.Fatalf("got command %q, want %q", fake.calls, tc.want)
+			}
+		})
+	}
+}
+
+func TestExecRunnerDebugLog(t *testing.T) {
+	testCases := []struct {
+		name    string
+		level   level.Option
+		wantLog bool
+	}{
+		{name: `debug`, level: level.AllowDebug(), wantLog: true},
+		{name: `info`, level: level.AllowInfo(), wantLog: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := level.NewFilter(log.NewLogfmtLogger(&buf), tc.level)
+			r := NewExecRunner(logger)
+
+			out, err := r.Run(context.Background(), `sh`, `-c`, `exit 0`, `test pool`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = out.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			const want = `argv="[\"sh\" \"-c\" \"exit 0\" \"test pool\"]"`
+			if got := strings.Contains(buf.String(), want); got != tc.wantLog {
+				t.Fatalf("log output %q contains %s: %t, want %t", buf.String(), want, got, tc.wantLog)
+			}
+		})
+	}
+}
+
+func TestExecRunnerStderr(t *testing.T) {
+	out, err := NewExecRunner(nil).Run(context.Background(), `sh`, `-c`, `echo "cannot open 'gonepool': no such pool" >&2; exit 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.Copy(io.Discard, out); err != nil {
+		t.Fatal(err)
+	}
+
+	const want = `exit status 1: cannot open 'gonepool': no such pool`
+	if err = out.Close(); err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %s", err, want)
+	}
+}
+
+func TestExecRunnerCloseBeforeEOF(t *testing.T) {
+	out, err := NewExecRunner(nil).Run(context.Background(), `sh`, `-c`, `while :; do echo testpool1/data; done`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = out.Read(make([]byte, 64)); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- out.Close()
+	}()
+	select {
+	case err = <-done:
+		if err == nil {
+			t.Fatal(`got no error closing output of interrupted command, want error`)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal(`timed out waiting for command to exit after closing output`)
+	}
+}
+
+// blockingRunner records the peak number of commands running concurrently, each running until released.
+type blockingRunner struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+	release chan struct{}
+}
+
+// Run implements the CommandRunner interface
+func (r *blockingRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	r.mu.Lock()
+	r.running++
+	if r.running > r.peak {
+		r.peak = r.running
+	}
+	r.mu.Unlock()
+	<-r.release
+	r.mu.Lock()
+	r.running--
+	r.mu.Unlock()
+	return fakeOutput{Reader: strings.NewReader(``)}, nil
+}
+
+func TestLimitRunner(t *testing.T) {
+	const limit = 2
+	fake := &blockingRunner{release: make(chan struct{})}
+	r := NewLimitRunner(limit, fake)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := r.Run(context.Background(), `zpool`, `list`)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = out.Close()
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		fake.release <- struct{}{}
+	}
+	wg.Wait()
+
+	if fake.peak > limit {
+		t.Fatalf("got %d concurrent commands, want at most %d", fake.peak, limit)
+	}
+}
+
+func TestLimitRunnerContextDone(t *testing.T) {
+	fake := &blockingRunner{release: make(chan struct{}, 1)}
+	fake.release <- struct{}{}
+	r := NewLimitRunner(1, fake)
+
+	out, err := r.Run(context.Background(), `zpool`, `list`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = r.Run(ctx, `zpool`, `list`); err != context.Canceled {
+		t.Fatalf("got error %v waiting for a slot, want %v", err, context.Canceled)
+	}
+}
+
+func TestContextRunner(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	r := NewContextRunner(parent, NewExecRunner(nil))
+
+	out, err := r.Run(context.Background(), `sleep`, `30`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- out.Close()
+	}()
+	select {
+	case err = <-done:
+		if err == nil {
+			t.Fatal(`got no error closing output of killed command, want error`)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal(`timed out waiting for command to be killed after the parent context was done`)
+	}
+
+	if _, err = r.Run(context.Background(), `true`); err != context.Canceled {
+		t.Fatalf("got error %v starting command after the parent context was done, want %v", err, context.Canceled)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zpool list -Ho name`: "testpool\n",
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	if _, err := WithContext(ctx, client).PoolNames(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+	names, err := client.PoolNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != `testpool` {
+		t.Fatalf("got pools %v, want [testpool]", names)
+	}
+}
+
+// flakyRunner fails the first failures runs of the command line with partial output and the provided error, then runs
+// commands with the wrapped runner.
+type flakyRunner struct {
+	mu       sync.Mutex
+	command  string
+	calls    int
+	failures int
+	err      error
+	runner   CommandRunner
+}
+
+// Run implements the CommandRunner interface
+func (r *flakyRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	if strings.Join(append([]string{name}, args...), ` `) != r.command {
+		return r.runner.Run(ctx, name, args...)
+	}
+	r.mu.Lock()
+	r.calls++
+	fail := r.calls <= r.failures
+	r.mu.Unlock()
+	if fail {
+		return fakeOutput{Reader: strings.NewReader("testpool1\tallocated\t1\n"), err: r.err}, nil
+	}
+	return r.runner.Run(ctx, name, args...)
+}
+
+func TestRetryRunner(t *testing.T) {
+	fake := &flakyRunner{
+		command:  `zpool get -Hpo name,property,value allocated,health,fragmentation testpool1`,
+		failures: 1,
+		err:      errors.New(`exit status 1: cannot open 'testpool1': pool is busy`),
+		runner: &fakeRunner{
+			output: map[string]string{
+				`zpool get -Hpo name,property,value allocated,health,fragmentation testpool1`: fixtureZpoolGet,
+			},
+		},
+	}
+	client := New(Config{Runner: fake, CommandRetries: 1})
+
+	props, err := client.Pool(`testpool1`).Properties(`allocated`, `health`, `fragmentation`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.calls != 2 {
+		t.Fatalf("got %d calls, want 2", fake.calls)
+	}
+	want := map[string]string{
+		`allocated`:     `1024`,
+		`health`:        `ONLINE`,
+		`fragmentation`: `5`,
+	}
+	if !reflect.DeepEqual(props.Properties(), want) {
+		t.Fatalf("got properties %v, want %v", props.Properties(), want)
+	}
+}
+
+func TestRetryRunnerNotRetried(t *testing.T) {
+	testCases := []struct {
+		name    string
+		retries int
+		err     error
+		calls   int
+	}{
+		{name: `retries exhausted`, retries: 2, err: errors.New(`exit status 1: pool is busy`), calls: 3},
+		{name: `no such pool`, retries: 2, err: errors.New(`exit status 1: cannot open 'testpool1': no such pool`), calls: 1},
+		{name: `permission denied`, retries: 2, err: errors.New(`exit status 1: permission denied, pool is busy`), calls: 1},
+		{name: `not retryable`, retries: 2, err: errors.New(`exit status 1: internal error`), calls: 1},
+		{name: `retries disabled`, retries: 0, err: errors.New(`exit status 1: pool is busy`), calls: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &flakyRunner{command: `zpool get`, failures: 5, err: tc.err}
+			r := NewRetryRunner(tc.retries, time.Millisecond, DefaultRetryableErrors, fake)
+
+			out, err := r.Run(context.Background(), `zpool`, `get`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = out.Close(); err != tc.err {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+			if fake.calls != tc.calls {
+				t.Fatalf("got %d calls, want %d", fake.calls, tc.calls)
+			}
+		})
+	}
+}
+
+func TestRetryRunnerContextDone(t *testing.T) {
+	fake := &flakyRunner{command: `zpool get`, failures: 5, err: errors.New(`exit status 1: pool is busy`)}
+	r := NewRetryRunner(5, time.Hour, DefaultRetryableErrors, fake)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.Run(ctx, `zpool`, `get`); err != context.DeadlineExceeded {
+		t.Fatalf("got error %v waiting to retry, want %v", err, context.DeadlineExceeded)
+	}
+	if fake.calls != 1 {
+		t.Fatalf("got %d calls, want 1", fake.calls)
+	}
+}
+
+func TestPathRunner(t *testing.T) {
+	fake := &fakeRunner{
+		output: map[string]string{
+			`/usr/local/sbin/zpool list -Ho name`:                                                      fixtureZpoolList,
+			`/usr/local/sbin/zfs get -Hprt filesystem -o name,property,value used,available testpool1`: fixtureZfsGet,
+		},
+	}
+	client := New(Config{Runner: fake, ZpoolPath: `/usr/local/sbin/zpool`, ZFSPath: `/usr/local/sbin/zfs`})
+
+	if _, err := client.PoolNames(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Datasets(`testpool1`, DatasetFilesystem).Properties(`used`, `available`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSudoRunner(t *testing.T) {
+	fake := &fakeRunner{
+		output: map[string]string{
+			`sudo -n zpool list -Ho name`: fixtureZpoolList,
+		},
+	}
+	client := New(Config{Runner: NewSudoRunner(fake)})
+
+	pools, err := client.PoolNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`testpool1`, `testpool2`}; !reflect.DeepEqual(pools, want) {
+		t.Fatalf("got pools %q, want %q", pools, want)
+	}
+	if want := []string{`sudo`, `-n`, `zpool`, `list`, `-Ho`, `name`}; len(fake.calls) != 1 || !reflect.DeepEqual(fake.calls[0], want) {
+		t.Fatalf("got command %q, want %q", fake.calls, want)
+	}
+}
+
+func TestCheckSudo(t *testing.T) {
+	fake := &fakeRunner{
+		errors: map[string]error{
+			`sudo -n -l zfs`: errors.New(`exit status 1`),
+		},
+	}
+
+	if err := CheckSudo(context.Background(), fake, `zpool`); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSudo(context.Background(), fake, `zfs`); err == nil {
+		t.Fatal(`expected error when sudo requires a password`)
+	}
+}
+
+func TestFixtureRunner(t *testing.T) {
+	dir := t.TempDir()
+	const output = "testpool1/data\tused\t1024\n"
+	if err := os.WriteFile(filepath.Join(dir, `zfs get -Hpo name,property,value used testpool1%2Fdata`), []byte(output), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r := NewFixtureRunner(dir)
+
+	// The path to the command is ignored.
+	out, err := r.Run(context.Background(), `/sbin/zfs`, `get`, `-Hpo`, `name,property,value`, `used`, `testpool1/data`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != output {
+		t.Fatalf("got output %q, want %q", got, output)
+	}
+
+	_, err = r.Run(context.Background(), `zpool`, `list`, `-Ho`, `name`)
+	if err == nil || !strings.Contains(err.Error(), `"zpool list -Ho name"`) {
+		t.Fatalf("got error %v, want missing fixture named by the command", err)
+	}
+}
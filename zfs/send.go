@@ -0,0 +1,98 @@
+package zfs
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// sendEstimatePattern matches the estimated size of a stream logged by `zfs send -v`, ie - `full send of
+	// tank/data@snap estimated size is 1.23G` or `send from @a to tank/data@b estimated size is 1.23G`.
+	sendEstimatePattern = regexp.MustCompile(`^(?:full send of|send from \S+ to) (\S+) estimated size is (\S+)$`)
+	// sendTimePattern matches the time that opens each progress line, ie - `10:00:01`.
+	sendTimePattern = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}$`)
+)
+
+// SendProgress holds the progress of a `zfs send` stream
+type SendProgress struct {
+	// Snapshot is the snapshot being sent
+	Snapshot string
+	// SentBytes is the amount of the stream sent so far
+	SentBytes uint64
+	// EstimatedBytes is the estimated size of the stream, 0 if not logged
+	EstimatedBytes uint64
+}
+
+// Dataset returns the name of the dataset of the snapshot being sent.
+func (p SendProgress) Dataset() string {
+	return strings.SplitN(p.Snapshot, `@`, 2)[0]
+}
+
+// SendProgressParser accumulates the progress of the streams logged by `zfs send -v` or `zfs send -P`, one line at a
+// time, so that logs may be parsed as they are written.
+type SendProgressParser struct {
+	streams map[string]*SendProgress
+	order   []string
+}
+
+// NewSendProgressParser instantiates a SendProgressParser
+func NewSendProgressParser() *SendProgressParser {
+	return &SendProgressParser{streams: make(map[string]*SendProgress)}
+}
+
+// ParseLine parses a single line of a send log. Lines that are not recognised (ie - the total estimated size, or output
+// interleaved from other commands) are ignored, returning ErrInvalidOutput only for recognised lines with invalid
+// sizes.
+func (p *SendProgressParser) ParseLine(line string) error {
+	if m := sendEstimatePattern.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+		return p.estimate(m[1], m[2])
+	}
+
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 3 && fields[0] == `full`:
+		// Parsable estimate, ie - `full	tank/data@snap	1234567`
+		return p.estimate(fields[1], fields[2])
+	case len(fields) == 4 && fields[0] == `incremental`:
+		// Parsable estimate, ie - `incremental	tank/data@a	tank/data@b	1234567`
+		return p.estimate(fields[2], fields[3])
+	case len(fields) == 3 && sendTimePattern.MatchString(fields[0]) && strings.Contains(fields[2], `@`):
+		sent, err := parseStatusBytes(fields[1])
+		if err != nil {
+			return ErrInvalidOutput
+		}
+		p.stream(fields[2]).SentBytes = sent
+	}
+
+	return nil
+}
+
+func (p *SendProgressParser) estimate(snapshot, size string) error {
+	estimate, err := parseStatusBytes(size)
+	if err != nil {
+		return ErrInvalidOutput
+	}
+	// A new estimate begins a new stream of the snapshot.
+	stream := p.stream(snapshot)
+	stream.EstimatedBytes, stream.SentBytes = estimate, 0
+	return nil
+}
+
+func (p *SendProgressParser) stream(snapshot string) *SendProgress {
+	stream, ok := p.streams[snapshot]
+	if !ok {
+		stream = &SendProgress{Snapshot: snapshot}
+		p.streams[snapshot] = stream
+		p.order = append(p.order, snapshot)
+	}
+	return stream
+}
+
+// Progress returns the progress of each stream logged, in the order they were first logged.
+func (p *SendProgressParser) Progress() []SendProgress {
+	result := make([]SendProgress, len(p.order))
+	for i, snapshot := range p.order {
+		result[i] = *p.streams[snapshot]
+	}
+	return result
+}
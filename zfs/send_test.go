@@ -0,0 +1,86 @@
+package zfs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	fixtureZfsSendVerbose = `full send of tank/data@daily estimated size is 1.50G
+send from @daily to tank/data@hourly estimated size is 12.0M
+total estimated size is 1.51G
+TIME        SENT   SNAPSHOT tank/data@daily
+10:00:01   512M   tank/data@daily
+10:00:02   1.00G   tank/data@daily
+TIME        SENT   SNAPSHOT tank/data@hourly
+10:00:03   4.00M   tank/data@hourly
+`
+	fixtureZfsSendParsable = "full\ttank/data@daily\t1610612736\n" +
+		"incremental\ttank/data@daily\ttank/data@hourly\t12582912\n" +
+		"size\t1623195648\n" +
+		"10:00:01\t536870912\ttank/data@daily\n" +
+		"10:00:02\t1073741824\ttank/data@daily\n" +
+		"10:00:03\t4194304\ttank/data@hourly\n"
+)
+
+func TestSendProgressParser(t *testing.T) {
+	want := []SendProgress{
+		{Snapshot: `tank/data@daily`, SentBytes: 1 << 30, EstimatedBytes: 1610612736},
+		{Snapshot: `tank/data@hourly`, SentBytes: 4 << 20, EstimatedBytes: 12 << 20},
+	}
+	testCases := []struct {
+		name    string
+		output  string
+		want    []SendProgress
+		wantErr bool
+	}{
+		{
+			name:   `verbose`,
+			output: fixtureZfsSendVerbose,
+			want:   want,
+		},
+		{
+			name:   `parsable`,
+			output: fixtureZfsSendParsable,
+			want:   want,
+		},
+		{
+			name:   `resent`,
+			output: fixtureZfsSendParsable + "full\ttank/data@daily\t1610612736\n",
+			want: []SendProgress{
+				{Snapshot: `tank/data@daily`, EstimatedBytes: 1610612736},
+				{Snapshot: `tank/data@hourly`, SentBytes: 4 << 20, EstimatedBytes: 12 << 20},
+			},
+		},
+		{
+			name:    `invalid size`,
+			output:  "10:00:01\tlots\ttank/data@daily\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewSendProgressParser()
+			var err error
+			for _, line := range strings.Split(tc.output, "\n") {
+				if err = p.ParseLine(line); err != nil {
+					break
+				}
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal(`expected error, got nil`)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.Progress(); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got progress %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package zfs
+
+import (
+	"context"
+	"strconv"
+)
+
+// SpaceKind enum of the accounting reported by `zfs userspace` and `zfs groupspace`
+type SpaceKind string
+
+const (
+	// SpaceUser enum entry, for space consumed by users
+	SpaceUser SpaceKind = `userspace`
+	// SpaceGroup enum entry, for space consumed by groups
+	SpaceGroup SpaceKind = `groupspace`
+)
+
+// spaceFields are the fields selected from `zfs userspace`/`zfs groupspace` output.
+const spaceFields = `type,name,used,quota`
+
+// SpaceUsage holds the space consumed by a single user or group within a dataset
+type SpaceUsage struct {
+	// Type is the identity type, ie - `POSIX User` or `SMB Group`
+	Type string
+	// Name is the user or group name, or the numeric ID where the name could not be resolved
+	Name string
+	// UsedBytes is the amount of space consumed
+	UsedBytes uint64
+	// QuotaBytes is the quota, 0 where no quota is set
+	QuotaBytes uint64
+}
+
+// Space returns the space consumed by each user or group within the dataset, via `zfs userspace` or
+// `zfs groupspace`.
+func (z clientImpl) Space(dataset string, kind SpaceKind) ([]SpaceUsage, error) {
+	h := &spaceHandler{}
+	if err := executeFields(context.Background(), z.runner, z.delimiter, dataset, h, 4, `zfs`, string(kind), `-Hp`, `-o`, spaceFields, dataset); err != nil {
+		return nil, err
+	}
+	return h.usage, nil
+}
+
+// spaceHandler parses the records of `zfs userspace`/`zfs groupspace` output.
+type spaceHandler struct {
+	usage []SpaceUsage
+}
+
+// processLine implements the handler interface
+func (h *spaceHandler) processLine(dataset string, line []string) error {
+	used, err := strconv.ParseUint(line[2], 10, 64)
+	if err != nil {
+		return ErrInvalidOutput
+	}
+	var quota uint64
+	if line[3] != `none` && line[3] != `-` {
+		if quota, err = strconv.ParseUint(line[3], 10, 64); err != nil {
+			return ErrInvalidOutput
+		}
+	}
+	h.usage = append(h.usage, SpaceUsage{Type: line[0], Name: line[1], UsedBytes: used, QuotaBytes: quota})
+	return nil
+}
@@ -0,0 +1,78 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+const fixtureZfsUserspace = "POSIX User\talice\t1073741824\t2147483648\n" +
+	"POSIX User\t1001\t4096\tnone\n" +
+	"SMB User\tbob@DOMAIN\t512\t-\n"
+
+func TestSpace(t *testing.T) {
+	testCases := []struct {
+		name    string
+		kind    SpaceKind
+		output  string
+		want    []SpaceUsage
+		wantErr bool
+	}{
+		{
+			name:   `userspace`,
+			kind:   SpaceUser,
+			output: fixtureZfsUserspace,
+			want: []SpaceUsage{
+				{Type: `POSIX User`, Name: `alice`, UsedBytes: 1 << 30, QuotaBytes: 2 << 30},
+				{Type: `POSIX User`, Name: `1001`, UsedBytes: 4096},
+				{Type: `SMB User`, Name: `bob@DOMAIN`, UsedBytes: 512},
+			},
+		},
+		{
+			name:   `groupspace`,
+			kind:   SpaceGroup,
+			output: "POSIX Group\tstaff\t8192\t1048576\n",
+			want: []SpaceUsage{
+				{Type: `POSIX Group`, Name: `staff`, UsedBytes: 8192, QuotaBytes: 1 << 20},
+			},
+		},
+		{
+			name:   `empty`,
+			kind:   SpaceUser,
+			output: ``,
+		},
+		{
+			name:    `invalid used`,
+			kind:    SpaceUser,
+			output:  "POSIX User\talice\t1G\tnone\n",
+			wantErr: true,
+		},
+		{
+			name:    `missing fields`,
+			kind:    SpaceUser,
+			output:  "POSIX User\talice\t1024\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{
+				output: map[string]string{`zfs ` + string(tc.kind) + ` -Hp -o type,name,used,quota testpool/home`: tc.output},
+			}
+
+			usage, err := New(Config{Runner: runner}).Space(`testpool/home`, tc.kind)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal(`expected error, got nil`)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(usage, tc.want) {
+				t.Fatalf("got usage %+v, want %+v", usage, tc.want)
+			}
+		})
+	}
+}
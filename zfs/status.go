@@ -0,0 +1,549 @@
+package zfs
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatusOption selects additional details to be reported by Pool.Status
+type StatusOption string
+
+const (
+	// StatusDedup reports deduplication table statistics, via `zpool status -D`
+	StatusDedup StatusOption = `-D`
+	// StatusTrim reports the trim state of leaf vdevs, via `zpool status -t`
+	StatusTrim StatusOption = `-t`
+)
+
+const (
+	// TrimNone is the trim state of vdevs that have never been trimmed
+	TrimNone = `untrimmed`
+	// TrimActive is the trim state of vdevs being trimmed
+	TrimActive = `active`
+	// TrimSuspended is the trim state of vdevs whose trim has been suspended
+	TrimSuspended = `suspended`
+	// TrimComplete is the trim state of vdevs whose last trim completed
+	TrimComplete = `complete`
+	// TrimUnsupported is the trim state of vdevs that do not support trim
+	TrimUnsupported = `unsupported`
+
+	// statusTimeLayout is the format of the times reported with the scan and trim states, in the local time of the host.
+	statusTimeLayout = `Mon Jan _2 15:04:05 2006`
+)
+
+// trimStates maps the verbs used to describe the trim progress of a vdev to the trim state.
+var trimStates = map[string]string{
+	`started`:   TrimActive,
+	`suspended`: TrimSuspended,
+	`completed`: TrimComplete,
+}
+
+const (
+	// VdevClassData is the class of vdevs storing pool data
+	VdevClassData = `data`
+	// VdevClassLog is the class of separate intent log (SLOG) vdevs
+	VdevClassLog = `log`
+	// VdevClassCache is the class of L2ARC cache devices
+	VdevClassCache = `cache`
+	// VdevClassSpare is the class of hot spares
+	VdevClassSpare = `spare`
+	// VdevClassSpecial is the class of special allocation vdevs for metadata and small blocks
+	VdevClassSpecial = `special`
+	// VdevClassDedup is the class of vdevs dedicated to deduplication tables
+	VdevClassDedup = `dedup`
+)
+
+const (
+	// VdevLayoutDisk is the layout of top-level vdevs of a single device, without redundancy
+	VdevLayoutDisk = `disk`
+	// VdevLayoutMirror is the layout of mirrored top-level vdevs
+	VdevLayoutMirror = `mirror`
+)
+
+// vdevLayoutPattern matches the names of top-level vdevs with redundancy, ie - `mirror-0`, `raidz2-1` or
+// `draid2:4d:9c:1s-0`, capturing the layout and its parity.
+var vdevLayoutPattern = regexp.MustCompile(`^(mirror|raidz|draid)([1-3])?(?::\S*)?-\d+$`)
+
+// distributedSparePattern matches the names of dRAID distributed spares, ie - `draid2-0-1` for the second spare of the
+// first dRAID vdev with double parity.
+var distributedSparePattern = regexp.MustCompile(`^draid[1-3]-\d+-\d+$`)
+
+// vdevClassHeadings maps the headings of the vdev classes in the config section of `zpool status` to their class.
+var vdevClassHeadings = map[string]string{
+	`logs`:    VdevClassLog,
+	`cache`:   VdevClassCache,
+	`spares`:  VdevClassSpare,
+	`special`: VdevClassSpecial,
+	`dedup`:   VdevClassDedup,
+}
+
+const (
+	// ScanScrub is the scan function for scrubs
+	ScanScrub = `scrub`
+	// ScanResilver is the scan function for resilvers
+	ScanResilver = `resilver`
+)
+
+var (
+	// statusSectionPattern matches the `key:` headings that begin each section of `zpool status` output.
+	statusSectionPattern = regexp.MustCompile(`^\s*([a-z]+):(?:\s(.*))?$`)
+	// scanFunctionPattern matches the opening of the scan section, ie - `scrub in progress since ...` for a running
+	// scan, or `scrub repaired ...`/`resilvered ...` for a completed scan. Sequential rebuilds (ie - of dRAID vdevs)
+	// name the top-level vdev being rebuilt, ie - `resilver (draid2:4d:11c:1s-0) in progress since ...` or `resilvered
+	// (draid2:4d:11c:1s-0) in 0 days 01:00:00 with 0 errors on ...`.
+	scanFunctionPattern = regexp.MustCompile(`^(scrub|resilver)\S*(?: \((\S+)\))?( in progress)?`)
+	// scanScannedPattern matches the scanned amount and rate, in all of the forms produced by supported versions, ie -
+	// `1.23T scanned at 512M/s`, `1.23T / 2.00T scanned at 512M/s` and `1.23T scanned out of 2.00T at 512M/s`.
+	scanScannedPattern = regexp.MustCompile(`(\S+) (?:/ (\S+) )?scanned(?: out of (\S+))?(?: at (\S+)/s)?`)
+	// scanIssuedPattern matches the issued amount and rate, ie - `800G issued at 256M/s` or `800G / 2.00T issued at
+	// 256M/s`, or for sequential rebuilds `800G issued 256M/s`.
+	scanIssuedPattern = regexp.MustCompile(`(\S+) (?:/ (\S+) )?issued(?: (?:at )?(\S+)/s)?`)
+	// scanCompletedPattern matches the outcome of a completed scan, ie - `scrub repaired 0B in 00:10:00 with 0 errors on
+	// Sun Oct 10 10:10:00 2021`.
+	scanCompletedPattern = regexp.MustCompile(`with (\d+) errors on (.+)$`)
+	// scanTotalPattern matches the total amount to be scanned, where reported separately.
+	scanTotalPattern = regexp.MustCompile(`(\S+) total`)
+	// scanRemainingPattern matches the estimated time remaining, ie - `01:23:45 to go`, `0 days 01:23:45 to go` or
+	// `1h23m to go` on older versions.
+	scanRemainingPattern = regexp.MustCompile(`(?:(?:(\d+) days )?(\d+):(\d{2}):(\d{2})|(\d+)h(\d+)m) to go`)
+	// dedupEntriesPattern matches the summary of the dedup section, ie - `DDT entries 1234, size 424 on disk, 136 in
+	// core`, where sizes are per entry.
+	dedupEntriesPattern = regexp.MustCompile(`^DDT entries (\d+), size (\S+) on disk, (\S+) in core`)
+	// trimProgressPattern matches the trim progress reported for a vdev, ie - `(25% trimmed, started at Sun Oct 10
+	// 10:00:00 2021)`.
+	trimProgressPattern = regexp.MustCompile(`\((\d+)% trimmed, (started|suspended|completed) at ([^)]+)\)`)
+)
+
+// Status holds the details of a pool reported by `zpool status`
+type Status struct {
+	// Scan is the most recent scrub or resilver, nil if the pool has never been scanned
+	Scan *ScanStatus
+	// Dedup is the deduplication table statistics, nil if not requested via StatusDedup, or the pool has no DDT
+	// entries
+	Dedup *DedupStatus
+	// Vdevs are the top-level vdevs of the pool, in the order reported
+	Vdevs []Vdev
+}
+
+// Vdev is a virtual device in the pool configuration
+type Vdev struct {
+	// Name of the vdev, ie - the device name for leaf vdevs, or `mirror-0` for a mirror
+	Name string
+	// Class of the vdev, one of the VdevClass* constants. Children share the class of their top-level vdev.
+	Class string
+	// State of the vdev, ie - `ONLINE`, or for spares `AVAIL` or `INUSE`
+	State string
+	// Children of the vdev, ie - the devices of a mirror
+	Children []Vdev
+	// Trim is the trim state of a leaf vdev, nil if not requested via StatusTrim
+	Trim *VdevTrim
+}
+
+// Layout returns the layout of a top-level vdev: VdevLayoutMirror, `raidz1` to `raidz3`, `draid1` to `draid3`, or
+// VdevLayoutDisk for a single device.
+func (v Vdev) Layout() string {
+	match := vdevLayoutPattern.FindStringSubmatch(v.Name)
+	switch {
+	case match == nil:
+		return VdevLayoutDisk
+	case match[1] == VdevLayoutMirror:
+		return VdevLayoutMirror
+	case match[2] == ``:
+		// Single parity raidz vdevs are named `raidz-0` by older versions.
+		return match[1] + `1`
+	}
+	return match[1] + match[2]
+}
+
+// DistributedSpare returns true for dRAID distributed spares, whose capacity is spread over the devices of a dRAID
+// vdev, rather than held by a dedicated device.
+func (v Vdev) DistributedSpare() bool {
+	return distributedSparePattern.MatchString(v.Name)
+}
+
+// VdevTrim holds the trim state of a leaf vdev
+type VdevTrim struct {
+	// State of the trim, one of the Trim* constants
+	State string
+	// Progress of the current or last trim as a ratio, for trims that have been started
+	Progress float64
+	// Time the current trim was started or suspended, or the last trim completed
+	Time time.Time
+}
+
+// ScanStatus holds the progress of a scrub or resilver
+type ScanStatus struct {
+	// Function is the kind of scan, one of ScanScrub or ScanResilver
+	Function string
+	// InProgress is true while the scan is running. Progress fields are only populated for running scans.
+	InProgress bool
+	// RebuildVdev is the top-level vdev being rebuilt by a sequential rebuild (ie - `draid2:4d:11c:1s-0`), empty for
+	// scrubs and healing resilvers
+	RebuildVdev string
+	// ScannedBytes is the amount of data whose metadata has been scanned
+	ScannedBytes uint64
+	// IssuedBytes is the amount of data for which I/O has been issued
+	IssuedBytes uint64
+	// TotalBytes is the total amount of data to be scanned
+	TotalBytes uint64
+	// ScanRate is the rate in bytes per second at which metadata is being scanned, 0 if unreported
+	ScanRate float64
+	// IssueRate is the rate in bytes per second at which I/O is being issued, 0 if unreported (including by versions
+	// that predate sequential scans, which do not distinguish issued I/O)
+	IssueRate float64
+	// Remaining is the estimated time until the running scan completes, 0 if unreported (ie - before an estimate is
+	// available)
+	Remaining time.Duration
+	// Errors is the number of errors encountered by a completed scan
+	Errors uint64
+	// EndTime is the time at which a completed scan finished, zero for running or canceled scans
+	EndTime time.Time
+}
+
+// DedupStatus holds the statistics of the pool deduplication table (DDT)
+type DedupStatus struct {
+	// Entries is the number of entries in the DDT
+	Entries uint64
+	// EntryDiskBytes is the average size of each entry on disk
+	EntryDiskBytes uint64
+	// EntryCoreBytes is the average size of each entry in memory
+	EntryCoreBytes uint64
+	// AllocatedBlocks is the number of unique blocks stored in the pool, as tracked by the DDT
+	AllocatedBlocks uint64
+	// ReferencedBlocks is the number of blocks referenced by the pool, including duplicates
+	ReferencedBlocks uint64
+}
+
+// Status returns the details of the pool reported by `zpool status`, including any additional details selected by
+// options.
+func (p poolImpl) Status(options ...StatusOption) (*Status, error) {
+	args := make([]string, 0, len(options)+2)
+	args = append(args, `status`)
+	for _, option := range options {
+		args = append(args, string(option))
+	}
+	args = append(args, p.name)
+
+	h := &statusHandler{location: p.location}
+	if err := executeLines(context.Background(), p.runner, h.processLine, `zpool`, args...); err != nil {
+		return nil, err
+	}
+	if err := h.finish(); err != nil {
+		return nil, err
+	}
+	return &h.status, nil
+}
+
+// UnhealthyPools returns the names of the pools reported by `zpool status -x`, which only reports pools with errors or
+// that are otherwise unavailable. This is considerably cheaper than the full status of every pool.
+func (z clientImpl) UnhealthyPools() ([]string, error) {
+	pools := make([]string, 0)
+	err := executeLines(context.Background(), z.runner, func(line string) error {
+		if match := statusSectionPattern.FindStringSubmatch(line); match != nil && match[1] == `pool` && !strings.HasPrefix(line, "\t") {
+			pools = append(pools, strings.TrimSpace(match[2]))
+		}
+		return nil
+	}, `zpool`, `status`, `-x`)
+	if err != nil {
+		return nil, err
+	}
+
+	return pools, nil
+}
+
+// statusHandler collects the sections of `zpool status` output and parses those of interest. Sections start with a
+// `key:` heading, and their values may continue over subsequent indented lines.
+type statusHandler struct {
+	section string
+	lines   []string
+	status  Status
+	// location is the time zone of the host, in which times are reported
+	location *time.Location
+}
+
+func (h *statusHandler) processLine(line string) error {
+	if match := statusSectionPattern.FindStringSubmatch(line); match != nil && !strings.HasPrefix(line, "\t") {
+		if err := h.finish(); err != nil {
+			return err
+		}
+		h.section = match[1]
+		h.lines = h.lines[:0]
+		line = match[2]
+	}
+	// Indentation is retained, as it describes the structure of the config section.
+	if strings.TrimSpace(line) != `` {
+		h.lines = append(h.lines, strings.TrimRight(line, ` `))
+	}
+
+	return nil
+}
+
+// finish parses the current section.
+func (h *statusHandler) finish() error {
+	if len(h.lines) == 0 {
+		return nil
+	}
+	var err error
+	switch h.section {
+	case `scan`:
+		h.status.Scan, err = parseScan(trimLines(h.lines), h.location)
+	case `dedup`:
+		h.status.Dedup, err = parseDedup(trimLines(h.lines))
+	case `config`:
+		h.status.Vdevs, err = parseConfig(h.lines, h.location)
+	}
+	h.section = ``
+
+	return err
+}
+
+// trimLines returns lines with leading and trailing whitespace removed.
+func trimLines(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSpace(line)
+	}
+	return trimmed
+}
+
+// parseScan parses the lines of the scan section, returning nil if the pool has never been scanned. Times are parsed in
+// the time zone of the host, loc.
+func parseScan(lines []string, loc *time.Location) (*ScanStatus, error) {
+	if strings.HasPrefix(lines[0], `none requested`) {
+		return nil, nil
+	}
+	match := scanFunctionPattern.FindStringSubmatch(lines[0])
+	if match == nil {
+		return nil, ErrInvalidOutput
+	}
+	scan := &ScanStatus{Function: match[1], RebuildVdev: match[2], InProgress: match[3] != ``}
+	var err error
+	if !scan.InProgress {
+		if m := scanCompletedPattern.FindStringSubmatch(lines[0]); m != nil {
+			if scan.Errors, err = strconv.ParseUint(m[1], 10, 64); err != nil {
+				return nil, err
+			}
+			if scan.EndTime, err = time.ParseInLocation(statusTimeLayout, m[2], loc); err != nil {
+				return nil, err
+			}
+		}
+		return scan, nil
+	}
+	progress := strings.Join(lines[1:], ` `)
+
+	if m := scanScannedPattern.FindStringSubmatch(progress); m != nil {
+		if scan.ScannedBytes, err = parseStatusBytes(m[1]); err != nil {
+			return nil, err
+		}
+		total := m[2]
+		if total == `` {
+			total = m[3]
+		}
+		if total != `` {
+			if scan.TotalBytes, err = parseStatusBytes(total); err != nil {
+				return nil, err
+			}
+		}
+		if m[4] != `` {
+			if scan.ScanRate, err = parseStatusRate(m[4]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if m := scanIssuedPattern.FindStringSubmatch(progress); m != nil {
+		if scan.IssuedBytes, err = parseStatusBytes(m[1]); err != nil {
+			return nil, err
+		}
+		if m[3] != `` {
+			if scan.IssueRate, err = parseStatusRate(m[3]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if m := scanTotalPattern.FindStringSubmatch(progress); m != nil {
+		if scan.TotalBytes, err = parseStatusBytes(m[1]); err != nil {
+			return nil, err
+		}
+	}
+	if m := scanRemainingPattern.FindStringSubmatch(progress); m != nil {
+		if scan.Remaining, err = parseScanRemaining(m); err != nil {
+			return nil, err
+		}
+	}
+
+	return scan, nil
+}
+
+// parseScanRemaining converts a match of scanRemainingPattern to a duration.
+func parseScanRemaining(match []string) (time.Duration, error) {
+	units := []struct {
+		value string
+		unit  time.Duration
+	}{
+		{match[1], 24 * time.Hour},
+		{match[2], time.Hour},
+		{match[3], time.Minute},
+		{match[4], time.Second},
+		{match[5], time.Hour},
+		{match[6], time.Minute},
+	}
+	var remaining time.Duration
+	for _, u := range units {
+		if u.value == `` {
+			continue
+		}
+		v, err := strconv.ParseUint(u.value, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		remaining += time.Duration(v) * u.unit
+	}
+
+	return remaining, nil
+}
+
+// parseDedup parses the lines of the dedup section, returning nil if the pool has no DDT entries. The summary is
+// followed by a histogram of blocks by reference count, of which only the totals are retained.
+func parseDedup(lines []string) (*DedupStatus, error) {
+	if strings.HasPrefix(lines[0], `no DDT entries`) {
+		return nil, nil
+	}
+	match := dedupEntriesPattern.FindStringSubmatch(lines[0])
+	if match == nil {
+		return nil, ErrInvalidOutput
+	}
+
+	dedup := &DedupStatus{}
+	var err error
+	if dedup.Entries, err = strconv.ParseUint(match[1], 10, 64); err != nil {
+		return nil, err
+	}
+	if dedup.EntryDiskBytes, err = parseStatusBytes(match[2]); err != nil {
+		return nil, err
+	}
+	if dedup.EntryCoreBytes, err = parseStatusBytes(match[3]); err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines[1:] {
+		// Fields are: `Total`, followed by blocks, lsize, psize and dsize for each of allocated and referenced.
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != `Total` {
+			continue
+		}
+		if len(fields) != 9 {
+			return nil, ErrInvalidOutput
+		}
+		if dedup.AllocatedBlocks, err = parseStatusBytes(fields[1]); err != nil {
+			return nil, err
+		}
+		if dedup.ReferencedBlocks, err = parseStatusBytes(fields[5]); err != nil {
+			return nil, err
+		}
+	}
+
+	return dedup, nil
+}
+
+// configEntry is a line of the config section, at a depth given by its indentation.
+type configEntry struct {
+	depth int
+	vdev  Vdev
+}
+
+// parseConfig parses the vdev tree from the config section. The first line is a header, followed by the pool itself
+// and its data vdevs, then a heading for each other class of vdevs present in the pool. Each level of the tree is
+// indented by a further two spaces.
+func parseConfig(lines []string, loc *time.Location) ([]Vdev, error) {
+	entries := make([]configEntry, 0, len(lines))
+	class := VdevClassData
+	for _, line := range lines {
+		line = strings.TrimPrefix(line, "\t")
+		depth := (len(line) - len(strings.TrimLeft(line, ` `))) / 2
+		fields := strings.Fields(line)
+		if depth == 0 {
+			if len(fields) == 1 {
+				if heading, ok := vdevClassHeadings[fields[0]]; ok {
+					class = heading
+					continue
+				}
+			}
+			// The column header and the pool itself.
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, ErrInvalidOutput
+		}
+		trim, err := parseTrim(line, loc)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, configEntry{
+			depth: depth,
+			vdev:  Vdev{Name: fields[0], Class: class, State: fields[1], Trim: trim},
+		})
+	}
+
+	vdevs, next := buildVdevTree(entries, 0, 1)
+	if next != len(entries) {
+		return nil, ErrInvalidOutput
+	}
+	return vdevs, nil
+}
+
+// parseTrim parses the trim state reported following the counters of a vdev line, returning nil if no state is
+// reported.
+func parseTrim(line string, loc *time.Location) (*VdevTrim, error) {
+	switch {
+	case strings.HasSuffix(line, `(untrimmed)`):
+		return &VdevTrim{State: TrimNone}, nil
+	case strings.HasSuffix(line, `(trim unsupported)`):
+		return &VdevTrim{State: TrimUnsupported}, nil
+	}
+	match := trimProgressPattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil, nil
+	}
+
+	percent, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	t, err := time.ParseInLocation(statusTimeLayout, match[3], loc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VdevTrim{State: trimStates[match[2]], Progress: float64(percent) / 100, Time: t}, nil
+}
+
+// buildVdevTree builds the vdevs at depth from entries, starting at index i, and returns them with the index of the
+// first entry not consumed.
+func buildVdevTree(entries []configEntry, i int, depth int) ([]Vdev, int) {
+	var vdevs []Vdev
+	for i < len(entries) && entries[i].depth == depth {
+		vdev := entries[i].vdev
+		vdev.Children, i = buildVdevTree(entries, i+1, depth+1)
+		vdevs = append(vdevs, vdev)
+	}
+	return vdevs, i
+}
+
+// parseStatusBytes parses a size as formatted by `zpool status`, which may carry a trailing `B` for values below 1K
+// (ie - `0B`) in addition to a binary unit suffix.
+func parseStatusBytes(value string) (uint64, error) {
+	value = strings.TrimSuffix(value, `,`)
+	if len(value) > 1 {
+		value = strings.TrimSuffix(value, `B`)
+	}
+	return parseSize(value)
+}
+
+// parseStatusRate parses a rate in bytes per second as formatted by `zpool status`, with the `/s` suffix removed.
+func parseStatusRate(value string) (float64, error) {
+	v, err := parseStatusBytes(value)
+	return float64(v), err
+}
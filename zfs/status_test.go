@@ -0,0 +1,635 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	fixtureZpoolStatusResilver = `  pool: testpool
+ state: DEGRADED
+status: One or more devices is currently being resilvered.  The pool will
+	continue to function, possibly in a degraded state.
+action: Wait for the resilver to complete.
+  scan: resilver in progress since Sun Oct 10 10:00:00 2021
+	1.23T scanned at 512M/s, 800G issued at 256M/s, 2.00T total
+	400G resilvered, 39.06% done, 01:23:45 to go
+config:
+
+	NAME             STATE     READ WRITE CKSUM
+	testpool         DEGRADED     0     0     0
+	  mirror-0       DEGRADED     0     0     0
+	    sda          ONLINE       0     0     0
+	    replacing-1  DEGRADED     0     0     0
+	      sdb        OFFLINE      0     0     0
+	      sdc        ONLINE       0     0     0  (resilvering)
+
+errors: No known data errors
+`
+	fixtureZpoolStatusScrub = `  pool: testpool
+ state: ONLINE
+  scan: scrub in progress since Sun Oct 10 10:00:00 2021
+	1.00T / 2.00T scanned at 1.50G/s, 512G / 2.00T issued at 768M/s
+	0B repaired, 25.00% done, 00:30:00 to go
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	testpool    ONLINE       0     0     0
+	  sda       ONLINE       0     0     0
+
+errors: No known data errors
+`
+	fixtureZpoolStatusScrubLegacy = `  pool: testpool
+ state: ONLINE
+  scan: scrub in progress since Sun Oct 10 10:00:00 2021
+	100G scanned out of 2.00T at 64M/s, 08:26:40 to go
+	0B repaired, 4.88% done
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	testpool    ONLINE       0     0     0
+	  sda       ONLINE       0     0     0
+
+errors: No known data errors
+`
+	fixtureZpoolStatusScrubFinished = `  pool: testpool
+ state: ONLINE
+  scan: scrub repaired 0B in 00:10:00 with 0 errors on Sun Oct 10 10:10:00 2021
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	testpool    ONLINE       0     0     0
+	  sda       ONLINE       0     0     0
+
+errors: No known data errors
+`
+	fixtureZpoolStatusDedup = `  pool: testpool
+ state: ONLINE
+  scan: none requested
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	testpool    ONLINE       0     0     0
+	  sda       ONLINE       0     0     0
+
+errors: No known data errors
+
+ dedup: DDT entries 1536, size 424 on disk, 136 in core
+
+bucket              allocated                       referenced
+______   ______________________________   ______________________________
+refcnt   blocks   LSIZE   PSIZE   DSIZE   blocks   LSIZE   PSIZE   DSIZE
+------   ------   -----   -----   -----   ------   -----   -----   -----
+     1    1.00K    128M    128M    128M    1.00K    128M    128M    128M
+     2      512     64M     64M     64M    1.00K    128M    128M    128M
+ Total    1.50K    192M    192M    192M    2.00K    256M    256M    256M
+`
+	fixtureZpoolStatusNoDedup = `  pool: testpool
+ state: ONLINE
+  scan: none requested
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	testpool    ONLINE       0     0     0
+	  sda       ONLINE       0     0     0
+
+errors: No known data errors
+
+ dedup: no DDT entries
+`
+	fixtureZpoolStatusDevices = `  pool: testpool
+ state: DEGRADED
+status: One or more devices are faulted in response to persistent errors.
+	Sufficient replicas exist for the pool to continue functioning in a
+	degraded state.
+action: Replace the faulted device, or use 'zpool clear' to mark the device
+	repaired.
+  scan: none requested
+config:
+
+	NAME          STATE     READ WRITE CKSUM
+	testpool      DEGRADED     0     0     0
+	  mirror-0    ONLINE       0     0     0
+	    sda       ONLINE       0     0     0
+	    sdb       ONLINE       0     0     0
+	logs
+	  mirror-1    DEGRADED     0     0     0
+	    nvme0n1   ONLINE       0     0     0
+	    nvme1n1   FAULTED      0    12     0  too many errors
+	cache
+	  nvme2n1     ONLINE       0     0     0
+	spares
+	  sdc         AVAIL
+	  sdd         FAULTED
+
+errors: No known data errors
+`
+	fixtureZpoolStatusLayouts = `  pool: testpool
+ state: ONLINE
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	testpool    ONLINE       0     0     0
+	  mirror-0  ONLINE       0     0     0
+	    sda     ONLINE       0     0     0
+	    sdb     ONLINE       0     0     0
+	  raidz2-1  ONLINE       0     0     0
+	    sdc     ONLINE       0     0     0
+	    sdd     ONLINE       0     0     0
+	    sde     ONLINE       0     0     0
+	    sdf     ONLINE       0     0     0
+	  raidz2-2  ONLINE       0     0     0
+	    sdg     ONLINE       0     0     0
+	    sdh     ONLINE       0     0     0
+	    sdi     ONLINE       0     0     0
+	    sdj     ONLINE       0     0     0
+	  sdk       ONLINE       0     0     0
+	logs
+	  nvme0n1   ONLINE       0     0     0
+
+errors: No known data errors
+`
+	fixtureZpoolStatusTrim = `  pool: testpool
+ state: ONLINE
+  scan: none requested
+config:
+
+	NAME          STATE     READ WRITE CKSUM
+	testpool      ONLINE       0     0     0
+	  mirror-0    ONLINE       0     0     0
+	    nvme0n1   ONLINE       0     0     0  (25% trimmed, started at Sun Oct 10 10:00:00 2021)
+	    nvme1n1   ONLINE       0     0     0  (100% trimmed, completed at Sun Oct 10 09:30:00 2021)
+	  mirror-1    ONLINE       0     0     0
+	    nvme2n1   ONLINE       0     0     0  (60% trimmed, suspended at Sun Oct 10 09:45:00 2021)
+	    nvme3n1   ONLINE       0     0     0  (untrimmed)
+	logs
+	  sda         ONLINE       0     0     0  (trim unsupported)
+
+errors: No known data errors
+`
+	fixtureZpoolStatusDRAIDRebuild = `  pool: testpool
+ state: DEGRADED
+status: One or more devices could not be used because the label is missing or
+	invalid.  Sufficient replicas exist for the pool to continue
+	functioning in a degraded state.
+action: Replace the device using 'zpool replace'.
+   see: https://openzfs.github.io/openzfs-docs/msg/ZFS-8000-4J
+  scan: resilver (draid2:4d:11c:1s-0) in progress since Tue Nov 24 14:34:25 2020
+	3.51T scanned at 13.4G/s, 1.59T issued 6.07G/s, 6.13T total
+	326G resilvered, 57.17% done, 00:03:21 to go
+config:
+
+	NAME                  STATE     READ WRITE CKSUM
+	testpool              DEGRADED     0     0     0
+	  draid2:4d:11c:1s-0  DEGRADED     0     0     0
+	    sda               ONLINE       0     0     0
+	    spare-1           DEGRADED     0     0     0
+	      sdb             UNAVAIL      0     0     0
+	      draid2-0-0      ONLINE       0     0     0
+	    sdc               ONLINE       0     0     0
+	spares
+	  draid2-0-0          INUSE     currently in use
+	  draid2-0-1          AVAIL
+	  sdz                 AVAIL
+
+errors: No known data errors
+`
+	fixtureZpoolStatusNoScan = `  pool: testpool
+ state: ONLINE
+  scan: none requested
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	testpool    ONLINE       0     0     0
+	  sda       ONLINE       0     0     0
+
+errors: No known data errors
+`
+	// Only unhealthy pools are reported by `zpool status -x`, which here are a degraded pool and a pool with errors.
+	fixtureZpoolStatusUnhealthy = `  pool: tank
+ state: DEGRADED
+status: One or more devices could not be used because the label is missing or
+	invalid.  Sufficient replicas exist for the pool to continue
+	functioning in a degraded state.
+action: Replace the device using 'zpool replace'.
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	tank        DEGRADED     0     0     0
+	  mirror-0  DEGRADED     0     0     0
+	    sda     ONLINE       0     0     0
+	    sdb     UNAVAIL      0     0     0
+
+errors: No known data errors
+
+  pool: backup
+ state: ONLINE
+status: One or more devices has experienced an error resulting in data
+	corruption.  Applications may be affected.
+action: Restore the file in question if possible.
+config:
+
+	NAME        STATE     READ WRITE CKSUM
+	backup      ONLINE       0     0     0
+	  sdc       ONLINE       0     0    12
+
+errors: 1 data errors, use '-v' for a list
+`
+)
+
+func TestPoolStatusScan(t *testing.T) {
+	testCases := []struct {
+		name    string
+		output  string
+		want    *ScanStatus
+		wantErr bool
+	}{
+		{
+			name:   `resilver in progress`,
+			output: fixtureZpoolStatusResilver,
+			want: &ScanStatus{
+				Function:     ScanResilver,
+				InProgress:   true,
+				ScannedBytes: 1352399302164,
+				IssuedBytes:  800 << 30,
+				TotalBytes:   2 << 40,
+				ScanRate:     512 << 20,
+				IssueRate:    256 << 20,
+				Remaining:    time.Hour + 23*time.Minute + 45*time.Second,
+			},
+		},
+		{
+			name:   `scrub in progress`,
+			output: fixtureZpoolStatusScrub,
+			want: &ScanStatus{
+				Function:     ScanScrub,
+				InProgress:   true,
+				ScannedBytes: 1 << 40,
+				IssuedBytes:  512 << 30,
+				TotalBytes:   2 << 40,
+				ScanRate:     1.5 * (1 << 30),
+				IssueRate:    768 << 20,
+				Remaining:    30 * time.Minute,
+			},
+		},
+		{
+			name:   `legacy scrub in progress`,
+			output: fixtureZpoolStatusScrubLegacy,
+			want: &ScanStatus{
+				Function:     ScanScrub,
+				InProgress:   true,
+				ScannedBytes: 100 << 30,
+				TotalBytes:   2 << 40,
+				ScanRate:     64 << 20,
+				Remaining:    8*time.Hour + 26*time.Minute + 40*time.Second,
+			},
+		},
+		{
+			name: `resilver in progress with small rates`,
+			output: "  scan: resilver in progress since Sun Oct 10 10:00:00 2021\n" +
+				"\t12.0M scanned at 512B/s, 6.00M issued at 1.50K/s, 2.00G total\n" +
+				"\t6.00M resilvered, 0.29% done, 1 days 02:03:04 to go\n",
+			want: &ScanStatus{
+				Function:     ScanResilver,
+				InProgress:   true,
+				ScannedBytes: 12 << 20,
+				IssuedBytes:  6 << 20,
+				TotalBytes:   2 << 30,
+				ScanRate:     512,
+				IssueRate:    1536,
+				Remaining:    26*time.Hour + 3*time.Minute + 4*time.Second,
+			},
+		},
+		{
+			name: `legacy resilver in progress`,
+			output: "  scan: resilver in progress since Sun Oct 10 10:00:00 2021\n" +
+				"\t100G scanned out of 2.00T at 64M/s, 8h26m to go\n" +
+				"\t50G resilvered, 4.88% done\n",
+			want: &ScanStatus{
+				Function:     ScanResilver,
+				InProgress:   true,
+				ScannedBytes: 100 << 30,
+				TotalBytes:   2 << 40,
+				ScanRate:     64 << 20,
+				Remaining:    8*time.Hour + 26*time.Minute,
+			},
+		},
+		{
+			name: `resilver in progress without estimate`,
+			output: "  scan: resilver in progress since Sun Oct 10 10:00:00 2021\n" +
+				"\t1.23T scanned at 512M/s, 0B issued, 2.00T total\n" +
+				"\t0B resilvered, 0.00% done, no estimated completion time\n",
+			want: &ScanStatus{
+				Function:     ScanResilver,
+				InProgress:   true,
+				ScannedBytes: 1352399302164,
+				TotalBytes:   2 << 40,
+				ScanRate:     512 << 20,
+			},
+		},
+		{
+			name:   `scrub finished`,
+			output: fixtureZpoolStatusScrubFinished,
+			want: &ScanStatus{
+				Function: ScanScrub,
+				EndTime:  time.Date(2021, time.October, 10, 10, 10, 0, 0, time.Local),
+			},
+		},
+		{
+			name:   `dRAID rebuild in progress`,
+			output: fixtureZpoolStatusDRAIDRebuild,
+			want: &ScanStatus{
+				Function:     ScanResilver,
+				InProgress:   true,
+				RebuildVdev:  `draid2:4d:11c:1s-0`,
+				ScannedBytes: 3859285813493,
+				IssuedBytes:  1748223488163,
+				TotalBytes:   6740006278266,
+				ScanRate:     14388140441,
+				IssueRate:    6517612871,
+				Remaining:    3*time.Minute + 21*time.Second,
+			},
+		},
+		{
+			name:   `dRAID rebuild finished`,
+			output: "  scan: resilvered (draid2:4d:11c:1s-0) in 0 days 00:05:00 with 0 errors on Tue Nov 24 14:39:25 2020\n",
+			want: &ScanStatus{
+				Function:    ScanResilver,
+				RebuildVdev: `draid2:4d:11c:1s-0`,
+				EndTime:     time.Date(2020, time.November, 24, 14, 39, 25, 0, time.Local),
+			},
+		},
+		{
+			name:   `resilver finished with errors`,
+			output: "  scan: resilvered 1.50G in 00:05:00 with 3 errors on Sun Oct  3 10:10:00 2021\n",
+			want: &ScanStatus{
+				Function: ScanResilver,
+				Errors:   3,
+				EndTime:  time.Date(2021, time.October, 3, 10, 10, 0, 0, time.Local),
+			},
+		},
+		{
+			name:   `scrub canceled`,
+			output: "  scan: scrub canceled on Sun Oct 10 10:10:00 2021\n",
+			want:   &ScanStatus{Function: ScanScrub},
+		},
+		{
+			name:   `never scanned`,
+			output: fixtureZpoolStatusNoScan,
+		},
+		{
+			name:    `invalid`,
+			output:  "  scan: unexpected\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{
+				output: map[string]string{`zpool status testpool`: tc.output},
+			}
+
+			status, err := New(Config{Runner: runner}).Pool(`testpool`).Status()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal(`expected error, got nil`)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(status.Scan, tc.want) {
+				t.Fatalf("got scan %+v, want %+v", status.Scan, tc.want)
+			}
+		})
+	}
+}
+
+func TestPoolStatusScanLocation(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{`zpool status testpool`: fixtureZpoolStatusScrubFinished},
+	}
+
+	// Times are reported in the time zone of the host running zpool, which may differ from that of the exporter.
+	loc := time.FixedZone(`AEST`, 10*60*60)
+	status, err := New(Config{Runner: runner, Location: loc}).Pool(`testpool`).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, time.October, 10, 0, 10, 0, 0, time.UTC); !status.Scan.EndTime.Equal(want) {
+		t.Fatalf("got end time %s, want %s", status.Scan.EndTime, want)
+	}
+}
+
+func TestPoolStatusDedup(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   *DedupStatus
+	}{
+		{
+			name:   `dedup`,
+			output: fixtureZpoolStatusDedup,
+			want: &DedupStatus{
+				Entries:          1536,
+				EntryDiskBytes:   424,
+				EntryCoreBytes:   136,
+				AllocatedBlocks:  1536,
+				ReferencedBlocks: 2048,
+			},
+		},
+		{
+			name:   `no entries`,
+			output: fixtureZpoolStatusNoDedup,
+		},
+		{
+			name:   `not reported`,
+			output: fixtureZpoolStatusNoScan,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{
+				output: map[string]string{`zpool status -D testpool`: tc.output},
+			}
+
+			status, err := New(Config{Runner: runner}).Pool(`testpool`).Status(StatusDedup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(status.Dedup, tc.want) {
+				t.Fatalf("got dedup %+v, want %+v", status.Dedup, tc.want)
+			}
+			if status.Scan != nil {
+				t.Fatalf("got scan %+v, want nil", status.Scan)
+			}
+		})
+	}
+}
+
+func TestPoolStatusVdevs(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{`zpool status testpool`: fixtureZpoolStatusDevices},
+	}
+
+	status, err := New(Config{Runner: runner}).Pool(`testpool`).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Vdev{
+		{Name: `mirror-0`, Class: VdevClassData, State: `ONLINE`, Children: []Vdev{
+			{Name: `sda`, Class: VdevClassData, State: `ONLINE`},
+			{Name: `sdb`, Class: VdevClassData, State: `ONLINE`},
+		}},
+		{Name: `mirror-1`, Class: VdevClassLog, State: `DEGRADED`, Children: []Vdev{
+			{Name: `nvme0n1`, Class: VdevClassLog, State: `ONLINE`},
+			{Name: `nvme1n1`, Class: VdevClassLog, State: `FAULTED`},
+		}},
+		{Name: `nvme2n1`, Class: VdevClassCache, State: `ONLINE`},
+		{Name: `sdc`, Class: VdevClassSpare, State: `AVAIL`},
+		{Name: `sdd`, Class: VdevClassSpare, State: `FAULTED`},
+	}
+	if !reflect.DeepEqual(status.Vdevs, want) {
+		t.Fatalf("got vdevs %+v, want %+v", status.Vdevs, want)
+	}
+}
+
+func TestPoolStatusVdevsInvalidOutput(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{`zpool status testpool`: "config:\n\n\tNAME STATE\n\ttestpool ONLINE\n\t    sda ONLINE\n"},
+	}
+
+	if _, err := New(Config{Runner: runner}).Pool(`testpool`).Status(); err != ErrInvalidOutput {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidOutput)
+	}
+}
+
+func TestPoolStatusVdevLayouts(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{`zpool status testpool`: fixtureZpoolStatusLayouts},
+	}
+
+	status, err := New(Config{Runner: runner}).Pool(`testpool`).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, vdev := range status.Vdevs {
+		got = append(got, vdev.Layout())
+	}
+	want := []string{VdevLayoutMirror, `raidz2`, `raidz2`, VdevLayoutDisk, VdevLayoutDisk}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got layouts %v, want %v", got, want)
+	}
+
+	names := map[string]string{
+		`raidz-0`:            `raidz1`,
+		`raidz1-0`:           `raidz1`,
+		`raidz3-4`:           `raidz3`,
+		`draid2:4d:9c:1s-0`:  `draid2`,
+		`draid:3d:4c:0s-1`:   `draid1`,
+		`mirror-12`:          VdevLayoutMirror,
+		`mirror`:             VdevLayoutDisk,
+		`ata-ST4000-raidz-0`: VdevLayoutDisk,
+	}
+	for name, want := range names {
+		if got := (Vdev{Name: name}).Layout(); got != want {
+			t.Errorf("Vdev{Name: %q}.Layout() = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPoolStatusDistributedSpares(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{`zpool status testpool`: fixtureZpoolStatusDRAIDRebuild},
+	}
+
+	status, err := New(Config{Runner: runner}).Pool(`testpool`).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for _, vdev := range status.Vdevs {
+		if vdev.Class == VdevClassSpare {
+			got[vdev.Name] = vdev.DistributedSpare()
+		}
+	}
+	want := map[string]bool{`draid2-0-0`: true, `draid2-0-1`: true, `sdz`: false}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got distributed spares %v, want %v", got, want)
+	}
+	if layout := status.Vdevs[0].Layout(); layout != `draid2` {
+		t.Errorf("got layout %q, want %q", layout, `draid2`)
+	}
+}
+
+func TestPoolStatusTrim(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{`zpool status -t testpool`: fixtureZpoolStatusTrim},
+	}
+
+	status, err := New(Config{Runner: runner}).Pool(`testpool`).Status(StatusTrim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour, min int) time.Time {
+		return time.Date(2021, time.October, 10, hour, min, 0, 0, time.Local)
+	}
+	want := []Vdev{
+		{Name: `mirror-0`, Class: VdevClassData, State: `ONLINE`, Children: []Vdev{
+			{Name: `nvme0n1`, Class: VdevClassData, State: `ONLINE`, Trim: &VdevTrim{State: TrimActive, Progress: 0.25, Time: at(10, 0)}},
+			{Name: `nvme1n1`, Class: VdevClassData, State: `ONLINE`, Trim: &VdevTrim{State: TrimComplete, Progress: 1, Time: at(9, 30)}},
+		}},
+		{Name: `mirror-1`, Class: VdevClassData, State: `ONLINE`, Children: []Vdev{
+			{Name: `nvme2n1`, Class: VdevClassData, State: `ONLINE`, Trim: &VdevTrim{State: TrimSuspended, Progress: 0.6, Time: at(9, 45)}},
+			{Name: `nvme3n1`, Class: VdevClassData, State: `ONLINE`, Trim: &VdevTrim{State: TrimNone}},
+		}},
+		{Name: `sda`, Class: VdevClassLog, State: `ONLINE`, Trim: &VdevTrim{State: TrimUnsupported}},
+	}
+	if !reflect.DeepEqual(status.Vdevs, want) {
+		t.Fatalf("got vdevs %+v, want %+v", status.Vdevs, want)
+	}
+}
+
+func TestUnhealthyPools(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			name:   `healthy`,
+			output: "all pools are healthy\n",
+			want:   []string{},
+		},
+		{
+			name:   `no pools`,
+			output: "no pools available\n",
+			want:   []string{},
+		},
+		{
+			name:   `unhealthy`,
+			output: fixtureZpoolStatusUnhealthy,
+			want:   []string{`tank`, `backup`},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{output: map[string]string{`zpool status -x`: tc.output}}
+
+			pools, err := New(Config{Runner: runner}).UnhealthyPools()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pools, tc.want) {
+				t.Fatalf("got unhealthy pools %v, want %v", pools, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package zfs
+
+import (
+	"context"
+	"strings"
+)
+
+// vdevListProperties names the columns requested from `zpool list -Hv` following the vdev name, in column order, by the
+// names of the equivalent pool properties. Columns are requested explicitly, as the default columns differ between
+// versions (ie - `checkpoint` was added in 0.8).
+var vdevListProperties = []string{
+	`size`,
+	`allocated`,
+	`free`,
+	`expandsize`,
+	`fragmentation`,
+	`capacity`,
+	`health`,
+}
+
+// vdevListHeadings maps the headings of the vdev classes in `zpool list -v` output to their class. Unlike `zpool
+// status`, hot spares are listed under the singular `spare`.
+var vdevListHeadings = map[string]string{
+	`dedup`:   VdevClassDedup,
+	`special`: VdevClassSpecial,
+	`logs`:    VdevClassLog,
+	`cache`:   VdevClassCache,
+	`spare`:   VdevClassSpare,
+}
+
+// VdevProperties holds the space of a top-level vdev, as reported by `zpool list -v`
+type VdevProperties struct {
+	// Name of the vdev, ie - `mirror-0`
+	Name string
+	// Class of the vdev, one of the VdevClass constants
+	Class string
+	// Leaf is true for the devices of top-level vdevs and hot spares, which report only their size
+	Leaf bool
+	// Properties maps the names of the pool properties reported for the vdev (ie - `size`, `allocated` and
+	// `fragmentation`) to their values, `-` where not applicable to the vdev
+	Properties map[string]string
+}
+
+// Vdevs returns the space of each vdev of the pool via `zpool list -v`, which helps identify unbalanced vdevs. The space
+// of top-level vdevs is reported in full, while leaf vdevs and hot spares report only their size.
+func (p poolImpl) Vdevs() ([]VdevProperties, error) {
+	delimiter := string(p.delimiter)
+	vdevs := make([]VdevProperties, 0)
+	class := VdevClassData
+	err := executeLines(context.Background(), p.runner, func(line string) error {
+		// Vdevs are indented by a delimiter, while the pool and class headings are not. Headings are padded by spaces
+		// rather than delimited.
+		if !strings.HasPrefix(line, delimiter) {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				return nil
+			}
+			if heading, ok := vdevListHeadings[fields[0]]; ok {
+				class = heading
+				return nil
+			}
+			if name, _, _ := strings.Cut(line, delimiter); name != p.name {
+				return ErrInvalidOutput
+			}
+			class = VdevClassData
+			return nil
+		}
+
+		fields := strings.Split(strings.TrimPrefix(line, delimiter), delimiter)
+		if len(fields) < len(vdevListProperties)+1 {
+			return ErrInvalidOutput
+		}
+		vdev := VdevProperties{Name: fields[0], Class: class, Properties: make(map[string]string, len(vdevListProperties))}
+		for i, name := range vdevListProperties {
+			vdev.Properties[name] = fields[i+1]
+		}
+		// Leaves are not distinguished by indentation in scripted output, but only top-level vdevs report allocated space.
+		vdev.Leaf = vdev.Properties[`allocated`] == `-`
+		vdevs = append(vdevs, vdev)
+		return nil
+	}, `zpool`, `list`, `-Hpv`, `-o`, `name,`+strings.Join(vdevListProperties, `,`), p.name)
+	if err != nil {
+		return nil, err
+	}
+
+	return vdevs, nil
+}
@@ -0,0 +1,76 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+// An unbalanced pool, where a second mirror was added once the first was nearly full, with log, cache and spare
+// devices. Class headings are padded by spaces, rather than delimited.
+const fixtureZpoolListVdevs = "testpool\t3985729650688\t2181843763200\t1803885887488\t-\t35\t54\tONLINE\n" +
+	"\tmirror-0\t1992864825344\t1793148370944\t199716454400\t-\t62\t89\tONLINE\n" +
+	"\tsda\t2000398934016\t-\t-\t-\t-\t-\tONLINE\n" +
+	"\tsdb\t2000398934016\t-\t-\t-\t-\t-\tONLINE\n" +
+	"\tmirror-1\t1992864825344\t388695392256\t1604169433088\t-\t8\t19\tONLINE\n" +
+	"\tsdc\t2000398934016\t-\t-\t-\t-\t-\tONLINE\n" +
+	"\tsdd\t2000398934016\t-\t-\t-\t-\t-\tONLINE\n" +
+	"logs      -      -      -         -      -      -  -\n" +
+	"\tnvme0n1\t16642998272\t1048576\t16641949696\t-\t0\t0\tONLINE\n" +
+	"cache      -      -      -         -      -      -  -\n" +
+	"\tnvme1n1\t256060514304\t128030257152\t128030257152\t-\t0\t50\tONLINE\n" +
+	"spare      -      -      -         -      -      -  -\n" +
+	"\tsde\t2000398934016\t-\t-\t-\t-\t-\tAVAIL\n"
+
+func TestPoolVdevs(t *testing.T) {
+	runner := &fakeRunner{output: map[string]string{`zpool list -Hpv -o name,size,allocated,free,expandsize,fragmentation,capacity,health testpool`: fixtureZpoolListVdevs}}
+
+	vdevs, err := New(Config{Runner: runner}).Pool(`testpool`).Vdevs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := func(name, class, health string) VdevProperties {
+		return VdevProperties{Name: name, Class: class, Leaf: true, Properties: map[string]string{
+			`size`: `2000398934016`, `allocated`: `-`, `free`: `-`,
+			`expandsize`: `-`, `fragmentation`: `-`, `capacity`: `-`, `health`: health,
+		}}
+	}
+	want := []VdevProperties{
+		{Name: `mirror-0`, Class: VdevClassData, Properties: map[string]string{
+			`size`: `1992864825344`, `allocated`: `1793148370944`, `free`: `199716454400`,
+			`expandsize`: `-`, `fragmentation`: `62`, `capacity`: `89`, `health`: `ONLINE`,
+		}},
+		leaf(`sda`, VdevClassData, `ONLINE`),
+		leaf(`sdb`, VdevClassData, `ONLINE`),
+		{Name: `mirror-1`, Class: VdevClassData, Properties: map[string]string{
+			`size`: `1992864825344`, `allocated`: `388695392256`, `free`: `1604169433088`,
+			`expandsize`: `-`, `fragmentation`: `8`, `capacity`: `19`, `health`: `ONLINE`,
+		}},
+		leaf(`sdc`, VdevClassData, `ONLINE`),
+		leaf(`sdd`, VdevClassData, `ONLINE`),
+		{Name: `nvme0n1`, Class: VdevClassLog, Properties: map[string]string{
+			`size`: `16642998272`, `allocated`: `1048576`, `free`: `16641949696`,
+			`expandsize`: `-`, `fragmentation`: `0`, `capacity`: `0`, `health`: `ONLINE`,
+		}},
+		{Name: `nvme1n1`, Class: VdevClassCache, Properties: map[string]string{
+			`size`: `256060514304`, `allocated`: `128030257152`, `free`: `128030257152`,
+			`expandsize`: `-`, `fragmentation`: `0`, `capacity`: `50`, `health`: `ONLINE`,
+		}},
+		leaf(`sde`, VdevClassSpare, `AVAIL`),
+	}
+	if !reflect.DeepEqual(vdevs, want) {
+		t.Fatalf("got vdevs %+v, want %+v", vdevs, want)
+	}
+}
+
+func TestPoolVdevsInvalidOutput(t *testing.T) {
+	for _, output := range []string{
+		"otherpool\t3985729650688\t2181843763200\t1803885887488\t-\t35\t54\tONLINE\n",
+		"testpool\t3985729650688\t2181843763200\t1803885887488\t-\t35\t54\tONLINE\n\tmirror-0\t1992864825344\n",
+	} {
+		runner := &fakeRunner{output: map[string]string{`zpool list -Hpv -o name,size,allocated,free,expandsize,fragmentation,capacity,health testpool`: output}}
+
+		if _, err := New(Config{Runner: runner}).Pool(`testpool`).Vdevs(); err != ErrInvalidOutput {
+			t.Fatalf("got error %v for output %q, want %v", err, output, ErrInvalidOutput)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	versionPrefixUserland = `zfs-`
+	versionPrefixKernel   = `zfs-kmod-`
+	// versionMarkerFreeBSD identifies the FreeBSD port in version strings (ie - `zfs-2.1.9-FreeBSD_g92e0d9d18`)
+	versionMarkerFreeBSD = `-FreeBSD_`
+)
+
+// Version of the OpenZFS userland tools and kernel module
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// Userland is the full version string of the userland tools (ie - `zfs-2.2.2-1`)
+	Userland string
+	// Kernel is the full version string of the kernel module (ie - `zfs-kmod-2.2.2-1`)
+	Kernel string
+}
+
+// AtLeast returns true if v is equal to or newer than the provided version
+func (v Version) AtLeast(major, minor, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Patch >= patch
+}
+
+// FreeBSD returns true if the version strings identify the FreeBSD port of OpenZFS
+func (v Version) FreeBSD() bool {
+	return strings.Contains(v.Userland, versionMarkerFreeBSD) || strings.Contains(v.Kernel, versionMarkerFreeBSD)
+}
+
+// String implements the fmt.Stringer interface
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// parseVersion parses the output of `zpool version` or `zfs version`, ie:
+//
+//	zfs-2.2.2-1
+//	zfs-kmod-2.2.2-1
+func parseVersion(lines []string) (Version, error) {
+	var v Version
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, versionPrefixKernel):
+			v.Kernel = line
+		case strings.HasPrefix(line, versionPrefixUserland):
+			if _, err := fmt.Sscanf(strings.TrimPrefix(line, versionPrefixUserland), `%d.%d.%d`, &v.Major, &v.Minor, &v.Patch); err != nil {
+				return v, ErrInvalidOutput
+			}
+			v.Userland = line
+		}
+	}
+	if v.Userland == `` {
+		return v, ErrInvalidOutput
+	}
+
+	return v, nil
+}
+
+// unrecognizedCommandMessage is the error output of zfs and zpool for subcommands they do not support.
+const unrecognizedCommandMessage = `unrecognized command`
+
+// probeVersion executes `zpool version`, falling back to `zfs version`, to determine the installed version. Returns
+// ErrVersionUnknown if neither command is supported, as prior to ZFS 0.8.
+func probeVersion(ctx context.Context, runner CommandRunner) (Version, error) {
+	var err error
+	for _, cmd := range []string{`zpool`, `zfs`} {
+		lines := make([]string, 0, 2)
+		err = executeLines(ctx, runner, func(line string) error {
+			lines = append(lines, line)
+			return nil
+		}, cmd, `version`)
+		if err == nil {
+			return parseVersion(lines)
+		}
+		if !strings.Contains(err.Error(), unrecognizedCommandMessage) {
+			return Version{}, err
+		}
+	}
+
+	return Version{}, fmt.Errorf("%w: %w", ErrVersionUnknown, err)
+}
@@ -0,0 +1,185 @@
+package zfs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		output  string
+		want    Version
+		wantErr bool
+	}{
+		{
+			name:   `linux`,
+			output: "zfs-2.2.2-1\nzfs-kmod-2.2.2-1\n",
+			want:   Version{Major: 2, Minor: 2, Patch: 2, Userland: `zfs-2.2.2-1`, Kernel: `zfs-kmod-2.2.2-1`},
+		},
+		{
+			name:   `linux distribution`,
+			output: "zfs-2.1.5-1ubuntu6~22.04.1\nzfs-kmod-2.1.5-1ubuntu6~22.04.1\n",
+			want:   Version{Major: 2, Minor: 1, Patch: 5, Userland: `zfs-2.1.5-1ubuntu6~22.04.1`, Kernel: `zfs-kmod-2.1.5-1ubuntu6~22.04.1`},
+		},
+		{
+			name:   `linux release candidate`,
+			output: "zfs-2.3.0-rc4\nzfs-kmod-2.3.0-rc4\n",
+			want:   Version{Major: 2, Minor: 3, Patch: 0, Userland: `zfs-2.3.0-rc4`, Kernel: `zfs-kmod-2.3.0-rc4`},
+		},
+		{
+			name:   `freebsd`,
+			output: "zfs-2.1.9-FreeBSD_g92e0d9d18\nzfs-kmod-2.1.9-FreeBSD_g92e0d9d18\n",
+			want:   Version{Major: 2, Minor: 1, Patch: 9, Userland: `zfs-2.1.9-FreeBSD_g92e0d9d18`, Kernel: `zfs-kmod-2.1.9-FreeBSD_g92e0d9d18`},
+		},
+		{
+			name:   `kernel module not loaded`,
+			output: "zfs-2.2.2-1\n",
+			want:   Version{Major: 2, Minor: 2, Patch: 2, Userland: `zfs-2.2.2-1`},
+		},
+		{
+			name:    `unsupported`,
+			output:  "unrecognized command 'version'\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseVersion(strings.Split(tc.output, "\n"))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	v := Version{Major: 2, Minor: 2, Patch: 2}
+	testCases := []struct {
+		major, minor, patch int
+		want                bool
+	}{
+		{major: 0, minor: 8, patch: 0, want: true},
+		{major: 2, minor: 1, patch: 15, want: true},
+		{major: 2, minor: 2, patch: 2, want: true},
+		{major: 2, minor: 2, patch: 3, want: false},
+		{major: 2, minor: 3, patch: 0, want: false},
+		{major: 3, minor: 0, patch: 0, want: false},
+	}
+
+	for _, tc := range testCases {
+		if got := v.AtLeast(tc.major, tc.minor, tc.patch); got != tc.want {
+			t.Errorf("%v.AtLeast(%d, %d, %d) = %t, want %t", v, tc.major, tc.minor, tc.patch, got, tc.want)
+		}
+	}
+}
+
+func TestVersionFreeBSD(t *testing.T) {
+	testCases := []struct {
+		version Version
+		want    bool
+	}{
+		{version: Version{Userland: `zfs-2.1.9-FreeBSD_g92e0d9d18`, Kernel: `zfs-kmod-2.1.9-FreeBSD_g92e0d9d18`}, want: true},
+		{version: Version{Userland: `zfs-2.2.2-1`, Kernel: `zfs-kmod-2.1.9-FreeBSD_g92e0d9d18`}, want: true},
+		{version: Version{Userland: `zfs-2.2.2-1`, Kernel: `zfs-kmod-2.2.2-1`}, want: false},
+		{version: Version{}, want: false},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.version.FreeBSD(); got != tc.want {
+			t.Errorf("%+v.FreeBSD() = %t, want %t", tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestVersionCached(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zpool version`: "zfs-2.2.2-1\nzfs-kmod-2.2.2-1\n",
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	for i := 0; i < 2; i++ {
+		v, err := client.Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !v.AtLeast(2, 2, 2) {
+			t.Fatalf("unexpected version %v", v)
+		}
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("version probed %d times, want 1", len(runner.calls))
+	}
+}
+
+func TestVersionRetriedAfterError(t *testing.T) {
+	runner := &fakeRunner{
+		errors: map[string]error{
+			`zpool version`: errors.New(`exit status 255: ssh: connect to host storage: Connection timed out`),
+		},
+	}
+	now := time.Unix(1700000000, 0)
+	client := New(Config{Runner: runner})
+	client.(clientImpl).version.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Version(); err == nil {
+			t.Fatal(`expected error, got nil`)
+		}
+		client.Pool(`tank`)
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("version probed %d times before the retry interval elapsed, want 1", len(runner.calls))
+	}
+
+	now = now.Add(versionRetryInterval)
+	runner.mu.Lock()
+	runner.output = map[string]string{
+		`zpool version`: "zfs-2.2.2-1\nzfs-kmod-2.2.2-1\n",
+	}
+	runner.errors = nil
+	runner.mu.Unlock()
+	v, err := client.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.AtLeast(2, 2, 2) {
+		t.Fatalf("unexpected version %v", v)
+	}
+	if len(runner.calls) != 2 {
+		t.Fatalf("version probed %d times, want 2", len(runner.calls))
+	}
+}
+
+func TestVersionLegacy(t *testing.T) {
+	runner := &fakeRunner{
+		errors: map[string]error{
+			`zpool version`: errors.New(`exit status 2: unrecognized command 'version'`),
+			`zfs version`:   errors.New(`exit status 2: unrecognized command 'version'`),
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Version(); !errors.Is(err, ErrVersionUnknown) {
+			t.Fatalf("got error %v, want %v", err, ErrVersionUnknown)
+		}
+	}
+	if len(runner.calls) != 2 {
+		t.Fatalf("version probed with %d commands, want 2", len(runner.calls))
+	}
+}
@@ -0,0 +1,125 @@
+package zfs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+const blockSizeHistogramHeader = `Block Size Histogram`
+
+// BlockSizeBucket holds the block counts and sizes for a single block size from the pool block size histogram
+type BlockSizeBucket struct {
+	// Size of the blocks in bytes
+	Size uint64
+	// PhysicalCount is the number of blocks with this physical (on-disk, compressed) size
+	PhysicalCount uint64
+	// PhysicalBytes is the total physical size of blocks with this physical size
+	PhysicalBytes uint64
+	// LogicalCount is the number of blocks with this logical (uncompressed) size
+	LogicalCount uint64
+	// LogicalBytes is the total logical size of blocks with this logical size
+	LogicalBytes uint64
+	// AllocatedCount is the number of blocks with this allocated (including padding and parity) size
+	AllocatedCount uint64
+	// AllocatedBytes is the total allocated size of blocks with this allocated size
+	AllocatedBytes uint64
+}
+
+// BlockSizeHistogram traverses the pool via `zdb -bbP` to build a histogram of block sizes. This reads all metadata in
+// the pool, and may take a very long time and generate significant I/O on large pools.
+func (p poolImpl) BlockSizeHistogram() ([]BlockSizeBucket, error) {
+	h := &blockSizeHistogramHandler{}
+	if err := executeLines(context.Background(), p.runner, h.processLine, `zdb`, `-bbP`, p.name); err != nil {
+		return nil, err
+	}
+	return h.buckets, nil
+}
+
+// blockSizeHistogramHandler extracts the block size histogram section from `zdb -bb` output.
+type blockSizeHistogramHandler struct {
+	inHistogram bool
+	done        bool
+	buckets     []BlockSizeBucket
+}
+
+func (h *blockSizeHistogramHandler) processLine(line string) error {
+	if h.done {
+		return nil
+	}
+	if strings.TrimSpace(line) == blockSizeHistogramHeader {
+		h.inHistogram = true
+		return nil
+	}
+	if !h.inHistogram {
+		return nil
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		// The histogram is terminated by the first blank line following the rows.
+		h.done = len(h.buckets) > 0
+		return nil
+	}
+	if !strings.HasSuffix(fields[0], `:`) {
+		// Title rows.
+		return nil
+	}
+	if len(fields) != 10 {
+		return ErrInvalidOutput
+	}
+
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		v, err := parseSize(strings.TrimSuffix(field, `:`))
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+	// Fields are: size, followed by count, size and cumulative size for each of psize, lsize and asize.
+	h.buckets = append(h.buckets, BlockSizeBucket{
+		Size:           values[0],
+		PhysicalCount:  values[1],
+		PhysicalBytes:  values[2],
+		LogicalCount:   values[4],
+		LogicalBytes:   values[5],
+		AllocatedCount: values[7],
+		AllocatedBytes: values[8],
+	})
+
+	return nil
+}
+
+// parseSize parses a size that may carry a binary unit suffix (ie - `1K`), as produced by zdb and zpool.
+func parseSize(value string) (uint64, error) {
+	multiplier := uint64(1)
+	if len(value) > 0 {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		case 'P':
+			multiplier = 1 << 50
+		case 'E':
+			multiplier = 1 << 60
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	if multiplier == 1 {
+		return strconv.ParseUint(value, 10, 64)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(v * float64(multiplier)), nil
+}
@@ -0,0 +1,80 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+const fixtureZdbBlocks = `
+Traversing all blocks to verify nothing leaked ...
+
+loading concrete vdev 0, metaslab 115 of 116 ...
+	No leaks (block sum matches space maps exactly)
+
+	bp count:                  7104
+	ganged count:                 0
+	bp logical:           623595520      avg:  87780
+	bp physical:          588873728      avg:  82893     compression:   1.06
+	bp allocated:         589352960      avg:  82961     compression:   1.06
+	bp deduped:                   0    ref>1:      0   deduplication:   1.00
+	Normal class:         589389824     used:  1.40%
+
+Block Size Histogram
+
+block	psize			lsize			asize
+size	Count	Size	Cum.	Count	Size	Cum.	Count	Size	Cum.
+512:	3584	1835008	1835008	3584	1835008	1835008	0	0	0
+1024:	120	122880	1957888	96	98304	1933312	0	0	0
+4096:	1024	4194304	6152192	1000	4096000	6029312	4708	19283968	19283968
+131072:	2376	311427072	317579264	2424	317718528	323747840	2396	314048512	333332480
+
+Blocks	LSIZE	PSIZE	ASIZE	  avg	 comp	%Total	Type
+`
+
+func TestBlockSizeHistogram(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zdb -bbP testpool`: fixtureZdbBlocks,
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	buckets, err := client.Pool(`testpool`).BlockSizeHistogram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BlockSizeBucket{
+		{Size: 512, PhysicalCount: 3584, PhysicalBytes: 1835008, LogicalCount: 3584, LogicalBytes: 1835008},
+		{Size: 1024, PhysicalCount: 120, PhysicalBytes: 122880, LogicalCount: 96, LogicalBytes: 98304},
+		{Size: 4096, PhysicalCount: 1024, PhysicalBytes: 4194304, LogicalCount: 1000, LogicalBytes: 4096000, AllocatedCount: 4708, AllocatedBytes: 19283968},
+		{Size: 131072, PhysicalCount: 2376, PhysicalBytes: 311427072, LogicalCount: 2424, LogicalBytes: 317718528, AllocatedCount: 2396, AllocatedBytes: 314048512},
+	}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Fatalf("got buckets %+v, want %+v", buckets, want)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  uint64
+	}{
+		{value: `512`, want: 512},
+		{value: `1K`, want: 1024},
+		{value: `1.50K`, want: 1536},
+		{value: `16M`, want: 16 << 20},
+		{value: `2G`, want: 2 << 30},
+		{value: `1T`, want: 1 << 40},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseSize(tc.value)
+		if err != nil {
+			t.Errorf("parseSize(%q) unexpected error: %v", tc.value, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tc.value, got, tc.want)
+		}
+	}
+}
@@ -1,28 +1,73 @@
 package zfs
 
 import (
+	"bufio"
+	"context"
 	"encoding/csv"
 	"errors"
+	"fmt"
 	"io"
-	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultDelimiter separates fields in output produced with the -H flag.
+	defaultDelimiter = '\t'
+	// defaultFieldsPerRecord is the number of fields in name,property,value output.
+	defaultFieldsPerRecord = 3
+	// defaultRetryBackoff is the delay before the first retry of a failed command, doubling for each subsequent retry.
+	defaultRetryBackoff = 100 * time.Millisecond
+	// versionRetryInterval is the time for which a failure to probe the installed version is cached before the probe
+	// is retried, so that a persistent failure does not run the version commands for every pool.
+	versionRetryInterval = time.Minute
 )
 
 var (
 	// ErrInvalidOutput is returned on unparseable CLI output
 	ErrInvalidOutput = errors.New(`Invalid output executing command`)
+	// ErrPoolNotFound is returned when a command fails because the pool does not exist, ie - it was exported or
+	// destroyed after the pools were listed
+	ErrPoolNotFound = errors.New(`no such pool`)
+	// ErrDatasetNotFound is returned when a command fails because a dataset other than the root dataset of the pool does
+	// not exist, ie - it was destroyed by snapshot rotation while the command was running
+	ErrDatasetNotFound = errors.New(`dataset does not exist`)
+	// ErrVersionUnknown is returned by Version when the installed tools predate the `version` subcommands, introduced in
+	// ZFS 0.8
+	ErrVersionUnknown = errors.New(`ZFS version unknown, version subcommands are unsupported prior to 0.8`)
+
+	// DefaultRetryableErrors are the error messages of commands that fail transiently, ie - while a pool is being
+	// imported or a device is busy
+	DefaultRetryableErrors = []string{`pool is busy`, `resource busy`, `temporarily unavailable`}
 )
 
 // Client is the primary entrypoint
 type Client interface {
+	Version() (Version, error)
 	PoolNames() ([]string, error)
+	PoolList(props ...string) (map[string]PoolProperties, error)
 	Pool(name string) Pool
 	Datasets(pool string, kind DatasetKind) Datasets
+	DatasetNames(kinds ...DatasetKind) ([]string, error)
+	Space(dataset string, kind SpaceKind) ([]SpaceUsage, error)
+	UnhealthyPools() ([]string, error)
 }
 
 // Pool allows querying pool properties
 type Pool interface {
 	Name() string
 	Properties(props ...string) (PoolProperties, error)
+	RootProperties(props ...string) (PoolProperties, error)
+	Health() (PoolStatus, error)
+	BlockSizeHistogram() ([]BlockSizeBucket, error)
+	Events() ([]Event, error)
+	Features() (map[string]string, error)
+	IOStat() (PoolProperties, error)
+	IOQueues() ([]IOQueue, error)
+	Status(options ...StatusOption) (*Status, error)
+	Vdevs() ([]VdevProperties, error)
 }
 
 // PoolProperties provides access to the properties for a pool
@@ -43,59 +88,266 @@ type DatasetProperties interface {
 	Properties() map[string]string
 }
 
+// CommandRunner executes commands on behalf of the client
+type CommandRunner interface {
+	// Run starts the named command, returning its output as it is produced. Closing the output waits for the command
+	// to exit, and returns any error from the command. Closing the output before it has been fully read may terminate
+	// the command. The command is killed if the context is done before it exits.
+	Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error)
+}
+
 type handler interface {
 	processLine(pool string, line []string) error
 }
 
+// Config configures a ZFS Client
+type Config struct {
+	// Runner executes commands, defaults to running commands on the local host
+	Runner CommandRunner
+	// ZpoolPath is the path to the zpool command, defaults to resolving zpool via PATH
+	ZpoolPath string
+	// ZFSPath is the path to the zfs command, defaults to resolving zfs via PATH
+	ZFSPath string
+	// Delimiter separates the fields of command output, defaults to tab as produced by the -H flag. Only required when
+	// the zfs/zpool commands are wrapped by scripts that reformat their output
+	Delimiter rune
+	// IOStatInterval is the interval over which pool I/O statistics are sampled, zero reports averages since boot
+	IOStatInterval time.Duration
+	// MaxConcurrency bounds the number of commands executing concurrently across all pools and collectors, zero is
+	// unbounded
+	MaxConcurrency int
+	// CommandRetries is the number of times a command failing with a retryable error is retried, zero disables retries.
+	// Output is buffered in memory until each command exits when retries are enabled
+	CommandRetries int
+	// RetryableErrors are the error messages of commands that are retried, defaults to DefaultRetryableErrors
+	RetryableErrors []string
+	// Context is the parent of every command, which are killed once it is done (ie - on shutdown), defaults to none
+	Context context.Context
+	// Location is the time zone of the host executing the commands, in which the times they report are parsed,
+	// defaults to the local time zone
+	Location *time.Location
+}
+
 type clientImpl struct {
+	runner         CommandRunner
+	delimiter      rune
+	iostatInterval time.Duration
+	location       *time.Location
+	version        *versionCache
+}
+
+// versionCache holds the result of probing the installed version. Definitive results are cached for the life of the
+// client, while other failures are cached until retryAt, so that a transient failure is retried.
+type versionCache struct {
+	sync.Mutex
+	now     func() time.Time
+	probed  bool
+	retryAt time.Time
+	version Version
+	err     error
+}
+
+// Version returns the installed ZFS version, which is queried on first use and cached for the life of the client.
+// Returns ErrVersionUnknown if the installed tools predate the `version` subcommands.
+func (z clientImpl) Version() (Version, error) {
+	z.version.Lock()
+	defer z.version.Unlock()
+	if z.version.probed && (z.version.retryAt.IsZero() || z.version.now().Before(z.version.retryAt)) {
+		return z.version.version, z.version.err
+	}
+	v, err := probeVersion(context.Background(), z.runner)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// The probe was killed with the scrape, which says nothing of the host.
+		return v, err
+	}
+	z.version.probed, z.version.version, z.version.err, z.version.retryAt = true, v, err, time.Time{}
+	if err != nil && !errors.Is(err, ErrVersionUnknown) {
+		z.version.retryAt = z.version.now().Add(versionRetryInterval)
+	}
+	return v, err
+}
+
+// WithContext returns a client sharing the configuration of client, whose commands are killed once ctx is done. Clients
+// not created by New are returned unchanged.
+func WithContext(ctx context.Context, client Client) Client {
+	z, ok := client.(clientImpl)
+	if !ok {
+		return client
+	}
+	z.runner = NewContextRunner(ctx, z.runner)
+	return z
 }
 
 func (z clientImpl) PoolNames() ([]string, error) {
-	return poolNames()
+	return poolNames(context.Background(), z.runner)
+}
+
+// PoolList returns the properties of every pool, keyed by pool name, fetched with a single command. Properties must be
+// among PoolListProperties.
+func (z clientImpl) PoolList(props ...string) (map[string]PoolProperties, error) {
+	return poolList(context.Background(), z.runner, z.delimiter, props)
 }
 
 func (z clientImpl) Pool(name string) Pool {
-	return newPoolImpl(name)
+	return newPoolImpl(z.runner, z.delimiter, name, z.supportsJSON(), z.iostatInterval, z.location)
+}
+
+// supportsJSON determines whether JSON output is available from the installed version.
+func (z clientImpl) supportsJSON() bool {
+	v, err := z.Version()
+	return err == nil && v.AtLeast(jsonMajor, jsonMinor, 0)
 }
 
 func (z clientImpl) Datasets(pool string, kind DatasetKind) Datasets {
-	return newDatasetsImpl(pool, kind)
+	return newDatasetsImpl(z.runner, z.delimiter, pool, kind)
+}
+
+func execute(ctx context.Context, runner CommandRunner, delimiter rune, pool string, h handler, cmd string, args ...string) error {
+	return executeFields(ctx, runner, delimiter, pool, h, defaultFieldsPerRecord, cmd, append(args, pool)...)
+}
+
+// executeFields runs a command with delimited output of the given number of fields per record, passing each record to
+// h. Unlike execute, the pool is not appended to args, so it may be positioned by the caller.
+func executeFields(ctx context.Context, runner CommandRunner, delimiter rune, pool string, h handler, fields int, cmd string, args ...string) error {
+	err := classifyError(pool, executeCommand(ctx, runner, delimiter, pool, h, fields, cmd, args...))
+	instrument(commandName(cmd, args), err)
+	return err
+}
+
+const (
+	// poolNotFoundMessage is the error output of zpool when the pool does not exist.
+	poolNotFoundMessage = `no such pool`
+	// datasetNotFoundMessage is the error output of zfs when a dataset, including the root dataset of a pool, does not
+	// exist.
+	datasetNotFoundMessage = `dataset does not exist`
+)
+
+// cannotOpenPattern matches the name of each pool or dataset that could not be opened in the error output of zfs.
+var cannotOpenPattern = regexp.MustCompile(`cannot open '([^']+)'`)
+
+// classifyError wraps command errors caused by a missing pool with ErrPoolNotFound, and by other missing datasets with
+// ErrDatasetNotFound, identified by the error output of the command. zfs reports a missing pool as its root dataset not
+// existing, so the missing names are compared with the pool, which is empty for commands not scoped to a pool.
+func classifyError(pool string, err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if strings.Contains(msg, poolNotFoundMessage) {
+		return fmt.Errorf("%w: %w", ErrPoolNotFound, err)
+	}
+	if !strings.Contains(msg, datasetNotFoundMessage) {
+		return err
+	}
+	for _, name := range missingDatasets(err) {
+		if name == pool {
+			return fmt.Errorf("%w: %w", ErrPoolNotFound, err)
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrDatasetNotFound, err)
 }
 
-func execute(pool string, h handler, cmd string, args ...string) error {
-	c := exec.Command(cmd, append(args, pool)...)
-	out, err := c.StdoutPipe()
+// missingDatasets returns the names of the datasets that could not be opened, from the error output of a command.
+func missingDatasets(err error) []string {
+	matches := cannotOpenPattern.FindAllStringSubmatch(err.Error(), -1)
+	names := make([]string, len(matches))
+	for i, match := range matches {
+		names[i] = match[1]
+	}
+	return names
+}
+
+// executeCommand parses the output of a command as it is read, so that memory use is bounded by the handler rather
+// than the size of the output.
+func executeCommand(ctx context.Context, runner CommandRunner, delimiter rune, pool string, h handler, fields int, cmd string, args ...string) error {
+	out, err := runner.Run(ctx, cmd, args...)
 	if err != nil {
 		return err
 	}
 
 	r := csv.NewReader(out)
-	r.Comma = '\t'
+	r.Comma = delimiter
 	r.LazyQuotes = true
 	r.ReuseRecord = true
-	r.FieldsPerRecord = 3
-
-	if err = c.Start(); err != nil {
-		return err
-	}
+	r.FieldsPerRecord = fields
 
 	for {
 		line, err := r.Read()
 		if err == io.EOF {
 			break
 		}
+		if err == nil {
+			err = h.processLine(pool, line)
+		}
 		if err != nil {
+			_ = out.Close()
 			return err
 		}
-		if err = h.processLine(pool, line); err != nil {
-			return err
+	}
+
+	return out.Close()
+}
+
+// executeLines runs a command, passing each line of output to fn.
+func executeLines(ctx context.Context, runner CommandRunner, fn func(line string) error, cmd string, args ...string) error {
+	out, err := runner.Run(ctx, cmd, args...)
+	if err == nil {
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			if err = fn(scanner.Text()); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = scanner.Err()
+		}
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
 		}
 	}
+	err = classifyError(``, err)
+	instrument(commandName(cmd, args), err)
 
-	return c.Wait()
+	return err
 }
 
 // New instantiates a ZFS Client
-func New() Client {
-	return clientImpl{}
+func New(config Config) Client {
+	if config.Runner == nil {
+		config.Runner = NewExecRunner(nil)
+	}
+	paths := make(map[string]string)
+	if config.ZpoolPath != `` && config.ZpoolPath != `zpool` {
+		paths[`zpool`] = config.ZpoolPath
+	}
+	if config.ZFSPath != `` && config.ZFSPath != `zfs` {
+		paths[`zfs`] = config.ZFSPath
+	}
+	if len(paths) > 0 {
+		config.Runner = pathRunner{paths: paths, runner: config.Runner}
+	}
+	// Wraps the paths, so that commands are labeled by name rather than path, but not the limit, so that time spent
+	// waiting for a slot is excluded.
+	config.Runner = timedRunner{runner: config.Runner}
+	if config.MaxConcurrency > 0 {
+		config.Runner = NewLimitRunner(config.MaxConcurrency, config.Runner)
+	}
+	if config.CommandRetries > 0 {
+		if len(config.RetryableErrors) == 0 {
+			config.RetryableErrors = DefaultRetryableErrors
+		}
+		// Wraps the limit, so that slots are not held while waiting to retry.
+		config.Runner = NewRetryRunner(config.CommandRetries, defaultRetryBackoff, config.RetryableErrors, config.Runner)
+	}
+	if config.Context != nil {
+		// Wraps the limit and retries, so that commands waiting for a slot or to retry are also interrupted.
+		config.Runner = NewContextRunner(config.Context, config.Runner)
+	}
+	if config.Delimiter == 0 {
+		config.Delimiter = defaultDelimiter
+	}
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+	return clientImpl{runner: config.Runner, delimiter: config.Delimiter, iostatInterval: config.IOStatInterval, location: config.Location, version: &versionCache{now: time.Now}}
 }
@@ -0,0 +1,599 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	fixtureZpoolList = "testpool1\ntestpool2\n"
+	fixtureZpoolGet  = "testpool1\tallocated\t1024\n" +
+		"testpool1\thealth\tONLINE\n" +
+		"testpool1\tfragmentation\t5\n"
+	fixtureZfsGet = "testpool1\tused\t4096\n" +
+		"testpool1\tavailable\t2048\n" +
+		"testpool1/data\tused\t1024\n" +
+		"testpool1/data\tavailable\t2048\n"
+)
+
+// fakeRunner returns canned output for commands, keyed by the full command line.
+type fakeRunner struct {
+	output map[string]string
+	errors map[string]error
+	calls  [][]string
+	mu     sync.Mutex
+}
+
+// Run implements the CommandRunner interface
+func (r *fakeRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	argv := append([]string{name}, args...)
+	r.mu.Lock()
+	r.calls = append(r.calls, argv)
+	r.mu.Unlock()
+	key := strings.Join(argv, ` `)
+	return fakeOutput{Reader: strings.NewReader(r.output[key]), err: r.errors[key]}, nil
+}
+
+type fakeOutput struct {
+	io.Reader
+	err error
+}
+
+func (o fakeOutput) Close() error {
+	return o.err
+}
+
+func TestPoolNames(t *testing.T) {
+	testCases := []struct {
+		name    string
+		output  string
+		err     error
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   `pools`,
+			output: fixtureZpoolList,
+			want:   []string{`testpool1`, `testpool2`},
+		},
+		{
+			name:   `no pools`,
+			output: ``,
+			want:   []string{},
+		},
+		{
+			name:    `error`,
+			err:     errors.New(`exit status 1`),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{
+				output: map[string]string{`zpool list -Ho name`: tc.output},
+				errors: map[string]error{`zpool list -Ho name`: tc.err},
+			}
+			client := New(Config{Runner: runner})
+
+			pools, err := client.PoolNames()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal(`expected error, got nil`)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pools, tc.want) {
+				t.Fatalf("got pools %q, want %q", pools, tc.want)
+			}
+		})
+	}
+}
+
+func TestPoolProperties(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zpool get -Hpo name,property,value allocated,health,fragmentation testpool1`: fixtureZpoolGet,
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	props, err := client.Pool(`testpool1`).Properties(`allocated`, `health`, `fragmentation`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		`allocated`:     `1024`,
+		`health`:        `ONLINE`,
+		`fragmentation`: `5`,
+	}
+	if !reflect.DeepEqual(props.Properties(), want) {
+		t.Fatalf("got properties %v, want %v", props.Properties(), want)
+	}
+}
+
+func TestPoolList(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zpool list -Hpo name,size,allocated,free,capacity,fragmentation,health`: "testpool1\t4096\t1024\t3072\t25\t5\tONLINE\n" +
+				"testpool2\t8192\t8192\t0\t100\t-\tDEGRADED\n",
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	pools, err := client.PoolList(`size`, `allocated`, `free`, `capacity`, `fragmentation`, `health`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]map[string]string, len(pools))
+	for name, pool := range pools {
+		got[name] = pool.Properties()
+	}
+	want := map[string]map[string]string{
+		`testpool1`: {
+			`size`:          `4096`,
+			`allocated`:     `1024`,
+			`free`:          `3072`,
+			`capacity`:      `25`,
+			`fragmentation`: `5`,
+			`health`:        `ONLINE`,
+		},
+		`testpool2`: {
+			`size`:          `8192`,
+			`allocated`:     `8192`,
+			`free`:          `0`,
+			`capacity`:      `100`,
+			`fragmentation`: `-`,
+			`health`:        `DEGRADED`,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got pools %v, want %v", got, want)
+	}
+}
+
+func TestPoolListInvalidOutput(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zpool list -Hpo name,size,health`: "testpool1\t4096\n",
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	if _, err := client.PoolList(`size`, `health`); err == nil {
+		t.Fatal(`expected error for missing columns`)
+	}
+}
+
+func TestPoolRootProperties(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zfs get -Hpo name,property,value available testpool1`: "testpool1\tavailable\t6291456\n",
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	props, err := client.Pool(`testpool1`).RootProperties(`available`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{`available`: `6291456`}
+	if !reflect.DeepEqual(props.Properties(), want) {
+		t.Fatalf("got properties %v, want %v", props.Properties(), want)
+	}
+}
+
+func TestPoolPropertiesDelimiter(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zpool get -Hpo name,property,value allocated,health,fragmentation testpool1`: strings.ReplaceAll(fixtureZpoolGet, "\t", `,`),
+		},
+	}
+
+	props, err := New(Config{Runner: runner, Delimiter: ','}).Pool(`testpool1`).Properties(`allocated`, `health`, `fragmentation`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		`allocated`:     `1024`,
+		`health`:        `ONLINE`,
+		`fragmentation`: `5`,
+	}
+	if !reflect.DeepEqual(props.Properties(), want) {
+		t.Fatalf("got properties %v, want %v", props.Properties(), want)
+	}
+
+	// Output that does not match the configured delimiter cannot satisfy the expected number of fields.
+	if _, err = New(Config{Runner: runner, Delimiter: ';'}).Pool(`testpool1`).Properties(`allocated`, `health`, `fragmentation`); err == nil {
+		t.Fatal(`expected error for mismatched delimiter`)
+	}
+}
+
+func TestPoolFeatures(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   map[string]string
+	}{
+		{
+			name: `feature flags`,
+			output: "testpool\tsize\t2048\n" +
+				"testpool\tversion\t-\n" +
+				"testpool\tfeature@async_destroy\tenabled\n" +
+				"testpool\tfeature@lz4_compress\tactive\n" +
+				"testpool\tfeature@draid\tdisabled\n",
+			want: map[string]string{
+				`async_destroy`: FeatureEnabled,
+				`lz4_compress`:  FeatureActive,
+				`draid`:         FeatureDisabled,
+			},
+		},
+		{
+			name: `legacy version`,
+			output: "testpool\tsize\t2048\n" +
+				"testpool\tversion\t28\n",
+			want: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runner := &fakeRunner{
+				output: map[string]string{
+					`zpool get -Hpo name,property,value all testpool`: tc.output,
+				},
+			}
+
+			features, err := New(Config{Runner: runner}).Pool(`testpool`).Features()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(features, tc.want) {
+				t.Fatalf("got features %v, want %v", features, tc.want)
+			}
+		})
+	}
+}
+
+func TestPoolHealth(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zpool list -Ho health testpool1`: "DEGRADED\n",
+		},
+	}
+
+	health, err := New(Config{Runner: runner}).Pool(`testpool1`).Health()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health != PoolDegraded {
+		t.Fatalf("got health %q, want %q", health, PoolDegraded)
+	}
+}
+
+func TestPoolPropertiesInvalidOutput(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zpool get -Hpo name,property,value health otherpool`: fixtureZpoolGet,
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	if _, err := client.Pool(`otherpool`).Properties(`health`); err != ErrInvalidOutput {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidOutput)
+	}
+}
+
+func TestPoolPropertiesNotFound(t *testing.T) {
+	runner := &fakeRunner{
+		errors: map[string]error{
+			`zpool get -Hpo name,property,value health gonepool`: errors.New(`exit status 1: cannot open 'gonepool': no such pool`),
+			`zpool get -Hpo name,property,value health badpool`:  errors.New(`exit status 1: internal error`),
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	if _, err := client.Pool(`gonepool`).Properties(`health`); !errors.Is(err, ErrPoolNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrPoolNotFound)
+	}
+	if _, err := client.Pool(`badpool`).Properties(`health`); err == nil || errors.Is(err, ErrPoolNotFound) {
+		t.Fatalf("got error %v, want error other than %v", err, ErrPoolNotFound)
+	}
+}
+
+func TestDatasetPropertiesNotFound(t *testing.T) {
+	runner := &fakeRunner{
+		errors: map[string]error{
+			`zfs get -Hprt filesystem -o name,property,value used gonepool`: errors.New(`exit status 1: cannot open 'gonepool': dataset does not exist`),
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	if _, err := client.Datasets(`gonepool`, DatasetFilesystem).Properties(`used`); !errors.Is(err, ErrPoolNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrPoolNotFound)
+	}
+}
+
+func TestDatasetPropertiesChildDestroyed(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zfs get -Hprt snapshot -o name,property,value used testpool`: "testpool@daily\tused\t1024\n" +
+				"testpool/home@daily\tused\t2048\n" +
+				"testpool/home@hourly\tused\t512\n",
+		},
+		errors: map[string]error{
+			`zfs get -Hprt snapshot -o name,property,value used testpool`: errors.New(`exit status 1: cannot open 'testpool/home@hourly': dataset does not exist`),
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	results, err := client.Datasets(`testpool`, DatasetSnapshot).Properties(`used`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(results))
+	for i, result := range results {
+		names[i] = result.DatasetName()
+	}
+	sort.Strings(names)
+	if want := []string{`testpool/home@daily`, `testpool@daily`}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("got datasets %v, want %v", names, want)
+	}
+}
+
+func TestDatasetProperties(t *testing.T) {
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zfs get -Hprt filesystem -o name,property,value used,available testpool1`: fixtureZfsGet,
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	datasets, err := client.Datasets(`testpool1`, DatasetFilesystem).Properties(`used`, `available`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]map[string]string, len(datasets))
+	for _, dataset := range datasets {
+		got[dataset.DatasetName()] = dataset.Properties()
+	}
+	want := map[string]map[string]string{
+		`testpool1`: {
+			`used`:      `4096`,
+			`available`: `2048`,
+		},
+		`testpool1/data`: {
+			`used`:      `1024`,
+			`available`: `2048`,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got datasets %v, want %v", got, want)
+	}
+}
+
+func TestDatasetNames(t *testing.T) {
+	const fixture = "testpool1\n" +
+		"testpool1@daily\n" +
+		"testpool1/data\n" +
+		"testpool1/vol0\n" +
+		"testpool2\n"
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zfs list -Hp -t filesystem,volume,snapshot -o name`: fixture,
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	got, err := client.DatasetNames(DatasetFilesystem, DatasetVolume, DatasetSnapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`testpool1`, `testpool1@daily`, `testpool1/data`, `testpool1/vol0`, `testpool2`}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got names %q, want %q", got, want)
+	}
+}
+
+func TestDatasetPropertiesRecursive(t *testing.T) {
+	// Recursive output for nested datasets, with the properties of each dataset not necessarily adjacent.
+	const fixture = "testpool1\tused\t8192\n" +
+		"testpool1/data\tused\t4096\n" +
+		"testpool1/data/nested\tused\t1024\n" +
+		"testpool1/data.old\tused\t512\n" +
+		"testpool1\tcompressratio\t1.00x\n" +
+		"testpool1/data\tcompressratio\t1.50x\n" +
+		"testpool1/data/nested\tcompressratio\t2.00x\n" +
+		"testpool1/data.old\tcompressratio\t1.00x\n"
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zfs get -Hprt filesystem -o name,property,value used,compressratio testpool1`: fixture,
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	datasets, err := client.Datasets(`testpool1`, DatasetFilesystem).Properties(`used`, `compressratio`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]map[string]string, len(datasets))
+	for _, dataset := range datasets {
+		got[dataset.DatasetName()] = dataset.Properties()
+	}
+	want := map[string]map[string]string{
+		`testpool1`:             {`used`: `8192`, `compressratio`: `1.00x`},
+		`testpool1/data`:        {`used`: `4096`, `compressratio`: `1.50x`},
+		`testpool1/data/nested`: {`used`: `1024`, `compressratio`: `2.00x`},
+		`testpool1/data.old`:    {`used`: `512`, `compressratio`: `1.00x`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got datasets %v, want %v", got, want)
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("got %d commands %v, want 1", len(runner.calls), runner.calls)
+	}
+}
+
+func TestDatasetPropertiesOtherPool(t *testing.T) {
+	// Output for a pool sharing a name prefix with the requested pool is invalid.
+	runner := &fakeRunner{
+		output: map[string]string{
+			`zfs get -Hprt filesystem -o name,property,value used testpool1`: "testpool1\tused\t4096\ntestpool10/data\tused\t1024\n",
+		},
+	}
+	client := New(Config{Runner: runner})
+
+	if _, err := client.Datasets(`testpool1`, DatasetFilesystem).Properties(`used`); err != ErrInvalidOutput {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidOutput)
+	}
+}
+
+// generatedOutput lazily produces `zfs get` output for a number of datasets, recording how much has been read.
+type generatedOutput struct {
+	pool     string
+	datasets int
+	invalid  int
+	next     int
+	buf      []byte
+	read     int
+	closed   bool
+}
+
+func (o *generatedOutput) Read(p []byte) (int, error) {
+	for len(o.buf) == 0 {
+		if o.next >= o.datasets {
+			return 0, io.EOF
+		}
+		name := fmt.Sprintf("%s/dataset%d", o.pool, o.next)
+		if o.next == o.invalid {
+			name = fmt.Sprintf("otherpool/dataset%d", o.next)
+		}
+		o.buf = append(o.buf, fmt.Sprintf("%s\tused\t%d\n%s\tavailable\t2048\n", name, o.next, name)...)
+		o.next++
+	}
+	n := copy(p, o.buf)
+	o.buf = o.buf[n:]
+	o.read += n
+	return n, nil
+}
+
+func (o *generatedOutput) Close() error {
+	o.closed = true
+	return nil
+}
+
+// generatedRunner returns generated output for every command.
+type generatedRunner struct {
+	output *generatedOutput
+}
+
+// Run implements the CommandRunner interface
+func (r generatedRunner) Run(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	return r.output, nil
+}
+
+func TestDatasetPropertiesLargeOutput(t *testing.T) {
+	const datasets = 50000
+	output := &generatedOutput{pool: `testpool1`, datasets: datasets, invalid: -1}
+	client := New(Config{Runner: generatedRunner{output: output}})
+
+	result, err := client.Datasets(`testpool1`, DatasetFilesystem).Properties(`used`, `available`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != datasets {
+		t.Fatalf("got %d datasets, want %d", len(result), datasets)
+	}
+	for _, dataset := range result {
+		if len(dataset.Properties()) != 2 {
+			t.Fatalf("got properties %v for dataset %s, want used and available", dataset.Properties(), dataset.DatasetName())
+		}
+	}
+	if !output.closed {
+		t.Fatal(`output was not closed`)
+	}
+}
+
+func TestDatasetPropertiesInvalidOutputStopsReading(t *testing.T) {
+	output := &generatedOutput{pool: `testpool1`, datasets: 50000, invalid: 10}
+	client := New(Config{Runner: generatedRunner{output: output}})
+
+	if _, err := client.Datasets(`testpool1`, DatasetFilesystem).Properties(`used`, `available`); err != ErrInvalidOutput {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidOutput)
+	}
+	if output.next == output.datasets {
+		t.Fatalf("read all %d datasets, want parsing to stop at invalid output", output.datasets)
+	}
+	if !output.closed {
+		t.Fatal(`output was not closed`)
+	}
+}
+
+func BenchmarkDatasetProperties(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		output := &generatedOutput{pool: `testpool1`, datasets: 10000, invalid: -1}
+		client := New(Config{Runner: generatedRunner{output: output}})
+		if _, err := client.Datasets(`testpool1`, DatasetFilesystem).Properties(`used`, `available`); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDatasetPropertiesFetch compares fetching the properties of every dataset of a pool with a single recursive
+// command, as the client does, against a command per dataset. The fake runner excludes the cost of spawning each
+// process, which dominates the per-dataset fetch in practice.
+func BenchmarkDatasetPropertiesFetch(b *testing.B) {
+	const datasets = 1000
+	names := make([]string, datasets)
+	output := make(map[string]string, datasets+1)
+	var all strings.Builder
+	for i := range names {
+		names[i] = fmt.Sprintf("testpool1/dataset%d", i)
+		out := fmt.Sprintf("%s\tused\t%d\n%s\tavailable\t2048\n", names[i], i, names[i])
+		all.WriteString(out)
+		output[`zfs get -Hp -o name,property,value used,available `+names[i]] = out
+	}
+	output[`zfs get -Hprt filesystem -o name,property,value used,available testpool1`] = all.String()
+
+	b.Run(`recursive`, func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			client := New(Config{Runner: &fakeRunner{output: output}})
+			result, err := client.Datasets(`testpool1`, DatasetFilesystem).Properties(`used`, `available`)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(result) != datasets {
+				b.Fatalf("got %d datasets, want %d", len(result), datasets)
+			}
+		}
+	})
+	b.Run(`per-dataset`, func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			runner := &fakeRunner{output: output}
+			result := make([]DatasetProperties, 0, datasets)
+			for _, name := range names {
+				handler := newDatasetHandler()
+				if err := execute(context.Background(), runner, '\t', name, handler, `zfs`, `get`, `-Hp`, `-o`, `name,property,value`, `used,available`); err != nil {
+					b.Fatal(err)
+				}
+				result = append(result, handler.datasets()...)
+			}
+			if len(result) != datasets {
+				b.Fatalf("got %d datasets, want %d", len(result), datasets)
+			}
+		}
+	})
+}
@@ -1,31 +1,68 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/pdf/zfs_exporter/v2/collector"
 	"github.com/pdf/zfs_exporter/v2/zfs"
 
 	"github.com/alecthomas/kingpin/v2"
+	"github.com/coreos/go-systemd/v22/daemon"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/promlog"
 	"github.com/prometheus/common/promlog/flag"
 	"github.com/prometheus/common/version"
-	"github.com/prometheus/exporter-toolkit/web"
 	"github.com/prometheus/exporter-toolkit/web/kingpinflag"
 )
 
+const (
+	// collectorsPath is the path under which the available collectors and properties are listed.
+	collectorsPath = "/collectors"
+	// healthyPath is the path of the liveness probe, which succeeds while the exporter is running.
+	healthyPath = "/-/healthy"
+	// readyPath is the path of the readiness probe, which succeeds while pools can be listed.
+	readyPath = "/-/ready"
+)
+
 func main() {
 	var (
-		metricsPath             = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
+		listenSocket            = kingpin.Flag("web.listen-socket", "Path of a unix domain socket on which to expose metrics and web interface, in addition to the listen addresses or systemd socket.").String()
+		metricsPath             = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics, relative to the route prefix.").Default("/metrics").String()
+		routePrefix             = kingpin.Flag("web.route-prefix", "Prefix for the paths of all routes, including the landing page, when served under a subpath (e.g. by a reverse proxy).").Default("/").String()
+		metricsLabels           = kingpin.Flag("web.telemetry-labels", "Constant labels to add to all metrics, as comma-separated name=value pairs (e.g. 'cluster=prod,dc=nyc').").String()
+		metricsLabelRenames     = kingpin.Flag("web.telemetry-label-renames", "Labels to rename on all metrics, as comma-separated old=new pairs (e.g. 'pool=zpool').").String()
+		metricsNameLabels       = kingpin.Flag("web.telemetry-name-labels", "Regular expression matched against the dataset name of each metric, or the pool name of metrics without a dataset, whose named capture groups are added as labels (e.g. '^[^/]+/(?P<env>[^/]+)/(?P<app>[^/]+)').").String()
+		metricsStripPool        = kingpin.Flag("web.telemetry-strip-pool-prefix", "Replace the name label of dataset and snapshot metrics with a dataset label relative to the pool (e.g. 'tank/data' is reported as pool='tank', dataset='data').").Bool()
 		metricsExporterDisabled = kingpin.Flag(`web.disable-exporter-metrics`, `Exclude metrics about the exporter itself (promhttp_*, process_*, go_*).`).Default(`false`).Bool()
 		deadline                = kingpin.Flag("deadline", "Maximum duration that a collection should run before returning cached data. Should be set to a value shorter than your scrape timeout duration. The current collection run will continue and update the cache when complete (default: 8s)").Default("8s").Duration()
+		collectorTimeout        = kingpin.Flag("collector.timeout", "Maximum duration of each collector run, after which the collector is reported as failed and its remaining metrics are discarded, 0 disables the timeout. A collector that exceeded the timeout is skipped until the run completes in the background.").Default("0s").Duration()
 		pools                   = kingpin.Flag("pool", "Name of the pool(s) to collect, repeat for multiple pools (default: all pools).").Strings()
+		includePools            = kingpin.Flag("include-pool", "Only collect pools whose name matches the provided regex (e.g. '^tank$'), may be specified multiple times. Evaluated before --exclude, which filters the datasets of the included pools (default: all pools).").Strings()
 		excludes                = kingpin.Flag("exclude", "Exclude datasets/snapshots/volumes that match the provided regex (e.g. '^rpool/docker/'), may be specified multiple times.").Strings()
+		sshTarget               = kingpin.Flag("zfs.ssh-target", "Execute zfs/zpool commands on the provided remote host via ssh, in [user@]host form. Requires non-interactive (ie - key-based) authentication (default: local host).").String()
+		useSudo                 = kingpin.Flag("zfs.use-sudo", "Execute zfs/zpool commands via sudo, which must be configured to permit them without a password.").Bool()
+		zpoolPath               = kingpin.Flag("zfs.zpool-path", "Path to the zpool command.").Default(zfs.DefaultZpoolPath).String()
+		zfsPath                 = kingpin.Flag("zfs.zfs-path", "Path to the zfs command.").Default(zfs.DefaultZFSPath).String()
+		delimiter               = kingpin.Flag("zfs.delimiter", "Field delimiter of zfs/zpool command output, only required when commands are wrapped by scripts that reformat their output (default: tab).").String()
+		iostatInterval          = kingpin.Flag("zfs.iostat-interval", "Interval over which pool I/O statistics are sampled by the pool-iostat collector, 0 reports averages since boot. Increases the duration of the collection by the interval.").Default("1s").Duration()
+		modulePath              = kingpin.Flag("zfs.module-path", "Path that exists only while the ZFS kernel module is loaded (e.g. '/sys/module/zfs' on Linux, '/dev/zfs' on FreeBSD). When set, collectors are skipped and zfs_module_loaded reports 0 while the path does not exist, rather than failing every scrape. The path is checked on the exporter host, not the --zfs.ssh-target host.").String()
+		maxConcurrency          = kingpin.Flag("zfs.max-concurrency", "Maximum number of zfs/zpool commands executed concurrently across all collectors and pools, 0 is unlimited. All enabled collectors query each pool concurrently, up to this limit.").Default("0").Int()
+		commandRetries          = kingpin.Flag("zfs.command-retries", "Number of times a zfs/zpool command failing with a transient error is retried, with exponential backoff from 100ms, 0 disables retries. Errors caused by missing pools or insufficient permissions are never retried.").Default("0").Int()
+		retryableErrors         = kingpin.Flag("zfs.retryable-error", "Error output of zfs/zpool commands that is retried, repeat for multiple messages (default: 'pool is busy', 'resource busy', 'temporarily unavailable').").Strings()
+		timezone                = kingpin.Flag("zfs.timezone", "Time zone of the host executing zfs/zpool commands, in which the times they report are parsed, as an IANA name (ie - 'UTC' or 'Europe/Berlin'). Required when the --zfs.ssh-target host, or the host that --zfs.fixture-dir output was captured on, has a different time zone to the exporter (default: local time zone).").String()
+		fixtureDir              = kingpin.Flag("zfs.fixture-dir", "Read the output of zfs/zpool commands from files in the provided directory instead of executing them, ie - to reproduce metrics from output captured on another host. Each file is named by the command line it replaces.").String()
+		preset                  = kingpin.Flag("preset", "Collector preset to apply, one of: [default, basic, full]. Collector and property flags that are explicitly set take precedence.").Default(collector.PresetDefault).Enum(collector.PresetNames...)
+		disableDefaults         = kingpin.Flag("collector.disable-defaults", "Set all collectors to disabled by default, only collectors explicitly enabled by their flag are run. Takes precedence over the preset.").Default("false").Bool()
+		durationBuckets         = kingpin.Flag("collector.duration-buckets", "Bucket upper bound in seconds for the zfs_scrape_collector_duration_histogram_seconds histogram, repeat for multiple buckets (default: 0.0005 to 60).").Float64List()
 		toolkitFlags            = kingpinflag.AddFlags(kingpin.CommandLine, ":9134")
 	)
 
@@ -39,13 +76,100 @@ func main() {
 	_ = level.Info(logger).Log("msg", "Starting zfs_exporter", "version", version.Info())
 	_ = level.Info(logger).Log("msg", "Build context", "context", version.BuildContext())
 
+	if err := collector.ApplyPreset(*preset); err != nil {
+		_ = level.Error(logger).Log("msg", "Error applying preset", "preset", *preset, "err", err)
+		os.Exit(1)
+	}
+	if *disableDefaults {
+		collector.DisableDefaultCollectors()
+	}
+	if err := collector.ValidateProperties(); err != nil {
+		_ = level.Error(logger).Log("msg", "Error validating properties", "err", err)
+		os.Exit(1)
+	}
+	if err := collector.ValidatePoolFlags(); err != nil {
+		_ = level.Error(logger).Log("msg", "Error validating pool collector flags", "err", err)
+		os.Exit(1)
+	}
+
+	labels, err := collector.ParseLabels(*metricsLabels)
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "Error parsing telemetry labels", "err", err)
+		os.Exit(1)
+	}
+	labelRenames, err := collector.ParseLabelRenames(*metricsLabelRenames, labels)
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "Error parsing telemetry label renames", "err", err)
+		os.Exit(1)
+	}
+	nameLabels, err := collector.ParseNameLabels(*metricsNameLabels)
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "Error parsing telemetry name labels", "err", err)
+		os.Exit(1)
+	}
+
+	// Cancelled on shutdown, to kill in-flight commands.
+	commandCtx, cancelCommands := context.WithCancel(context.Background())
+	defer cancelCommands()
+	zfsConfig := zfs.Config{
+		Runner:          zfs.NewExecRunner(logger),
+		ZpoolPath:       *zpoolPath,
+		ZFSPath:         *zfsPath,
+		IOStatInterval:  *iostatInterval,
+		MaxConcurrency:  *maxConcurrency,
+		CommandRetries:  *commandRetries,
+		RetryableErrors: *retryableErrors,
+		Context:         commandCtx,
+	}
+	if *delimiter != "" {
+		if utf8.RuneCountInString(*delimiter) != 1 {
+			_ = level.Error(logger).Log("msg", "Delimiter must be a single character", "delimiter", *delimiter)
+			os.Exit(1)
+		}
+		zfsConfig.Delimiter, _ = utf8.DecodeRuneInString(*delimiter)
+	}
+	if *timezone != "" {
+		if zfsConfig.Location, err = time.LoadLocation(*timezone); err != nil {
+			_ = level.Error(logger).Log("msg", "Error loading time zone", "timezone", *timezone, "err", err)
+			os.Exit(1)
+		}
+	}
+	if *fixtureDir != "" {
+		if *sshTarget != "" || *useSudo {
+			_ = level.Error(logger).Log("msg", "Fixture directory can not be combined with ssh or sudo", "dir", *fixtureDir)
+			os.Exit(1)
+		}
+		zfsConfig.Runner = zfs.NewFixtureRunner(*fixtureDir)
+		_ = level.Info(logger).Log("msg", "Reading command output from fixtures", "dir", *fixtureDir)
+	}
+	if *sshTarget != "" {
+		zfsConfig.Runner = zfs.NewSSHRunner(*sshTarget, zfsConfig.Runner)
+		_ = level.Info(logger).Log("msg", "Executing commands via ssh", "target", *sshTarget)
+	}
+
+	if *useSudo {
+		for _, name := range []string{*zpoolPath, *zfsPath} {
+			if err := zfs.CheckSudo(context.Background(), zfsConfig.Runner, name); err != nil {
+				_ = level.Error(logger).Log("msg", "Error checking sudo configuration", "err", err)
+				os.Exit(1)
+			}
+		}
+		zfsConfig.Runner = zfs.NewSudoRunner(zfsConfig.Runner)
+		_ = level.Info(logger).Log("msg", "Executing commands via sudo")
+	}
+
+	zfsClient := zfs.New(zfsConfig)
 	c, err := collector.NewZFS(collector.ZFSConfig{
-		DisableMetrics: *metricsExporterDisabled,
-		Deadline:       *deadline,
-		Pools:          *pools,
-		Excludes:       *excludes,
-		Logger:         logger,
-		ZFSClient:      zfs.New(),
+		DisableMetrics:   *metricsExporterDisabled,
+		Deadline:         *deadline,
+		CollectorTimeout: *collectorTimeout,
+		Pools:            *pools,
+		Includes:         *includePools,
+		Excludes:         *excludes,
+		Logger:           logger,
+		ZFSClient:        zfsClient,
+		ModulePath:       *modulePath,
+		DurationBuckets:  *durationBuckets,
 	})
 	if err != nil {
 		_ = level.Error(logger).Log("msg", "Error creating an exporter", "err", err)
@@ -58,6 +182,9 @@ func main() {
 		prometheus.DefaultGatherer = r
 	}
 	prometheus.MustRegister(c)
+	if !*metricsExporterDisabled {
+		prometheus.MustRegister(zfs.Instrumentation()...)
+	}
 	prometheus.MustRegister(version.NewCollector("zfs_exporter"))
 
 	if len(c.Pools) > 0 {
@@ -74,30 +201,39 @@ func main() {
 	}
 	_ = level.Info(logger).Log("msg", "Enabling collectors", "collectors", strings.Join(collectorNames, ", "))
 
-	http.Handle(*metricsPath, promhttp.Handler())
-	if *metricsPath != "/" {
-		landingConfig := web.LandingConfig{
-			Name:        "ZFS Exporter",
-			Description: "Prometheus ZFS Exporter",
-			Version:     version.Info(),
-			Links: []web.LandingLinks{
-				{
-					Address: *metricsPath,
-					Text:    "Metrics",
-				},
-			},
-		}
-		landingPage, err := web.NewLandingPage(landingConfig)
-		if err != nil {
-			_ = level.Error(logger).Log("err", err)
-			os.Exit(1)
-		}
-		http.Handle("/", landingPage)
+	// Labels are extracted from names before renaming, so that the pattern applies to the original label names.
+	gatherer := collector.NewLabelGatherer(collector.NewDatasetLabelGatherer(collector.NewNameLabelGatherer(prometheus.DefaultGatherer, nameLabels), *metricsStripPool), labelRenames, labels)
+	router, err := newRouter(routeConfig{
+		prefix:      *routePrefix,
+		metricsPath: *metricsPath,
+		metrics: promhttp.InstrumentMetricHandler(
+			prometheus.DefaultRegisterer, collector.NewMetricsHandler(gatherer),
+		),
+		collectors: collector.NewCollectorsHandler(),
+		healthy:    collector.NewHealthyHandler(),
+		ready:      collector.NewReadyHandler(zfsClient),
+	})
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "Error configuring routes", "err", err)
+		os.Exit(1)
 	}
 
-	server := &http.Server{}
-	err = web.ListenAndServe(server, toolkitFlags, logger)
+	listeners, err := listen(toolkitFlags, *listenSocket, logger)
 	if err != nil {
+		_ = level.Error(logger).Log("msg", "Error listening", "err", err)
+		os.Exit(1)
+	}
+	// Notify systemd that startup is complete, for units of Type=notify. This is a no-op when not run by systemd.
+	if notified, err := daemon.SdNotify(false, daemon.SdNotifyReady); err != nil {
+		_ = level.Warn(logger).Log("msg", "Error notifying systemd of readiness", "err", err)
+	} else if notified {
+		_ = level.Debug(logger).Log("msg", "Notified systemd of readiness")
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	server := &http.Server{Handler: router}
+	if err = serve(server, listeners, toolkitFlags, logger, signals, cancelCommands); err != nil {
 		_ = level.Error(logger).Log("msg", "Error starting HTTP server", "err", err)
 		os.Exit(1)
 	}